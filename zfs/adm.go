@@ -38,16 +38,31 @@ import (
 
 var (
 	once sync.Once
-	adm  = &ZFSadm{}
+	adm  = &ZFSadm{runner: NewLocalRunner()}
 )
 
 type ZFSadm struct {
 	zpool string
 	zfs   string
 
+	runner Runner
+
 	//System *System
 }
 
+// New creates ZFSadm by the given options, ZFSadm executes commands by Runner,
+// e.g. manages zfs on remote host:
+//
+//	zfs.New(zfs.WithRunner(zfs.NewRfsRunner(ssh.New(ssh.Host("192.168.2.120:22"), ssh.Auth("root", "123456")))))
+func New(opts ...Option) *ZFSadm {
+	options := newOptions(opts...)
+	return &ZFSadm{
+		zpool:  options.zpool,
+		zfs:    options.zfs,
+		runner: options.runner,
+	}
+}
+
 func Default() (*ZFSadm, error) {
 	/**
 	if runtime.GOOS != "linux" {
@@ -57,10 +72,10 @@ func Default() (*ZFSadm, error) {
 
 	adm.lazy()
 	if len(adm.zfs) == 0 {
-		return nil, fmt.Errorf("could't find command 'zfs', zfs is not installed")
+		return nil, fmt.Errorf("%w: could't find command 'zfs'", ErrNotInstalled)
 	}
 	if len(adm.zpool) == 0 {
-		return nil, fmt.Errorf("could't find command 'zpool', zfs is not installed")
+		return nil, fmt.Errorf("%w: could't find command 'zpool'", ErrNotInstalled)
 	}
 	//go adm.GarbageCollecting()
 	return adm, nil
@@ -107,9 +122,11 @@ func (z *ZFSadm) GetPoolIO(ctx context.Context, name string) (string, string, st
 	//	return "", "", "", "", err
 	//}
 
-	execute := ZPoolCtl(z.zpool).Iostat(ctx, name, "", "", "-Hp", `-n 5 2 | tail -1`)
+	// the first report is the statistics since boot, takes the last one
+	execute := z.RawZPool().Iostat(ctx, name, "", "", "-Hp", "5", "2")
 	data, _ := execute.Exec()
-	line := strings.Split(strings.TrimSuffix(string(data), "\n"), "\t")
+	lines := strings.Split(strings.TrimSuffix(string(data), "\n"), "\n")
+	line := strings.Split(lines[len(lines)-1], "\t")
 	if len(line) > 6 {
 		readIO = line[3]
 		writeIO = line[4]
@@ -121,62 +138,53 @@ func (z *ZFSadm) GetPoolIO(ctx context.Context, name string) (string, string, st
 }
 
 func (z *ZFSadm) ImportPool(ctx context.Context, name string) error {
-	execute := z.RawZPool().Import5(ctx, name, "", nil, "", "", false, false, false, "", false, false, "")
+	execute := z.RawZPool().Import6(ctx, name, "", "", nil, nil, "", "", "")
 	_, err := execute.Exec()
 	if err != nil {
-		return fmt.Errorf("%s: %v", execute.Commit(), err)
+		return err
 	}
 	return nil
 }
 
-func (z *ZFSadm) CreatePool(ctx context.Context, name, compression, raid string, quota float64, devices []string) (*Pool, error) {
-
-	if pool, _ := z.getPool(ctx, name); pool != nil {
-		return nil, fmt.Errorf("pool '%s' is already exists", name)
+// CreateRaidPool creates pool of devices in a single vdev group of raid (e.g. "mirror", "raidz2",
+// "" for stripe), sets the compression of root dataset and the quota by the percentage of pool size.
+//
+// Deprecated: use CreatePool which accepts the whole vdev tree by PoolSpec.
+func (z *ZFSadm) CreateRaidPool(ctx context.Context, name, compression, raid string, quota float64, devices []string) (*Pool, error) {
+	spec := &PoolSpec{Name: name, Data: []VdevSpec{{Type: VdevGroup(raid), Devices: devices}}, Force: true}
+	if len(compression) > 0 {
+		spec.FileSystemProperties = map[string]string{"compression": compression}
 	}
-
-	pool := &Pool{Name: name}
-	execute := ZPoolCtl(z.zpool).Create(ctx, name, "-f", "", "", nil, raid, devices...)
-	_, err := execute.Exec()
+	pool, err := z.CreatePool(ctx, spec)
 	if err != nil {
-		return nil, fmt.Errorf("%s: %v", execute.Commit(), err)
+		return nil, err
 	}
 
-	z.wrapPool(ctx, name, pool)
-
 	poolSize, _ := strconv.ParseFloat(pool.Size, 64)
 	_quota := int64(poolSize * quota / 100)
 	properties := map[string]string{
-		"compression": compression,
-		"quota":       strconv.FormatInt(_quota, 10),
+		"quota": strconv.FormatInt(_quota, 10),
 	}
 
-	execute = ZFSCtl(z.zfs).Set(ctx, name, properties)
+	execute := z.RawZFS().Set(ctx, name, properties)
 	_, err = execute.Exec()
-	if err != nil {
-		return nil, fmt.Errorf("%s: %v", execute.Commit(), err)
-	}
-	return pool, nil
-}
-
-func (z *ZFSadm) ExpensePool(ctx context.Context, name string, devices ...string) (*Pool, error) {
-
-	pool, err := z.GetPool(ctx, name)
 	if err != nil {
 		return nil, err
 	}
 
-	execute := ZPoolCtl(z.zpool).Add(ctx, name, "", devices...)
-	_, err = execute.Exec()
-	if err != nil {
-		return nil, fmt.Errorf("%s: %v", execute.Commit(), err)
-	}
-
 	z.wrapPool(ctx, name, pool)
-
 	return pool, nil
 }
 
+// ExpensePoolDevices adds devices to pool as top-level vdevs each, it fails if the replication
+// level of pool doesn't match.
+//
+// Deprecated: use ExpensePool which accepts the vdev groups by PoolSpec.
+func (z *ZFSadm) ExpensePoolDevices(ctx context.Context, name string, devices ...string) (*Pool, error) {
+	spec := &PoolSpec{Data: []VdevSpec{{Type: GroupStripe, Devices: devices}}}
+	return z.ExpensePool(ctx, name, spec)
+}
+
 func (z *ZFSadm) SetPoolQuota(ctx context.Context, name string, quota float64) (*Pool, error) {
 
 	pool, err := z.getPool(ctx, name)
@@ -189,10 +197,10 @@ func (z *ZFSadm) SetPoolQuota(ctx context.Context, name string, quota float64) (
 	properties := map[string]string{
 		"quota": strconv.FormatInt(_quota, 10),
 	}
-	execute := ZFSCtl(z.zfs).Set(ctx, name, properties)
+	execute := z.RawZFS().Set(ctx, name, properties)
 	_, err = execute.Exec()
 	if err != nil {
-		return nil, fmt.Errorf("%s: %v", execute.Commit(), err)
+		return nil, err
 	}
 
 	z.wrapPool(ctx, name, pool)
@@ -201,11 +209,11 @@ func (z *ZFSadm) SetPoolQuota(ctx context.Context, name string, quota float64) (
 }
 
 func (z *ZFSadm) wrapPool(ctx context.Context, name string, out *Pool) {
-	execute := ZPoolCtl(z.zpool).Get(ctx, name, "-Hp", nil, "all")
+	execute := z.RawZPool().Get(ctx, name, "-Hp", nil, "all")
 	data, _ := execute.Exec()
 	SetValue(data, out)
 
-	execute = ZFSCtl(z.zfs).Get(ctx, name, "-Hp", "", nil, "", "", "all")
+	execute = z.RawZFS().Get(ctx, name, "-Hp", "", nil, "", "", "all")
 	data, _ = execute.Exec()
 	SetValue(data, out)
 }
@@ -213,9 +221,12 @@ func (z *ZFSadm) wrapPool(ctx context.Context, name string, out *Pool) {
 func (z *ZFSadm) GetPools(ctx context.Context) (map[string]*Pool, error) {
 	pools := make(map[string]*Pool, 0)
 	// zpool list -Hp -o name
-	shell := ZPoolCtl(adm.zpool).
+	shell := z.RawZPool().
 		List(ctx, "", "-Hp", []string{"name"}, "")
-	out, _ := shell.Exec()
+	out, err := shell.Exec()
+	if err != nil {
+		return nil, err
+	}
 	if len(out) == 0 {
 		return pools, nil
 	}
@@ -233,11 +244,14 @@ func (z *ZFSadm) GetPools(ctx context.Context) (map[string]*Pool, error) {
 }
 
 func (z *ZFSadm) getPool(ctx context.Context, name string) (*Pool, error) {
-	shell := ZPoolCtl(adm.zpool).
+	shell := z.RawZPool().
 		List(ctx, name, "-Hp", []string{"name"}, "")
-	out, _ := shell.Exec()
+	out, err := shell.Exec()
+	if err != nil {
+		return nil, err
+	}
 	if len(out) == 0 {
-		return nil, fmt.Errorf("pool '%s' is not exists", name)
+		return nil, fmt.Errorf("%w: %s", ErrPoolNotFound, name)
 	}
 	return &Pool{Name: name}, nil
 }
@@ -256,43 +270,28 @@ func (z *ZFSadm) GetPool(ctx context.Context, name string) (*Pool, error) {
 func (z *ZFSadm) DeletePool(ctx context.Context, name string) (*Pool, error) {
 	pool, err := z.getPool(ctx, name)
 	if err != nil {
-		return nil, fmt.Errorf("pool '%s' is not exists: %v", name, err)
+		return nil, err
 	}
 
-	execute := ZPoolCtl(z.zpool).Destroy(ctx, name, false)
+	execute := z.RawZPool().Destroy(ctx, name, false)
 	_, err = execute.Exec()
 	if err != nil {
-		return nil, fmt.Errorf("%s: %v", execute.Commit(), err)
+		return nil, err
 	}
 
 	return pool, nil
 }
 
 func (z *ZFSadm) GetFileSystems(ctx context.Context) (map[string]*Volume, error) {
-	filesystems := make(map[string]*Volume, 0)
-	// 获取所有的 filesystem
-	shell := ZFSCtl(adm.zfs).
-		List(ctx, "", "-Hp", "", []string{"name", "origin"}, "", "", "filesystem")
-	out, _ := shell.Exec()
-	lines := strings.Split(string(out), "\n")
-	for _, line := range lines {
-		parts := strings.Split(line, "\t")
-		name := strings.TrimSpace(parts[0])
+	// 一次 zfs get 获取所有的 filesystem 及其属性
+	filesystems, err := z.ListFileSystems(ctx, ListOptions{})
+	if err != nil {
+		return nil, err
+	}
+	for name := range filesystems {
 		if !strings.Contains(name, "/") {
-			continue
-		}
-		var source string
-		if v := strings.TrimSpace(parts[1]); v != "-" {
-			source = v
+			delete(filesystems, name)
 		}
-		filesystems[name] = &Volume{
-			Name:   name,
-			Source: source,
-		}
-	}
-	// 解析所有的filesystem
-	for name, filesystem := range filesystems {
-		z.wrapFileSystem(ctx, name, filesystem)
 	}
 	return filesystems, nil
 }
@@ -307,7 +306,7 @@ func (z *ZFSadm) GetFileSystem(ctx context.Context, name string) (*Volume, error
 }
 
 func (z *ZFSadm) getFileSystem(ctx context.Context, name string) (*Volume, error) {
-	shell := ZFSCtl(adm.zfs).
+	shell := z.RawZFS().
 		Get(ctx, name, "-Hp", "", []string{"name"}, "", "", "all")
 	_, err := shell.Exec()
 	if err != nil {
@@ -317,7 +316,7 @@ func (z *ZFSadm) getFileSystem(ctx context.Context, name string) (*Volume, error
 }
 
 func (z *ZFSadm) wrapFileSystem(ctx context.Context, name string, out *Volume) {
-	execute := ZFSCtl(z.zfs).Get(ctx, name, "-Hp", "", nil, "", "", "all")
+	execute := z.RawZFS().Get(ctx, name, "-Hp", "", nil, "", "", "all")
 	data, _ := execute.Exec()
 	SetValue(data, out)
 }
@@ -329,19 +328,19 @@ func (z *ZFSadm) CreateFileSystem(ctx context.Context, name string, properties m
 		return nil, err
 	}
 	if fs, _ := z.getFileSystem(ctx, name); fs != nil {
-		return nil, fmt.Errorf("fileSystem '%s' is already exists", name)
+		return nil, fmt.Errorf("%w: %s", ErrDatasetExists, name)
 	}
 
 	fs := &Volume{Name: name}
-	execute := ZFSCtl(z.zfs).CreateFileSystem(ctx, name, nil)
+	execute := z.RawZFS().CreateFileSystem(ctx, name, nil)
 	if _, err := execute.Exec(); err != nil {
-		return nil, fmt.Errorf("%s: %v", execute.Commit(), err)
+		return nil, err
 	}
 
 	if properties != nil {
-		execute = ZFSCtl(z.zfs).Set(ctx, name, properties)
+		execute = z.RawZFS().Set(ctx, name, properties)
 		if _, err := execute.Exec(); err != nil {
-			return nil, fmt.Errorf("%s: %v", execute.Commit(), err)
+			return nil, err
 		}
 	}
 
@@ -350,25 +349,28 @@ func (z *ZFSadm) CreateFileSystem(ctx context.Context, name string, properties m
 	return fs, nil
 }
 
+// ShareFileSystem exports filesystem by NFS to ips with the same mode (rw or ro), the
+// exports are no_root_squash and insecure. Only sharenfs is changed.
+//
+// Deprecated: use SetShare which supports the options per client and SMB.
 func (z *ZFSadm) ShareFileSystem(ctx context.Context, name string, ips []string, mode string) error {
-	// zfs set sharenfs='rw=@192.168.2.0/24,rw=@192.168.221.0/24,all_squash,insecure' tank/test
+	// zfs set sharenfs='rw=@192.168.2.0/24,rw=@192.168.221.0/24,no_root_squash,insecure' tank/test
 	if _, err := z.getFileSystem(ctx, name); err != nil {
 		return err
 	}
 
-	properties := map[string]string{"sync": "disabled"}
-	execute := ZFSCtl(z.zfs).Set(ctx, name, properties)
-	_, _ = execute.Exec()
-
-	args := ""
+	share := &NFSShare{Squash: NoRootSquash, Insecure: true}
 	for _, ip := range ips {
-		args += fmt.Sprintf(`%s=@%s,`, mode, ip)
+		share.Clients = append(share.Clients, NFSClient{Host: "@" + ip, Access: NFSAccess(mode)})
 	}
-	args += "no_root_squash,insecure"
-	properties = map[string]string{"sharenfs": fmt.Sprintf(`'%s'`, args)}
-	execute = ZFSCtl(z.zfs).Set(ctx, name, properties)
+	spec := &ShareSpec{NFS: share}
+	if err := spec.Validate(); err != nil {
+		return err
+	}
+	properties := map[string]string{"sharenfs": spec.NFSValue()}
+	execute := z.RawZFS().Set(ctx, name, properties)
 	if _, err := execute.Exec(); err != nil {
-		return fmt.Errorf("%s: %v", execute.Commit(), err)
+		return err
 	}
 
 	return nil
@@ -381,9 +383,9 @@ func (z *ZFSadm) UnShareFileSystem(ctx context.Context, name string) error {
 	}
 
 	properties := map[string]string{"sharenfs": "off"}
-	execute := ZFSCtl(z.zfs).Set(ctx, name, properties)
+	execute := z.RawZFS().Set(ctx, name, properties)
 	if _, err := execute.Exec(); err != nil {
-		return fmt.Errorf("%s: %v", execute.Commit(), err)
+		return err
 	}
 	return nil
 }
@@ -393,44 +395,25 @@ func (z *ZFSadm) UnShareFileSystem(ctx context.Context, name string) error {
 func (z *ZFSadm) DeleteFileSystem(ctx context.Context, name string) error {
 
 	options := "-rRf"
-	execute := ZFSCtl(z.zfs).DestroyFileSystemOrVolume(ctx, name, options)
+	execute := z.RawZFS().DestroyFileSystemOrVolume(ctx, name, options)
 	if _, err := execute.Exec(); err != nil {
-		return fmt.Errorf("%s: %v", execute.Commit(), err)
+		return err
 	}
 
 	return nil
 }
 
 func (z *ZFSadm) GetVolumes(ctx context.Context) (map[string]*Volume, error) {
-	volumes := make(map[string]*Volume, 0)
-	// 获取所有的 volume
-	shell := ZFSCtl(adm.zfs).
-		List(ctx, "", "-Hp", "", []string{"name", "origin"}, "", "", "volume")
-	out, err := shell.Exec()
+	// 一次 zfs get 获取所有的 volume 及其属性
+	volumes, err := z.ListVolumes(ctx, ListOptions{})
 	if err != nil {
 		return nil, err
 	}
-
-	lines := strings.Split(string(out), "\n")
-	for _, line := range lines {
-		parts := strings.Split(line, "\t")
-		name := strings.TrimSpace(parts[0])
+	for name := range volumes {
 		if !strings.Contains(name, "/") {
-			continue
+			delete(volumes, name)
 		}
-
-		var source string
-		if v := strings.TrimSpace(parts[1]); v != "-" {
-			source = v
-		}
-		volumes[name] = &Volume{Name: name, Source: source}
 	}
-
-	// 解析所有的volume
-	for name, volume := range volumes {
-		z.wrapVolume(ctx, name, volume)
-	}
-
 	return volumes, nil
 }
 
@@ -444,17 +427,17 @@ func (z *ZFSadm) GetVolume(ctx context.Context, name string) (*Volume, error) {
 }
 
 func (z *ZFSadm) getVolume(ctx context.Context, name string) (*Volume, error) {
-	shell := ZFSCtl(adm.zfs).
+	shell := z.RawZFS().
 		Get(ctx, name, "-Hp", "", []string{"name"}, "", "", "all")
 	_, err := shell.Exec()
 	if err != nil {
-		return nil, fmt.Errorf("volume '%s' is not exists", name)
+		return nil, err
 	}
 	return &Volume{Name: name}, nil
 }
 
 func (z *ZFSadm) wrapVolume(ctx context.Context, name string, volume *Volume) {
-	execute := ZFSCtl(z.zfs).Get(ctx, name, "-Hp", "", nil, "", "", "all")
+	execute := z.RawZFS().Get(ctx, name, "-Hp", "", nil, "", "", "all")
 	data, _ := execute.Exec()
 	SetValue(data, volume)
 }
@@ -466,19 +449,19 @@ func (z *ZFSadm) CreateVolume(ctx context.Context, name string, properties map[s
 		return nil, err
 	}
 	if vol, _ := z.getVolume(ctx, name); vol != nil {
-		return nil, fmt.Errorf("volume '%s' is alreay exists", name)
+		return nil, fmt.Errorf("%w: %s", ErrDatasetExists, name)
 	}
 
 	vol := &Volume{Name: name}
-	execute := ZFSCtl(z.zfs).CreateVolume(ctx, name, 4096, nil, strconv.FormatInt(size, 10))
+	execute := z.RawZFS().CreateVolume(ctx, name, 4096, nil, strconv.FormatInt(size, 10))
 	if _, err := execute.Exec(); err != nil {
-		return nil, fmt.Errorf("%s: %v", execute.Commit(), err)
+		return nil, err
 	}
 
 	if properties != nil {
-		execute = ZFSCtl(z.zfs).Set(ctx, name, properties)
+		execute = z.RawZFS().Set(ctx, name, properties)
 		if _, err := execute.Exec(); err != nil {
-			return nil, fmt.Errorf("%s: %v", execute.Commit(), err)
+			return nil, err
 		}
 	}
 
@@ -489,38 +472,24 @@ func (z *ZFSadm) CreateVolume(ctx context.Context, name string, properties map[s
 func (z *ZFSadm) DeleteVolume(ctx context.Context, name string) error {
 
 	options := "-Rrf"
-	execute := ZFSCtl(z.zfs).DestroyFileSystemOrVolume(ctx, name, options)
+	execute := z.RawZFS().DestroyFileSystemOrVolume(ctx, name, options)
 	if _, err := execute.Exec(); err != nil {
-		return fmt.Errorf("%s: %v", execute.Commit(), err.Error())
+		return err
 	}
 
 	return nil
 }
 
 func (z *ZFSadm) GetSnapshots(ctx context.Context) (map[string]*Snapshot, error) {
-	snapshots := make(map[string]*Snapshot, 0)
-	// 获取所有的 snapshot
-	shell := ZFSCtl(adm.zfs).
-		List(ctx, "", "-Hp", "", []string{"name"}, "", "", "snapshot")
-	out, err := shell.Exec()
+	// 一次 zfs get 获取所有的 snapshot 及其属性
+	snapshots, err := z.ListSnapshots(ctx, ListOptions{})
 	if err != nil {
 		return nil, err
 	}
-
-	lines := strings.Split(string(out), "\n")
-	for _, line := range lines {
-		parts := strings.Split(line, "\t")
-		name := strings.TrimSpace(parts[0])
+	for name := range snapshots {
 		if !strings.Contains(name, "/") {
-			continue
+			delete(snapshots, name)
 		}
-		parent := strings.Split(name, "@")[0]
-		snapshots[name] = &Snapshot{Name: name, Parent: parent}
-	}
-
-	// 解析所有的snapshot
-	for name, snapshot := range snapshots {
-		z.wrapSnapshot(ctx, name, snapshot)
 	}
 	return snapshots, nil
 }
@@ -535,18 +504,18 @@ func (z *ZFSadm) GetSnapshot(ctx context.Context, name string) (*Snapshot, error
 }
 
 func (z *ZFSadm) getSnapshot(ctx context.Context, name string) (*Snapshot, error) {
-	shell := ZFSCtl(adm.zfs).
+	shell := z.RawZFS().
 		Get(ctx, name, "-Hp", "", []string{"name"}, "", "", "all")
 	_, err := shell.Exec()
 	if err != nil {
-		return nil, fmt.Errorf("volume '%s' is not exists", name)
+		return nil, err
 	}
 	parent := strings.Split(name, "@")[0]
 	return &Snapshot{Name: name, Parent: parent}, nil
 }
 
 func (z *ZFSadm) wrapSnapshot(ctx context.Context, name string, out *Snapshot) {
-	shell := ZFSCtl(adm.zfs).
+	shell := z.RawZFS().
 		Get(ctx, name, "-Hp", "", nil, "", "", "all")
 	data, _ := shell.Exec()
 	setSnapshot(data, out)
@@ -554,29 +523,29 @@ func (z *ZFSadm) wrapSnapshot(ctx context.Context, name string, out *Snapshot) {
 
 func (z *ZFSadm) CreateSnapshot(ctx context.Context, parent, name string, properties map[string]string) (*Snapshot, error) {
 	if !strings.Contains(name, "@") {
-		return nil, fmt.Errorf("missing '@' in snapshot name")
+		return nil, fmt.Errorf("%w: missing '@' in snapshot name", ErrInvalidName)
 	}
 
 	if _, err := z.getFileSystem(ctx, parent); err != nil {
 		if _, err := z.getVolume(ctx, parent); err != nil {
-			return nil, fmt.Errorf("parent '%s' is not exists", parent)
+			return nil, fmt.Errorf("%w: %s", ErrDatasetNotFound, parent)
 		}
 	}
 
 	if ss, _ := z.getSnapshot(ctx, name); ss != nil {
-		return nil, fmt.Errorf("snapshot '%s' is already exists", name)
+		return nil, fmt.Errorf("%w: %s", ErrDatasetExists, name)
 	}
 
 	snapshot := &Snapshot{Name: name, Parent: parent}
-	execute := ZFSCtl(z.zfs).Snapshot(ctx, name, nil)
+	execute := z.RawZFS().Snapshot(ctx, name, nil)
 	if _, err := execute.Exec(); err != nil {
-		return nil, fmt.Errorf("%s: %v", execute.Commit(), err)
+		return nil, err
 	}
 
 	if properties != nil {
-		execute = ZFSCtl(z.zfs).Set(ctx, name, properties)
+		execute = z.RawZFS().Set(ctx, name, properties)
 		if _, err := execute.Exec(); err != nil {
-			return nil, fmt.Errorf("%s: %v", execute.Commit(), err)
+			return nil, err
 		}
 	}
 
@@ -584,21 +553,28 @@ func (z *ZFSadm) CreateSnapshot(ctx context.Context, parent, name string, proper
 	return snapshot, nil
 }
 
-func (z *ZFSadm) DeleteSnapshot(ctx context.Context, name string) error {
+// DeleteSnapshot destroys snapshot and its namesakes of descendants. The snapshots held or with
+// dependent clones are marked for deferred destruction, it returns the holds deferring the destroy.
+func (z *ZFSadm) DeleteSnapshot(ctx context.Context, name string) ([]*Hold, error) {
+
+	holds, err := z.GetHolds(ctx, name, true)
+	if err != nil {
+		return nil, err
+	}
 
 	options := "-dr"
-	execute := ZFSCtl(z.zfs).DestroySnapshot(ctx, name, options)
+	execute := z.RawZFS().DestroySnapshot(ctx, name, options)
 	if _, err := execute.Exec(); err != nil {
-		return fmt.Errorf("%s: %v", execute.Commit(), err)
+		return nil, err
 	}
 
-	return nil
+	return holds, nil
 }
 
 func (z *ZFSadm) CloneFileSystem(ctx context.Context, name, snap string, properties map[string]string) (*Volume, error) {
 
 	if v, _ := z.getFileSystem(ctx, name); v != nil {
-		return nil, fmt.Errorf("fileSystem '%s' is already exists", name)
+		return nil, fmt.Errorf("%w: %s", ErrDatasetExists, name)
 	}
 
 	if _, err := z.getSnapshot(ctx, snap); err != nil {
@@ -607,15 +583,15 @@ func (z *ZFSadm) CloneFileSystem(ctx context.Context, name, snap string, propert
 
 	pool := strings.SplitN(snap, "/", 2)[0]
 	name = path.Join(pool, name)
-	execute := ZFSCtl(z.zfs).Clone(ctx, name, nil, snap)
+	execute := z.RawZFS().Clone(ctx, name, nil, snap)
 	if _, err := execute.Exec(); err != nil {
-		return nil, fmt.Errorf("%s: %v", execute.Commit(), err)
+		return nil, err
 	}
 
 	if properties != nil {
-		execute = ZFSCtl(z.zfs).Set(ctx, name, properties)
+		execute = z.RawZFS().Set(ctx, name, properties)
 		if _, err := execute.Exec(); err != nil {
-			return nil, fmt.Errorf("%s: %v", execute.Commit(), err)
+			return nil, err
 		}
 	}
 
@@ -626,7 +602,7 @@ func (z *ZFSadm) CloneFileSystem(ctx context.Context, name, snap string, propert
 
 func (z *ZFSadm) CloneVolume(ctx context.Context, name, snap string, properties map[string]string) (*Volume, error) {
 	if v, _ := z.getVolume(ctx, name); v != nil {
-		return nil, fmt.Errorf("volume '%s' is already exists", name)
+		return nil, fmt.Errorf("%w: %s", ErrDatasetExists, name)
 	}
 
 	if _, err := z.getSnapshot(ctx, snap); err != nil {
@@ -635,15 +611,15 @@ func (z *ZFSadm) CloneVolume(ctx context.Context, name, snap string, properties
 
 	pool := strings.SplitN(snap, "/", 2)[0]
 	name = path.Join(pool, name)
-	execute := ZFSCtl(z.zfs).Clone(ctx, name, nil, snap)
+	execute := z.RawZFS().Clone(ctx, name, nil, snap)
 	if _, err := execute.Exec(); err != nil {
-		return nil, fmt.Errorf("%s: %v", execute.Commit(), err)
+		return nil, err
 	}
 
 	if properties != nil {
-		execute = ZFSCtl(z.zfs).Set(ctx, name, properties)
+		execute = z.RawZFS().Set(ctx, name, properties)
 		if _, err := execute.Exec(); err != nil {
-			return nil, fmt.Errorf("%s: %v", execute.Commit(), err)
+			return nil, err
 		}
 	}
 
@@ -653,24 +629,24 @@ func (z *ZFSadm) CloneVolume(ctx context.Context, name, snap string, properties
 }
 
 func (z *ZFSadm) Get(ctx context.Context, name, options, max string, out []string, t, s string, properties ...string) ([]byte, error) {
-	execute := ZFSCtl(z.zfs).Get(ctx, name, options, max, out, t, s, properties...)
+	execute := z.RawZFS().Get(ctx, name, options, max, out, t, s, properties...)
 	return execute.Exec()
 }
 
 func (z *ZFSadm) SendBash(ctx context.Context, name, options, i string) *exec.Cmd {
-	return ZFSCtl(z.zfs).Send(ctx, name, options, i).Bash()
+	return z.RawZFS().Send(ctx, name, options, i).Bash()
 }
 
 func (z *ZFSadm) ReceiveBash(ctx context.Context, name, options string, properties map[string]string) *exec.Cmd {
-	return ZFSCtl(z.zfs).Receive(ctx, name, options, properties, "").Bash()
+	return z.RawZFS().Receive(ctx, name, options, properties, "").Bash()
 }
 
 func (z *ZFSadm) RawZFS() *zfsctl {
-	return ZFSCtl(z.zfs)
+	return ZFSCtl(z.zfs).WithRunner(z.runner)
 }
 
 func (z *ZFSadm) RawZPool() *zpoolctl {
-	return ZPoolCtl(z.zpool)
+	return ZPoolCtl(z.zpool).WithRunner(z.runner)
 }
 
 func setSnapshot(data []byte, into *Snapshot) {
@@ -685,6 +661,9 @@ func setSnapshot(data []byte, into *Snapshot) {
 
 		line = strings.TrimSpace(line)
 		if len(line) == 0 {
+			if err == io.EOF {
+				return
+			}
 			continue
 		}
 
@@ -0,0 +1,107 @@
+// MIT License
+//
+// Copyright (c) 2021 Lack
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+// SOFTWARE.
+
+package zfs
+
+import (
+	"context"
+	"fmt"
+	"path"
+	"strconv"
+	"strings"
+)
+
+// ARCStats the statistics of ARC (Adaptive Replacement Cache) and L2ARC, read from
+// the arcstats kstat of Linux
+type ARCStats struct {
+	// Size the current size of ARC in bytes
+	Size uint64 `json:"size"`
+	// TargetSize the target size of ARC (c)
+	TargetSize uint64 `json:"targetSize"`
+	MinSize    uint64 `json:"minSize"`
+	MaxSize    uint64 `json:"maxSize"`
+	Hits       uint64 `json:"hits"`
+	Misses     uint64 `json:"misses"`
+	// L2Size the size of L2ARC in bytes, 0 if there is no cache device
+	L2Size   uint64 `json:"l2Size"`
+	L2Hits   uint64 `json:"l2Hits"`
+	L2Misses uint64 `json:"l2Misses"`
+
+	// Values all numeric values of arcstats by their names
+	Values map[string]uint64 `json:"values"`
+}
+
+// HitRatio returns the ratio of ARC hits, 0 if there is no access yet
+func (s *ARCStats) HitRatio() float64 {
+	if s.Hits+s.Misses == 0 {
+		return 0
+	}
+	return float64(s.Hits) / float64(s.Hits+s.Misses)
+}
+
+// GetARCStats returns the statistics of ARC by the arcstats kstat, which is only available on Linux
+func (z *ZFSadm) GetARCStats(ctx context.Context) (*ARCStats, error) {
+	file := path.Join(kstatRoot, "arcstats")
+	out, err := (&execute{ctx: ctx, name: "cat", args: []string{file}, runner: z.runner}).Exec()
+	if err != nil {
+		return nil, err
+	}
+	return ParseARCStats(string(out))
+}
+
+// ParseARCStats parses the arcstats kstat of Linux, e.g.
+//
+//	13 1 0x01 123 33456 5214870658 46917549814939
+//	name                            type data
+//	hits                            4    1265732
+//	misses                          4    82124
+//	size                            4    1073741824
+func ParseARCStats(data string) (*ARCStats, error) {
+	values := make(map[string]uint64)
+	for _, line := range strings.Split(data, "\n") {
+		fields := strings.Fields(line)
+		if len(fields) != 3 {
+			continue
+		}
+		n, err := strconv.ParseUint(fields[2], 10, 64)
+		if err != nil {
+			continue
+		}
+		values[fields[0]] = n
+	}
+	if _, ok := values["size"]; !ok {
+		return nil, fmt.Errorf("invalid arcstats, missing size")
+	}
+
+	return &ARCStats{
+		Size:       values["size"],
+		TargetSize: values["c"],
+		MinSize:    values["c_min"],
+		MaxSize:    values["c_max"],
+		Hits:       values["hits"],
+		Misses:     values["misses"],
+		L2Size:     values["l2_size"],
+		L2Hits:     values["l2_hits"],
+		L2Misses:   values["l2_misses"],
+		Values:     values,
+	}, nil
+}
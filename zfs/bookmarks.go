@@ -0,0 +1,131 @@
+// MIT License
+//
+// Copyright (c) 2021 Lack
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+// SOFTWARE.
+
+package zfs
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Bookmark the bookmark of snapshot, it's the source of incremental send after the snapshot is destroyed
+type Bookmark struct {
+	// Name the full name of bookmark, e.g. tank/a#b1
+	Name string `json:"name"`
+	// GUID the guid of snapshot which the bookmark is created from
+	GUID      uint64    `json:"guid"`
+	CreateTXG uint64    `json:"createTXG"`
+	Creation  time.Time `json:"creation"`
+}
+
+// Dataset returns the name of filesystem or volume which bookmark belongs to
+func (b *Bookmark) Dataset() string {
+	return strings.SplitN(b.Name, "#", 2)[0]
+}
+
+var bookmarkProperties = []string{"name", "guid", "createtxg", "creation"}
+
+// ListBookmarks returns the bookmarks of dataset and its descendants, all bookmarks if dataset is empty
+func (z *ZFSadm) ListBookmarks(ctx context.Context, dataset string) ([]*Bookmark, error) {
+	options := "-Hp"
+	if len(dataset) > 0 {
+		options = "-Hp -r"
+	}
+	out, err := z.RawZFS().List(ctx, dataset, options, "", bookmarkProperties, "", "", "bookmark").Exec()
+	if err != nil {
+		return nil, err
+	}
+	return ParseBookmarks(string(out))
+}
+
+// GetBookmark returns the bookmark by its full name, e.g. tank/a#b1
+func (z *ZFSadm) GetBookmark(ctx context.Context, name string) (*Bookmark, error) {
+	if !strings.Contains(name, "#") {
+		return nil, fmt.Errorf("%w: '%s' is not a bookmark", ErrInvalidName, name)
+	}
+	out, err := z.RawZFS().List(ctx, name, "-Hp", "", bookmarkProperties, "", "", "bookmark").Exec()
+	if err != nil {
+		return nil, err
+	}
+	bookmarks, err := ParseBookmarks(string(out))
+	if err != nil {
+		return nil, err
+	}
+	if len(bookmarks) == 0 {
+		return nil, fmt.Errorf("%w: %s", ErrDatasetNotFound, name)
+	}
+	return bookmarks[0], nil
+}
+
+// CreateBookmark creates bookmark of snapshot, the bookmark could be the full name (tank/a#b1)
+// or the short name (b1 or #b1) which is put in the dataset of snapshot
+func (z *ZFSadm) CreateBookmark(ctx context.Context, snapshot, bookmark string) (*Bookmark, error) {
+	i := strings.Index(snapshot, "@")
+	if i < 0 {
+		return nil, fmt.Errorf("%w: '%s' is not a snapshot", ErrInvalidName, snapshot)
+	}
+	switch j := strings.Index(bookmark, "#"); {
+	case j < 0:
+		bookmark = snapshot[:i] + "#" + bookmark
+	case j == 0:
+		bookmark = snapshot[:i] + bookmark
+	}
+	if _, err := z.RawZFS().Bookmark(ctx, snapshot, bookmark).Exec(); err != nil {
+		return nil, err
+	}
+	return z.GetBookmark(ctx, bookmark)
+}
+
+// DeleteBookmark destroys bookmark by its full name, ErrDatasetNotFound is returned if it doesn't exist
+func (z *ZFSadm) DeleteBookmark(ctx context.Context, name string) error {
+	// the bookmark is looked up first, 'zfs destroy' reports the missing bookmark in various messages
+	if _, err := z.GetBookmark(ctx, name); err != nil {
+		return err
+	}
+	_, err := z.RawZFS().DestroyBookmark(ctx, name).Exec()
+	return err
+}
+
+// ParseBookmarks parses the output of 'zfs list -Hp -t bookmark -o name,guid,createtxg,creation'
+func ParseBookmarks(data string) ([]*Bookmark, error) {
+	bookmarks := make([]*Bookmark, 0)
+	for _, line := range strings.Split(data, "\n") {
+		if len(strings.TrimSpace(line)) == 0 {
+			continue
+		}
+		parts := strings.Split(line, "\t")
+		if len(parts) != 4 {
+			return nil, fmt.Errorf("invalid bookmark '%s'", line)
+		}
+		bookmark := &Bookmark{Name: parts[0]}
+		bookmark.GUID, _ = strconv.ParseUint(parts[1], 10, 64)
+		bookmark.CreateTXG, _ = strconv.ParseUint(parts[2], 10, 64)
+		if sec, err := strconv.ParseInt(parts[3], 10, 64); err == nil {
+			bookmark.Creation = time.Unix(sec, 0)
+		}
+		bookmarks = append(bookmarks, bookmark)
+	}
+	return bookmarks, nil
+}
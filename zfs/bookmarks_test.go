@@ -0,0 +1,82 @@
+// MIT License
+//
+// Copyright (c) 2021 Lack
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+// SOFTWARE.
+
+package zfs
+
+import (
+	"context"
+	"errors"
+	"testing"
+)
+
+func TestZFSadm_Bookmarks(t *testing.T) {
+	_, z := newTestSimulator(t)
+	ctx := context.TODO()
+
+	if _, err := z.CreateFileSystem(ctx, "tank/a", nil); err != nil {
+		t.Fatal(err)
+	}
+	snapshot, err := z.CreateSnapshot(ctx, "tank/a", "tank/a@s1", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	bookmark, err := z.CreateBookmark(ctx, "tank/a@s1", "b1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if bookmark.Name != "tank/a#b1" || bookmark.Dataset() != "tank/a" || bookmark.GUID == 0 ||
+		bookmark.Creation.IsZero() || bookmark.Creation.Unix() != snapshot.CreationTime.Unix() {
+		t.Fatalf("unexpected bookmark: %+v", bookmark)
+	}
+	if _, err = z.CreateBookmark(ctx, "tank/a@s1", "#b1"); err == nil {
+		t.Fatal("expect error for existing bookmark")
+	}
+	if _, err = z.CreateBookmark(ctx, "tank/a@s1", "tank#b2"); err == nil {
+		t.Fatal("expect error for bookmark of another dataset")
+	}
+
+	// the bookmark outlives its snapshot
+	if _, err = z.DeleteSnapshot(ctx, "tank/a@s1"); err != nil {
+		t.Fatal(err)
+	}
+	bookmarks, err := z.ListBookmarks(ctx, "tank")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(bookmarks) != 1 || bookmarks[0].GUID != bookmark.GUID || bookmarks[0].CreateTXG != bookmark.CreateTXG {
+		t.Fatalf("unexpected bookmarks: %+v", bookmarks)
+	}
+
+	if err = z.DeleteBookmark(ctx, "tank/a#b1"); err != nil {
+		t.Fatal(err)
+	}
+	if err = z.DeleteBookmark(ctx, "tank/a#b1"); !errors.Is(err, ErrDatasetNotFound) {
+		t.Fatalf("expect ErrDatasetNotFound, got %v", err)
+	}
+	if _, err = z.GetBookmark(ctx, "tank/a#b1"); !errors.Is(err, ErrDatasetNotFound) {
+		t.Fatalf("expect ErrDatasetNotFound, got %v", err)
+	}
+	if bookmarks, err = z.ListBookmarks(ctx, ""); err != nil || len(bookmarks) != 0 {
+		t.Fatalf("unexpected bookmarks: %v, %v", bookmarks, err)
+	}
+}
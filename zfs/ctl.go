@@ -17,32 +17,37 @@ import (
 	"bytes"
 	"context"
 	"fmt"
+	"io"
 	"os/exec"
+	"sort"
+	"strconv"
 	"strings"
+	"sync"
 )
 
 type zfsctl struct {
-	cmd string
+	cmd    string
+	runner Runner
 }
 
 func ZFSCtl(cmd string) *zfsctl {
-	zfsctl := &zfsctl{cmd: cmd}
+	zfsctl := &zfsctl{cmd: cmd, runner: NewLocalRunner()}
 	return zfsctl
 }
 
+// WithRunner executes commands of zfsctl by the given Runner
+func (z *zfsctl) WithRunner(runner Runner) *zfsctl {
+	z.runner = runner
+	return z
+}
+
 // CreateFileSystem creates zfs filesystem
 // 	zfs create [-p] [-o property=value] ... <filesystem>
 func (z *zfsctl) CreateFileSystem(ctx context.Context, name string, properties map[string]string) *execute {
 	args := []string{"create", "-p"}
-	if properties != nil {
-		kv := "-o "
-		for k, v := range properties {
-			kv += fmt.Sprintf("%s=%s ", k, v)
-		}
-		args = append(args, kv)
-	}
+	args = append(args, propertyArgs("-o", properties)...)
 	args = append(args, name)
-	return &execute{ctx: ctx, name: z.cmd, args: args}
+	return &execute{ctx: ctx, name: z.cmd, args: args, runner: z.runner}
 }
 
 // CreateVolume creates zfs block volume
@@ -50,17 +55,11 @@ func (z *zfsctl) CreateFileSystem(ctx context.Context, name string, properties m
 func (z *zfsctl) CreateVolume(ctx context.Context, name string, block int64, properties map[string]string, size string) *execute {
 	args := []string{"create", "-ps"}
 	if block > 0 {
-		args = append(args, fmt.Sprintf("-b %d", block))
-	}
-	if properties != nil {
-		kv := "-o "
-		for k, v := range properties {
-			kv += fmt.Sprintf("%s=%s ", k, v)
-		}
-		args = append(args, kv)
+		args = append(args, "-b", strconv.FormatInt(block, 10))
 	}
+	args = append(args, propertyArgs("-o", properties)...)
 	args = append(args, "-V", size, name)
-	return &execute{ctx: ctx, name: z.cmd, args: args}
+	return &execute{ctx: ctx, name: z.cmd, args: args, runner: z.runner}
 }
 
 // DestroyFileSystemOrVolume destroy zfs filesystem or volume
@@ -68,10 +67,10 @@ func (z *zfsctl) CreateVolume(ctx context.Context, name string, block int64, pro
 func (z *zfsctl) DestroyFileSystemOrVolume(ctx context.Context, name, options string) *execute {
 	args := []string{"destroy"}
 	if len(options) > 0 {
-		args = append(args, options)
+		args = append(args, strings.Fields(options)...)
 	}
 	args = append(args, name)
-	return &execute{ctx: ctx, name: z.cmd, args: args}
+	return &execute{ctx: ctx, name: z.cmd, args: args, runner: z.runner}
 }
 
 // DestroySnapshot destroy zfs snapshot
@@ -79,32 +78,38 @@ func (z *zfsctl) DestroyFileSystemOrVolume(ctx context.Context, name, options st
 func (z *zfsctl) DestroySnapshot(ctx context.Context, name, options string) *execute {
 	args := []string{"destroy"}
 	if len(options) > 0 {
-		args = append(args, options)
+		args = append(args, strings.Fields(options)...)
 	}
 	args = append(args, name)
-	return &execute{ctx: ctx, name: z.cmd, args: args}
+	return &execute{ctx: ctx, name: z.cmd, args: args, runner: z.runner}
 }
 
 // DestroyBookmark destroy zfs bookmark that belongs to filesystem or volume
 // 	zfs destroy <filesystem|volume>#<bookmark>
 func (z *zfsctl) DestroyBookmark(ctx context.Context, name string) *execute {
 	args := []string{"destroy", name}
-	return &execute{ctx: ctx, name: z.cmd, args: args}
+	return &execute{ctx: ctx, name: z.cmd, args: args, runner: z.runner}
 }
 
 // Snapshot create snapshot that belongs to volume or filesystem
 // 	zfs snapshot|snap [-r] [-o property=value] ... <filesystem|volume>@<snap> ...
 func (z *zfsctl) Snapshot(ctx context.Context, name string, properties map[string]string) *execute {
 	args := []string{"snapshot", "-r"}
-	if properties != nil {
-		kv := "-o "
-		for k, v := range properties {
-			kv += fmt.Sprintf("%s=%s ", k, v)
-		}
-		args = append(args, kv)
-	}
+	args = append(args, propertyArgs("-o", properties)...)
 	args = append(args, name)
-	return &execute{ctx: ctx, name: z.cmd, args: args}
+	return &execute{ctx: ctx, name: z.cmd, args: args, runner: z.runner}
+}
+
+// Snapshot2 creates snapshots atomically, snapshots of descendent datasets are created if r is true
+// 	zfs snapshot|snap [-r] [-o property=value] ... <filesystem|volume>@<snap> ...
+func (z *zfsctl) Snapshot2(ctx context.Context, r bool, properties map[string]string, names ...string) *execute {
+	args := []string{"snapshot"}
+	if r {
+		args = append(args, "-r")
+	}
+	args = append(args, propertyArgs("-o", properties)...)
+	args = append(args, names...)
+	return &execute{ctx: ctx, name: z.cmd, args: args, runner: z.runner}
 }
 
 // Rollback uses old data from snapshot
@@ -112,32 +117,26 @@ func (z *zfsctl) Snapshot(ctx context.Context, name string, properties map[strin
 func (z *zfsctl) Rollback(ctx context.Context, options string, snap string) *execute {
 	args := []string{"rollback"}
 	if len(options) > 0 {
-		args = append(args, options)
+		args = append(args, strings.Fields(options)...)
 	}
 	args = append(args, snap)
-	return &execute{ctx: ctx, name: z.cmd, args: args}
+	return &execute{ctx: ctx, name: z.cmd, args: args, runner: z.runner}
 }
 
 // Clone creates a volume or filesystem from snapshot
 // 	zfs clone [-p] [-o property=value] ... <snapshot> <filesystem|volume>
 func (z *zfsctl) Clone(ctx context.Context, name string, properties map[string]string, source string) *execute {
 	args := []string{"clone", "-p"}
-	if properties != nil {
-		kv := "-o "
-		for k, v := range properties {
-			kv += fmt.Sprintf("%s=%s ", k, v)
-		}
-		args = append(args, kv)
-	}
+	args = append(args, propertyArgs("-o", properties)...)
 	args = append(args, source, name)
-	return &execute{ctx: ctx, name: z.cmd, args: args}
+	return &execute{ctx: ctx, name: z.cmd, args: args, runner: z.runner}
 }
 
 // Promote
 // 	zfs promote <clone-filesystem>
 func (z *zfsctl) Promote(ctx context.Context, name string) *execute {
 	args := []string{"promote", name}
-	return &execute{ctx: ctx, name: z.cmd, args: args}
+	return &execute{ctx: ctx, name: z.cmd, args: args, runner: z.runner}
 }
 
 // Rename renames volume, filesystem or snapshot
@@ -148,7 +147,7 @@ func (z *zfsctl) Rename(ctx context.Context, name, newName string, force bool) *
 		args = append(args, "-f")
 	}
 	args = append(args, name, newName)
-	return &execute{ctx: ctx, name: z.cmd, args: args}
+	return &execute{ctx: ctx, name: z.cmd, args: args, runner: z.runner}
 }
 
 // RenameFileSystemOrVolume renames volume or filesystem
@@ -160,7 +159,7 @@ func (z *zfsctl) RenameFileSystemOrVolume(ctx context.Context, name, newName str
 	}
 	args = append(args, "-p")
 	args = append(args, name, newName)
-	return &execute{ctx: ctx, name: z.cmd, args: args}
+	return &execute{ctx: ctx, name: z.cmd, args: args, runner: z.runner}
 }
 
 // RenameSnapshot renames snapshot:
@@ -168,14 +167,14 @@ func (z *zfsctl) RenameFileSystemOrVolume(ctx context.Context, name, newName str
 func (z *zfsctl) RenameSnapshot(ctx context.Context, name, newName string) *execute {
 	args := []string{"rename", "-r"}
 	args = append(args, name, newName)
-	return &execute{ctx: ctx, name: z.cmd, args: args}
+	return &execute{ctx: ctx, name: z.cmd, args: args, runner: z.runner}
 }
 
 // Bookmark setup bookmark for snapshot:
 // 	zfs bookmark <snapshot> <bookmark>
 func (z *zfsctl) Bookmark(ctx context.Context, snapshot, bookmark string) *execute {
 	args := []string{"bookmark", snapshot, bookmark}
-	return &execute{ctx: ctx, name: z.cmd, args: args}
+	return &execute{ctx: ctx, name: z.cmd, args: args, runner: z.runner}
 }
 
 // List get all datasets:
@@ -184,46 +183,36 @@ func (z *zfsctl) Bookmark(ctx context.Context, snapshot, bookmark string) *execu
 func (z *zfsctl) List(ctx context.Context, name, options, max string, oProperties []string, sProperty, SProperty, t string) *execute {
 	args := []string{"list"}
 	if len(options) > 0 {
-		args = append(args, options)
+		args = append(args, strings.Fields(options)...)
 	}
 	if len(max) > 0 {
-		args = append(args, max)
+		args = append(args, strings.Fields(max)...)
 	}
-	if oProperties != nil {
-		o := "-o "
-		for _, p := range oProperties {
-			o += p + ","
-		}
-		args = append(args, strings.TrimSuffix(o, ","))
+	if len(oProperties) > 0 {
+		args = append(args, "-o", strings.Join(oProperties, ","))
 	}
 	if len(sProperty) > 0 {
-		args = append(args, sProperty)
+		args = append(args, strings.Fields(sProperty)...)
 	}
 	if len(SProperty) > 0 {
-		args = append(args, SProperty)
+		args = append(args, strings.Fields(SProperty)...)
 	}
 	if len(t) > 0 {
-		args = append(args, "-t "+t)
+		args = append(args, "-t", t)
 	}
 	if len(name) > 0 {
 		args = append(args, name)
 	}
-	return &execute{ctx: ctx, name: z.cmd, args: args}
+	return &execute{ctx: ctx, name: z.cmd, args: args, runner: z.runner}
 }
 
 // Set setup dataset property:
 // 	zfs set <property=value> ... <filesystem|volume|snapshot> ...
 func (z *zfsctl) Set(ctx context.Context, name string, properties map[string]string) *execute {
 	args := []string{"set"}
-	if properties != nil {
-		kv := ""
-		for k, v := range properties {
-			kv += fmt.Sprintf("%s=%s ", k, v)
-		}
-		args = append(args, kv)
-	}
+	args = append(args, propertyArgs("", properties)...)
 	args = append(args, name)
-	return &execute{ctx: ctx, name: z.cmd, args: args}
+	return &execute{ctx: ctx, name: z.cmd, args: args, runner: z.runner}
 }
 
 // Get gets dataset's property:
@@ -233,34 +222,27 @@ func (z *zfsctl) Set(ctx context.Context, name string, properties map[string]str
 func (z *zfsctl) Get(ctx context.Context, name, options, max string, out []string, t, s string, properties ...string) *execute {
 	args := []string{"get"}
 	if len(options) > 0 {
-		args = append(args, options)
+		args = append(args, strings.Fields(options)...)
 	}
 	if len(max) > 0 {
-		args = append(args, "-d "+max)
+		args = append(args, "-d", max)
 	}
-	if out != nil {
-		o := "-o "
-		for _, p := range properties {
-			o += p + ","
-		}
-		args = append(args, strings.TrimSuffix(o, ","))
+	if len(out) > 0 {
+		args = append(args, "-o", strings.Join(out, ","))
 	}
 	if len(t) > 0 {
-		args = append(args, "-t "+t)
+		args = append(args, "-t", t)
 	}
 	if len(s) > 0 {
-		args = append(args, "-s ", s)
+		args = append(args, "-s", s)
 	}
-	if properties != nil {
-		o := ""
-		for _, p := range properties {
-			o += p + ","
-		}
-		o = strings.TrimSuffix(o, ",")
-		args = append(args, o)
+	if len(properties) > 0 {
+		args = append(args, strings.Join(properties, ","))
 	}
-	args = append(args, name)
-	return &execute{ctx: ctx, name: z.cmd, args: args}
+	if len(name) > 0 {
+		args = append(args, name)
+	}
+	return &execute{ctx: ctx, name: z.cmd, args: args, runner: z.runner}
 }
 
 // Inherit :
@@ -268,10 +250,10 @@ func (z *zfsctl) Get(ctx context.Context, name, options, max string, out []strin
 func (z *zfsctl) Inherit(ctx context.Context, name string, options string, property string) *execute {
 	args := []string{"inherit"}
 	if len(options) != 0 {
-		args = append(args, options)
+		args = append(args, strings.Fields(options)...)
 	}
 	args = append(args, property, name)
-	return &execute{ctx: ctx, name: z.cmd, args: args}
+	return &execute{ctx: ctx, name: z.cmd, args: args, runner: z.runner}
 }
 
 // Upgrade zfs upgrade
@@ -281,7 +263,7 @@ func (z *zfsctl) Upgrade(ctx context.Context, v bool) *execute {
 	if v {
 		args = append(args, "-v")
 	}
-	return &execute{ctx: ctx, name: z.cmd, args: args}
+	return &execute{ctx: ctx, name: z.cmd, args: args, runner: z.runner}
 }
 
 // UpgradeFileSystem
@@ -292,14 +274,14 @@ func (z *zfsctl) UpgradeFileSystem(ctx context.Context, name, version string, r,
 		args = append(args, "-r")
 	}
 	if len(version) > 0 {
-		args = append(args, "-V "+version)
+		args = append(args, "-V", version)
 	}
 	if all {
 		args = append(args, "-a")
 	} else {
 		args = append(args, name)
 	}
-	return &execute{ctx: ctx, name: z.cmd, args: args}
+	return &execute{ctx: ctx, name: z.cmd, args: args, runner: z.runner}
 }
 
 // Userspace
@@ -308,26 +290,22 @@ func (z *zfsctl) UpgradeFileSystem(ctx context.Context, name, version string, r,
 func (z *zfsctl) Userspace(ctx context.Context, name, options string, fields []string, sField, SField, t string) *execute {
 	args := []string{"userspace"}
 	if len(options) > 0 {
-		args = append(args, options)
+		args = append(args, strings.Fields(options)...)
 	}
-	if fields != nil {
-		o := "-o "
-		for _, field := range fields {
-			o += field + ","
-		}
-		args = append(args, strings.TrimSuffix(o, ","))
+	if len(fields) > 0 {
+		args = append(args, "-o", strings.Join(fields, ","))
 	}
 	if len(sField) > 0 {
-		args = append(args, "-s "+sField)
+		args = append(args, "-s", sField)
 	}
 	if len(SField) > 0 {
-		args = append(args, "-S "+SField)
+		args = append(args, "-S", SField)
 	}
 	if len(t) > 0 {
-		args = append(args, "-t "+t)
+		args = append(args, "-t", t)
 	}
 	args = append(args, name)
-	return &execute{ctx: ctx, name: z.cmd, args: args}
+	return &execute{ctx: ctx, name: z.cmd, args: args, runner: z.runner}
 }
 
 // GroupSpace
@@ -336,34 +314,50 @@ func (z *zfsctl) Userspace(ctx context.Context, name, options string, fields []s
 func (z *zfsctl) GroupSpace(ctx context.Context, name, options string, fields []string, sField, SField, t string) *execute {
 	args := []string{"groupspace"}
 	if len(options) > 0 {
-		args = append(args, options)
+		args = append(args, strings.Fields(options)...)
 	}
-	if fields != nil {
-		o := "-o "
-		for _, field := range fields {
-			o += field + ","
-		}
-		o = strings.TrimSuffix(o, ",")
-		args = append(args, o)
+	if len(fields) > 0 {
+		args = append(args, "-o", strings.Join(fields, ","))
 	}
 	if len(sField) > 0 {
-		args = append(args, "-s "+sField)
+		args = append(args, "-s", sField)
 	}
 	if len(SField) > 0 {
-		args = append(args, "-S "+SField)
+		args = append(args, "-S", SField)
 	}
 	if len(t) > 0 {
-		args = append(args, "-t "+t)
+		args = append(args, "-t", t)
+	}
+	args = append(args, name)
+	return &execute{ctx: ctx, name: z.cmd, args: args, runner: z.runner}
+}
+
+// ProjectSpace
+// 	zfs projectspace [-Hp] [-o field[,...]] [-s field] ...
+//            [-S field] ... <filesystem|snapshot|path>
+func (z *zfsctl) ProjectSpace(ctx context.Context, name, options string, fields []string, sField, SField string) *execute {
+	args := []string{"projectspace"}
+	if len(options) > 0 {
+		args = append(args, strings.Fields(options)...)
+	}
+	if len(fields) > 0 {
+		args = append(args, "-o", strings.Join(fields, ","))
+	}
+	if len(sField) > 0 {
+		args = append(args, "-s", sField)
+	}
+	if len(SField) > 0 {
+		args = append(args, "-S", SField)
 	}
 	args = append(args, name)
-	return &execute{ctx: ctx, name: z.cmd, args: args}
+	return &execute{ctx: ctx, name: z.cmd, args: args, runner: z.runner}
 }
 
 // Mount mounts all endpoint
 // 	zfs mount
 func (z *zfsctl) Mount(ctx context.Context) *execute {
 	args := []string{"mount"}
-	return &execute{ctx: ctx, name: z.cmd, args: args}
+	return &execute{ctx: ctx, name: z.cmd, args: args, runner: z.runner}
 }
 
 // MountFileSystem
@@ -371,17 +365,17 @@ func (z *zfsctl) Mount(ctx context.Context) *execute {
 func (z *zfsctl) MountFileSystem(ctx context.Context, name, options, opts string, all bool) *execute {
 	args := []string{"mount"}
 	if len(options) > 0 {
-		args = append(args, options)
+		args = append(args, strings.Fields(options)...)
 	}
 	if len(opts) > 0 {
-		args = append(args, opts)
+		args = append(args, "-o", opts)
 	}
 	if all {
 		args = append(args, "-a")
 	} else {
 		args = append(args, name)
 	}
-	return &execute{ctx: ctx, name: z.cmd, args: args}
+	return &execute{ctx: ctx, name: z.cmd, args: args, runner: z.runner}
 }
 
 // Umount
@@ -396,7 +390,7 @@ func (z *zfsctl) Umount(ctx context.Context, name string, force, all bool) *exec
 	} else {
 		args = append(args, name)
 	}
-	return &execute{ctx: ctx, name: z.cmd, args: args}
+	return &execute{ctx: ctx, name: z.cmd, args: args, runner: z.runner}
 }
 
 // Share
@@ -411,7 +405,7 @@ func (z *zfsctl) Share(ctx context.Context, name string, all bool, kind string)
 	} else {
 		args = append(args, name)
 	}
-	return &execute{ctx: ctx, name: z.cmd, args: args}
+	return &execute{ctx: ctx, name: z.cmd, args: args, runner: z.runner}
 }
 
 // Unshare
@@ -426,7 +420,7 @@ func (z *zfsctl) Unshare(ctx context.Context, name string, all bool, kind string
 	} else {
 		args = append(args, name)
 	}
-	return &execute{ctx: ctx, name: z.cmd, args: args}
+	return &execute{ctx: ctx, name: z.cmd, args: args, runner: z.runner}
 }
 
 // SendSnapshot Examples:
@@ -434,37 +428,44 @@ func (z *zfsctl) Unshare(ctx context.Context, name string, all bool, kind string
 func (z *zfsctl) SendSnapshot(ctx context.Context, name, options string, i string) *execute {
 	args := []string{"send"}
 	if len(options) > 0 {
-		args = append(args, options)
+		args = append(args, strings.Fields(options)...)
 	}
 	if len(i) > 0 {
-		args = append(args, "-i "+i)
+		args = append(args, "-i", i)
 	}
 	args = append(args, name)
-	return &execute{ctx: ctx, name: z.cmd, args: args}
+	return &execute{ctx: ctx, name: z.cmd, args: args, runner: z.runner}
 }
 
 // SendAndRecv Examples:
-// 	zfs send tank/sla@snap | ssh storage@192.168.2.120 -i test "sudo /usr/sbin/zfs receive -Fu dup/sla@snap"
-func (z *zfsctl) SendAndRecv(ctx context.Context, source, target, user, host string) *execute {
+// 	zfs send tank/sla@snap | ssh storage@192.168.2.120 "sudo /usr/sbin/zfs receive -Fu dup/sla@snap"
+//
+// Deprecated: use Replicator, which reports progress and resumes interrupted streams.
+func (z *zfsctl) SendAndRecv(ctx context.Context, source, target, user, host string) *pipeline {
 	args := []string{"send", source}
-	if len(user) != 0 && len(host) != 0 {
-		args = append(args, fmt.Sprintf(`| ssh %s@%s -i %s "sudo %s receive -Fu %s"`, user, host, user, z.cmd, target))
-	} else {
-		args = append(args, fmt.Sprintf(`| %s receive -Fu %s`, z.cmd, target))
-	}
-	return &execute{ctx: ctx, name: z.cmd, args: args}
+	send := &execute{ctx: ctx, name: z.cmd, args: args, runner: z.runner}
+	return send.Pipe(z.receiveFu(ctx, target, user, host))
 }
 
 // IncrementSendAndRecv Examples:
-// 	zfs send -i tank/sla@snap1 tank/sla@snap2 | ssh storage@192.168.2.120 -i test "sudo /usr/sbin/zfs receive -Fu dup/sla@snap2"
-func (z *zfsctl) IncrementSendAndRecv(ctx context.Context, source, lastSnapshot, target, user, host string) *execute {
+// 	zfs send -i tank/sla@snap1 tank/sla@snap2 | ssh storage@192.168.2.120 "sudo /usr/sbin/zfs receive -Fu dup/sla@snap2"
+//
+// Deprecated: use Replicator, which picks the common snapshot automatically.
+func (z *zfsctl) IncrementSendAndRecv(ctx context.Context, source, lastSnapshot, target, user, host string) *pipeline {
 	args := []string{"send", "-i", lastSnapshot, source}
+	send := &execute{ctx: ctx, name: z.cmd, args: args, runner: z.runner}
+	return send.Pipe(z.receiveFu(ctx, target, user, host))
+}
+
+// receiveFu is the receiving side of SendAndRecv and IncrementSendAndRecv,
+// the remote command of ssh is interpreted by remote shell, so the target is quoted.
+func (z *zfsctl) receiveFu(ctx context.Context, target, user, host string) *execute {
 	if len(user) != 0 && len(host) != 0 {
-		args = append(args, fmt.Sprintf(`| ssh %s@%s -i %s "sudo %s receive -Fu %s"`, user, host, user, z.cmd, target))
-	} else {
-		args = append(args, fmt.Sprintf(`| %s receive -Fu %s`, z.cmd, target))
+		args := []string{user + "@" + host, "sudo", z.cmd, "receive", "-Fu", shellQuote(target)}
+		return &execute{ctx: ctx, name: "ssh", args: args, runner: z.runner}
 	}
-	return &execute{ctx: ctx, name: z.cmd, args: args}
+	args := []string{"receive", "-Fu", target}
+	return &execute{ctx: ctx, name: z.cmd, args: args, runner: z.runner}
 }
 
 // Send Examples:
@@ -472,13 +473,24 @@ func (z *zfsctl) IncrementSendAndRecv(ctx context.Context, source, lastSnapshot,
 func (z *zfsctl) Send(ctx context.Context, name, options string, i string) *execute {
 	args := []string{"send"}
 	if len(options) > 0 {
-		args = append(args, options)
+		args = append(args, strings.Fields(options)...)
 	}
 	if len(i) > 0 {
-		args = append(args, "-i "+i)
+		args = append(args, "-i", i)
 	}
 	args = append(args, name)
-	return &execute{ctx: ctx, name: z.cmd, args: args}
+	return &execute{ctx: ctx, name: z.cmd, args: args, runner: z.runner}
+}
+
+// SendIntermediary Examples:
+// 	zfs send [-Lec] -I <snapshot|bookmark> <snapshot>
+func (z *zfsctl) SendIntermediary(ctx context.Context, name, options string, i string) *execute {
+	args := []string{"send"}
+	if len(options) > 0 {
+		args = append(args, strings.Fields(options)...)
+	}
+	args = append(args, "-I", i, name)
+	return &execute{ctx: ctx, name: z.cmd, args: args, runner: z.runner}
 }
 
 // SendToken Examples:
@@ -486,10 +498,10 @@ func (z *zfsctl) Send(ctx context.Context, name, options string, i string) *exec
 func (z *zfsctl) SendToken(ctx context.Context, name, options string) *execute {
 	args := []string{"send"}
 	if len(options) > 0 {
-		args = append(args, options)
+		args = append(args, strings.Fields(options)...)
 	}
 	args = append(args, "-t", name)
-	return &execute{ctx: ctx, name: z.cmd, args: args}
+	return &execute{ctx: ctx, name: z.cmd, args: args, runner: z.runner}
 }
 
 // Receive Examples:
@@ -498,20 +510,14 @@ func (z *zfsctl) SendToken(ctx context.Context, name, options string) *execute {
 func (z *zfsctl) Receive(ctx context.Context, name, options string, properties map[string]string, xProperty string) *execute {
 	args := []string{"receive"}
 	if len(options) > 0 {
-		args = append(args, options)
-	}
-	if properties != nil {
-		kv := "-o "
-		for k, v := range properties {
-			kv += fmt.Sprintf("%s=%s ", k, v)
-		}
-		args = append(args, strings.TrimSuffix(kv, " "))
+		args = append(args, strings.Fields(options)...)
 	}
+	args = append(args, propertyArgs("-o", properties)...)
 	if len(xProperty) > 0 {
-		args = append(args, "-x "+xProperty)
+		args = append(args, "-x", xProperty)
 	}
 	args = append(args, name)
-	return &execute{ctx: ctx, name: z.cmd, args: args}
+	return &execute{ctx: ctx, name: z.cmd, args: args, runner: z.runner}
 }
 
 // ReceiveFileSystem Examples:
@@ -520,17 +526,11 @@ func (z *zfsctl) Receive(ctx context.Context, name, options string, properties m
 func (z *zfsctl) ReceiveFileSystem(ctx context.Context, name, options string, properties map[string]string, xProperty string, de string) *execute {
 	args := []string{"receive"}
 	if len(options) > 0 {
-		args = append(args, options)
-	}
-	if properties != nil {
-		kv := "-o "
-		for k, v := range properties {
-			kv += fmt.Sprintf("%s=%s ", k, v)
-		}
-		args = append(args, strings.TrimSuffix(kv, " "))
+		args = append(args, strings.Fields(options)...)
 	}
+	args = append(args, propertyArgs("-o", properties)...)
 	if len(xProperty) > 0 {
-		args = append(args, "-x "+xProperty)
+		args = append(args, "-x", xProperty)
 	}
 	switch de {
 	case "-d":
@@ -539,21 +539,21 @@ func (z *zfsctl) ReceiveFileSystem(ctx context.Context, name, options string, pr
 		args = append(args, "-e")
 	}
 	args = append(args, name)
-	return &execute{ctx: ctx, name: z.cmd, args: args}
+	return &execute{ctx: ctx, name: z.cmd, args: args, runner: z.runner}
 }
 
 // ReceiveAll Examples:
 // 	zfs receive -A <filesystem|volume>
 func (z *zfsctl) ReceiveAll(ctx context.Context, name string) *execute {
 	args := []string{"receive", "-A", name}
-	return &execute{ctx: ctx, name: z.cmd, args: args}
+	return &execute{ctx: ctx, name: z.cmd, args: args, runner: z.runner}
 }
 
 // Allow1 Examples:
 // 	zfs allow <filesystem|volume>
 func (z *zfsctl) Allow1(ctx context.Context, name string) *execute {
 	args := []string{"allow", name}
-	return &execute{ctx: ctx, name: z.cmd, args: args}
+	return &execute{ctx: ctx, name: z.cmd, args: args, runner: z.runner}
 }
 
 // Allow2 Examples:
@@ -562,10 +562,10 @@ func (z *zfsctl) Allow1(ctx context.Context, name string) *execute {
 func (z *zfsctl) Allow2(ctx context.Context, name, options, authority, perm string) *execute {
 	args := []string{"allow"}
 	if len(options) > 0 {
-		args = append(args, options)
+		args = append(args, strings.Fields(options)...)
 	}
 	args = append(args, authority, perm, name)
-	return &execute{ctx: ctx, name: z.cmd, args: args}
+	return &execute{ctx: ctx, name: z.cmd, args: args, runner: z.runner}
 }
 
 // Allow3 Examples:
@@ -573,24 +573,24 @@ func (z *zfsctl) Allow2(ctx context.Context, name, options, authority, perm stri
 func (z *zfsctl) Allow3(ctx context.Context, name, options, perm string) *execute {
 	args := []string{"allow"}
 	if len(options) > 0 {
-		args = append(args, options)
+		args = append(args, strings.Fields(options)...)
 	}
 	args = append(args, perm, name)
-	return &execute{ctx: ctx, name: z.cmd, args: args}
+	return &execute{ctx: ctx, name: z.cmd, args: args, runner: z.runner}
 }
 
 // Allow4 Examples:
 // 	zfs allow -c <perm|@setname>[,...] <filesystem|volume>
 func (z *zfsctl) Allow4(ctx context.Context, name, perm string) *execute {
 	args := []string{"allow", "-c", perm, name}
-	return &execute{ctx: ctx, name: z.cmd, args: args}
+	return &execute{ctx: ctx, name: z.cmd, args: args, runner: z.runner}
 }
 
 // Allow5 Examples:
 // 	zfs allow -s @setname <perm|@setname>[,...] <filesystem|volume>
 func (z *zfsctl) Allow5(ctx context.Context, name, setname, perm string) *execute {
 	args := []string{"allow", "-s", setname, perm, name}
-	return &execute{ctx: ctx, name: z.cmd, args: args}
+	return &execute{ctx: ctx, name: z.cmd, args: args, runner: z.runner}
 }
 
 // Unallow1 Examples:
@@ -599,10 +599,10 @@ func (z *zfsctl) Allow5(ctx context.Context, name, setname, perm string) *execut
 func (z *zfsctl) Unallow1(ctx context.Context, name, options, authority, perm string) *execute {
 	args := []string{"unallow"}
 	if len(options) > 0 {
-		args = append(args, options)
+		args = append(args, strings.Fields(options)...)
 	}
 	args = append(args, authority, perm, name)
-	return &execute{ctx: ctx, name: z.cmd, args: args}
+	return &execute{ctx: ctx, name: z.cmd, args: args, runner: z.runner}
 }
 
 // Unallow2 Examples:
@@ -610,10 +610,10 @@ func (z *zfsctl) Unallow1(ctx context.Context, name, options, authority, perm st
 func (z *zfsctl) Unallow2(ctx context.Context, name, options, perm string) *execute {
 	args := []string{"unallow"}
 	if len(options) > 0 {
-		args = append(args, options)
+		args = append(args, strings.Fields(options)...)
 	}
 	args = append(args, perm, name)
-	return &execute{ctx: ctx, name: z.cmd, args: args}
+	return &execute{ctx: ctx, name: z.cmd, args: args, runner: z.runner}
 }
 
 // Unallow3 Examples:
@@ -624,7 +624,7 @@ func (z *zfsctl) Unallow3(ctx context.Context, name string, r bool, perm string)
 		args = append(args, "-r")
 	}
 	args = append(args, "-c", perm, name)
-	return &execute{ctx: ctx, name: z.cmd, args: args}
+	return &execute{ctx: ctx, name: z.cmd, args: args, runner: z.runner}
 }
 
 // Unallow4 Examples:
@@ -635,7 +635,7 @@ func (z *zfsctl) Unallow4(ctx context.Context, name string, r bool, setmame, per
 		args = append(args, "-r")
 	}
 	args = append(args, "-s", setmame, perm, name)
-	return &execute{ctx: ctx, name: z.cmd, args: args}
+	return &execute{ctx: ctx, name: z.cmd, args: args, runner: z.runner}
 }
 
 // Hold Examples:
@@ -646,18 +646,18 @@ func (z *zfsctl) Hold(ctx context.Context, name string, r bool, tag string) *exe
 		args = append(args, "-r")
 	}
 	args = append(args, tag, name)
-	return &execute{ctx: ctx, name: z.cmd, args: args}
+	return &execute{ctx: ctx, name: z.cmd, args: args, runner: z.runner}
 }
 
 // Holds Examples:
-// 	zfs holds [-r] <snapshot> ...
-func (z *zfsctl) Holds(ctx context.Context, name string, r bool) *execute {
+// 	zfs holds [-rHp] <snapshot> ...
+func (z *zfsctl) Holds(ctx context.Context, name, options string) *execute {
 	args := []string{"holds"}
-	if r {
-		args = append(args, "-r")
+	if len(options) > 0 {
+		args = append(args, strings.Fields(options)...)
 	}
 	args = append(args, name)
-	return &execute{ctx: ctx, name: z.cmd, args: args}
+	return &execute{ctx: ctx, name: z.cmd, args: args, runner: z.runner}
 }
 
 // Release Examples:
@@ -668,7 +668,7 @@ func (z *zfsctl) Release(ctx context.Context, name string, r bool, tag string) *
 		args = append(args, "-r")
 	}
 	args = append(args, tag, name)
-	return &execute{ctx: ctx, name: z.cmd, args: args}
+	return &execute{ctx: ctx, name: z.cmd, args: args, runner: z.runner}
 }
 
 // Diff Examples:
@@ -676,21 +676,72 @@ func (z *zfsctl) Release(ctx context.Context, name string, r bool, tag string) *
 func (z *zfsctl) Diff(ctx context.Context, name, options string, target string) *execute {
 	args := []string{"diff"}
 	if len(options) > 0 {
-		args = append(args, options)
+		args = append(args, strings.Fields(options)...)
 	}
 	args = append(args, name)
 	if len(target) > 0 {
 		args = append(args, target)
 	}
-	return &execute{ctx: ctx, name: z.cmd, args: args}
+	return &execute{ctx: ctx, name: z.cmd, args: args, runner: z.runner}
+}
+
+// LoadKey Examples:
+// 	zfs load-key [-rn] [-L <keylocation>] <-a | filesystem|volume>
+func (z *zfsctl) LoadKey(ctx context.Context, name, options, location string) *execute {
+	args := []string{"load-key"}
+	if len(options) > 0 {
+		args = append(args, strings.Fields(options)...)
+	}
+	if len(location) > 0 {
+		args = append(args, "-L", location)
+	}
+	if len(name) > 0 {
+		args = append(args, name)
+	}
+	return &execute{ctx: ctx, name: z.cmd, args: args, runner: z.runner}
+}
+
+// UnloadKey Examples:
+// 	zfs unload-key [-r] <-a | filesystem|volume>
+func (z *zfsctl) UnloadKey(ctx context.Context, name, options string) *execute {
+	args := []string{"unload-key"}
+	if len(options) > 0 {
+		args = append(args, strings.Fields(options)...)
+	}
+	if len(name) > 0 {
+		args = append(args, name)
+	}
+	return &execute{ctx: ctx, name: z.cmd, args: args, runner: z.runner}
+}
+
+// ChangeKey Examples:
+// 	zfs change-key [-l] [-o keyformat=<value>]
+// 	    [-o keylocation=<value>] [-o pbkdf2iters=<value>]
+// 	    <filesystem|volume>
+// 	zfs change-key -i [-l] <filesystem|volume>
+func (z *zfsctl) ChangeKey(ctx context.Context, name, options string, properties map[string]string) *execute {
+	args := []string{"change-key"}
+	if len(options) > 0 {
+		args = append(args, strings.Fields(options)...)
+	}
+	args = append(args, propertyArgs("-o", properties)...)
+	args = append(args, name)
+	return &execute{ctx: ctx, name: z.cmd, args: args, runner: z.runner}
 }
 
 type zpoolctl struct {
-	cmd string
+	cmd    string
+	runner Runner
 }
 
 func ZPoolCtl(cmd string) *zpoolctl {
-	z := &zpoolctl{cmd: cmd}
+	z := &zpoolctl{cmd: cmd, runner: NewLocalRunner()}
+	return z
+}
+
+// WithRunner executes commands of zpoolctl by the given Runner
+func (z *zpoolctl) WithRunner(runner Runner) *zpoolctl {
+	z.runner = runner
 	return z
 }
 
@@ -702,27 +753,49 @@ func ZPoolCtl(cmd string) *zpoolctl {
 func (z *zpoolctl) Create(ctx context.Context, name, options, point, root string, properties map[string]string, raid string, devs ...string) *execute {
 	args := []string{"create"}
 	if len(options) > 0 {
-		args = append(args, options)
-	}
-	if properties != nil {
-		kv := "-o "
-		for k, v := range properties {
-			kv += fmt.Sprintf("%s=%s ", k, v)
-		}
-		args = append(args, kv)
+		args = append(args, strings.Fields(options)...)
 	}
+	args = append(args, propertyArgs("-o", properties)...)
 	if len(point) > 0 {
-		args = append(args, "-m "+point)
+		args = append(args, "-m", point)
 	}
 	if len(root) > 0 {
-		args = append(args, "-R "+root)
+		args = append(args, "-R", root)
+	}
+	args = append(args, name)
+	if len(raid) > 0 {
+		args = append(args, raid)
 	}
-	args = append(args, name, raid)
 	for _, dev := range devs {
 		args = append(args, dev)
 	}
 
-	return &execute{ctx: ctx, name: z.cmd, args: args}
+	return &execute{ctx: ctx, name: z.cmd, args: args, runner: z.runner}
+}
+
+// Create2 creates new pool with the properties of root dataset, the vdevs is the
+// whole vdev specification, e.g. "raidz2 sda sdb sdc sdd log mirror sde sdf"
+// Examples:
+// 	zpool create [-fnd] [-o property=value] ...
+//            [-O file-system-property=value] ...
+//            [-m mountpoint] [-R root] <pool> <vdev>
+func (z *zpoolctl) Create2(ctx context.Context, name, options, point, root string, properties, fsProperties map[string]string, vdevs ...string) *execute {
+	args := []string{"create"}
+	if len(options) > 0 {
+		args = append(args, strings.Fields(options)...)
+	}
+	args = append(args, propertyArgs("-o", properties)...)
+	args = append(args, propertyArgs("-O", fsProperties)...)
+	if len(point) > 0 {
+		args = append(args, "-m", point)
+	}
+	if len(root) > 0 {
+		args = append(args, "-R", root)
+	}
+	args = append(args, name)
+	args = append(args, vdevs...)
+
+	return &execute{ctx: ctx, name: z.cmd, args: args, runner: z.runner}
 }
 
 // Destroy delete a pool
@@ -734,7 +807,7 @@ func (z *zpoolctl) Destroy(ctx context.Context, name string, force bool) *execut
 		args = append(args, "-f")
 	}
 	args = append(args, name)
-	return &execute{ctx: ctx, name: z.cmd, args: args}
+	return &execute{ctx: ctx, name: z.cmd, args: args, runner: z.runner}
 }
 
 // Add Examples:
@@ -742,13 +815,13 @@ func (z *zpoolctl) Destroy(ctx context.Context, name string, force bool) *execut
 func (z *zpoolctl) Add(ctx context.Context, name string, options string, devs ...string) *execute {
 	args := []string{"add"}
 	if len(options) > 0 {
-		args = append(args, options)
+		args = append(args, strings.Fields(options)...)
 	}
 	args = append(args, name)
 	for _, dev := range devs {
 		args = append(args, dev)
 	}
-	return &execute{ctx: ctx, name: z.cmd, args: args}
+	return &execute{ctx: ctx, name: z.cmd, args: args, runner: z.runner}
 }
 
 // Remove Examples:
@@ -758,7 +831,7 @@ func (z *zpoolctl) Remove(ctx context.Context, name string, devs ...string) *exe
 	for _, dev := range devs {
 		args = append(args, dev)
 	}
-	return &execute{ctx: ctx, name: z.cmd, args: args}
+	return &execute{ctx: ctx, name: z.cmd, args: args, runner: z.runner}
 }
 
 // LabelClear Examples:
@@ -766,9 +839,10 @@ func (z *zpoolctl) Remove(ctx context.Context, name string, devs ...string) *exe
 func (z *zpoolctl) LabelClear(ctx context.Context, device string, force bool) *execute {
 	args := []string{"labelclear"}
 	if force {
-		args = append(args, "-f", device)
+		args = append(args, "-f")
 	}
-	return &execute{ctx: ctx, name: z.cmd, args: args}
+	args = append(args, device)
+	return &execute{ctx: ctx, name: z.cmd, args: args, runner: z.runner}
 }
 
 // List Examples:
@@ -776,21 +850,18 @@ func (z *zpoolctl) LabelClear(ctx context.Context, device string, force bool) *e
 func (z *zpoolctl) List(ctx context.Context, name, options string, properties []string, t string) *execute {
 	args := []string{"list"}
 	if len(options) > 0 {
-		args = append(args, options)
+		args = append(args, strings.Fields(options)...)
 	}
-	if properties != nil {
-		kv := "-o "
-		for _, v := range properties {
-			kv += v + ","
-		}
-		kv = strings.TrimSuffix(kv, ",")
-		args = append(args, kv)
+	if len(properties) > 0 {
+		args = append(args, "-o", strings.Join(properties, ","))
 	}
 	if len(t) > 0 {
-		args = append(args, "-T "+t)
+		args = append(args, "-T", t)
 	}
-	args = append(args, name)
-	return &execute{ctx: ctx, name: z.cmd, args: args}
+	if len(name) > 0 {
+		args = append(args, name)
+	}
+	return &execute{ctx: ctx, name: z.cmd, args: args, runner: z.runner}
 }
 
 // Iostat Examples:
@@ -799,19 +870,21 @@ func (z *zpoolctl) List(ctx context.Context, name, options string, properties []
 func (z *zpoolctl) Iostat(ctx context.Context, name, scripts, t, options string, devs ...string) *execute {
 	args := []string{"iostat"}
 	if len(scripts) > 0 {
-		args = append(args, scripts)
+		args = append(args, strings.Fields(scripts)...)
 	}
 	if len(t) > 0 {
-		args = append(args, t)
+		args = append(args, strings.Fields(t)...)
 	}
 	if len(options) > 0 {
-		args = append(args, options)
+		args = append(args, strings.Fields(options)...)
+	}
+	if len(name) > 0 {
+		args = append(args, name)
 	}
-	args = append(args, name)
 	for _, dev := range devs {
 		args = append(args, dev)
 	}
-	return &execute{ctx: ctx, name: z.cmd, args: args}
+	return &execute{ctx: ctx, name: z.cmd, args: args, runner: z.runner}
 }
 
 // Status Examples:
@@ -819,16 +892,18 @@ func (z *zpoolctl) Iostat(ctx context.Context, name, scripts, t, options string,
 func (z *zpoolctl) Status(ctx context.Context, scripts, options, t string, name string) *execute {
 	args := []string{"status"}
 	if len(scripts) > 0 {
-		args = append(args, scripts)
+		args = append(args, strings.Fields(scripts)...)
 	}
 	if len(t) > 0 {
-		args = append(args, t)
+		args = append(args, strings.Fields(t)...)
 	}
 	if len(options) > 0 {
-		args = append(args, options)
+		args = append(args, strings.Fields(options)...)
 	}
-	args = append(args, name)
-	return &execute{ctx: ctx, name: z.cmd, args: args}
+	if len(name) > 0 {
+		args = append(args, name)
+	}
+	return &execute{ctx: ctx, name: z.cmd, args: args, runner: z.runner}
 }
 
 // Online Examples:
@@ -839,42 +914,45 @@ func (z *zpoolctl) Online(ctx context.Context, name string, devs ...string) *exe
 	for _, dev := range devs {
 		args = append(args, dev)
 	}
-	return &execute{ctx: ctx, name: z.cmd, args: args}
+	return &execute{ctx: ctx, name: z.cmd, args: args, runner: z.runner}
 }
 
 // Offline Examples:
 //	zpool offline [-f] [-t] <pool> <device> ...
 func (z *zpoolctl) Offline(ctx context.Context, name string, force, t bool, devs ...string) *execute {
 	args := []string{"offline"}
-	args = append(args, name)
 	if force {
 		args = append(args, "-f")
 	}
 	if t {
 		args = append(args, "-t")
 	}
+	args = append(args, name)
 	for _, dev := range devs {
 		args = append(args, dev)
 	}
-	return &execute{ctx: ctx, name: z.cmd, args: args}
+	return &execute{ctx: ctx, name: z.cmd, args: args, runner: z.runner}
 }
 
 // Clear Examples:
 //	zpool clear [-nF] <pool> [device]
 func (z *zpoolctl) Clear(ctx context.Context, name, options string, devs ...string) *execute {
 	args := []string{"clear"}
-	args = append(args, options, name)
+	if len(options) > 0 {
+		args = append(args, strings.Fields(options)...)
+	}
+	args = append(args, name)
 	for _, dev := range devs {
 		args = append(args, dev)
 	}
-	return &execute{ctx: ctx, name: z.cmd, args: args}
+	return &execute{ctx: ctx, name: z.cmd, args: args, runner: z.runner}
 }
 
 // Reopen Examples:
 //	zpool reopen <pool>
 func (z *zpoolctl) Reopen(ctx context.Context, name string) *execute {
 	args := []string{"reopen", name}
-	return &execute{ctx: ctx, name: z.cmd, args: args}
+	return &execute{ctx: ctx, name: z.cmd, args: args, runner: z.runner}
 }
 
 // Attach Examples:
@@ -884,23 +962,16 @@ func (z *zpoolctl) Attach(ctx context.Context, name string, force bool, properti
 	if force {
 		args = append(args, "-f")
 	}
-	if properties != nil {
-		kv := "-o "
-		for k, v := range properties {
-			kv += fmt.Sprintf("%s=%s,", k, v)
-		}
-		kv = strings.TrimSuffix(kv, ",")
-		args = append(args, kv)
-	}
+	args = append(args, propertyArgs("-o", properties)...)
 	args = append(args, name, dev, newDev)
-	return &execute{ctx: ctx, name: z.cmd, args: args}
+	return &execute{ctx: ctx, name: z.cmd, args: args, runner: z.runner}
 }
 
 // Detach Examples:
 //	zpool detach <pool> <device>
 func (z *zpoolctl) Detach(ctx context.Context, name, dev string) *execute {
-	args := []string{"attach", name, dev}
-	return &execute{ctx: ctx, name: z.cmd, args: args}
+	args := []string{"detach", name, dev}
+	return &execute{ctx: ctx, name: z.cmd, args: args, runner: z.runner}
 }
 
 // Replace Examples:
@@ -910,19 +981,12 @@ func (z *zpoolctl) Replace(ctx context.Context, name string, force bool, propert
 	if force {
 		args = append(args, "-f")
 	}
-	if properties != nil {
-		kv := "-o "
-		for k, v := range properties {
-			kv += fmt.Sprintf("%s=%s,", k, v)
-		}
-		kv = strings.TrimSuffix(kv, ",")
-		args = append(args, kv)
-	}
+	args = append(args, propertyArgs("-o", properties)...)
 	args = append(args, name, dev)
 	if len(newDev) > 0 {
 		args = append(args, newDev)
 	}
-	return &execute{ctx: ctx, name: z.cmd, args: args}
+	return &execute{ctx: ctx, name: z.cmd, args: args, runner: z.runner}
 }
 
 // Split Examples:
@@ -931,38 +995,46 @@ func (z *zpoolctl) Replace(ctx context.Context, name string, force bool, propert
 func (z *zpoolctl) Split(ctx context.Context, name, options, altRoot, mntopts string, properties map[string]string, newName string, devs ...string) *execute {
 	args := []string{"split"}
 	if len(options) > 0 {
-		args = append(args, options)
+		args = append(args, strings.Fields(options)...)
 	}
 	if len(altRoot) > 0 {
-		args = append(args, altRoot)
+		args = append(args, "-R", altRoot)
 	}
 	if len(mntopts) > 0 {
-		args = append(args, mntopts)
-	}
-	if properties != nil {
-		kv := "-o "
-		for k, v := range properties {
-			kv += fmt.Sprintf("%s=%s,", k, v)
-		}
-		kv = strings.TrimSuffix(kv, ",")
-		args = append(args, kv)
+		args = append(args, "-o", mntopts)
 	}
+	args = append(args, propertyArgs("-o", properties)...)
 	args = append(args, name, newName)
 	for _, dev := range devs {
 		args = append(args, dev)
 	}
-	return &execute{ctx: ctx, name: z.cmd, args: args}
+	return &execute{ctx: ctx, name: z.cmd, args: args, runner: z.runner}
 }
 
-// Examples:
+// Scrub Examples:
 //	zpool scrub [-s | -p] <pool> ...
-func (z *zpoolctl) scrub(ctx context.Context, name, option string) *execute {
+func (z *zpoolctl) Scrub(ctx context.Context, name, option string) *execute {
 	args := []string{"scrub"}
 	if len(option) > 0 {
-		args = append(args, option)
+		args = append(args, strings.Fields(option)...)
+	}
+	args = append(args, name)
+	return &execute{ctx: ctx, name: z.cmd, args: args, runner: z.runner}
+}
+
+// Trim Examples:
+//	zpool trim [-dw] [-r rate] [-c | -s] <pool> [<device> ...]
+func (z *zpoolctl) Trim(ctx context.Context, name, options, rate string, devs ...string) *execute {
+	args := []string{"trim"}
+	if len(options) > 0 {
+		args = append(args, strings.Fields(options)...)
+	}
+	if len(rate) > 0 {
+		args = append(args, "-r", rate)
 	}
 	args = append(args, name)
-	return &execute{ctx: ctx, name: z.cmd, args: args}
+	args = append(args, devs...)
+	return &execute{ctx: ctx, name: z.cmd, args: args, runner: z.runner}
 }
 
 // Import1 Examples:
@@ -970,12 +1042,12 @@ func (z *zpoolctl) scrub(ctx context.Context, name, option string) *execute {
 func (z *zpoolctl) Import1(ctx context.Context, dir string, d bool) *execute {
 	args := []string{"import"}
 	if len(dir) > 0 {
-		args = append(args, dir)
+		args = append(args, "-d", dir)
 	}
 	if d {
 		args = append(args, "-D")
 	}
-	return &execute{ctx: ctx, name: z.cmd, args: args}
+	return &execute{ctx: ctx, name: z.cmd, args: args, runner: z.runner}
 }
 
 // Import2 Examples:
@@ -983,12 +1055,12 @@ func (z *zpoolctl) Import1(ctx context.Context, dir string, d bool) *execute {
 func (z *zpoolctl) Import2(ctx context.Context, dir string, d bool) *execute {
 	args := []string{"import"}
 	if len(dir) > 0 {
-		args = append(args, dir)
+		args = append(args, "-d", dir)
 	}
 	if d {
 		args = append(args, "-D")
 	}
-	return &execute{ctx: ctx, name: z.cmd, args: args}
+	return &execute{ctx: ctx, name: z.cmd, args: args, runner: z.runner}
 }
 
 // Import3 Examples:
@@ -997,9 +1069,9 @@ func (z *zpoolctl) Import3(ctx context.Context, name, dir, file string, force, n
 	args := []string{"import"}
 	switch {
 	case len(dir) > 0:
-		args = append(args, "-d "+dir)
+		args = append(args, "-d", dir)
 	case len(file) > 0:
-		args = append(args, "-c "+file)
+		args = append(args, "-c", file)
 	}
 	if force {
 		args = append(args, "-f")
@@ -1007,7 +1079,8 @@ func (z *zpoolctl) Import3(ctx context.Context, name, dir, file string, force, n
 			args = append(args, "-n")
 		}
 	}
-	return &execute{ctx: ctx, name: z.cmd, args: args}
+	args = append(args, name)
+	return &execute{ctx: ctx, name: z.cmd, args: args, runner: z.runner}
 }
 
 // Import4 Examples:
@@ -1019,24 +1092,17 @@ func (z *zpoolctl) Import4(
 	dir, file string, d, m, N bool, root string, force, n bool) *execute {
 	args := []string{"import"}
 	if len(mntopts) > 0 {
-		args = append(args, "-o "+mntopts)
-	}
-	if properties != nil {
-		kv := "-o "
-		for k, v := range properties {
-			kv += fmt.Sprintf("%s=%s,", k, v)
-		}
-		kv = strings.TrimSuffix(kv, ",")
-		args = append(args, kv)
+		args = append(args, "-o", mntopts)
 	}
+	args = append(args, propertyArgs("-o", properties)...)
 	switch {
 	case len(dir) > 0:
-		args = append(args, "-d "+dir)
+		args = append(args, "-d", dir)
 	case len(file) > 0:
-		args = append(args, "-c "+file)
+		args = append(args, "-c", file)
 	}
 	if d {
-		args = append(args, "-d")
+		args = append(args, "-D")
 	}
 	if m {
 		args = append(args, "-m")
@@ -1045,7 +1111,7 @@ func (z *zpoolctl) Import4(
 		args = append(args, "-N")
 	}
 	if len(root) > 0 {
-		args = append(args, "-R "+root)
+		args = append(args, "-R", root)
 	}
 	if force {
 		args = append(args, "-f")
@@ -1054,7 +1120,7 @@ func (z *zpoolctl) Import4(
 		}
 	}
 	args = append(args, "-a")
-	return &execute{ctx: ctx, name: z.cmd, args: args}
+	return &execute{ctx: ctx, name: z.cmd, args: args, runner: z.runner}
 }
 
 // Import5 Examples:
@@ -1067,24 +1133,17 @@ func (z *zpoolctl) Import5(
 	dir, file string, d, m, N bool, root string, force, n bool, newPool string) *execute {
 	args := []string{"import"}
 	if len(mntopts) > 0 {
-		args = append(args, "-o "+mntopts)
-	}
-	if properties != nil {
-		kv := "-o "
-		for k, v := range properties {
-			kv += fmt.Sprintf("%s=%s,", k, v)
-		}
-		kv = strings.TrimSuffix(kv, ",")
-		args = append(args, kv)
+		args = append(args, "-o", mntopts)
 	}
+	args = append(args, propertyArgs("-o", properties)...)
 	switch {
 	case len(dir) > 0:
-		args = append(args, "-d "+dir)
+		args = append(args, "-d", dir)
 	case len(file) > 0:
-		args = append(args, "-c "+file)
+		args = append(args, "-c", file)
 	}
 	if d {
-		args = append(args, "-d")
+		args = append(args, "-D")
 	}
 	if m {
 		args = append(args, "-m")
@@ -1093,7 +1152,7 @@ func (z *zpoolctl) Import5(
 		args = append(args, "-N")
 	}
 	if len(root) > 0 {
-		args = append(args, "-R "+root)
+		args = append(args, "-R", root)
 	}
 	if force {
 		args = append(args, "-f")
@@ -1105,7 +1164,42 @@ func (z *zpoolctl) Import5(
 	if len(newPool) > 0 {
 		args = append(args, newPool)
 	}
-	return &execute{ctx: ctx, name: z.cmd, args: args}
+	return &execute{ctx: ctx, name: z.cmd, args: args, runner: z.runner}
+}
+
+// Import6 Examples:
+//	zpool import [-o mntopts] [-o property=value] ...
+//            [-d dir]... | [-c cachefile] [-DfmN] [-R root] [-F [-nX] [-T txg]]
+//            <pool | id> [newpool]
+func (z *zpoolctl) Import6(
+	ctx context.Context,
+	name, options, mntopts string, properties map[string]string,
+	dirs []string, file, root, newPool string) *execute {
+	args := []string{"import"}
+	if len(options) > 0 {
+		args = append(args, strings.Fields(options)...)
+	}
+	if len(mntopts) > 0 {
+		args = append(args, "-o", mntopts)
+	}
+	args = append(args, propertyArgs("-o", properties)...)
+	for _, dir := range dirs {
+		args = append(args, "-d", dir)
+	}
+	if len(file) > 0 {
+		args = append(args, "-c", file)
+	}
+	if len(root) > 0 {
+		args = append(args, "-R", root)
+	}
+	// lists the pools available to import if name is empty
+	if len(name) > 0 {
+		args = append(args, name)
+	}
+	if len(newPool) > 0 {
+		args = append(args, newPool)
+	}
+	return &execute{ctx: ctx, name: z.cmd, args: args, runner: z.runner}
 }
 
 // Export Examples:
@@ -1113,10 +1207,10 @@ func (z *zpoolctl) Import5(
 func (z *zpoolctl) Export(ctx context.Context, name string, options string) *execute {
 	args := []string{"export"}
 	if len(options) > 0 {
-		args = append(args, options)
+		args = append(args, strings.Fields(options)...)
 	}
 	args = append(args, name)
-	return &execute{ctx: ctx, name: z.cmd, args: args}
+	return &execute{ctx: ctx, name: z.cmd, args: args, runner: z.runner}
 }
 
 // Upgrade1 Examples:
@@ -1126,7 +1220,7 @@ func (z *zpoolctl) Upgrade1(ctx context.Context, v bool) *execute {
 	if v {
 		args = append(args, "-v")
 	}
-	return &execute{ctx: ctx, name: z.cmd, args: args}
+	return &execute{ctx: ctx, name: z.cmd, args: args, runner: z.runner}
 }
 
 // Upgrade2 Examples:
@@ -1134,17 +1228,17 @@ func (z *zpoolctl) Upgrade1(ctx context.Context, v bool) *execute {
 func (z *zpoolctl) Upgrade2(ctx context.Context, name, version string) *execute {
 	args := []string{"upgrade"}
 	if len(version) > 0 {
-		args = append(args, "-V "+version)
+		args = append(args, "-V", version)
 	}
 	args = append(args, name)
-	return &execute{ctx: ctx, name: z.cmd, args: args}
+	return &execute{ctx: ctx, name: z.cmd, args: args, runner: z.runner}
 }
 
 // Reguid Examples:
 //	zpool reguid <pool>
 func (z *zpoolctl) Reguid(ctx context.Context, name string) *execute {
 	args := []string{"reguid", name}
-	return &execute{ctx: ctx, name: z.cmd, args: args}
+	return &execute{ctx: ctx, name: z.cmd, args: args, runner: z.runner}
 }
 
 // History Examples:
@@ -1152,22 +1246,22 @@ func (z *zpoolctl) Reguid(ctx context.Context, name string) *execute {
 func (z *zpoolctl) History(ctx context.Context, options string, pool string) *execute {
 	args := []string{"history"}
 	if len(options) > 0 {
-		args = append(args, options)
+		args = append(args, strings.Fields(options)...)
 	}
 	if len(pool) > 0 {
 		args = append(args, pool)
 	}
-	return &execute{ctx: ctx, name: z.cmd, args: args}
+	return &execute{ctx: ctx, name: z.cmd, args: args, runner: z.runner}
 }
 
 // Event Examples:
 //	zpool events [-vHfc]
 func (z *zpoolctl) Event(ctx context.Context, options string) *execute {
-	args := []string{"event"}
+	args := []string{"events"}
 	if len(options) > 0 {
-		args = append(args, options)
+		args = append(args, strings.Fields(options)...)
 	}
-	return &execute{ctx: ctx, name: z.cmd, args: args}
+	return &execute{ctx: ctx, name: z.cmd, args: args, runner: z.runner}
 }
 
 // Get Examples:
@@ -1175,36 +1269,25 @@ func (z *zpoolctl) Event(ctx context.Context, options string) *execute {
 func (z *zpoolctl) Get(ctx context.Context, name, options string, out []string, properties ...string) *execute {
 	args := []string{"get"}
 	if len(options) > 0 {
-		args = append(args, options)
+		args = append(args, strings.Fields(options)...)
 	}
-	if len(options) > 0 {
-		args = append(args, options)
+	if len(out) > 0 {
+		args = append(args, "-o", strings.Join(out, ","))
 	}
-	if out != nil && len(out) > 0 {
-		options := "-o "
-		for _, o := range out {
-			options += o + ","
-		}
-		options = strings.TrimSuffix(options, ",")
-		args = append(args, options)
+	if len(properties) > 0 {
+		args = append(args, strings.Join(properties, ","))
 	}
-	if properties != nil {
-		kv := ""
-		for _, p := range properties {
-			kv += p + ","
-		}
-		kv = strings.TrimSuffix(kv, ",")
-		args = append(args, kv)
+	if len(name) > 0 {
+		args = append(args, name)
 	}
-	args = append(args, name)
-	return &execute{ctx: ctx, name: z.cmd, args: args}
+	return &execute{ctx: ctx, name: z.cmd, args: args, runner: z.runner}
 }
 
 // Set Examples:
 //	zpool set <property=value> <pool>
 func (z *zpoolctl) Set(ctx context.Context, name, k, v string) *execute {
 	args := []string{"set", k + "=" + v, name}
-	return &execute{ctx: ctx, name: z.cmd, args: args}
+	return &execute{ctx: ctx, name: z.cmd, args: args, runner: z.runner}
 }
 
 // Sync Examples:
@@ -1216,13 +1299,58 @@ func (z *zpoolctl) Sync(ctx context.Context, names ...string) *execute {
 			args = append(args, name)
 		}
 	}
-	return &execute{ctx: ctx, name: z.cmd, args: args}
+	return &execute{ctx: ctx, name: z.cmd, args: args, runner: z.runner}
+}
+
+// propertyArgs converts properties to arguments in order, e.g. "-o a=b -o c=d",
+// each property is passed to command as a single argument, flag is omitted when it's empty.
+func propertyArgs(flag string, properties map[string]string) []string {
+	keys := make([]string, 0, len(properties))
+	for k := range properties {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	args := make([]string, 0, len(properties)*2)
+	for _, k := range keys {
+		if len(flag) > 0 {
+			args = append(args, flag)
+		}
+		args = append(args, k+"="+properties[k])
+	}
+	return args
+}
+
+// shellQuote quotes s for POSIX shell, so it's always interpreted as single word.
+func shellQuote(s string) string {
+	if len(s) == 0 {
+		return "''"
+	}
+	safe := true
+	for _, c := range s {
+		if !(c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c >= '0' && c <= '9' || strings.ContainsRune("@%+=:,./_-", c)) {
+			safe = false
+			break
+		}
+	}
+	if safe {
+		return s
+	}
+	return "'" + strings.ReplaceAll(s, "'", `'"'"'`) + "'"
 }
 
 type execute struct {
-	ctx  context.Context
-	name string
-	args []string
+	ctx    context.Context
+	name   string
+	args   []string
+	runner Runner
+	stdin  io.Reader
+}
+
+// WithStdin feeds r to the stdin of command, e.g. the key of 'zfs load-key'
+func (e *execute) WithStdin(r io.Reader) *execute {
+	e.stdin = r
+	return e
 }
 
 func (e *execute) Commit() string {
@@ -1233,24 +1361,140 @@ func (e *execute) Exec() ([]byte, error) {
 	if e.ctx == nil {
 		e.ctx = context.Background()
 	}
-	return execution(e.ctx, fmt.Sprintf(`%s %s`, e.name, strings.Join(e.args, " ")))
+	if e.runner == nil {
+		e.runner = NewLocalRunner()
+	}
+	return execution(e.ctx, e.runner, &Cmd{Name: e.name, Args: e.args, Stdin: e.stdin})
 }
 
+// Bash returns *exec.Cmd of local host, the arguments are passed without shell.
 func (e *execute) Bash() *exec.Cmd {
 	if e.ctx == nil {
 		e.ctx = context.Background()
 	}
-	shell := fmt.Sprintf(`%s %s`, e.name, strings.Join(e.args, " "))
-	return exec.CommandContext(e.ctx, "/bin/bash", "-c", shell)
+	cmd := exec.CommandContext(e.ctx, e.name, e.args...)
+	cmd.Stdin = e.stdin
+	return cmd
+}
+
+// Pipe connects the stdout of e to the stdin of next, like 'e | next'
+func (e *execute) Pipe(next *execute) *pipeline {
+	return &pipeline{ctx: e.ctx, steps: []*execute{e, next}}
 }
 
-func execution(ctx context.Context, shell string) ([]byte, error) {
-	data, err := exec.CommandContext(ctx, "/bin/sh", "-c", shell).CombinedOutput()
+// execution executes cmd by runner and returns its stdout, *CommandError
+// is returned when cmd fails.
+func execution(ctx context.Context, runner Runner, cmd *Cmd) ([]byte, error) {
+	stdout, stderr := &syncBuffer{}, &syncBuffer{}
+	cmd.Stdout = stdout
+	cmd.Stderr = stderr
+	err := runner.Run(ctx, cmd)
+	data := stdout.Bytes()
 	if bytes.HasSuffix(data, []byte("\n")) {
 		data = bytes.TrimSuffix(data, []byte("\n"))
 	}
 	if err != nil {
-		return nil, fmt.Errorf("%v:%v", err, string(data))
+		return nil, newCommandError(cmd, string(data), string(stderr.Bytes()), err)
 	}
 	return data, nil
 }
+
+// pipeline executes commands concurrently, each one reads the stdout of previous one.
+// Every step is executed by its own Runner, so that a pipeline could cross hosts.
+type pipeline struct {
+	ctx   context.Context
+	steps []*execute
+}
+
+// Pipe appends next to the tail of pipeline
+func (p *pipeline) Pipe(next *execute) *pipeline {
+	p.steps = append(p.steps, next)
+	return p
+}
+
+func (p *pipeline) Commit() string {
+	commits := make([]string, 0, len(p.steps))
+	for _, step := range p.steps {
+		commits = append(commits, step.Commit())
+	}
+	return strings.Join(commits, " | ")
+}
+
+func (p *pipeline) Exec() ([]byte, error) {
+	if p.ctx == nil {
+		p.ctx = context.Background()
+	}
+	ctx, cancel := context.WithCancel(p.ctx)
+	defer cancel()
+
+	var (
+		stdout = &syncBuffer{}
+		once   sync.Once
+		cause  error
+		wg     = sync.WaitGroup{}
+	)
+
+	var stdin io.Reader
+	for i, step := range p.steps {
+		runner := step.runner
+		if runner == nil {
+			runner = NewLocalRunner()
+		}
+		stderr := &syncBuffer{}
+		cmd := &Cmd{Name: step.name, Args: step.args, Stdin: stdin, Stderr: stderr}
+
+		var pw *io.PipeWriter
+		if i == len(p.steps)-1 {
+			cmd.Stdout = stdout
+		} else {
+			var pr *io.PipeReader
+			pr, pw = io.Pipe()
+			cmd.Stdout = pw
+			stdin = pr
+		}
+
+		wg.Add(1)
+		go func(cmd *Cmd, pw *io.PipeWriter, stderr *syncBuffer) {
+			defer wg.Done()
+			err := runner.Run(ctx, cmd)
+			if pw != nil {
+				_ = pw.CloseWithError(err)
+			}
+			if r, ok := cmd.Stdin.(*io.PipeReader); ok {
+				// the previous step must not block on writing when this step exits
+				_ = r.CloseWithError(io.ErrClosedPipe)
+			}
+			if err != nil {
+				// keeps the first failure, the others are probably caused by cancel()
+				once.Do(func() {
+					cause = newCommandError(cmd, "", string(stderr.Bytes()), err)
+				})
+				cancel()
+			}
+		}(cmd, pw, stderr)
+	}
+	wg.Wait()
+
+	if cause != nil {
+		return nil, cause
+	}
+	return bytes.TrimSuffix(stdout.Bytes(), []byte("\n")), nil
+}
+
+// syncBuffer is bytes.Buffer which is safe for concurrent writing
+type syncBuffer struct {
+	b  bytes.Buffer
+	mu sync.Mutex
+}
+
+func (w *syncBuffer) Write(p []byte) (int, error) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return w.b.Write(p)
+}
+
+func (w *syncBuffer) Bytes() []byte {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return w.b.Bytes()
+}
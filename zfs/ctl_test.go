@@ -0,0 +1,98 @@
+// MIT License
+//
+// Copyright (c) 2021 Lack
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+// SOFTWARE.
+
+package zfs
+
+import (
+	"context"
+	"reflect"
+	"testing"
+)
+
+func TestZFSCtl_Args(t *testing.T) {
+	ctx := context.TODO()
+	properties := map[string]string{
+		"sharenfs":    "rw=@192.168.2.0/24; rm -rf /",
+		"compression": "lz4",
+	}
+
+	execute := ZFSCtl("zfs").CreateFileSystem(ctx, "tank/a b", properties)
+	expect := []string{"create", "-p", "-o", "compression=lz4", "-o", "sharenfs=rw=@192.168.2.0/24; rm -rf /", "tank/a b"}
+	if !reflect.DeepEqual(execute.args, expect) {
+		t.Fatalf("expect %q, got %q", expect, execute.args)
+	}
+
+	execute = ZFSCtl("zfs").Get(ctx, "tank", "-Hp", "", []string{"name", "value"}, "filesystem", "", "used", "available")
+	expect = []string{"get", "-Hp", "-o", "name,value", "-t", "filesystem", "used,available", "tank"}
+	if !reflect.DeepEqual(execute.args, expect) {
+		t.Fatalf("expect %q, got %q", expect, execute.args)
+	}
+
+	execute = ZFSCtl("zfs").Holds(ctx, "tank@s1", "-rHp")
+	expect = []string{"holds", "-rHp", "tank@s1"}
+	if !reflect.DeepEqual(execute.args, expect) {
+		t.Fatalf("expect %q, got %q", expect, execute.args)
+	}
+
+	execute = ZPoolCtl("zpool").Create(ctx, "tank", "-f", "", "", nil, "", "/dev/sdb")
+	expect = []string{"create", "-f", "tank", "/dev/sdb"}
+	if !reflect.DeepEqual(execute.args, expect) {
+		t.Fatalf("expect %q, got %q", expect, execute.args)
+	}
+}
+
+func TestPipeline(t *testing.T) {
+	ctx := context.TODO()
+	printf := &execute{ctx: ctx, name: "printf", args: []string{"a\nb\n"}, runner: NewLocalRunner()}
+	tail := &execute{ctx: ctx, name: "tail", args: []string{"-n", "1"}, runner: NewLocalRunner()}
+
+	p := printf.Pipe(tail)
+	if p.Commit() != "printf a\nb\n | tail -n 1" {
+		t.Fatalf("unexpected commit: %s", p.Commit())
+	}
+	out, err := p.Exec()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(out) != "b" {
+		t.Fatalf("expect 'b', got '%s'", out)
+	}
+
+	fail := &execute{ctx: ctx, name: "false", runner: NewLocalRunner()}
+	if _, err = printf.Pipe(fail).Exec(); err == nil {
+		t.Fatal("expect error of pipeline")
+	}
+}
+
+func TestShellQuote(t *testing.T) {
+	tests := map[string]string{
+		"tank/a@snap": "tank/a@snap",
+		"":            "''",
+		"a b":         "'a b'",
+		"a'b; ls":     `'a'"'"'b; ls'`,
+	}
+	for in, expect := range tests {
+		if out := shellQuote(in); out != expect {
+			t.Errorf("shellQuote(%q): expect %s, got %s", in, expect, out)
+		}
+	}
+}
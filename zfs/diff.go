@@ -0,0 +1,236 @@
+// MIT License
+//
+// Copyright (c) 2021 Lack
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+// SOFTWARE.
+
+package zfs
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// DiffChange the kind of change reported by zfs diff
+type DiffChange string
+
+const (
+	DiffAdded    DiffChange = "+"
+	DiffRemoved  DiffChange = "-"
+	DiffModified DiffChange = "M"
+	DiffRenamed  DiffChange = "R"
+)
+
+// DiffFileType the type of file reported by zfs diff -F
+type DiffFileType string
+
+const (
+	DiffFile        DiffFileType = "F"
+	DiffDirectory   DiffFileType = "/"
+	DiffSymlink     DiffFileType = "@"
+	DiffSocket      DiffFileType = "="
+	DiffDoor        DiffFileType = ">"
+	DiffNamedPipe   DiffFileType = "|"
+	DiffBlockDevice DiffFileType = "B"
+	DiffCharDevice  DiffFileType = "C"
+	DiffEventPort   DiffFileType = "P"
+)
+
+// DiffEntry a change of file between a snapshot and a later snapshot or the current file system
+type DiffEntry struct {
+	// Time the change time (ctime) of file
+	Time     time.Time    `json:"time"`
+	Change   DiffChange   `json:"change"`
+	FileType DiffFileType `json:"fileType"`
+	Path     string       `json:"path"`
+	// NewPath the path after renaming, it's only set if Change is DiffRenamed
+	NewPath string `json:"newPath,omitempty"`
+}
+
+// Diff streams the changes between the snapshot and the later snapshot or file system 'to',
+// the current file system of snapshot is used if 'to' is empty. Both channels are closed
+// when zfs diff exits or ctx is done.
+func (z *ZFSadm) Diff(ctx context.Context, fromSnap, to string) (<-chan *DiffEntry, <-chan error) {
+	entries := make(chan *DiffEntry)
+	errs := make(chan error, 1)
+
+	if !strings.Contains(fromSnap, "@") {
+		errs <- fmt.Errorf("%w: %s is not a snapshot", ErrInvalidName, fromSnap)
+		close(entries)
+		close(errs)
+		return entries, errs
+	}
+
+	go func() {
+		defer close(errs)
+		defer close(entries)
+		if err := z.followDiff(ctx, fromSnap, to, entries); err != nil && ctx.Err() == nil {
+			errs <- err
+		}
+	}()
+	return entries, errs
+}
+
+func (z *ZFSadm) followDiff(ctx context.Context, fromSnap, to string, entries chan<- *DiffEntry) error {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	execute := z.RawZFS().Diff(ctx, fromSnap, "-FHt", to)
+
+	pr, pw := io.Pipe()
+	result := make(chan error, 1)
+	go func() {
+		stderr := &syncBuffer{}
+		cmd := &Cmd{Name: execute.name, Args: execute.args, Stdout: pw, Stderr: stderr}
+		err := execute.runner.Run(ctx, cmd)
+		if err != nil {
+			err = newCommandError(cmd, "", string(stderr.Bytes()), err)
+		}
+		_ = pw.Close()
+		result <- err
+	}()
+
+	var failed error
+	scanner := bufio.NewScanner(pr)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	for scanner.Scan() {
+		line := scanner.Text()
+		if len(line) == 0 {
+			continue
+		}
+		entry, err := ParseDiffLine(line)
+		if err != nil {
+			failed = err
+			break
+		}
+		select {
+		case entries <- entry:
+			continue
+		case <-ctx.Done():
+		}
+		break
+	}
+	if failed == nil {
+		failed = scanner.Err()
+	}
+	if failed != nil {
+		cancel()
+	}
+	_ = pr.CloseWithError(io.ErrClosedPipe)
+	if err := <-result; err != nil && failed == nil {
+		return err
+	}
+	return failed
+}
+
+// ParseDiff parses the output of 'zfs diff -FHt'
+func ParseDiff(data string) ([]*DiffEntry, error) {
+	entries := make([]*DiffEntry, 0)
+	for _, line := range strings.Split(data, "\n") {
+		if len(line) == 0 {
+			continue
+		}
+		entry, err := ParseDiffLine(line)
+		if err != nil {
+			return nil, err
+		}
+		entries = append(entries, entry)
+	}
+	return entries, nil
+}
+
+// ParseDiffLine parses a line of 'zfs diff -FHt', e.g.
+//
+//	1623000000.123456789	R	F	/tank/a/old\0040name	/tank/a/new
+func ParseDiffLine(line string) (*DiffEntry, error) {
+	fields := strings.Split(strings.TrimRight(line, "\r"), "\t")
+	if len(fields) < 4 {
+		return nil, fmt.Errorf("invalid line of zfs diff: %q", line)
+	}
+
+	entry := &DiffEntry{Change: DiffChange(fields[1]), FileType: DiffFileType(fields[2])}
+	switch entry.Change {
+	case DiffAdded, DiffRemoved, DiffModified:
+	case DiffRenamed:
+		if len(fields) < 5 {
+			return nil, fmt.Errorf("invalid line of zfs diff, missing new path: %q", line)
+		}
+	default:
+		return nil, fmt.Errorf("invalid change %q of zfs diff: %q", fields[1], line)
+	}
+
+	t, err := parseDiffTime(fields[0])
+	if err != nil {
+		return nil, fmt.Errorf("invalid time of zfs diff: %q", line)
+	}
+	entry.Time = t
+	entry.Path = unescapeDiffPath(fields[3])
+	if entry.Change == DiffRenamed {
+		entry.NewPath = unescapeDiffPath(fields[4])
+	}
+	return entry, nil
+}
+
+// parseDiffTime parses the time of -t, e.g. 1623000000.123456789
+func parseDiffTime(s string) (time.Time, error) {
+	parts := strings.SplitN(s, ".", 2)
+	sec, err := strconv.ParseInt(parts[0], 10, 64)
+	if err != nil {
+		return time.Time{}, err
+	}
+	var nsec int64
+	if len(parts) == 2 {
+		frac := (parts[1] + "000000000")[:9]
+		if nsec, err = strconv.ParseInt(frac, 10, 64); err != nil {
+			return time.Time{}, err
+		}
+	}
+	return time.Unix(sec, nsec), nil
+}
+
+// unescapeDiffPath decodes the octal escaped characters of path, zfs diff prints the
+// non-printable characters, space and backslash as "\0ooo", e.g. "a\0040b" -> "a b"
+func unescapeDiffPath(s string) string {
+	if !strings.Contains(s, `\`) {
+		return s
+	}
+	b := make([]byte, 0, len(s))
+	for i := 0; i < len(s); i++ {
+		if s[i] != '\\' {
+			b = append(b, s[i])
+			continue
+		}
+		j := i + 1
+		for j < len(s) && j < i+5 && s[j] >= '0' && s[j] <= '7' {
+			j++
+		}
+		n, err := strconv.ParseUint(s[i+1:j], 8, 8)
+		if j-i < 4 || err != nil {
+			b = append(b, s[i])
+			continue
+		}
+		b = append(b, byte(n))
+		i = j - 1
+	}
+	return string(b)
+}
@@ -0,0 +1,91 @@
+// MIT License
+//
+// Copyright (c) 2021 Lack
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+// SOFTWARE.
+
+package zfs
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"testing"
+	"time"
+)
+
+const zfsDiff = "1623000000.123456789\tM\t/\t/tank/a\n" +
+	"1623000001.000000000\t+\tF\t/tank/a/new\\0040file.txt\n" +
+	"1623000002.5\t-\t@\t/tank/a/link\n" +
+	"1623000003.000000000\tR\tF\t/tank/a/caf\\0303\\0251\t/tank/a/back\\0134slash\n"
+
+func TestParseDiff(t *testing.T) {
+	entries, err := ParseDiff(zfsDiff)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expect := []*DiffEntry{
+		{Time: time.Unix(1623000000, 123456789), Change: DiffModified, FileType: DiffDirectory, Path: "/tank/a"},
+		{Time: time.Unix(1623000001, 0), Change: DiffAdded, FileType: DiffFile, Path: "/tank/a/new file.txt"},
+		{Time: time.Unix(1623000002, 500000000), Change: DiffRemoved, FileType: DiffSymlink, Path: "/tank/a/link"},
+		{Time: time.Unix(1623000003, 0), Change: DiffRenamed, FileType: DiffFile, Path: "/tank/a/café", NewPath: `/tank/a/back\slash`},
+	}
+	if !reflect.DeepEqual(entries, expect) {
+		for i := range entries {
+			t.Logf("%+v", entries[i])
+		}
+		t.Fatal("unexpected entries")
+	}
+
+	for _, line := range []string{"1623000000\tM\t/", "1623000000\tX\tF\t/a", "1623000000\tR\tF\t/a", "now\tM\tF\t/a"} {
+		if _, err = ParseDiffLine(line); err == nil {
+			t.Fatalf("expect error of %q", line)
+		}
+	}
+}
+
+func TestZFSadm_Diff(t *testing.T) {
+	runner := NewFakeRunner().On("zfs diff -FHt tank/a@s1 tank/a@s2", zfsDiff, nil)
+	z := New(WithRunner(runner))
+
+	entries, errs := z.Diff(context.TODO(), "tank/a@s1", "tank/a@s2")
+	count := 0
+	for range entries {
+		count++
+	}
+	if err := <-errs; err != nil {
+		t.Fatal(err)
+	}
+	if count != 4 {
+		t.Fatalf("expect 4 entries, got %d", count)
+	}
+
+	_, errs = z.Diff(context.TODO(), "tank/a", "")
+	if err := <-errs; !errors.Is(err, ErrInvalidName) {
+		t.Fatalf("expect ErrInvalidName, got %v", err)
+	}
+
+	runner.On("zfs diff -FHt tank/a@s3", "Unable to obtain diffs: dataset does not exist\n", errors.New("exit status 1"))
+	entries, errs = z.Diff(context.TODO(), "tank/a@s3", "")
+	for range entries {
+	}
+	if err := <-errs; !errors.Is(err, ErrDatasetNotFound) {
+		t.Fatalf("expect ErrDatasetNotFound, got %v", err)
+	}
+}
@@ -0,0 +1,204 @@
+// MIT License
+//
+// Copyright (c) 2021 Lack
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+// SOFTWARE.
+
+package zfs
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+)
+
+var ErrInvalidKey = errors.New("invalid encryption key")
+
+// KeyFormat the format of wrapping key of encrypted dataset
+type KeyFormat string
+
+const (
+	// KeyFormatRaw the key is 32 raw bytes
+	KeyFormatRaw KeyFormat = "raw"
+	// KeyFormatHex the key is 64 hexadecimal characters
+	KeyFormatHex        KeyFormat = "hex"
+	KeyFormatPassphrase KeyFormat = "passphrase"
+)
+
+// KeyStatus the value of property keystatus
+const (
+	KeyAvailable   = "available"
+	KeyUnavailable = "unavailable"
+)
+
+// EncryptionKey the wrapping key of encrypted dataset
+type EncryptionKey struct {
+	// Algorithm the encryption suite, e.g. aes-256-gcm, the default one is used if it's empty
+	Algorithm string
+	Format    KeyFormat
+	// Key reads the key material, which is fed to the stdin of zfs, so it never appears
+	// on the command line. It must be nil if Location isn't "prompt".
+	Key io.Reader
+	// Location the keylocation of dataset, e.g. file:///etc/zfs/keys/tank, it's "prompt" if empty
+	Location string
+	// PBKDF2Iters the iterations of passphrase derivation, the default one is used if it's 0
+	PBKDF2Iters uint64
+}
+
+func (k *EncryptionKey) validate() error {
+	switch k.Format {
+	case KeyFormatRaw, KeyFormatHex, KeyFormatPassphrase:
+	default:
+		return fmt.Errorf("%w: unknown key format %q", ErrInvalidKey, k.Format)
+	}
+	prompt := len(k.Location) == 0 || k.Location == "prompt"
+	if prompt && k.Key == nil {
+		return fmt.Errorf("%w: key is required by keylocation=prompt", ErrInvalidKey)
+	}
+	if !prompt && k.Key != nil {
+		return fmt.Errorf("%w: key is read from %s, but it's also given", ErrInvalidKey, k.Location)
+	}
+	if k.PBKDF2Iters > 0 && k.Format != KeyFormatPassphrase {
+		return fmt.Errorf("%w: pbkdf2iters is only used by passphrase", ErrInvalidKey)
+	}
+	return nil
+}
+
+// properties returns the properties of zfs create and zfs change-key
+func (k *EncryptionKey) properties() map[string]string {
+	properties := map[string]string{
+		"keyformat":   string(k.Format),
+		"keylocation": "prompt",
+	}
+	if len(k.Location) > 0 {
+		properties["keylocation"] = k.Location
+	}
+	if k.PBKDF2Iters > 0 {
+		properties["pbkdf2iters"] = strconv.FormatUint(k.PBKDF2Iters, 10)
+	}
+	return properties
+}
+
+func (k *EncryptionKey) createProperties(properties map[string]string) map[string]string {
+	out := k.properties()
+	for name, value := range properties {
+		out[name] = value
+	}
+	out["encryption"] = "on"
+	if len(k.Algorithm) > 0 {
+		out["encryption"] = k.Algorithm
+	}
+	return out
+}
+
+// CreateEncryptedFileSystem creates a file system which is an encryption root,
+// the encryption properties must be set on creation, so that properties are also set by zfs create.
+func (z *ZFSadm) CreateEncryptedFileSystem(ctx context.Context, name string, properties map[string]string, key EncryptionKey) (*Volume, error) {
+	if err := key.validate(); err != nil {
+		return nil, err
+	}
+	pool := strings.SplitN(name, "/", 2)[0]
+	if _, err := z.getPool(ctx, pool); err != nil {
+		return nil, err
+	}
+	if fs, _ := z.getFileSystem(ctx, name); fs != nil {
+		return nil, fmt.Errorf("%w: %s", ErrDatasetExists, name)
+	}
+
+	execute := z.RawZFS().CreateFileSystem(ctx, name, key.createProperties(properties)).WithStdin(key.Key)
+	if _, err := execute.Exec(); err != nil {
+		return nil, err
+	}
+
+	fs := &Volume{Name: name}
+	z.wrapFileSystem(ctx, name, fs)
+	return fs, nil
+}
+
+// CreateEncryptedVolume creates a volume which is an encryption root
+func (z *ZFSadm) CreateEncryptedVolume(ctx context.Context, name string, properties map[string]string, size int64, key EncryptionKey) (*Volume, error) {
+	if err := key.validate(); err != nil {
+		return nil, err
+	}
+	pool := strings.SplitN(name, "/", 2)[0]
+	if _, err := z.getPool(ctx, pool); err != nil {
+		return nil, err
+	}
+	if vol, _ := z.getVolume(ctx, name); vol != nil {
+		return nil, fmt.Errorf("%w: %s", ErrDatasetExists, name)
+	}
+
+	execute := z.RawZFS().CreateVolume(ctx, name, 4096, key.createProperties(properties), strconv.FormatInt(size, 10)).
+		WithStdin(key.Key)
+	if _, err := execute.Exec(); err != nil {
+		return nil, err
+	}
+
+	vol := &Volume{Name: name}
+	z.wrapVolume(ctx, name, vol)
+	return vol, nil
+}
+
+// LoadKey loads the wrapping key of encryption root, key is read from keylocation of dataset if it's nil.
+// The key is fed to every encryption root with keylocation=prompt when recursive is true,
+// so key must be nil unless there is only one of them.
+func (z *ZFSadm) LoadKey(ctx context.Context, name string, recursive bool, key io.Reader) error {
+	options := ""
+	if recursive {
+		options = "-r"
+	}
+	location := ""
+	if key != nil {
+		location = "prompt"
+	}
+	_, err := z.RawZFS().LoadKey(ctx, name, options, location).WithStdin(key).Exec()
+	return err
+}
+
+// UnloadKey unloads the wrapping key of encryption root, the datasets must be unmounted
+func (z *ZFSadm) UnloadKey(ctx context.Context, name string, recursive bool) error {
+	options := ""
+	if recursive {
+		options = "-r"
+	}
+	_, err := z.RawZFS().UnloadKey(ctx, name, options).Exec()
+	return err
+}
+
+// ChangeKey rotates the wrapping key of encrypted dataset, the dataset becomes an encryption root
+// if it inherits the key. The current key must be loaded, the data isn't re-encrypted.
+func (z *ZFSadm) ChangeKey(ctx context.Context, name string, key EncryptionKey) error {
+	if err := key.validate(); err != nil {
+		return err
+	}
+	if len(key.Algorithm) > 0 {
+		return fmt.Errorf("%w: the encryption suite can't be changed", ErrInvalidKey)
+	}
+	_, err := z.RawZFS().ChangeKey(ctx, name, "", key.properties()).WithStdin(key.Key).Exec()
+	return err
+}
+
+// InheritKey makes the dataset inherit the wrapping key of its parent, it's no longer an encryption root
+func (z *ZFSadm) InheritKey(ctx context.Context, name string) error {
+	_, err := z.RawZFS().ChangeKey(ctx, name, "-i", nil).Exec()
+	return err
+}
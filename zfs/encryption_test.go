@@ -0,0 +1,140 @@
+// MIT License
+//
+// Copyright (c) 2021 Lack
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+// SOFTWARE.
+
+package zfs
+
+import (
+	"context"
+	"errors"
+	"io"
+	"strings"
+	"sync"
+	"testing"
+)
+
+// stdinRunner records the stdin of commands executed by FakeRunner
+type stdinRunner struct {
+	*FakeRunner
+	mu    sync.Mutex
+	input map[string]string
+}
+
+func (r *stdinRunner) Run(ctx context.Context, cmd *Cmd) error {
+	if cmd.Stdin != nil {
+		data, err := io.ReadAll(cmd.Stdin)
+		if err != nil {
+			return err
+		}
+		r.mu.Lock()
+		r.input[cmd.String()] = string(data)
+		r.mu.Unlock()
+		cmd.Stdin = nil
+	}
+	return r.FakeRunner.Run(ctx, cmd)
+}
+
+func TestZFSadm_CreateEncryptedFileSystem(t *testing.T) {
+	create := "zfs create -p -o compression=lz4 -o encryption=aes-256-gcm -o keyformat=passphrase " +
+		"-o keylocation=prompt -o pbkdf2iters=350000 tank/secret"
+	runner := &stdinRunner{input: map[string]string{}, FakeRunner: NewFakeRunner().
+		On("zpool list -Hp -o name tank", "tank", nil).
+		On("zfs list -Hp -o name -t filesystem tank/secret", "", errors.New("exit status 1")).
+		On(create, "", nil).
+		On("zfs get -Hp all tank/secret", "tank/secret\tkeystatus\tavailable\t-\n"+
+			"tank/secret\tencryptionroot\ttank/secret\t-\ntank/secret\tencryption\taes-256-gcm\t-\n", nil),
+	}
+	z := New(WithRunner(runner))
+
+	key := EncryptionKey{
+		Algorithm:   "aes-256-gcm",
+		Format:      KeyFormatPassphrase,
+		Key:         strings.NewReader("correct horse battery staple\n"),
+		PBKDF2Iters: 350000,
+	}
+	fs, err := z.CreateEncryptedFileSystem(context.TODO(), "tank/secret", map[string]string{"compression": "lz4"}, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if fs.Keystatus != KeyAvailable || fs.EncryptionRoot != "tank/secret" || fs.Encryption != "aes-256-gcm" {
+		t.Fatalf("unexpected file system: %+v", fs)
+	}
+	if input := runner.input[create]; input != "correct horse battery staple\n" {
+		t.Fatalf("expect key from stdin, got %q", input)
+	}
+	for _, line := range runner.History() {
+		if strings.Contains(line, "staple") {
+			t.Fatalf("key is leaked on command line: %s", line)
+		}
+	}
+
+	invalid := []EncryptionKey{
+		{Format: "base64", Key: strings.NewReader("x")},
+		{Format: KeyFormatHex},
+		{Format: KeyFormatRaw, Location: "file:///etc/zfs/key", Key: strings.NewReader("x")},
+		{Format: KeyFormatRaw, Key: strings.NewReader("x"), PBKDF2Iters: 1000},
+	}
+	for _, key := range invalid {
+		if _, err = z.CreateEncryptedFileSystem(context.TODO(), "tank/secret", nil, key); !errors.Is(err, ErrInvalidKey) {
+			t.Fatalf("expect ErrInvalidKey of %+v, got %v", key, err)
+		}
+	}
+}
+
+func TestZFSadm_LoadKey(t *testing.T) {
+	runner := &stdinRunner{input: map[string]string{}, FakeRunner: NewFakeRunner().
+		On("zfs load-key -L prompt tank/secret", "", nil).
+		On("zfs load-key -r tank", "", nil).
+		On("zfs unload-key -r tank", "", nil).
+		On("zfs change-key -o keyformat=hex -o keylocation=prompt tank/secret", "", nil).
+		On("zfs change-key -o keyformat=raw -o keylocation=file:///etc/zfs/key tank/other", "", nil).
+		On("zfs change-key -i tank/secret/child", "", nil),
+	}
+	z := New(WithRunner(runner))
+	ctx := context.TODO()
+
+	if err := z.LoadKey(ctx, "tank/secret", false, strings.NewReader("secret\n")); err != nil {
+		t.Fatal(err)
+	}
+	if runner.input["zfs load-key -L prompt tank/secret"] != "secret\n" {
+		t.Fatalf("unexpected input: %v", runner.input)
+	}
+	if err := z.LoadKey(ctx, "tank", true, nil); err != nil {
+		t.Fatal(err)
+	}
+	if err := z.UnloadKey(ctx, "tank", true); err != nil {
+		t.Fatal(err)
+	}
+
+	hex := strings.Repeat("ab", 32)
+	if err := z.ChangeKey(ctx, "tank/secret", EncryptionKey{Format: KeyFormatHex, Key: strings.NewReader(hex)}); err != nil {
+		t.Fatal(err)
+	}
+	if runner.input["zfs change-key -o keyformat=hex -o keylocation=prompt tank/secret"] != hex {
+		t.Fatalf("unexpected input: %v", runner.input)
+	}
+	if err := z.ChangeKey(ctx, "tank/other", EncryptionKey{Format: KeyFormatRaw, Location: "file:///etc/zfs/key"}); err != nil {
+		t.Fatal(err)
+	}
+	if err := z.InheritKey(ctx, "tank/secret/child"); err != nil {
+		t.Fatal(err)
+	}
+}
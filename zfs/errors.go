@@ -0,0 +1,144 @@
+// Copyright 2021 lack
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package zfs
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+var (
+	ErrNotInstalled         = errors.New("zfs is not installed")
+	ErrInvalidName          = errors.New("invalid name")
+	ErrPoolNotFound         = errors.New("pool does not exist")
+	ErrPoolExists           = errors.New("pool already exists")
+	ErrPoolBusy             = errors.New("pool is busy")
+	ErrDatasetNotFound      = errors.New("dataset does not exist")
+	ErrDatasetExists        = errors.New("dataset already exists")
+	ErrDatasetBusy          = errors.New("dataset is busy")
+	ErrHasChildren          = errors.New("dataset has children")
+	ErrHasClones            = errors.New("dataset has dependent clones")
+	ErrNoSpace              = errors.New("out of space")
+	ErrInsufficientReplicas = errors.New("insufficient replicas")
+	ErrDeviceNotFound       = errors.New("no such device in pool")
+	ErrDeviceInUse          = errors.New("device is in use")
+	ErrDeviceTooSmall       = errors.New("device is too small")
+	ErrPermissionDenied     = errors.New("permission denied")
+)
+
+// classifications maps the stderr messages of zfs and zpool to errors, ordered by priority
+var classifications = []struct {
+	message string
+	err     error
+}{
+	{"no such device in pool", ErrDeviceNotFound},
+	{"no such pool", ErrPoolNotFound},
+	{"dataset does not exist", ErrDatasetNotFound},
+	{"could not find any snapshots", ErrDatasetNotFound},
+	{"bookmark does not exist", ErrDatasetNotFound},
+	{"pool already exists", ErrPoolExists},
+	{"a pool with that name already exists", ErrPoolExists},
+	{"dataset already exists", ErrDatasetExists},
+	{"has dependent clones", ErrHasClones},
+	{"has children", ErrHasChildren},
+	{"pool is busy", ErrPoolBusy},
+	{"dataset is busy", ErrDatasetBusy},
+	{"target is busy", ErrDatasetBusy},
+	{"insufficient replicas", ErrInsufficientReplicas},
+	{"no valid replicas", ErrInsufficientReplicas},
+	{"out of space", ErrNoSpace},
+	{"no space left on device", ErrNoSpace},
+	{"is part of active pool", ErrDeviceInUse},
+	{"is in use", ErrDeviceInUse},
+	{"device is too small", ErrDeviceTooSmall},
+	{"permission denied", ErrPermissionDenied},
+}
+
+// CommandError is returned when zfs or zpool command fails, it could be
+// checked with errors.Is, e.g. errors.Is(err, ErrDatasetNotFound)
+type CommandError struct {
+	// Cmd the command line
+	Cmd string
+	// ExitCode the exit code of command, -1 if it's unknown
+	ExitCode int
+	Stdout   string
+	Stderr   string
+	// Err the error returned by Runner
+	Err error
+
+	kind error
+}
+
+func newCommandError(cmd *Cmd, stdout, stderr string, err error) *CommandError {
+	e := &CommandError{
+		Cmd:      cmd.String(),
+		ExitCode: exitCode(err),
+		Stdout:   stdout,
+		Stderr:   stderr,
+		Err:      err,
+	}
+	e.kind = classify(stderr)
+	return e
+}
+
+func (e *CommandError) Error() string {
+	if msg := strings.TrimSpace(e.Stderr); len(msg) > 0 {
+		return fmt.Sprintf("%s: %s", e.Cmd, msg)
+	}
+	return fmt.Sprintf("%s: %v", e.Cmd, e.Err)
+}
+
+func (e *CommandError) Unwrap() error {
+	return e.Err
+}
+
+// Is reports whether the failure of command is classified as target
+func (e *CommandError) Is(target error) bool {
+	return e.kind != nil && e.kind == target
+}
+
+func classify(stderr string) error {
+	msg := strings.ToLower(stderr)
+	for _, c := range classifications {
+		if strings.Contains(msg, c.message) {
+			return c.err
+		}
+	}
+	return nil
+}
+
+var exitStatusRegexp = regexp.MustCompile(`exit(?:ed with)? status (\d+)`)
+
+// exitCode extracts exit code from the error of Runner
+func exitCode(err error) int {
+	var ec interface{ ExitCode() int }
+	if errors.As(err, &ec) {
+		return ec.ExitCode()
+	}
+	var es interface{ ExitStatus() int }
+	if errors.As(err, &es) {
+		return es.ExitStatus()
+	}
+	// remote runner (e.g. rfs/ssh) reports exit status in text
+	if err != nil {
+		if m := exitStatusRegexp.FindStringSubmatch(err.Error()); len(m) == 2 {
+			code, _ := strconv.Atoi(m[1])
+			return code
+		}
+	}
+	return -1
+}
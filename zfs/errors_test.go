@@ -0,0 +1,85 @@
+// MIT License
+//
+// Copyright (c) 2021 Lack
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+// SOFTWARE.
+
+package zfs
+
+import (
+	"context"
+	"errors"
+	"testing"
+)
+
+func TestCommandError(t *testing.T) {
+	ctx := context.TODO()
+	cmd := &Cmd{Name: "sh", Args: []string{"-c", "echo out; echo \"cannot destroy 'tank/a': dataset is busy\" >&2; exit 3"}}
+	_, err := execution(ctx, NewLocalRunner(), cmd)
+
+	var e *CommandError
+	if !errors.As(err, &e) {
+		t.Fatalf("expect *CommandError, got %T", err)
+	}
+	if e.ExitCode != 3 {
+		t.Fatalf("expect exit code 3, got %d", e.ExitCode)
+	}
+	if e.Stdout != "out" {
+		t.Fatalf("unexpected stdout: %s", e.Stdout)
+	}
+	if !errors.Is(err, ErrDatasetBusy) || errors.Is(err, ErrDatasetNotFound) {
+		t.Fatalf("unexpected classification: %v", err)
+	}
+}
+
+func TestZFSadm_Errors(t *testing.T) {
+	exit := errors.New("Process exited with status 1")
+	runner := NewFakeRunner().
+		On("zfs get -Hp -o name all tank/none", "cannot open 'tank/none': dataset does not exist", exit).
+		On("zfs destroy -rRf tank/a", "cannot destroy 'tank/a': filesystem has children", exit).
+		On("zpool list -Hp -o name dup", "cannot open 'dup': no such pool", exit)
+
+	z := New(WithRunner(runner))
+	ctx := context.TODO()
+
+	_, err := z.GetFileSystem(ctx, "tank/none")
+	if !errors.Is(err, ErrDatasetNotFound) {
+		t.Fatalf("expect ErrDatasetNotFound, got %v", err)
+	}
+
+	err = z.DeleteFileSystem(ctx, "tank/a")
+	if !errors.Is(err, ErrHasChildren) {
+		t.Fatalf("expect ErrHasChildren, got %v", err)
+	}
+	var e *CommandError
+	if errors.As(err, &e) && e.ExitCode != 1 {
+		t.Fatalf("expect exit code 1, got %d", e.ExitCode)
+	}
+
+	_, err = z.GetPool(ctx, "dup")
+	if !errors.Is(err, ErrPoolNotFound) {
+		t.Fatalf("expect ErrPoolNotFound, got %v", err)
+	}
+
+	// the missing path is not a missing dataset
+	err = newCommandError(&Cmd{Name: "zpool", Args: []string{"labelclear", "/dev/sdx"}}, "", "failed to open /dev/sdx: '/dev/sdx' does not exist.", exit)
+	if errors.Is(err, ErrDatasetNotFound) {
+		t.Fatalf("unexpected classification: %v", err)
+	}
+}
@@ -0,0 +1,387 @@
+// MIT License
+//
+// Copyright (c) 2021 Lack
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+// SOFTWARE.
+
+package zfs
+
+import (
+	"bufio"
+	"context"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// EventKind the kind of Event
+type EventKind string
+
+const (
+	EventDeviceStateChange EventKind = "DeviceStateChange"
+	EventDeviceFaulted     EventKind = "DeviceFaulted"
+	EventDeviceRemoved     EventKind = "DeviceRemoved"
+	EventResilverStarted   EventKind = "ResilverStarted"
+	EventResilverFinished  EventKind = "ResilverFinished"
+	EventScrubStarted      EventKind = "ScrubStarted"
+	EventScrubFinished     EventKind = "ScrubFinished"
+	EventScrubAborted      EventKind = "ScrubAborted"
+	EventChecksumError     EventKind = "ChecksumError"
+	EventIOError           EventKind = "IOError"
+	EventDataError         EventKind = "DataError"
+	// EventPoolStateChange is emitted by polling 'zpool status', see WatchOptions.Poll
+	EventPoolStateChange EventKind = "PoolStateChange"
+	EventOther           EventKind = "Other"
+)
+
+// eventKinds maps the class of zpool events to EventKind
+var eventKinds = map[string]EventKind{
+	"resource.fs.zfs.statechange":     EventDeviceStateChange,
+	"resource.fs.zfs.removed":         EventDeviceRemoved,
+	"sysevent.fs.zfs.resilver_start":  EventResilverStarted,
+	"sysevent.fs.zfs.resilver_finish": EventResilverFinished,
+	"sysevent.fs.zfs.scrub_start":     EventScrubStarted,
+	"sysevent.fs.zfs.scrub_finish":    EventScrubFinished,
+	"sysevent.fs.zfs.scrub_abort":     EventScrubAborted,
+	"ereport.fs.zfs.checksum":         EventChecksumError,
+	"ereport.fs.zfs.io":               EventIOError,
+	"ereport.fs.zfs.data":             EventDataError,
+}
+
+// vdevStates the names of vdev_state_t
+var vdevStates = []string{"UNKNOWN", "CLOSED", "OFFLINE", "REMOVED", "UNAVAIL", "FAULTED", "DEGRADED", "ONLINE"}
+
+// Event the event of zpool
+type Event struct {
+	Time  time.Time `json:"time"`
+	Class string    `json:"class"`
+	Kind  EventKind `json:"kind"`
+	// Pool the name of pool
+	Pool string `json:"pool"`
+	// Vdev the path of vdev, it's empty if the event isn't about a device
+	Vdev string `json:"vdev,omitempty"`
+	// State the state of vdev, or the state of pool for EventPoolStateChange
+	State string `json:"state,omitempty"`
+	// Attributes the top-level attributes of event, e.g. "eid", "pool_guid", "vdev_guid"
+	Attributes map[string]string `json:"attributes,omitempty"`
+}
+
+// WatchOptions the options of WatchEvents
+type WatchOptions struct {
+	// Replay emits the events which are happened before watching too
+	Replay bool
+	// Poll polls 'zpool status' at the interval and emits EventPoolStateChange, 0 disables polling
+	Poll time.Duration
+}
+
+// WatchEvents follows 'zpool events -f -H -v' and emits typed events on the channel,
+// the channels are closed when ctx is done or the command exits. The error of the
+// command is sent to the error channel.
+func (z *ZFSadm) WatchEvents(ctx context.Context, opts WatchOptions) (<-chan *Event, <-chan error) {
+	ctx, cancel := context.WithCancel(ctx)
+	events := make(chan *Event, 64)
+	errs := make(chan error, 2)
+
+	emit := func(e *Event) bool {
+		select {
+		case events <- e:
+			return true
+		case <-ctx.Done():
+			return false
+		}
+	}
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		if err := z.followEvents(ctx, opts, emit); err != nil && ctx.Err() == nil {
+			errs <- err
+		}
+		cancel()
+	}()
+
+	polled := make(chan struct{})
+	go func() {
+		defer close(polled)
+		if opts.Poll <= 0 {
+			return
+		}
+		if err := z.pollPoolStates(ctx, opts.Poll, emit); err != nil && ctx.Err() == nil {
+			errs <- err
+			cancel()
+		}
+	}()
+
+	go func() {
+		<-done
+		<-polled
+		close(events)
+		close(errs)
+	}()
+
+	return events, errs
+}
+
+func (z *ZFSadm) followEvents(ctx context.Context, opts WatchOptions, emit func(*Event) bool) error {
+	var backlog eventBacklog
+	if !opts.Replay {
+		out, err := z.RawZPool().Event(ctx, "-H -v").Exec()
+		if err != nil {
+			return err
+		}
+		backlog = newEventBacklog(ParseEvents(string(out)))
+	}
+
+	execute := z.RawZPool().Event(ctx, "-f -H -v")
+
+	pr, pw := io.Pipe()
+	result := make(chan error, 1)
+	go func() {
+		stderr := &syncBuffer{}
+		cmd := &Cmd{Name: execute.name, Args: execute.args, Stdout: pw, Stderr: stderr}
+		err := execute.runner.Run(ctx, cmd)
+		if err != nil {
+			err = newCommandError(cmd, "", string(stderr.Bytes()), err)
+		}
+		_ = pw.Close()
+		result <- err
+	}()
+
+	parser := &eventParser{}
+	rd := bufio.NewReader(pr)
+	seen := 0
+	for {
+		line, err := rd.ReadString('\n')
+		completed := []*Event{parser.feed(line)}
+		if err != nil {
+			completed = append(completed, parser.flush())
+		}
+		for _, e := range completed {
+			if e == nil {
+				continue
+			}
+			seen++
+			if !opts.Replay && backlog.contains(e, seen) {
+				continue
+			}
+			if !emit(e) {
+				_ = pr.CloseWithError(ctx.Err())
+				return <-result
+			}
+		}
+		if err != nil {
+			break
+		}
+	}
+	return <-result
+}
+
+// eventBacklog the events which are happened before watching, 'zpool events -f' prints them
+// before the new ones. The events are identified by eid rather than the time, because the
+// time is printed in the zone of host which could be remote.
+type eventBacklog struct {
+	// eid the last eid of backlog, 0 if the events have no eid
+	eid uint64
+	// count the count of events in backlog
+	count int
+}
+
+func newEventBacklog(events []*Event) eventBacklog {
+	backlog := eventBacklog{count: len(events)}
+	for _, e := range events {
+		if eid, ok := e.EID(); ok && eid > backlog.eid {
+			backlog.eid = eid
+		}
+	}
+	return backlog
+}
+
+// contains reports whether the nth event (1-based) of 'zpool events -f' is in the backlog,
+// the position is used when the event has no eid
+func (b eventBacklog) contains(e *Event, nth int) bool {
+	if eid, ok := e.EID(); ok && b.eid > 0 {
+		return eid <= b.eid
+	}
+	return nth <= b.count
+}
+
+func (z *ZFSadm) pollPoolStates(ctx context.Context, interval time.Duration, emit func(*Event) bool) error {
+	states := map[string]string{}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		items, err := z.GetPoolStatuses(ctx)
+		if err != nil {
+			return err
+		}
+		for _, item := range items {
+			previous, ok := states[item.Name]
+			states[item.Name] = item.State
+			if !ok || previous == item.State {
+				continue
+			}
+			e := &Event{
+				Time:       time.Now(),
+				Kind:       EventPoolStateChange,
+				Pool:       item.Name,
+				State:      item.State,
+				Attributes: map[string]string{"previous": previous},
+			}
+			if !emit(e) {
+				return nil
+			}
+		}
+
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-ticker.C:
+		}
+	}
+}
+
+// EID returns the event id which increases monotonically on the host, false if it's unknown
+func (e *Event) EID() (uint64, bool) {
+	eid, ok := e.Attributes["eid"]
+	if !ok {
+		return 0, false
+	}
+	n, err := strconv.ParseUint(strings.TrimPrefix(eid, "0x"), 16, 64)
+	if err != nil || !strings.HasPrefix(eid, "0x") {
+		return 0, false
+	}
+	return n, true
+}
+
+// ParseEvents parses the output of 'zpool events -H -v'
+func ParseEvents(data string) []*Event {
+	events := make([]*Event, 0)
+	parser := &eventParser{}
+	for _, line := range strings.Split(data, "\n") {
+		if e := parser.feed(line); e != nil {
+			events = append(events, e)
+		}
+	}
+	if e := parser.flush(); e != nil {
+		events = append(events, e)
+	}
+	return events
+}
+
+// eventParser parses the events line by line, an event starts with "<time>\t<class>",
+// its attributes are indented and it ends with a blank line:
+//
+//	Jun  6 2021 10:00:00.123456789	ereport.fs.zfs.checksum
+//	        class = "ereport.fs.zfs.checksum"
+//	        pool = "tank"
+//	        vdev_path = "/dev/sdb1"
+type eventParser struct {
+	current *Event
+	// depth the depth of embedded nvlist, only the top-level attributes are kept
+	depth int
+}
+
+// feed parses a line, returns the completed event if there is one
+func (p *eventParser) feed(line string) *Event {
+	line = strings.TrimRight(line, "\r\n")
+	if len(strings.TrimSpace(line)) == 0 {
+		return p.flush()
+	}
+
+	if line[0] != ' ' && line[0] != '\t' {
+		completed := p.flush()
+		parts := strings.SplitN(line, "\t", 2)
+		if len(parts) != 2 {
+			parts = []string{"", strings.TrimSpace(line)}
+		}
+		t, _ := time.ParseInLocation("Jan _2 2006 15:04:05.000000000", strings.TrimSpace(parts[0]), time.Local)
+		p.current = &Event{Time: t, Class: strings.TrimSpace(parts[1]), Attributes: map[string]string{}}
+		return completed
+	}
+
+	if p.current == nil {
+		return nil
+	}
+	text := strings.TrimSpace(line)
+	if strings.HasPrefix(text, "(end ") {
+		p.depth--
+		return nil
+	}
+	kv := strings.SplitN(text, " = ", 2)
+	if len(kv) != 2 {
+		return nil
+	}
+	if kv[1] == "(embedded nvlist)" || strings.HasPrefix(kv[1], "(array of embedded nvlists)") {
+		p.depth++
+		return nil
+	}
+	if p.depth == 0 {
+		p.current.Attributes[kv[0]] = eventValue(kv[1])
+	}
+	return nil
+}
+
+// flush completes the current event
+func (p *eventParser) flush() *Event {
+	e := p.current
+	p.current, p.depth = nil, 0
+	if e == nil {
+		return nil
+	}
+
+	e.Pool = e.Attributes["pool"]
+	e.Vdev = e.Attributes["vdev_path"]
+	if state, ok := e.Attributes["vdev_state"]; ok {
+		e.State = vdevStateName(state)
+	}
+
+	e.Kind = eventKinds[e.Class]
+	switch {
+	case len(e.Kind) == 0:
+		e.Kind = EventOther
+	case e.Kind == EventDeviceStateChange && (e.State == "FAULTED" || e.State == "UNAVAIL"):
+		e.Kind = EventDeviceFaulted
+	case e.Kind == EventDeviceStateChange && e.State == "REMOVED":
+		e.Kind = EventDeviceRemoved
+	}
+	return e
+}
+
+// eventValue returns the value of attribute, e.g. `"tank"` -> tank, `"ONLINE" (0x7)` -> ONLINE
+func eventValue(s string) string {
+	if strings.HasPrefix(s, `"`) {
+		if i := strings.Index(s[1:], `"`); i >= 0 {
+			return s[1 : i+1]
+		}
+	}
+	return s
+}
+
+// vdevStateName returns the name of vdev state, the old releases print it as number, e.g. 0x5
+func vdevStateName(s string) string {
+	n, err := strconv.ParseUint(strings.TrimPrefix(s, "0x"), 16, 64)
+	if err != nil || !strings.HasPrefix(s, "0x") {
+		return s
+	}
+	if int(n) < len(vdevStates) {
+		return vdevStates[n]
+	}
+	return s
+}
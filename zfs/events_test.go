@@ -0,0 +1,143 @@
+// MIT License
+//
+// Copyright (c) 2021 Lack
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+// SOFTWARE.
+
+package zfs
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+const zpoolEvents = `Jun  6 2021 10:00:00.123456789	sysevent.fs.zfs.scrub_start
+        version = 0x0
+        class = "sysevent.fs.zfs.scrub_start"
+        pool = "tank"
+        pool_guid = 0x1234
+        eid = 0x1
+
+Jun  6 2021 10:01:00.000000000	ereport.fs.zfs.checksum
+        class = "ereport.fs.zfs.checksum"
+        detector = (embedded nvlist)
+                version = 0x0
+                pool = 0x1234
+        (end detector)
+        pool = "tank"
+        vdev_path = "/dev/sdb1"
+        eid = 0x2
+
+Jun  6 2021 10:02:00.000000000	resource.fs.zfs.statechange
+        class = "resource.fs.zfs.statechange"
+        pool = "tank"
+        vdev_path = "/dev/sdb1"
+        vdev_state = "FAULTED" (0x5)
+
+Jun  6 2021 10:03:00.000000000	resource.fs.zfs.statechange
+        pool = "tank"
+        vdev_path = "/dev/sdc1"
+        vdev_state = 0x3
+`
+
+func TestParseEvents(t *testing.T) {
+	events := ParseEvents(zpoolEvents)
+	if len(events) != 4 {
+		t.Fatalf("expect 4 events, got %d", len(events))
+	}
+
+	kinds := []EventKind{EventScrubStarted, EventChecksumError, EventDeviceFaulted, EventDeviceRemoved}
+	for i, kind := range kinds {
+		if events[i].Kind != kind || events[i].Pool != "tank" {
+			t.Fatalf("unexpected event %d: %+v", i, events[i])
+		}
+	}
+	if e := events[1]; e.Vdev != "/dev/sdb1" || e.Attributes["pool"] != "tank" || e.Attributes["eid"] != "0x2" {
+		t.Fatalf("unexpected checksum event: %+v", e)
+	}
+	if e := events[2]; e.State != "FAULTED" {
+		t.Fatalf("unexpected state: %s", e.State)
+	}
+	if e := events[0]; e.Time.Year() != 2021 || e.Time.Minute() != 0 {
+		t.Fatalf("unexpected time: %v", e.Time)
+	}
+}
+
+func TestZFSadm_WatchEvents(t *testing.T) {
+	runner := NewFakeRunner().
+		On("zpool events -f -H -v", zpoolEvents, nil).
+		On("zpool status -p", "  pool: tank\n state: ONLINE\n", nil)
+
+	z := New(WithRunner(runner))
+	ctx, cancel := context.WithTimeout(context.TODO(), time.Second*5)
+	defer cancel()
+
+	events, errs := z.WatchEvents(ctx, WatchOptions{Replay: true, Poll: time.Millisecond * 10})
+	received := make([]*Event, 0)
+	for e := range events {
+		received = append(received, e)
+	}
+	if err := <-errs; err != nil {
+		t.Fatal(err)
+	}
+	if len(received) != 4 {
+		t.Fatalf("expect 4 events, got %d", len(received))
+	}
+}
+
+func TestZFSadm_WatchEventsSkipHistory(t *testing.T) {
+	// the new event has an old time, e.g. the host is in another zone
+	newEvent := `
+Jun  6 2001 10:04:00.000000000	sysevent.fs.zfs.scrub_finish
+        class = "sysevent.fs.zfs.scrub_finish"
+        pool = "tank"
+        eid = 0x3
+`
+	runner := NewFakeRunner().
+		On("zpool events -H -v", zpoolEvents, nil).
+		On("zpool events -f -H -v", zpoolEvents+newEvent, nil)
+
+	z := New(WithRunner(runner))
+	events, errs := z.WatchEvents(context.TODO(), WatchOptions{})
+	received := make([]*Event, 0)
+	for e := range events {
+		received = append(received, e)
+	}
+	if err := <-errs; err != nil {
+		t.Fatal(err)
+	}
+	if len(received) != 1 || received[0].Kind != EventScrubFinished {
+		t.Fatalf("expect the new event only, got %+v", received)
+	}
+
+	// nothing is skipped if there is no backlog
+	runner.On("zpool events -H -v", "", nil)
+	events, errs = z.WatchEvents(context.TODO(), WatchOptions{})
+	received = received[:0]
+	for e := range events {
+		received = append(received, e)
+	}
+	if err := <-errs; err != nil {
+		t.Fatal(err)
+	}
+	if len(received) != 5 {
+		t.Fatalf("expect 5 events, got %d", len(received))
+	}
+}
@@ -0,0 +1,405 @@
+// MIT License
+//
+// Copyright (c) 2021 Lack
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+// SOFTWARE.
+
+// Package exporter exports the metrics of zfs pools and datasets to prometheus, e.g.
+//
+//	prometheus.MustRegister(exporter.New(zfs.New(), exporter.WithMaxDatasets(200)))
+package exporter
+
+import (
+	"context"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/vine-io/pkg/zfs"
+)
+
+// poolStates the states of pool, zfs_pool_health reports 1 for the current state and 0 for the others
+var poolStates = []string{"ONLINE", "DEGRADED", "FAULTED", "OFFLINE", "UNAVAIL", "REMOVED", "SUSPENDED"}
+
+// datasetProperties the properties fetched for the metrics of datasets
+var datasetProperties = []string{"type", "used", "available", "referenced", "compressratio"}
+
+var _ prometheus.Collector = (*Collector)(nil)
+
+// Collector implements prometheus.Collector on top of ZFSadm, every scrape runs zpool and zfs
+// commands by the Runner of ZFSadm.
+type Collector struct {
+	z       *zfs.ZFSadm
+	options Options
+	// now returns the current time, it's replaced by tests
+	now func() time.Time
+
+	poolSize          *prometheus.Desc
+	poolFree          *prometheus.Desc
+	poolAllocated     *prometheus.Desc
+	poolFragmentation *prometheus.Desc
+	poolDedupRatio    *prometheus.Desc
+	poolHealth        *prometheus.Desc
+
+	scrubActive   *prometheus.Desc
+	scrubProgress *prometheus.Desc
+	scrubErrors   *prometheus.Desc
+	scrubEnd      *prometheus.Desc
+
+	datasetUsed          *prometheus.Desc
+	datasetAvailable     *prometheus.Desc
+	datasetReferenced    *prometheus.Desc
+	datasetCompressRatio *prometheus.Desc
+	datasetsDropped      *prometheus.Desc
+
+	snapshots          *prometheus.Desc
+	snapshotOldestAge  *prometheus.Desc
+	snapshotNewestAge  *prometheus.Desc
+	snapshotsUsedBytes *prometheus.Desc
+
+	arcSize       *prometheus.Desc
+	arcTargetSize *prometheus.Desc
+	arcMinSize    *prometheus.Desc
+	arcMaxSize    *prometheus.Desc
+	arcHits       *prometheus.Desc
+	arcMisses     *prometheus.Desc
+	l2arcSize     *prometheus.Desc
+	l2arcHits     *prometheus.Desc
+	l2arcMisses   *prometheus.Desc
+
+	scrapeSuccess  *prometheus.Desc
+	scrapeDuration *prometheus.Desc
+}
+
+// New creates Collector by the given options
+func New(z *zfs.ZFSadm, opts ...Option) *Collector {
+	options := newOptions(opts...)
+	desc := func(subsystem, name, help string, labels ...string) *prometheus.Desc {
+		return prometheus.NewDesc(prometheus.BuildFQName(options.namespace, subsystem, name), help, labels, nil)
+	}
+
+	return &Collector{
+		z:       z,
+		options: options,
+		now:     time.Now,
+
+		poolSize:          desc("pool", "size_bytes", "The total size of pool.", "pool"),
+		poolFree:          desc("pool", "free_bytes", "The free space of pool.", "pool"),
+		poolAllocated:     desc("pool", "allocated_bytes", "The allocated space of pool.", "pool"),
+		poolFragmentation: desc("pool", "fragmentation_ratio", "The fragmentation of free space of pool, from 0 to 1.", "pool"),
+		poolDedupRatio:    desc("pool", "dedup_ratio", "The deduplication ratio of pool.", "pool"),
+		poolHealth:        desc("pool", "health", "The health of pool, 1 for the current state.", "pool", "state"),
+
+		scrubActive:   desc("pool", "scan_active", "Whether a scrub or resilver is in progress.", "pool", "function"),
+		scrubProgress: desc("pool", "scan_progress_ratio", "The progress of the current or last scan, from 0 to 1.", "pool", "function"),
+		scrubErrors:   desc("pool", "scan_errors", "The errors found by the last finished scan.", "pool", "function"),
+		scrubEnd:      desc("pool", "scan_end_timestamp_seconds", "The time when the last scan finished or was canceled.", "pool", "function"),
+
+		datasetUsed:          desc("dataset", "used_bytes", "The space used by dataset and its descendents.", "pool", "dataset", "type"),
+		datasetAvailable:     desc("dataset", "available_bytes", "The space available to dataset.", "pool", "dataset", "type"),
+		datasetReferenced:    desc("dataset", "referenced_bytes", "The space referenced by dataset.", "pool", "dataset", "type"),
+		datasetCompressRatio: desc("dataset", "compress_ratio", "The compression ratio of dataset.", "pool", "dataset", "type"),
+		datasetsDropped:      desc("exporter", "datasets_dropped", "The datasets skipped by the cap of cardinality."),
+
+		snapshots:          desc("dataset", "snapshots", "The count of snapshots of dataset.", "pool", "dataset"),
+		snapshotOldestAge:  desc("dataset", "oldest_snapshot_age_seconds", "The age of the oldest snapshot of dataset.", "pool", "dataset"),
+		snapshotNewestAge:  desc("dataset", "newest_snapshot_age_seconds", "The age of the newest snapshot of dataset.", "pool", "dataset"),
+		snapshotsUsedBytes: desc("dataset", "snapshots_used_bytes", "The space used by the snapshots of dataset.", "pool", "dataset"),
+
+		arcSize:       desc("arc", "size_bytes", "The current size of ARC."),
+		arcTargetSize: desc("arc", "target_size_bytes", "The target size of ARC."),
+		arcMinSize:    desc("arc", "min_size_bytes", "The minimum size of ARC."),
+		arcMaxSize:    desc("arc", "max_size_bytes", "The maximum size of ARC."),
+		arcHits:       desc("arc", "hits_total", "The hits of ARC."),
+		arcMisses:     desc("arc", "misses_total", "The misses of ARC."),
+		l2arcSize:     desc("l2arc", "size_bytes", "The size of L2ARC."),
+		l2arcHits:     desc("l2arc", "hits_total", "The hits of L2ARC."),
+		l2arcMisses:   desc("l2arc", "misses_total", "The misses of L2ARC."),
+
+		scrapeSuccess:  desc("exporter", "scrape_success", "Whether the collector succeeded.", "collector"),
+		scrapeDuration: desc("exporter", "scrape_duration_seconds", "The duration of the collector.", "collector"),
+	}
+}
+
+// Describe implements prometheus.Collector
+func (c *Collector) Describe(ch chan<- *prometheus.Desc) {
+	for _, d := range []*prometheus.Desc{
+		c.poolSize, c.poolFree, c.poolAllocated, c.poolFragmentation, c.poolDedupRatio, c.poolHealth,
+		c.scrubActive, c.scrubProgress, c.scrubErrors, c.scrubEnd,
+		c.datasetUsed, c.datasetAvailable, c.datasetReferenced, c.datasetCompressRatio, c.datasetsDropped,
+		c.snapshots, c.snapshotOldestAge, c.snapshotNewestAge, c.snapshotsUsedBytes,
+		c.arcSize, c.arcTargetSize, c.arcMinSize, c.arcMaxSize, c.arcHits, c.arcMisses,
+		c.l2arcSize, c.l2arcHits, c.l2arcMisses,
+		c.scrapeSuccess, c.scrapeDuration,
+	} {
+		ch <- d
+	}
+}
+
+// Collect implements prometheus.Collector, a failed collector is reported by scrape_success
+// and doesn't stop the others.
+func (c *Collector) Collect(ch chan<- prometheus.Metric) {
+	ctx, cancel := context.WithTimeout(context.Background(), c.options.timeout)
+	defer cancel()
+
+	collectors := []struct {
+		name    string
+		enabled bool
+		fn      func(context.Context, chan<- prometheus.Metric) error
+	}{
+		{name: "pool", enabled: true, fn: c.collectPools},
+		{name: "scan", enabled: true, fn: c.collectScans},
+		{name: "dataset", enabled: true, fn: c.collectDatasets},
+		{name: "arc", enabled: c.options.arc, fn: c.collectARC},
+	}
+	for _, collector := range collectors {
+		if !collector.enabled {
+			continue
+		}
+		start := time.Now()
+		err := collector.fn(ctx, ch)
+		success := 1.0
+		if err != nil {
+			success = 0
+		}
+		ch <- prometheus.MustNewConstMetric(c.scrapeSuccess, prometheus.GaugeValue, success, collector.name)
+		ch <- prometheus.MustNewConstMetric(c.scrapeDuration, prometheus.GaugeValue, time.Since(start).Seconds(), collector.name)
+	}
+}
+
+func (c *Collector) collectPools(ctx context.Context, ch chan<- prometheus.Metric) error {
+	pools, err := c.z.GetPools(ctx)
+	if err != nil {
+		return err
+	}
+	for name, pool := range pools {
+		if !c.poolEnabled(name) {
+			continue
+		}
+		gauge := func(desc *prometheus.Desc, value float64, labels ...string) {
+			ch <- prometheus.MustNewConstMetric(desc, prometheus.GaugeValue, value, append([]string{name}, labels...)...)
+		}
+		gauge(c.poolSize, float64(pool.SizeBytes))
+		gauge(c.poolFree, float64(pool.FreeBytes))
+		gauge(c.poolAllocated, float64(pool.AllocatedBytes))
+		if fragmentation, err := strconv.ParseFloat(strings.TrimSuffix(pool.Fragmentation, "%"), 64); err == nil {
+			gauge(c.poolFragmentation, fragmentation/100)
+		}
+		gauge(c.poolDedupRatio, pool.DedupRatioValue)
+		for _, state := range poolStates {
+			value := 0.0
+			if pool.Health == state {
+				value = 1
+			}
+			gauge(c.poolHealth, value, state)
+		}
+	}
+	return nil
+}
+
+func (c *Collector) collectScans(ctx context.Context, ch chan<- prometheus.Metric) error {
+	statuses, err := c.z.GetPoolStatuses(ctx)
+	if err != nil {
+		return err
+	}
+	for _, status := range statuses {
+		if !c.poolEnabled(status.Name) || status.Scan == nil || status.Scan.State == zfs.ScanNone {
+			continue
+		}
+		scan := status.Scan
+		gauge := func(desc *prometheus.Desc, value float64) {
+			ch <- prometheus.MustNewConstMetric(desc, prometheus.GaugeValue, value, status.Name, string(scan.Function))
+		}
+		active := 0.0
+		if scan.State == zfs.ScanScanning || scan.State == zfs.ScanPaused {
+			active = 1
+		}
+		gauge(c.scrubActive, active)
+		progress := scan.Percent / 100
+		if scan.State == zfs.ScanFinished {
+			progress = 1
+		}
+		gauge(c.scrubProgress, progress)
+		if scan.State == zfs.ScanFinished {
+			gauge(c.scrubErrors, float64(scan.Errors))
+		}
+		if !scan.End.IsZero() {
+			gauge(c.scrubEnd, float64(scan.End.Unix()))
+		}
+	}
+	return nil
+}
+
+func (c *Collector) collectDatasets(ctx context.Context, ch chan<- prometheus.Metric) error {
+	datasets := make(map[string]*zfs.Volume)
+	roots := c.options.pools
+	if len(roots) == 0 {
+		// all pools by a single 'zfs get'
+		roots = []string{""}
+	}
+	for _, root := range roots {
+		opts := zfs.ListOptions{Root: root, Properties: datasetProperties}
+		filesystems, err := c.z.ListFileSystems(ctx, opts)
+		if err != nil {
+			return err
+		}
+		volumes, err := c.z.ListVolumes(ctx, opts)
+		if err != nil {
+			return err
+		}
+		for _, items := range []map[string]*zfs.Volume{filesystems, volumes} {
+			for name, item := range items {
+				if c.datasetEnabled(name) {
+					datasets[name] = item
+				}
+			}
+		}
+	}
+
+	names := make([]string, 0, len(datasets))
+	for name := range datasets {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	dropped := 0
+	if max := c.options.maxDatasets; max > 0 && len(names) > max {
+		dropped = len(names) - max
+		names = names[:max]
+	}
+	ch <- prometheus.MustNewConstMetric(c.datasetsDropped, prometheus.GaugeValue, float64(dropped))
+
+	for _, name := range names {
+		dataset := datasets[name]
+		labels := []string{poolOf(name), name, dataset.Type}
+		ch <- prometheus.MustNewConstMetric(c.datasetUsed, prometheus.GaugeValue, float64(dataset.UsedBytes), labels...)
+		ch <- prometheus.MustNewConstMetric(c.datasetAvailable, prometheus.GaugeValue, float64(dataset.AvailableBytes), labels...)
+		ch <- prometheus.MustNewConstMetric(c.datasetReferenced, prometheus.GaugeValue, float64(dataset.ReferencedBytes), labels...)
+		ch <- prometheus.MustNewConstMetric(c.datasetCompressRatio, prometheus.GaugeValue, dataset.CompressRatioValue, labels...)
+	}
+
+	if !c.options.snapshots {
+		return nil
+	}
+	return c.collectSnapshots(ctx, ch, names)
+}
+
+// collectSnapshots exports the summary of snapshots of the given datasets, a dataset without snapshot
+// reports the count of 0 only.
+func (c *Collector) collectSnapshots(ctx context.Context, ch chan<- prometheus.Metric, datasets []string) error {
+	type summary struct {
+		count          int
+		used           uint64
+		oldest, newest time.Time
+	}
+	summaries := make(map[string]*summary, len(datasets))
+	for _, name := range datasets {
+		summaries[name] = &summary{}
+	}
+
+	roots := c.options.pools
+	if len(roots) == 0 {
+		roots = []string{""}
+	}
+	for _, root := range roots {
+		snapshots, err := c.z.ListSnapshots(ctx, zfs.ListOptions{Root: root, Properties: []string{"creation", "used"}})
+		if err != nil {
+			return err
+		}
+		for _, snapshot := range snapshots {
+			s, ok := summaries[snapshot.Parent]
+			if !ok {
+				continue
+			}
+			s.count += 1
+			s.used += snapshot.UsedBytes
+			if s.oldest.IsZero() || snapshot.CreationTime.Before(s.oldest) {
+				s.oldest = snapshot.CreationTime
+			}
+			if snapshot.CreationTime.After(s.newest) {
+				s.newest = snapshot.CreationTime
+			}
+		}
+	}
+
+	now := c.now()
+	for _, name := range datasets {
+		s := summaries[name]
+		labels := []string{poolOf(name), name}
+		ch <- prometheus.MustNewConstMetric(c.snapshots, prometheus.GaugeValue, float64(s.count), labels...)
+		if s.count == 0 {
+			continue
+		}
+		ch <- prometheus.MustNewConstMetric(c.snapshotsUsedBytes, prometheus.GaugeValue, float64(s.used), labels...)
+		ch <- prometheus.MustNewConstMetric(c.snapshotOldestAge, prometheus.GaugeValue, now.Sub(s.oldest).Seconds(), labels...)
+		ch <- prometheus.MustNewConstMetric(c.snapshotNewestAge, prometheus.GaugeValue, now.Sub(s.newest).Seconds(), labels...)
+	}
+	return nil
+}
+
+func (c *Collector) collectARC(ctx context.Context, ch chan<- prometheus.Metric) error {
+	stats, err := c.z.GetARCStats(ctx)
+	if err != nil {
+		return err
+	}
+	ch <- prometheus.MustNewConstMetric(c.arcSize, prometheus.GaugeValue, float64(stats.Size))
+	ch <- prometheus.MustNewConstMetric(c.arcTargetSize, prometheus.GaugeValue, float64(stats.TargetSize))
+	ch <- prometheus.MustNewConstMetric(c.arcMinSize, prometheus.GaugeValue, float64(stats.MinSize))
+	ch <- prometheus.MustNewConstMetric(c.arcMaxSize, prometheus.GaugeValue, float64(stats.MaxSize))
+	ch <- prometheus.MustNewConstMetric(c.arcHits, prometheus.CounterValue, float64(stats.Hits))
+	ch <- prometheus.MustNewConstMetric(c.arcMisses, prometheus.CounterValue, float64(stats.Misses))
+	ch <- prometheus.MustNewConstMetric(c.l2arcSize, prometheus.GaugeValue, float64(stats.L2Size))
+	ch <- prometheus.MustNewConstMetric(c.l2arcHits, prometheus.CounterValue, float64(stats.L2Hits))
+	ch <- prometheus.MustNewConstMetric(c.l2arcMisses, prometheus.CounterValue, float64(stats.L2Misses))
+	return nil
+}
+
+func (c *Collector) poolEnabled(name string) bool {
+	if len(c.options.pools) == 0 {
+		return true
+	}
+	for _, pool := range c.options.pools {
+		if pool == name {
+			return true
+		}
+	}
+	return false
+}
+
+func (c *Collector) datasetEnabled(name string) bool {
+	for _, re := range c.options.exclude {
+		if re.MatchString(name) {
+			return false
+		}
+	}
+	if len(c.options.include) == 0 {
+		return true
+	}
+	for _, re := range c.options.include {
+		if re.MatchString(name) {
+			return true
+		}
+	}
+	return false
+}
+
+func poolOf(dataset string) string {
+	return strings.SplitN(dataset, "/", 2)[0]
+}
@@ -0,0 +1,202 @@
+// MIT License
+//
+// Copyright (c) 2021 Lack
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+// SOFTWARE.
+
+package exporter
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/vine-io/pkg/zfs"
+)
+
+const arcstats = `13 1 0x01 123 33456 5214870658 46917549814939
+name                            type data
+hits                            4    900
+misses                          4    100
+c                               4    2147483648
+c_min                           4    33554432
+c_max                           4    4294967296
+size                            4    1073741824
+l2_hits                         4    0
+l2_misses                       4    0
+l2_size                         4    0
+`
+
+// kstatRunner serves arcstats and delegates the others to simulator
+type kstatRunner struct {
+	*zfs.Simulator
+}
+
+func (r *kstatRunner) Run(ctx context.Context, cmd *zfs.Cmd) error {
+	if cmd.Name == "cat" {
+		_, err := cmd.Stdout.Write([]byte(arcstats))
+		return err
+	}
+	return r.Simulator.Run(ctx, cmd)
+}
+
+func newTestCollector(t *testing.T, opts ...Option) (*zfs.Simulator, *Collector) {
+	now := time.Date(2021, 6, 6, 10, 0, 0, 0, time.Local)
+	sim := zfs.NewSimulator()
+	sim.Now = func() time.Time { return now }
+	z := zfs.New(zfs.WithRunner(&kstatRunner{Simulator: sim}))
+	ctx := context.TODO()
+	if _, err := z.CreatePool(ctx, &zfs.PoolSpec{Name: "tank", Data: []zfs.VdevSpec{{Type: zfs.GroupMirror, Devices: []string{"sda", "sdb"}}}}); err != nil {
+		t.Fatal(err)
+	}
+	for _, name := range []string{"tank/a", "tank/b", "tank/tmp"} {
+		if _, err := z.CreateFileSystem(ctx, name, nil); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if _, err := z.CreateSnapshot(ctx, "tank/a", "tank/a@s1", nil); err != nil {
+		t.Fatal(err)
+	}
+	now = now.Add(time.Hour)
+	if _, err := z.CreateSnapshot(ctx, "tank/a", "tank/a@s2", nil); err != nil {
+		t.Fatal(err)
+	}
+	if err := z.StartScrub(ctx, "tank"); err != nil {
+		t.Fatal(err)
+	}
+	now = now.Add(time.Hour)
+
+	c := New(z, opts...)
+	c.now = sim.Now
+	return sim, c
+}
+
+// gather returns the values of metrics by 'name{label="value",...}'
+func gather(t *testing.T, c prometheus.Collector) map[string]float64 {
+	registry := prometheus.NewRegistry()
+	registry.MustRegister(c)
+	families, err := registry.Gather()
+	if err != nil {
+		t.Fatal(err)
+	}
+	values := make(map[string]float64)
+	for _, family := range families {
+		for _, m := range family.GetMetric() {
+			labels := make([]string, 0)
+			for _, label := range m.GetLabel() {
+				labels = append(labels, fmt.Sprintf("%s=%q", label.GetName(), label.GetValue()))
+			}
+			sort.Strings(labels)
+			value := m.GetGauge().GetValue()
+			if m.GetCounter() != nil {
+				value = m.GetCounter().GetValue()
+			}
+			values[family.GetName()+"{"+strings.Join(labels, ",")+"}"] = value
+		}
+	}
+	return values
+}
+
+func TestCollector(t *testing.T) {
+	_, c := newTestCollector(t)
+	values := gather(t, c)
+
+	expect := map[string]float64{
+		`zfs_pool_size_bytes{pool="tank"}`:                                           10 << 30,
+		`zfs_pool_health{pool="tank",state="ONLINE"}`:                                1,
+		`zfs_pool_health{pool="tank",state="DEGRADED"}`:                              0,
+		`zfs_pool_fragmentation_ratio{pool="tank"}`:                                  0,
+		`zfs_pool_scan_active{function="scrub",pool="tank"}`:                         0,
+		`zfs_pool_scan_progress_ratio{function="scrub",pool="tank"}`:                 1,
+		`zfs_pool_scan_errors{function="scrub",pool="tank"}`:                         0,
+		`zfs_dataset_compress_ratio{dataset="tank/a",pool="tank",type="filesystem"}`: 1,
+		`zfs_dataset_snapshots{dataset="tank/a",pool="tank"}`:                        2,
+		`zfs_dataset_snapshots{dataset="tank/b",pool="tank"}`:                        0,
+		`zfs_dataset_oldest_snapshot_age_seconds{dataset="tank/a",pool="tank"}`:      7200,
+		`zfs_dataset_newest_snapshot_age_seconds{dataset="tank/a",pool="tank"}`:      3600,
+		`zfs_exporter_datasets_dropped{}`:                                            0,
+		`zfs_arc_size_bytes{}`:                                                       1 << 30,
+		`zfs_arc_hits_total{}`:                                                       900,
+		`zfs_exporter_scrape_success{collector="pool"}`:                              1,
+		`zfs_exporter_scrape_success{collector="dataset"}`:                           1,
+		`zfs_exporter_scrape_success{collector="arc"}`:                               1,
+	}
+	for name, value := range expect {
+		got, ok := values[name]
+		if !ok {
+			t.Errorf("missing metric %s", name)
+			continue
+		}
+		if got != value {
+			t.Errorf("expect %s %v, got %v", name, value, got)
+		}
+	}
+	if _, ok := values[`zfs_dataset_newest_snapshot_age_seconds{dataset="tank/b",pool="tank"}`]; ok {
+		t.Error("unexpected age of dataset without snapshot")
+	}
+	if values[`zfs_dataset_used_bytes{dataset="tank",pool="tank",type="filesystem"}`] == 0 {
+		t.Error("expect used bytes of tank")
+	}
+}
+
+func TestCollector_Filters(t *testing.T) {
+	_, c := newTestCollector(t,
+		WithExclude(regexp.MustCompile(`/tmp$`)),
+		WithMaxDatasets(2),
+		WithSnapshots(false),
+		WithARC(false))
+	values := gather(t, c)
+
+	datasets := make([]string, 0)
+	for name := range values {
+		if strings.HasPrefix(name, "zfs_dataset_used_bytes") {
+			datasets = append(datasets, name)
+		}
+		if strings.HasPrefix(name, "zfs_dataset_snapshots") || strings.HasPrefix(name, "zfs_arc_") {
+			t.Errorf("unexpected metric %s", name)
+		}
+	}
+	sort.Strings(datasets)
+	expect := []string{
+		`zfs_dataset_used_bytes{dataset="tank",pool="tank",type="filesystem"}`,
+		`zfs_dataset_used_bytes{dataset="tank/a",pool="tank",type="filesystem"}`,
+	}
+	if strings.Join(datasets, ",") != strings.Join(expect, ",") {
+		t.Fatalf("expect %v, got %v", expect, datasets)
+	}
+	if dropped := values[`zfs_exporter_datasets_dropped{}`]; dropped != 1 {
+		t.Fatalf("expect 1 dataset dropped, got %v", dropped)
+	}
+}
+
+func TestCollector_Failure(t *testing.T) {
+	runner := zfs.NewFakeRunner()
+	c := New(zfs.New(zfs.WithRunner(runner)), WithPools("tank"))
+	values := gather(t, c)
+	for _, name := range []string{"pool", "scan", "dataset", "arc"} {
+		if success := values[fmt.Sprintf(`zfs_exporter_scrape_success{collector=%q}`, name)]; success != 0 {
+			t.Errorf("expect collector %s failed", name)
+		}
+	}
+}
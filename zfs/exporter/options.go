@@ -0,0 +1,114 @@
+// MIT License
+//
+// Copyright (c) 2021 Lack
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+// SOFTWARE.
+
+package exporter
+
+import (
+	"regexp"
+	"time"
+)
+
+type Options struct {
+	namespace   string
+	pools       []string
+	include     []*regexp.Regexp
+	exclude     []*regexp.Regexp
+	maxDatasets int
+	snapshots   bool
+	arc         bool
+	timeout     time.Duration
+}
+
+func newOptions(opts ...Option) Options {
+	options := Options{
+		namespace:   "zfs",
+		maxDatasets: 1000,
+		snapshots:   true,
+		arc:         true,
+		timeout:     time.Second * 30,
+	}
+	for _, o := range opts {
+		o(&options)
+	}
+	return options
+}
+
+type Option func(*Options)
+
+// WithNamespace sets the namespace of metrics, defaults to "zfs"
+func WithNamespace(namespace string) Option {
+	return func(o *Options) {
+		o.namespace = namespace
+	}
+}
+
+// WithPools limits the metrics to the given pools and their datasets, all pools are exported by default
+func WithPools(pools ...string) Option {
+	return func(o *Options) {
+		o.pools = append(o.pools, pools...)
+	}
+}
+
+// WithInclude exports the datasets whose names match any of the patterns only
+func WithInclude(patterns ...*regexp.Regexp) Option {
+	return func(o *Options) {
+		o.include = append(o.include, patterns...)
+	}
+}
+
+// WithExclude skips the datasets whose names match any of the patterns, it takes precedence over WithInclude
+func WithExclude(patterns ...*regexp.Regexp) Option {
+	return func(o *Options) {
+		o.exclude = append(o.exclude, patterns...)
+	}
+}
+
+// WithMaxDatasets caps the number of datasets exported per scrape to bound the cardinality of labels,
+// the datasets are taken in the order of names and the rest are counted by datasets_dropped.
+// Defaults to 1000, 0 means unlimited.
+func WithMaxDatasets(n int) Option {
+	return func(o *Options) {
+		o.maxDatasets = n
+	}
+}
+
+// WithSnapshots enables or disables the metrics of snapshots, enabled by default
+func WithSnapshots(enabled bool) Option {
+	return func(o *Options) {
+		o.snapshots = enabled
+	}
+}
+
+// WithARC enables or disables the metrics of ARC, enabled by default.
+// ARC statistics are only available on Linux.
+func WithARC(enabled bool) Option {
+	return func(o *Options) {
+		o.arc = enabled
+	}
+}
+
+// WithTimeout sets the timeout of a scrape, defaults to 30s
+func WithTimeout(timeout time.Duration) Option {
+	return func(o *Options) {
+		o.timeout = timeout
+	}
+}
@@ -25,9 +25,12 @@ package zfs
 import (
 	"bufio"
 	"bytes"
+	"fmt"
 	"io"
 	"reflect"
+	"strconv"
 	"strings"
+	"time"
 )
 
 func SetValue(data []byte, into interface{}) {
@@ -42,6 +45,9 @@ func SetValue(data []byte, into interface{}) {
 
 		line = strings.TrimSpace(line)
 		if len(line) == 0 {
+			if err == io.EOF {
+				return
+			}
 			continue
 		}
 		set(into, line, "\t")
@@ -52,18 +58,103 @@ func SetValue(data []byte, into interface{}) {
 	}
 }
 
-// 利用反射动态设置 target
+// 利用反射动态设置 target, 根据字段类型转换数值
 // @target: Target install
 // @data: 需要处理的字符串
 // @slim: 分隔符
 func set(target interface{}, data string, slim string) {
 	line := strings.Split(data, slim)
+	if len(line) < 3 {
+		return
+	}
 	getType := reflect.TypeOf(target).Elem()
 	valueType := reflect.ValueOf(target).Elem()
 	for i := 0; i < getType.NumField(); i++ {
 		field := getType.Field(i)
 		if field.Tag.Get("zfs") == line[1] {
-			valueType.Field(i).SetString(line[2])
+			setField(valueType.Field(i), line[2])
 		}
 	}
 }
+
+var timeType = reflect.TypeOf(time.Time{})
+
+// setField converts the value of zfs property by the type of field:
+// string as is, size to uint64, ratio to float64, "on"/"off" to bool and time to time.Time
+func setField(field reflect.Value, value string) {
+	switch {
+	case field.Kind() == reflect.String:
+		field.SetString(value)
+	case field.Kind() == reflect.Uint64:
+		n, _ := parseSize(value)
+		field.SetUint(n)
+	case field.Kind() == reflect.Int64:
+		n, _ := strconv.ParseInt(value, 10, 64)
+		field.SetInt(n)
+	case field.Kind() == reflect.Float64:
+		f, _ := parseRatio(value)
+		field.SetFloat(f)
+	case field.Kind() == reflect.Bool:
+		field.SetBool(parseBool(value))
+	case field.Type() == timeType:
+		t, _ := parseTime(value)
+		field.Set(reflect.ValueOf(t))
+	}
+}
+
+// parseRatio parses the ratio printed by zfs, e.g. "1.50", "1.50x", "12%"
+func parseRatio(s string) (float64, error) {
+	s = strings.TrimRight(strings.TrimSpace(s), "x%")
+	if len(s) == 0 || s == "-" {
+		return 0, nil
+	}
+	return strconv.ParseFloat(s, 64)
+}
+
+// parseBool parses the on/off property of zfs, e.g. "on", "off", "yes", "no"
+func parseBool(s string) bool {
+	switch strings.TrimSpace(s) {
+	case "on", "yes", "true", "1":
+		return true
+	}
+	return false
+}
+
+// parseTime parses the time printed by zfs, the seconds since epoch of parsable output (-p)
+// or the human-readable time, e.g. "Sun Jun  6 10:00 2021"
+func parseTime(s string) (time.Time, error) {
+	s = strings.TrimSpace(s)
+	if len(s) == 0 || s == "-" {
+		return time.Time{}, nil
+	}
+	if n, err := strconv.ParseInt(s, 10, 64); err == nil {
+		return time.Unix(n, 0), nil
+	}
+	return time.ParseInLocation("Mon Jan _2 15:04 2006", s, time.Local)
+}
+
+// parseSize parses the human-readable size printed by zfs, e.g. "0B", "512", "1.23G", "10.0T".
+// The units are powers of 1024.
+func parseSize(s string) (uint64, error) {
+	s = strings.TrimSuffix(strings.TrimSpace(s), "/s")
+	if len(s) == 0 || s == "-" || s == "none" {
+		return 0, nil
+	}
+	if n, err := strconv.ParseUint(s, 10, 64); err == nil {
+		return n, nil
+	}
+
+	units := "BKMGTPE"
+	unit := strings.IndexByte(units, strings.ToUpper(s[len(s)-1:])[0])
+	if unit < 0 {
+		return 0, fmt.Errorf("invalid size '%s'", s)
+	}
+	f, err := strconv.ParseFloat(s[:len(s)-1], 64)
+	if err != nil {
+		return 0, fmt.Errorf("invalid size '%s'", s)
+	}
+	for i := 0; i < unit; i++ {
+		f *= 1024
+	}
+	return uint64(f), nil
+}
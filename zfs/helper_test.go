@@ -0,0 +1,81 @@
+// MIT License
+//
+// Copyright (c) 2021 Lack
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+// SOFTWARE.
+
+package zfs
+
+import (
+	"testing"
+	"time"
+)
+
+func TestSetValue(t *testing.T) {
+	data := []byte("tank/a\ttype\tfilesystem\t-\n" +
+		"tank/a\tcreation\t1622973600\t-\n" +
+		"tank/a\tused\t1048576\t-\n" +
+		"tank/a\tavailable\t1.5G\t-\n" +
+		"tank/a\tcompressratio\t1.50x\t-\n" +
+		"tank/a\treadonly\ton\tlocal\n" +
+		"tank/a\tunknown\n")
+
+	volume := &Volume{}
+	SetValue(data, volume)
+	if volume.Type != "filesystem" || volume.Used != "1048576" {
+		t.Fatalf("unexpected string values: %+v", volume)
+	}
+	if volume.UsedBytes != 1<<20 || volume.AvailableBytes != 3<<29 {
+		t.Fatalf("unexpected bytes: %d %d", volume.UsedBytes, volume.AvailableBytes)
+	}
+	if volume.CompressRatioValue != 1.5 {
+		t.Fatalf("unexpected ratio: %v", volume.CompressRatioValue)
+	}
+	if !volume.IsReadonly {
+		t.Fatal("expect readonly")
+	}
+	if !volume.CreationTime.Equal(time.Unix(1622973600, 0)) {
+		t.Fatalf("unexpected creation: %v", volume.CreationTime)
+	}
+}
+
+func TestParseSize(t *testing.T) {
+	cases := map[string]uint64{
+		"":      0,
+		"-":     0,
+		"512":   512,
+		"0B":    0,
+		"1K":    1024,
+		"1.5M":  3 << 19,
+		"10.0G": 10 << 30,
+		"1T/s":  1 << 40,
+	}
+	for in, expect := range cases {
+		n, err := parseSize(in)
+		if err != nil {
+			t.Fatalf("parse '%s': %v", in, err)
+		}
+		if n != expect {
+			t.Fatalf("parse '%s': expect %d, got %d", in, expect, n)
+		}
+	}
+	if _, err := parseSize("abc"); err == nil {
+		t.Fatal("expect error of invalid size")
+	}
+}
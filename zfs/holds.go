@@ -0,0 +1,97 @@
+// MIT License
+//
+// Copyright (c) 2021 Lack
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+// SOFTWARE.
+
+package zfs
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Hold the user hold of snapshot, a held snapshot can't be destroyed until the hold is released
+type Hold struct {
+	Snapshot string    `json:"snapshot"`
+	Tag      string    `json:"tag"`
+	Created  time.Time `json:"created"`
+}
+
+// GetHolds returns the holds of snapshot, with the holds of its namesakes of descendants if recursive is true
+func (z *ZFSadm) GetHolds(ctx context.Context, snapshot string, recursive bool) ([]*Hold, error) {
+	if !strings.Contains(snapshot, "@") {
+		return nil, fmt.Errorf("%w: '%s' is not a snapshot", ErrInvalidName, snapshot)
+	}
+	options := "-Hp"
+	if recursive {
+		options = "-rHp"
+	}
+	out, err := z.RawZFS().Holds(ctx, snapshot, options).Exec()
+	if err != nil {
+		return nil, err
+	}
+	return ParseHolds(string(out))
+}
+
+// HoldSnapshot adds the hold of tag to snapshot, and to its namesakes of descendants if recursive is true
+func (z *ZFSadm) HoldSnapshot(ctx context.Context, snapshot, tag string, recursive bool) error {
+	if !strings.Contains(snapshot, "@") {
+		return fmt.Errorf("%w: '%s' is not a snapshot", ErrInvalidName, snapshot)
+	}
+	_, err := z.RawZFS().Hold(ctx, snapshot, recursive, tag).Exec()
+	return err
+}
+
+// ReleaseSnapshot removes the hold of tag from snapshot, and from its namesakes of descendants if recursive is true
+func (z *ZFSadm) ReleaseSnapshot(ctx context.Context, snapshot, tag string, recursive bool) error {
+	if !strings.Contains(snapshot, "@") {
+		return fmt.Errorf("%w: '%s' is not a snapshot", ErrInvalidName, snapshot)
+	}
+	_, err := z.RawZFS().Release(ctx, snapshot, recursive, tag).Exec()
+	return err
+}
+
+// ParseHolds parses the output of 'zfs holds -Hp', e.g.
+//
+//	tank/a@s1	keep	1622944800
+func ParseHolds(data string) ([]*Hold, error) {
+	holds := make([]*Hold, 0)
+	for _, line := range strings.Split(data, "\n") {
+		if len(strings.TrimSpace(line)) == 0 {
+			continue
+		}
+		parts := strings.Split(line, "\t")
+		if len(parts) != 3 {
+			return nil, fmt.Errorf("invalid hold '%s'", line)
+		}
+		hold := &Hold{Snapshot: parts[0], Tag: parts[1]}
+		if sec, err := strconv.ParseInt(parts[2], 10, 64); err == nil {
+			hold.Created = time.Unix(sec, 0)
+		} else {
+			// the timestamp is formatted without -p, e.g. "Sun Jun  6 10:00 2021"
+			hold.Created, _ = time.ParseInLocation("Mon Jan _2 15:04 2006", strings.TrimSpace(parts[2]), time.Local)
+		}
+		holds = append(holds, hold)
+	}
+	return holds, nil
+}
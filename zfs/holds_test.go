@@ -0,0 +1,96 @@
+// MIT License
+//
+// Copyright (c) 2021 Lack
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+// SOFTWARE.
+
+package zfs
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestParseHolds(t *testing.T) {
+	holds, err := ParseHolds("tank/a@s1\tkeep\t1622944800\ntank/a/b@s1\tbackup\tSun Jun  6 10:00 2021\n")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(holds) != 2 || holds[0].Tag != "keep" || !holds[0].Created.Equal(time.Unix(1622944800, 0)) {
+		t.Fatalf("unexpected holds: %+v", holds)
+	}
+	if holds[1].Snapshot != "tank/a/b@s1" || holds[1].Created.IsZero() {
+		t.Fatalf("unexpected hold: %+v", holds[1])
+	}
+	if _, err = ParseHolds("tank/a@s1\tkeep"); err == nil {
+		t.Fatal("expect error for invalid hold")
+	}
+}
+
+func TestZFSadm_Holds(t *testing.T) {
+	sim, z := newTestSimulator(t)
+	ctx := context.TODO()
+
+	for _, name := range []string{"tank/a", "tank/a/b"} {
+		if _, err := z.RawZFS().CreateFileSystem(ctx, name, nil).Exec(); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if _, err := z.RawZFS().Snapshot2(ctx, true, nil, "tank/a@s1").Exec(); err != nil {
+		t.Fatal(err)
+	}
+	if err := z.HoldSnapshot(ctx, "tank/a@s1", "backup", true); err != nil {
+		t.Fatal(err)
+	}
+	if err := z.HoldSnapshot(ctx, "tank/a@s1", "backup", false); err == nil {
+		t.Fatal("expect error for duplicated tag")
+	}
+
+	holds, err := z.GetHolds(ctx, "tank/a@s1", true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(holds) != 2 || holds[1].Snapshot != "tank/a/b@s1" || !holds[0].Created.Equal(sim.Now().Truncate(time.Second)) {
+		t.Fatalf("unexpected holds: %+v", holds)
+	}
+
+	// the held snapshots are marked for deferred destruction and destroyed once released
+	deferring, err := z.DeleteSnapshot(ctx, "tank/a@s1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(deferring) != 2 || deferring[0].Tag != "backup" || deferring[1].Snapshot != "tank/a/b@s1" {
+		t.Fatalf("unexpected holds: %+v", deferring)
+	}
+	if v := mustGet(t, z, "tank/a/b@s1", "defer_destroy"); v != "on" {
+		t.Fatalf("expect deferred destroy, got %q", v)
+	}
+
+	if err = z.ReleaseSnapshot(ctx, "tank/a@s1", "backup", true); err != nil {
+		t.Fatal(err)
+	}
+	if _, err = z.GetHolds(ctx, "tank/a/b@s1", false); !errors.Is(err, ErrDatasetNotFound) {
+		t.Fatalf("expect ErrDatasetNotFound, got %v", err)
+	}
+	if _, err = z.GetHolds(ctx, "tank/a", false); !errors.Is(err, ErrInvalidName) {
+		t.Fatalf("expect ErrInvalidName, got %v", err)
+	}
+}
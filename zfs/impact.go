@@ -0,0 +1,382 @@
+// MIT License
+//
+// Copyright (c) 2021 Lack
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+// SOFTWARE.
+
+package zfs
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Operation the operation which Impact is computed for
+type Operation string
+
+const (
+	OperationRollback Operation = "rollback"
+	OperationPromote  Operation = "promote"
+	OperationRename   Operation = "rename"
+)
+
+// RenameChange the dataset or snapshot renamed by rename or moved by promote
+type RenameChange struct {
+	From string `json:"from"`
+	To   string `json:"to"`
+}
+
+// MountpointChange the mountpoint changed by rename
+type MountpointChange struct {
+	Dataset string `json:"dataset"`
+	From    string `json:"from"`
+	To      string `json:"to"`
+}
+
+// Impact the consequences of rollback, promote or rename, it's computed before the operation
+// is executed so that it could be confirmed
+type Impact struct {
+	Operation Operation `json:"operation"`
+	// Target the snapshot to roll back to, the clone to promote or the dataset to rename
+	Target string `json:"target"`
+	// Destroyed the snapshots, bookmarks and clones destroyed by rollback
+	Destroyed []string `json:"destroyed,omitempty"`
+	// Clones the clones depending on the operation: the clones destroyed by rollback, or the
+	// datasets whose origin is changed by promote, including the former origin filesystem
+	Clones []string `json:"clones,omitempty"`
+	// Renamed the datasets and snapshots renamed by rename, or the snapshots moved by promote
+	Renamed []*RenameChange `json:"renamed,omitempty"`
+	// Mountpoints the mountpoints of filesystems changed by rename
+	Mountpoints []*MountpointChange `json:"mountpoints,omitempty"`
+	// Conflicts the names which prevent the operation
+	Conflicts []string `json:"conflicts,omitempty"`
+}
+
+// Destructive reports whether the operation destroys any data
+func (i *Impact) Destructive() bool {
+	return len(i.Destroyed) > 0
+}
+
+func (i *Impact) conflict() error {
+	if len(i.Conflicts) == 0 {
+		return nil
+	}
+	return fmt.Errorf("%w: cannot %s %s: %s", ErrDatasetExists, i.Operation, i.Target, strings.Join(i.Conflicts, ", "))
+}
+
+// Rollback rolls back the filesystem or volume to snapshot, the newer snapshots, bookmarks and their
+// clones are destroyed. The impact is returned without rolling back unless confirm is true.
+func (z *ZFSadm) Rollback(ctx context.Context, snapshot string, confirm bool) (*Impact, error) {
+	impact, err := z.rollbackImpact(ctx, snapshot)
+	if err != nil || !confirm {
+		return impact, err
+	}
+
+	options := ""
+	switch {
+	case len(impact.Clones) > 0:
+		options = "-R"
+	case len(impact.Destroyed) > 0:
+		options = "-r"
+	}
+	_, err = z.RawZFS().Rollback(ctx, options, snapshot).Exec()
+	return impact, err
+}
+
+// Promote promotes clone, so that it's no longer dependent on its origin snapshot. The snapshots
+// of origin filesystem up to the origin snapshot are moved to clone. The impact is returned without
+// promoting unless confirm is true.
+func (z *ZFSadm) Promote(ctx context.Context, clone string, confirm bool) (*Impact, error) {
+	impact, err := z.promoteImpact(ctx, clone)
+	if err != nil || !confirm {
+		return impact, err
+	}
+	if err = impact.conflict(); err != nil {
+		return impact, err
+	}
+
+	_, err = z.RawZFS().Promote(ctx, clone).Exec()
+	return impact, err
+}
+
+// Rename renames filesystem, volume or snapshot, the missing parents of new name are created.
+// The short name of snapshot (e.g. "@new") is accepted. The impact is returned without renaming
+// unless confirm is true.
+func (z *ZFSadm) Rename(ctx context.Context, name, newName string, confirm bool) (*Impact, error) {
+	if i := strings.Index(name, "@"); i > 0 && strings.HasPrefix(newName, "@") {
+		newName = name[:i] + newName
+	}
+	impact, err := z.renameImpact(ctx, name, newName)
+	if err != nil || !confirm {
+		return impact, err
+	}
+	if err = impact.conflict(); err != nil {
+		return impact, err
+	}
+
+	if strings.Contains(name, "@") {
+		_, err = z.RawZFS().Rename(ctx, name, newName, false).Exec()
+	} else {
+		_, err = z.RawZFS().RenameFileSystemOrVolume(ctx, name, newName, false).Exec()
+	}
+	return impact, err
+}
+
+// snapshotTXGs returns the snapshots of dataset with their createtxg in the order of creation
+func (z *ZFSadm) snapshotTXGs(ctx context.Context, dataset string) ([]string, map[string]uint64, error) {
+	out, err := z.RawZFS().List(ctx, dataset, "-Hp", "-d 1", []string{"name", "createtxg"}, "", "", "snapshot").Exec()
+	if err != nil {
+		return nil, nil, err
+	}
+	names := make([]string, 0)
+	txgs := make(map[string]uint64)
+	for _, line := range strings.Split(string(out), "\n") {
+		parts := strings.Split(line, "\t")
+		if len(parts) != 2 {
+			continue
+		}
+		names = append(names, parts[0])
+		txgs[parts[0]], _ = strconv.ParseUint(parts[1], 10, 64)
+	}
+	sort.SliceStable(names, func(i, j int) bool { return txgs[names[i]] < txgs[names[j]] })
+	return names, txgs, nil
+}
+
+// origins returns the origins of clones in pool by their names, and the names of all filesystems
+// and volumes in the order of 'zfs list'
+func (z *ZFSadm) origins(ctx context.Context, pool string) (map[string]string, []string, error) {
+	out, err := z.RawZFS().List(ctx, pool, "-Hp -r", "", []string{"name", "origin"}, "", "", "filesystem,volume").Exec()
+	if err != nil {
+		return nil, nil, err
+	}
+	origins := make(map[string]string)
+	names := make([]string, 0)
+	for _, line := range strings.Split(string(out), "\n") {
+		parts := strings.Split(line, "\t")
+		if len(parts) != 2 {
+			continue
+		}
+		names = append(names, parts[0])
+		if parts[1] != "-" && len(parts[1]) > 0 {
+			origins[parts[0]] = parts[1]
+		}
+	}
+	return origins, names, nil
+}
+
+func (z *ZFSadm) rollbackImpact(ctx context.Context, snapshot string) (*Impact, error) {
+	i := strings.Index(snapshot, "@")
+	if i <= 0 {
+		return nil, fmt.Errorf("%w: '%s' is not a snapshot", ErrInvalidName, snapshot)
+	}
+	dataset := snapshot[:i]
+	impact := &Impact{Operation: OperationRollback, Target: snapshot}
+
+	snapshots, txgs, err := z.snapshotTXGs(ctx, dataset)
+	if err != nil {
+		return nil, err
+	}
+	txg, ok := txgs[snapshot]
+	if !ok {
+		return nil, fmt.Errorf("%w: %s", ErrDatasetNotFound, snapshot)
+	}
+	newer := map[string]bool{}
+	for _, name := range snapshots {
+		if txgs[name] > txg {
+			newer[name] = true
+			impact.Destroyed = append(impact.Destroyed, name)
+		}
+	}
+
+	bookmarks, err := z.ListBookmarks(ctx, dataset)
+	if err != nil {
+		return nil, err
+	}
+	for _, bookmark := range bookmarks {
+		if bookmark.Dataset() == dataset && bookmark.CreateTXG > txg {
+			impact.Destroyed = append(impact.Destroyed, bookmark.Name)
+		}
+	}
+
+	if len(newer) == 0 {
+		return impact, nil
+	}
+	origins, names, err := z.origins(ctx, strings.SplitN(dataset, "/", 2)[0])
+	if err != nil {
+		return nil, err
+	}
+	for _, name := range names {
+		if newer[origins[name]] {
+			impact.Clones = append(impact.Clones, name)
+		}
+	}
+	for _, clone := range impact.Clones {
+		for _, name := range names {
+			if name == clone || strings.HasPrefix(name, clone+"/") {
+				impact.Destroyed = append(impact.Destroyed, name)
+			}
+		}
+	}
+	return impact, nil
+}
+
+func (z *ZFSadm) promoteImpact(ctx context.Context, clone string) (*Impact, error) {
+	if strings.Contains(clone, "@") {
+		return nil, fmt.Errorf("%w: '%s' is a snapshot", ErrInvalidName, clone)
+	}
+	origins, names, err := z.origins(ctx, strings.SplitN(clone, "/", 2)[0])
+	if err != nil {
+		return nil, err
+	}
+	origin, ok := origins[clone]
+	if !ok {
+		return nil, fmt.Errorf("%w: '%s' is not a clone", ErrInvalidName, clone)
+	}
+	parent := origin[:strings.Index(origin, "@")]
+	impact := &Impact{Operation: OperationPromote, Target: clone, Clones: []string{parent}}
+
+	snapshots, txgs, err := z.snapshotTXGs(ctx, parent)
+	if err != nil {
+		return nil, err
+	}
+	existing, _, err := z.snapshotTXGs(ctx, clone)
+	if err != nil {
+		return nil, err
+	}
+	moved := map[string]bool{}
+	for _, name := range snapshots {
+		if txgs[name] > txgs[origin] {
+			continue
+		}
+		moved[name] = true
+		to := clone + name[strings.Index(name, "@"):]
+		impact.Renamed = append(impact.Renamed, &RenameChange{From: name, To: to})
+		for _, item := range existing {
+			if item == to {
+				impact.Conflicts = append(impact.Conflicts, to)
+			}
+		}
+	}
+	for _, name := range names {
+		if name != clone && moved[origins[name]] {
+			impact.Clones = append(impact.Clones, name)
+		}
+	}
+	return impact, nil
+}
+
+func (z *ZFSadm) renameImpact(ctx context.Context, name, newName string) (*Impact, error) {
+	impact := &Impact{Operation: OperationRename, Target: name}
+	if strings.Contains(name, "@") {
+		if !strings.Contains(newName, "@") || strings.SplitN(name, "@", 2)[0] != strings.SplitN(newName, "@", 2)[0] {
+			return nil, fmt.Errorf("%w: snapshot %s must be renamed within the same dataset", ErrInvalidName, name)
+		}
+		if _, err := z.getSnapshot(ctx, name); err != nil {
+			return nil, err
+		}
+		impact.Renamed = append(impact.Renamed, &RenameChange{From: name, To: newName})
+		if _, err := z.getSnapshot(ctx, newName); err == nil {
+			impact.Conflicts = append(impact.Conflicts, newName)
+		}
+		return impact, nil
+	}
+
+	if strings.Contains(newName, "@") || strings.SplitN(name, "/", 2)[0] != strings.SplitN(newName, "/", 2)[0] {
+		return nil, fmt.Errorf("%w: %s must be renamed within the same pool", ErrInvalidName, name)
+	}
+	if strings.HasPrefix(newName, name+"/") {
+		return nil, fmt.Errorf("%w: %s can't be renamed to its descendant", ErrInvalidName, name)
+	}
+	out, err := z.RawZFS().List(ctx, name, "-Hp -r", "", []string{"name"}, "", "", "filesystem,volume,snapshot").Exec()
+	if err != nil {
+		return nil, err
+	}
+	for _, dataset := range strings.Split(string(out), "\n") {
+		if len(dataset) > 0 {
+			impact.Renamed = append(impact.Renamed, &RenameChange{From: dataset, To: newName + strings.TrimPrefix(dataset, name)})
+		}
+	}
+	if _, err = z.RawZFS().List(ctx, newName, "-H", "", []string{"name"}, "", "", "").Exec(); err == nil {
+		impact.Conflicts = append(impact.Conflicts, newName)
+	} else if !errors.Is(err, ErrDatasetNotFound) {
+		return nil, err
+	}
+
+	out, err = z.RawZFS().Get(ctx, name, "-Hp -r", "", []string{"name", "value", "source"}, "filesystem", "", "mountpoint").Exec()
+	if err != nil {
+		return nil, err
+	}
+	parentMountpoint, err := z.mountpointOf(ctx, path.Dir(newName))
+	if err != nil {
+		return nil, err
+	}
+	// the parents are listed before their children
+	mountpoints := map[string]string{}
+	for _, line := range strings.Split(string(out), "\n") {
+		parts := strings.Split(line, "\t")
+		if len(parts) != 3 {
+			continue
+		}
+		dataset, from := parts[0], parts[1]
+		to, mountpoint := newName+strings.TrimPrefix(dataset, name), from
+		if parts[2] != "local" && parts[2] != "received" {
+			parent, ok := mountpoints[path.Dir(dataset)]
+			if !ok || dataset == name {
+				parent = parentMountpoint
+			}
+			mountpoint = childMountpoint(parent, path.Base(to))
+		}
+		mountpoints[dataset] = mountpoint
+		if mountpoint != from {
+			impact.Mountpoints = append(impact.Mountpoints, &MountpointChange{Dataset: to, From: from, To: mountpoint})
+		}
+	}
+	return impact, nil
+}
+
+// mountpointOf returns the mountpoint of filesystem, it's computed from the nearest ancestor
+// if the filesystem doesn't exist yet, e.g. it's created by 'zfs rename -p'
+func (z *ZFSadm) mountpointOf(ctx context.Context, name string) (string, error) {
+	out, err := z.RawZFS().Get(ctx, name, "-Hp", "", []string{"value"}, "", "", "mountpoint").Exec()
+	if err == nil {
+		return string(out), nil
+	}
+	if !errors.Is(err, ErrDatasetNotFound) || !strings.Contains(name, "/") {
+		return "", err
+	}
+	parent, err := z.mountpointOf(ctx, path.Dir(name))
+	if err != nil {
+		return "", err
+	}
+	return childMountpoint(parent, path.Base(name)), nil
+}
+
+// childMountpoint returns the mountpoint inherited from the mountpoint of parent
+func childMountpoint(parent, name string) string {
+	switch parent {
+	case "none", "legacy", "-":
+		return parent
+	}
+	return path.Join(parent, name)
+}
@@ -0,0 +1,193 @@
+// MIT License
+//
+// Copyright (c) 2021 Lack
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+// SOFTWARE.
+
+package zfs
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"testing"
+)
+
+func mustExec(t *testing.T, execute *execute) {
+	t.Helper()
+	if _, err := execute.Exec(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func mustGet(t *testing.T, z *ZFSadm, name, property string) string {
+	t.Helper()
+	data, err := z.RawZFS().Get(context.TODO(), name, "-Hp", "", []string{"value"}, "", "", property).Exec()
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(data)
+}
+
+func TestZFSadm_Rollback(t *testing.T) {
+	_, z := newTestSimulator(t)
+	ctx := context.TODO()
+
+	mustExec(t, z.RawZFS().CreateFileSystem(ctx, "tank/a", nil))
+	for _, name := range []string{"tank/a@s1", "tank/a@s2", "tank/a@s3"} {
+		mustExec(t, z.RawZFS().Snapshot2(ctx, false, nil, name))
+	}
+	mustExec(t, z.RawZFS().Bookmark(ctx, "tank/a@s3", "tank/a#b3"))
+	mustExec(t, z.RawZFS().Clone(ctx, "tank/c", nil, "tank/a@s2"))
+
+	impact, err := z.Rollback(ctx, "tank/a@s1", false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expect := &Impact{
+		Operation: OperationRollback,
+		Target:    "tank/a@s1",
+		Destroyed: []string{"tank/a@s2", "tank/a@s3", "tank/a#b3", "tank/c"},
+		Clones:    []string{"tank/c"},
+	}
+	if !reflect.DeepEqual(impact, expect) || !impact.Destructive() {
+		t.Fatalf("expect %+v, got %+v", expect, impact)
+	}
+	// nothing is changed without confirmation
+	if _, err = z.GetSnapshot(ctx, "tank/a@s3"); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err = z.Rollback(ctx, "tank/a@s1", true); err != nil {
+		t.Fatal(err)
+	}
+	if _, err = z.GetSnapshot(ctx, "tank/a@s2"); !errors.Is(err, ErrDatasetNotFound) {
+		t.Fatalf("expect ErrDatasetNotFound, got %v", err)
+	}
+	if _, err = z.GetFileSystem(ctx, "tank/c"); !errors.Is(err, ErrDatasetNotFound) {
+		t.Fatalf("expect ErrDatasetNotFound, got %v", err)
+	}
+	if bookmarks, err := z.ListBookmarks(ctx, "tank/a"); err != nil || len(bookmarks) != 0 {
+		t.Fatalf("unexpected bookmarks %v, %v", bookmarks, err)
+	}
+
+	if impact, err = z.Rollback(ctx, "tank/a@s1", false); err != nil || impact.Destructive() {
+		t.Fatalf("unexpected impact %+v, %v", impact, err)
+	}
+	if _, err = z.Rollback(ctx, "tank/a", false); !errors.Is(err, ErrInvalidName) {
+		t.Fatalf("expect ErrInvalidName, got %v", err)
+	}
+}
+
+func TestZFSadm_Promote(t *testing.T) {
+	_, z := newTestSimulator(t)
+	ctx := context.TODO()
+
+	mustExec(t, z.RawZFS().CreateFileSystem(ctx, "tank/a", nil))
+	mustExec(t, z.RawZFS().Snapshot2(ctx, false, nil, "tank/a@s1"))
+	mustExec(t, z.RawZFS().Snapshot2(ctx, false, nil, "tank/a@s2"))
+	mustExec(t, z.RawZFS().Clone(ctx, "tank/c", nil, "tank/a@s1"))
+	mustExec(t, z.RawZFS().Clone(ctx, "tank/d", nil, "tank/a@s1"))
+
+	impact, err := z.Promote(ctx, "tank/c", false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expect := &Impact{
+		Operation: OperationPromote,
+		Target:    "tank/c",
+		Clones:    []string{"tank/a", "tank/d"},
+		Renamed:   []*RenameChange{{From: "tank/a@s1", To: "tank/c@s1"}},
+	}
+	if !reflect.DeepEqual(impact, expect) || impact.Destructive() {
+		t.Fatalf("expect %+v, got %+v", expect, impact)
+	}
+
+	if _, err = z.Promote(ctx, "tank/c", true); err != nil {
+		t.Fatal(err)
+	}
+	for name, origin := range map[string]string{"tank/a": "tank/c@s1", "tank/d": "tank/c@s1", "tank/c": "-"} {
+		if got := mustGet(t, z, name, "origin"); got != origin {
+			t.Fatalf("expect origin of %s %q, got %q", name, origin, got)
+		}
+	}
+	if _, err = z.Promote(ctx, "tank/c", false); !errors.Is(err, ErrInvalidName) {
+		t.Fatalf("expect ErrInvalidName, got %v", err)
+	}
+
+	// the snapshot of origin conflicts with the snapshot of clone
+	mustExec(t, z.RawZFS().Snapshot2(ctx, false, nil, "tank/a@s1"))
+	_, err = z.Promote(ctx, "tank/a", true)
+	if !errors.Is(err, ErrDatasetExists) {
+		t.Fatalf("expect ErrDatasetExists, got %v", err)
+	}
+}
+
+func TestZFSadm_Rename(t *testing.T) {
+	_, z := newTestSimulator(t)
+	ctx := context.TODO()
+
+	mustExec(t, z.RawZFS().CreateFileSystem(ctx, "tank/a", nil))
+	mustExec(t, z.RawZFS().CreateFileSystem(ctx, "tank/a/b", nil))
+	mustExec(t, z.RawZFS().CreateFileSystem(ctx, "tank/a/c", map[string]string{"mountpoint": "/srv/c"}))
+	mustExec(t, z.RawZFS().Snapshot2(ctx, false, nil, "tank/a@s1"))
+	if _, err := z.CreateVolume(ctx, "tank/a/v", nil, 1<<30); err != nil {
+		t.Fatal(err)
+	}
+
+	impact, err := z.Rename(ctx, "tank/a", "tank/x/y", false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expect := &Impact{
+		Operation: OperationRename,
+		Target:    "tank/a",
+		Renamed: []*RenameChange{
+			{From: "tank/a", To: "tank/x/y"},
+			{From: "tank/a@s1", To: "tank/x/y@s1"},
+			{From: "tank/a/b", To: "tank/x/y/b"},
+			{From: "tank/a/c", To: "tank/x/y/c"},
+			{From: "tank/a/v", To: "tank/x/y/v"},
+		},
+		Mountpoints: []*MountpointChange{
+			{Dataset: "tank/x/y", From: "/tank/a", To: "/tank/x/y"},
+			{Dataset: "tank/x/y/b", From: "/tank/a/b", To: "/tank/x/y/b"},
+		},
+	}
+	if !reflect.DeepEqual(impact, expect) {
+		t.Fatalf("expect %+v, got %+v", expect, impact)
+	}
+
+	if _, err = z.Rename(ctx, "tank/a", "tank/x/y", true); err != nil {
+		t.Fatal(err)
+	}
+	if got := mustGet(t, z, "tank/x/y/b", "mountpoint"); got != "/tank/x/y/b" {
+		t.Fatalf("unexpected mountpoint %s", got)
+	}
+
+	if impact, err = z.Rename(ctx, "tank/x/y@s1", "@s2", true); err != nil || impact.Renamed[0].To != "tank/x/y@s2" {
+		t.Fatalf("unexpected impact %+v, %v", impact, err)
+	}
+	if _, err = z.Rename(ctx, "tank/x/y/b", "tank/x/y/c", true); !errors.Is(err, ErrDatasetExists) {
+		t.Fatalf("expect ErrDatasetExists, got %v", err)
+	}
+	if _, err = z.Rename(ctx, "tank/x", "data/x", false); !errors.Is(err, ErrInvalidName) {
+		t.Fatalf("expect ErrInvalidName, got %v", err)
+	}
+}
@@ -0,0 +1,230 @@
+// MIT License
+//
+// Copyright (c) 2021 Lack
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+// SOFTWARE.
+
+package zfs
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// ImportablePool a pool which could be imported, listed by 'zpool import'
+type ImportablePool struct {
+	PoolStatus
+	GUID uint64 `json:"guid"`
+	// ForeignHost reports whether the pool was last accessed by another system,
+	// it's imported only by force
+	ForeignHost bool `json:"foreignHost"`
+	// Destroyed reports whether the pool was destroyed, it's listed by 'zpool import -D'
+	Destroyed bool `json:"destroyed"`
+}
+
+// Importable reports whether the pool could be imported without recovery
+func (p *ImportablePool) Importable() bool {
+	return p.State == "ONLINE" || p.State == "DEGRADED"
+}
+
+// DiscoverImportablePools searches the devices in dirs (the default ones if it's empty)
+// and returns the pools which could be imported
+func (z *ZFSadm) DiscoverImportablePools(ctx context.Context, dirs ...string) ([]*ImportablePool, error) {
+	out, err := z.RawZPool().Import6(ctx, "", "", "", nil, dirs, "", "", "").Exec()
+	if err != nil {
+		var ce *CommandError
+		if errors.As(err, &ce) && strings.Contains(ce.Stderr, "no pools available to import") {
+			return []*ImportablePool{}, nil
+		}
+		return nil, err
+	}
+	return ParseImportablePools(string(out)), nil
+}
+
+// ParseImportablePools parses the output of 'zpool import', e.g.
+//
+//	  pool: tank
+//	    id: 15205566455137357372
+//	 state: ONLINE
+//	status: The pool was last accessed by another system.
+//	action: The pool can be imported using its name or numeric identifier and
+//	       the '-f' flag.
+//	config:
+//
+//	       tank        ONLINE
+//	         mirror-0  ONLINE
+//	           sda     ONLINE
+//	           sdb     ONLINE
+func ParseImportablePools(data string) []*ImportablePool {
+	items := make([]*ImportablePool, 0)
+	for _, sections := range splitSections(data) {
+		item := &ImportablePool{PoolStatus: *parseStatusSections(sections)}
+		for _, s := range sections {
+			if s.key == "id" {
+				item.GUID, _ = strconv.ParseUint(s.text(), 10, 64)
+			}
+		}
+		if strings.HasSuffix(item.State, "(DESTROYED)") {
+			item.State = strings.TrimSpace(strings.TrimSuffix(item.State, "(DESTROYED)"))
+			item.Destroyed = true
+		}
+		item.ForeignHost = strings.Contains(item.Status, "last accessed by another system")
+		items = append(items, item)
+	}
+	return items
+}
+
+// RecoveryMode the recovery mode of importing a damaged pool
+type RecoveryMode string
+
+const (
+	// RecoveryNone imports the pool as is
+	RecoveryNone RecoveryMode = ""
+	// RecoveryRewind discards the last few transactions to make the pool importable (-F)
+	RecoveryRewind RecoveryMode = "rewind"
+	// RecoveryExtreme searches the older transactions to rewind, it's extremely hazardous (-FX)
+	RecoveryExtreme RecoveryMode = "extreme"
+	// RecoveryCheck reports whether the pool could be imported by rewinding, without importing (-Fn)
+	RecoveryCheck RecoveryMode = "check"
+)
+
+// ImportOptions options of importing a pool
+type ImportOptions struct {
+	// Name the name of pool, it's ignored if GUID is set
+	Name string `json:"name,omitempty"`
+	// GUID the numeric identifier of pool, it's required if pools share the same name
+	GUID uint64 `json:"guid,omitempty"`
+	// NewName imports the pool with another name
+	NewName string `json:"newName,omitempty"`
+	// Dirs the directories which devices are searched in (-d)
+	Dirs []string `json:"dirs,omitempty"`
+	// CacheFile reads the configuration from cache file instead of searching devices (-c)
+	CacheFile string `json:"cacheFile,omitempty"`
+	// ReadOnly imports the pool in read-only mode
+	ReadOnly bool `json:"readOnly,omitempty"`
+	// AltRoot the alternate root directory of pool (-R)
+	AltRoot string `json:"altRoot,omitempty"`
+	// Force imports the pool which is in use by another system (-f)
+	Force bool `json:"force,omitempty"`
+	// NoMount doesn't mount the file systems (-N)
+	NoMount bool `json:"noMount,omitempty"`
+	// MissingLog allows the pool is imported with a missing log device (-m)
+	MissingLog bool `json:"missingLog,omitempty"`
+	// Destroyed imports the destroyed pool (-D)
+	Destroyed bool         `json:"destroyed,omitempty"`
+	Recovery  RecoveryMode `json:"recovery,omitempty"`
+	// RewindTxg rewinds to the given transaction, it's only used by recovery (-T)
+	RewindTxg uint64 `json:"rewindTxg,omitempty"`
+	// MountOptions the options of mounting file systems, e.g. "ro,noatime"
+	MountOptions string `json:"mountOptions,omitempty"`
+	// Properties the pool properties set on import
+	Properties map[string]string `json:"properties,omitempty"`
+}
+
+func (o *ImportOptions) options() (string, error) {
+	flags := make([]string, 0)
+	if o.Destroyed {
+		flags = append(flags, "-D")
+	}
+	if o.Force {
+		flags = append(flags, "-f")
+	}
+	if o.MissingLog {
+		flags = append(flags, "-m")
+	}
+	if o.NoMount {
+		flags = append(flags, "-N")
+	}
+	switch o.Recovery {
+	case RecoveryNone:
+		if o.RewindTxg > 0 {
+			return "", fmt.Errorf("rewind txg is only used by recovery")
+		}
+	case RecoveryRewind:
+		flags = append(flags, "-F")
+	case RecoveryExtreme:
+		flags = append(flags, "-F", "-X")
+	case RecoveryCheck:
+		flags = append(flags, "-F", "-n")
+	default:
+		return "", fmt.Errorf("unknown recovery mode %q", o.Recovery)
+	}
+	if o.RewindTxg > 0 {
+		flags = append(flags, "-T", strconv.FormatUint(o.RewindTxg, 10))
+	}
+	return strings.Join(flags, " "), nil
+}
+
+// Import imports the pool, returns the imported pool. nil is returned with RecoveryCheck,
+// since the pool isn't imported.
+func (z *ZFSadm) Import(ctx context.Context, opts ImportOptions) (*Pool, error) {
+	name := opts.Name
+	if opts.GUID > 0 {
+		name = strconv.FormatUint(opts.GUID, 10)
+	}
+	if len(name) == 0 {
+		return nil, fmt.Errorf("%w: missing name or guid of pool", ErrInvalidName)
+	}
+	if len(opts.Dirs) > 0 && len(opts.CacheFile) > 0 {
+		return nil, fmt.Errorf("dirs and cache file are exclusive")
+	}
+	options, err := opts.options()
+	if err != nil {
+		return nil, err
+	}
+	properties := opts.Properties
+	if opts.ReadOnly {
+		properties = map[string]string{}
+		for k, v := range opts.Properties {
+			properties[k] = v
+		}
+		properties["readonly"] = "on"
+	}
+
+	execute := z.RawZPool().Import6(ctx, name, options, opts.MountOptions, properties,
+		opts.Dirs, opts.CacheFile, opts.AltRoot, opts.NewName)
+	if _, err = execute.Exec(); err != nil {
+		return nil, err
+	}
+	if opts.Recovery == RecoveryCheck {
+		return nil, nil
+	}
+
+	imported := opts.NewName
+	if len(imported) == 0 {
+		imported = opts.Name
+	}
+	if len(imported) == 0 {
+		// imported by guid, looks up the name by guid
+		pools, err := z.GetPools(ctx)
+		if err != nil {
+			return nil, err
+		}
+		for _, pool := range pools {
+			if pool.Guid == name {
+				return pool, nil
+			}
+		}
+		return nil, fmt.Errorf("%w: %s", ErrPoolNotFound, name)
+	}
+	return z.GetPool(ctx, imported)
+}
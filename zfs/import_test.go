@@ -0,0 +1,125 @@
+// MIT License
+//
+// Copyright (c) 2021 Lack
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+// SOFTWARE.
+
+package zfs
+
+import (
+	"context"
+	"errors"
+	"strconv"
+	"testing"
+)
+
+const zpoolImport = `   pool: tank
+     id: 15205566455137357372
+  state: ONLINE
+ status: The pool was last accessed by another system.
+ action: The pool can be imported using its name or numeric identifier and
+	the '-f' flag.
+   see: https://openzfs.github.io/openzfs-docs/msg/ZFS-8000-EY
+ config:
+
+	tank        ONLINE
+	  mirror-0  ONLINE
+	    sda     ONLINE
+	    sdb     ONLINE
+	logs
+	  sdc       ONLINE
+
+   pool: old
+     id: 42
+  state: UNAVAIL (DESTROYED)
+ status: One or more devices are missing from the system.
+ action: The pool cannot be imported. Attach the missing
+	devices and try again.
+ config:
+
+	old         UNAVAIL  insufficient replicas
+	  sdd       UNAVAIL
+`
+
+func TestParseImportablePools(t *testing.T) {
+	pools := ParseImportablePools(zpoolImport)
+	if len(pools) != 2 {
+		t.Fatalf("expect 2 pools, got %d", len(pools))
+	}
+	tank := pools[0]
+	if tank.Name != "tank" || tank.GUID != 15205566455137357372 || !tank.ForeignHost || tank.Destroyed || !tank.Importable() {
+		t.Fatalf("unexpected pool: %+v", tank)
+	}
+	if tank.Action != "The pool can be imported using its name or numeric identifier and the '-f' flag." {
+		t.Fatalf("unexpected action: %s", tank.Action)
+	}
+	if len(tank.Root.Children) != 1 || len(tank.Root.Children[0].Children) != 2 || len(tank.Logs) != 1 {
+		t.Fatalf("unexpected vdevs: %+v", tank.Root)
+	}
+	old := pools[1]
+	if old.State != "UNAVAIL" || !old.Destroyed || old.ForeignHost || old.Importable() || old.GUID != 42 {
+		t.Fatalf("unexpected pool: %+v", old)
+	}
+}
+
+func TestZFSadm_Import(t *testing.T) {
+	sim, z := newTestSimulator(t)
+	ctx := context.TODO()
+
+	pools, err := z.DiscoverImportablePools(ctx)
+	if err != nil || len(pools) != 0 {
+		t.Fatalf("expect no pool, got %v, %v", pools, err)
+	}
+	if _, err = z.CreateFileSystem(ctx, "tank/a", nil); err != nil {
+		t.Fatal(err)
+	}
+	if _, err = z.RawZPool().Export(ctx, "tank", "").Exec(); err != nil {
+		t.Fatal(err)
+	}
+
+	pools, err = z.DiscoverImportablePools(ctx, "/dev/disk/by-id")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(pools) != 1 || pools[0].Name != "tank" || pools[0].GUID == 0 || !pools[0].Importable() {
+		t.Fatalf("unexpected pools: %+v", pools)
+	}
+
+	if _, err = z.Import(ctx, ImportOptions{Name: "tank", Recovery: "magic"}); err == nil {
+		t.Fatal("expect error of unknown recovery mode")
+	}
+	pool, err := z.Import(ctx, ImportOptions{GUID: pools[0].GUID, NewName: "restored", ReadOnly: true, AltRoot: "/mnt"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if pool.Name != "restored" || pool.AltRoot != "/mnt" {
+		t.Fatalf("unexpected pool: %+v", pool)
+	}
+	history := sim.History()
+	expect := "zpool import -o readonly=on -R /mnt " + strconv.FormatUint(pools[0].GUID, 10) + " restored"
+	if !contains(history, expect) {
+		t.Fatalf("expect %s in %v", expect, history)
+	}
+	if _, err = z.GetFileSystem(ctx, "restored/a"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err = z.Import(ctx, ImportOptions{Name: "tank"}); !errors.Is(err, ErrPoolNotFound) {
+		t.Fatalf("expect ErrPoolNotFound, got %v", err)
+	}
+}
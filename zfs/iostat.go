@@ -0,0 +1,549 @@
+// MIT License
+//
+// Copyright (c) 2021 Lack
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+// SOFTWARE.
+
+package zfs
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"io"
+	"path"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// IOLatency the average latencies of I/O reported by 'zpool iostat -l'
+type IOLatency struct {
+	// TotalWaitRead the average total read latency, including queuing and disk time
+	TotalWaitRead  time.Duration `json:"totalWaitRead"`
+	TotalWaitWrite time.Duration `json:"totalWaitWrite"`
+	// DiskWaitRead the average latency of disk read
+	DiskWaitRead  time.Duration `json:"diskWaitRead"`
+	DiskWaitWrite time.Duration `json:"diskWaitWrite"`
+	// SyncqWaitRead the average time of synchronous read waiting in queue
+	SyncqWaitRead  time.Duration `json:"syncqWaitRead"`
+	SyncqWaitWrite time.Duration `json:"syncqWaitWrite"`
+	// AsyncqWaitRead the average time of asynchronous read waiting in queue
+	AsyncqWaitRead  time.Duration `json:"asyncqWaitRead"`
+	AsyncqWaitWrite time.Duration `json:"asyncqWaitWrite"`
+	ScrubWait       time.Duration `json:"scrubWait"`
+	// TrimWait the average time of trim waiting in queue, it's 0 if zfs doesn't report it
+	TrimWait time.Duration `json:"trimWait"`
+}
+
+// IOLatencyBucket the bucket of latency histogram reported by 'zpool iostat -w', the values are
+// the counts of I/O whose latency is in the bucket
+type IOLatencyBucket struct {
+	// Latency the upper bound of bucket
+	Latency         time.Duration `json:"latency"`
+	TotalWaitRead   uint64        `json:"totalWaitRead"`
+	TotalWaitWrite  uint64        `json:"totalWaitWrite"`
+	DiskWaitRead    uint64        `json:"diskWaitRead"`
+	DiskWaitWrite   uint64        `json:"diskWaitWrite"`
+	SyncqWaitRead   uint64        `json:"syncqWaitRead"`
+	SyncqWaitWrite  uint64        `json:"syncqWaitWrite"`
+	AsyncqWaitRead  uint64        `json:"asyncqWaitRead"`
+	AsyncqWaitWrite uint64        `json:"asyncqWaitWrite"`
+	ScrubWait       uint64        `json:"scrubWait"`
+	// TrimWait the count of trim I/O, it's 0 if zfs doesn't report it
+	TrimWait uint64 `json:"trimWait"`
+}
+
+// IOStats the statistics of pool or vdev in an interval
+type IOStats struct {
+	Name string `json:"name"`
+	// Class the allocation class of vdev, e.g. logs, cache, special, it's empty for pool and data vdevs
+	Class     string `json:"class,omitempty"`
+	Allocated uint64 `json:"allocated"`
+	Free      uint64 `json:"free"`
+	// ReadOps the read operations per second
+	ReadOps  uint64 `json:"readOps"`
+	WriteOps uint64 `json:"writeOps"`
+	// ReadBytes the read bandwidth in bytes per second
+	ReadBytes  uint64 `json:"readBytes"`
+	WriteBytes uint64 `json:"writeBytes"`
+	// Latency the latencies, it's nil unless IOStatOptions.Latency is set
+	Latency *IOLatency `json:"latency,omitempty"`
+	// Histogram the latency histogram in the ascending order of buckets, it's empty unless
+	// IOStatOptions.Histogram is set. The other statistics are 0 in this case.
+	Histogram []*IOLatencyBucket `json:"histogram,omitempty"`
+}
+
+// PoolIOStats the statistics of pool and its vdevs
+type PoolIOStats struct {
+	IOStats
+	// Vdevs the vdevs in the order of 'zpool iostat -v', it's empty unless IOStatOptions.Vdevs is set
+	Vdevs []*IOStats `json:"vdevs,omitempty"`
+}
+
+// IOSample the statistics of pools in an interval
+type IOSample struct {
+	Time  time.Time      `json:"time"`
+	Pools []*PoolIOStats `json:"pools"`
+}
+
+// IOStatOptions options of WatchIOStats
+type IOStatOptions struct {
+	// Pools the pools to watch, all pools are watched if it's empty
+	Pools []string
+	// Interval the interval of samples, in seconds at least, defaults to 5s
+	Interval time.Duration
+	// Vdevs reports the statistics of vdevs (-v)
+	Vdevs bool
+	// Latency reports the average latencies (-l)
+	Latency bool
+	// Histogram reports the latency histograms (-w) instead of the bandwidth and operations,
+	// it can't be used with Latency
+	Histogram bool
+}
+
+// WatchIOStats runs 'zpool iostat' continuously and sends a sample every interval, the sample
+// since boot is skipped. Both channels are closed when ctx is done or zpool iostat exits.
+// A sample is complete when the timestamp of next one is printed, unless only the pools are
+// watched without Vdevs and Histogram, then it's sent as soon as all pools are printed.
+func (z *ZFSadm) WatchIOStats(ctx context.Context, opts IOStatOptions) (<-chan *IOSample, <-chan error) {
+	samples := make(chan *IOSample)
+	errs := make(chan error, 1)
+	go func() {
+		defer close(errs)
+		defer close(samples)
+		if err := z.followIOStats(ctx, opts, samples); err != nil && ctx.Err() == nil {
+			errs <- err
+		}
+	}()
+	return samples, errs
+}
+
+func (z *ZFSadm) followIOStats(ctx context.Context, opts IOStatOptions, samples chan<- *IOSample) error {
+	if opts.Latency && opts.Histogram {
+		return fmt.Errorf("latency and histogram of iostat can't be used together")
+	}
+
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	pools := opts.Pools
+	if len(pools) == 0 {
+		out, err := z.RawZPool().List(ctx, "", "-H", []string{"name"}, "").Exec()
+		if err != nil {
+			return err
+		}
+		for _, name := range strings.Split(string(out), "\n") {
+			if len(name) > 0 {
+				pools = append(pools, name)
+			}
+		}
+	}
+
+	interval := int64(5)
+	if opts.Interval > 0 {
+		interval = int64(opts.Interval / time.Second)
+		if interval < 1 {
+			interval = 1
+		}
+	}
+	options := "-Hpy"
+	if opts.Vdevs {
+		options += "v"
+	}
+	if opts.Latency {
+		options += "l"
+	}
+	if opts.Histogram {
+		options += "w"
+	}
+	args := append(append([]string{}, pools...), strconv.FormatInt(interval, 10))
+	execute := z.RawZPool().Iostat(ctx, "", "", "-T u", options, args...)
+
+	pr, pw := io.Pipe()
+	result := make(chan error, 1)
+	go func() {
+		stderr := &syncBuffer{}
+		cmd := &Cmd{Name: execute.name, Args: execute.args, Stdout: pw, Stderr: stderr}
+		err := execute.runner.Run(ctx, cmd)
+		if err != nil {
+			err = newCommandError(cmd, "", string(stderr.Bytes()), err)
+		}
+		_ = pw.Close()
+		result <- err
+	}()
+
+	lines := make(chan string)
+	go func() {
+		defer close(lines)
+		scanner := bufio.NewScanner(pr)
+		for scanner.Scan() {
+			select {
+			case lines <- scanner.Text():
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+
+	parser := newIOStatParser(pools)
+	parser.histogram = opts.Histogram
+	if !opts.Vdevs && !opts.Histogram {
+		parser.expect = len(pools)
+	}
+	emit := func(sample *IOSample) bool {
+		if sample == nil {
+			return true
+		}
+		select {
+		case samples <- sample:
+			return true
+		case <-ctx.Done():
+			return false
+		}
+	}
+
+loop:
+	for {
+		select {
+		case line, ok := <-lines:
+			if !ok {
+				emit(parser.flush())
+				_ = pr.CloseWithError(io.ErrClosedPipe)
+				return <-result
+			}
+			if !emit(parser.feed(line)) {
+				break loop
+			}
+		case <-ctx.Done():
+			break loop
+		}
+	}
+	cancel()
+	_ = pr.CloseWithError(io.ErrClosedPipe)
+	<-result
+	return nil
+}
+
+// iostatParser parses the output of 'zpool iostat -T u -Hp[vl]', every sample starts with
+// a unix timestamp, followed by the rows of pools and their vdevs, e.g.
+//
+//	1623000000
+//	tank	49152	10737369088	1	2	4096	8192
+//	mirror-0	49152	10737369088	1	2	4096	8192
+//	sda	-	-	0	1	2048	4096
+//
+// The output of 'zpool iostat -T u -Hpw' has the name of pool or vdev in a row, followed by
+// the buckets of histogram:
+//
+//	1623000000
+//	tank
+//	1	0	0	0	0	0	0	0	0	0	0
+//	3	2	1	2	1	0	0	0	0	0	0
+//
+// The samples are split by the timestamps only, so that a sample printed slowly (e.g. by ssh)
+// is never broken.
+type iostatParser struct {
+	pools map[string]bool
+	// histogram the output is printed with -w
+	histogram bool
+	// expect the count of pools in a sample, the sample is completed when all pools are
+	// parsed, 0 if it's unknown
+	expect  int
+	current *IOSample
+	pool    *PoolIOStats
+	stats   *IOStats
+	class   string
+}
+
+func newIOStatParser(pools []string) *iostatParser {
+	p := &iostatParser{pools: map[string]bool{}}
+	for _, name := range pools {
+		p.pools[name] = true
+	}
+	return p
+}
+
+// feed parses a line, returns the completed sample if there is one
+func (p *iostatParser) feed(line string) *IOSample {
+	line = strings.TrimSpace(line)
+	if len(line) == 0 {
+		return nil
+	}
+	fields := strings.Split(line, "\t")
+	if len(fields) == 1 {
+		if sec, err := strconv.ParseInt(fields[0], 10, 64); err == nil {
+			completed := p.flush()
+			p.current = &IOSample{Time: time.Unix(sec, 0), Pools: []*PoolIOStats{}}
+			return completed
+		}
+	}
+
+	if p.histogram {
+		name := strings.TrimSpace(fields[0])
+		switch {
+		case len(fields) == 1:
+			if _, ok := vdevGroups[name]; ok {
+				p.class = name
+				return nil
+			}
+			p.add(&IOStats{Name: name, Histogram: []*IOLatencyBucket{}})
+		case len(fields) >= 10 && p.stats != nil:
+			p.stats.Histogram = append(p.stats.Histogram, parseLatencyBucket(fields))
+		}
+		return nil
+	}
+
+	if len(fields) < 7 {
+		return nil
+	}
+	name := strings.TrimSpace(fields[0])
+	if _, ok := vdevGroups[name]; ok && fields[3] == "-" {
+		// the header of allocation class
+		p.class = name
+		return nil
+	}
+	if p.add(parseIOStats(name, fields)) && p.expect > 0 && len(p.current.Pools) == p.expect {
+		return p.flush()
+	}
+	return nil
+}
+
+// add adds the statistics of pool or vdev to the current sample, reports whether it's a pool
+func (p *iostatParser) add(stats *IOStats) bool {
+	if p.current == nil {
+		p.current = &IOSample{Time: time.Now(), Pools: []*PoolIOStats{}}
+	}
+	if p.pools[stats.Name] || p.pool == nil {
+		p.pool = &PoolIOStats{IOStats: *stats}
+		p.stats = &p.pool.IOStats
+		p.class = ""
+		p.current.Pools = append(p.current.Pools, p.pool)
+		return true
+	}
+	stats.Class = p.class
+	p.stats = stats
+	p.pool.Vdevs = append(p.pool.Vdevs, stats)
+	return false
+}
+
+// flush completes the current sample
+func (p *iostatParser) flush() *IOSample {
+	sample := p.current
+	p.current, p.pool, p.stats, p.class = nil, nil, nil, ""
+	if sample == nil || len(sample.Pools) == 0 {
+		return nil
+	}
+	return sample
+}
+
+// parseLatencyBucket parses the bucket of histogram, the bucket is in nanoseconds with -p,
+// e.g. 1023, or the human-readable duration, e.g. 1us
+func parseLatencyBucket(fields []string) *IOLatencyBucket {
+	value := func(i int) uint64 {
+		if i >= len(fields) {
+			return 0
+		}
+		n, _ := parseSize(fields[i])
+		return n
+	}
+	bucket := &IOLatencyBucket{
+		TotalWaitRead:   value(1),
+		TotalWaitWrite:  value(2),
+		DiskWaitRead:    value(3),
+		DiskWaitWrite:   value(4),
+		SyncqWaitRead:   value(5),
+		SyncqWaitWrite:  value(6),
+		AsyncqWaitRead:  value(7),
+		AsyncqWaitWrite: value(8),
+		ScrubWait:       value(9),
+		TrimWait:        value(10),
+	}
+	label := strings.TrimSpace(fields[0])
+	if n, err := strconv.ParseUint(label, 10, 64); err == nil {
+		bucket.Latency = time.Duration(n)
+	} else if d, err := time.ParseDuration(label); err == nil {
+		bucket.Latency = d
+	}
+	return bucket
+}
+
+func parseIOStats(name string, fields []string) *IOStats {
+	value := func(i int) uint64 {
+		if i >= len(fields) {
+			return 0
+		}
+		n, _ := parseSize(fields[i])
+		return n
+	}
+	stats := &IOStats{
+		Name:       name,
+		Allocated:  value(1),
+		Free:       value(2),
+		ReadOps:    value(3),
+		WriteOps:   value(4),
+		ReadBytes:  value(5),
+		WriteBytes: value(6),
+	}
+	if len(fields) > 7 {
+		latency := func(i int) time.Duration {
+			return time.Duration(value(7 + i))
+		}
+		stats.Latency = &IOLatency{
+			TotalWaitRead:   latency(0),
+			TotalWaitWrite:  latency(1),
+			DiskWaitRead:    latency(2),
+			DiskWaitWrite:   latency(3),
+			SyncqWaitRead:   latency(4),
+			SyncqWaitWrite:  latency(5),
+			AsyncqWaitRead:  latency(6),
+			AsyncqWaitWrite: latency(7),
+			ScrubWait:       latency(8),
+			TrimWait:        latency(9),
+		}
+	}
+	return stats
+}
+
+// ParseIOStats parses the output of 'zpool iostat -T u -Hp[vl]', pools are the names of pools
+// which tell the rows of pools from the rows of vdevs
+func ParseIOStats(data string, pools ...string) []*IOSample {
+	return parseIOSamples(newIOStatParser(pools), data)
+}
+
+// ParseIOHistograms parses the output of 'zpool iostat -T u -Hpw[v]', pools are the names of
+// pools which tell the histograms of pools from the histograms of vdevs
+func ParseIOHistograms(data string, pools ...string) []*IOSample {
+	parser := newIOStatParser(pools)
+	parser.histogram = true
+	return parseIOSamples(parser, data)
+}
+
+func parseIOSamples(parser *iostatParser, data string) []*IOSample {
+	samples := make([]*IOSample, 0)
+	for _, line := range strings.Split(data, "\n") {
+		if sample := parser.feed(line); sample != nil {
+			samples = append(samples, sample)
+		}
+	}
+	if sample := parser.flush(); sample != nil {
+		samples = append(samples, sample)
+	}
+	return samples
+}
+
+// DatasetIOStats the cumulative I/O statistics of dataset since it's mounted,
+// read from the objset kstats of Linux
+type DatasetIOStats struct {
+	Pool    string `json:"pool"`
+	Dataset string `json:"dataset"`
+	// Objset the name of kstat, e.g. objset-0x36
+	Objset       string `json:"objset"`
+	Writes       uint64 `json:"writes"`
+	WrittenBytes uint64 `json:"writtenBytes"`
+	Reads        uint64 `json:"reads"`
+	ReadBytes    uint64 `json:"readBytes"`
+	Unlinks      uint64 `json:"unlinks"`
+	Unlinked     uint64 `json:"unlinked"`
+}
+
+// kstatRoot the directory of zfs kstats on Linux
+const kstatRoot = "/proc/spl/kstat/zfs"
+
+// GetDatasetIOStats returns the I/O statistics of datasets of pool by the objset kstats,
+// which are only available on Linux. The files are read by the Runner, so that it works
+// on remote hosts too.
+func (z *ZFSadm) GetDatasetIOStats(ctx context.Context, pool string) ([]*DatasetIOStats, error) {
+	dir := path.Join(kstatRoot, pool)
+	out, err := (&execute{ctx: ctx, name: "ls", args: []string{dir}, runner: z.runner}).Exec()
+	if err != nil {
+		return nil, err
+	}
+	files := make([]string, 0)
+	for _, name := range strings.Fields(string(out)) {
+		if strings.HasPrefix(name, "objset-") {
+			files = append(files, path.Join(dir, name))
+		}
+	}
+	if len(files) == 0 {
+		return []*DatasetIOStats{}, nil
+	}
+
+	// the objset is gone if the dataset is destroyed or unmounted meanwhile, so the files are read one by one
+	items := make([]*DatasetIOStats, 0, len(files))
+	for _, file := range files {
+		out, err = (&execute{ctx: ctx, name: "cat", args: []string{file}, runner: z.runner}).Exec()
+		if err != nil {
+			continue
+		}
+		item, err := ParseObjsetKstat(string(out))
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", file, err)
+		}
+		item.Pool, item.Objset = pool, path.Base(file)
+		items = append(items, item)
+	}
+	return items, nil
+}
+
+// ParseObjsetKstat parses the objset kstat of Linux, e.g.
+//
+//	31 1 0x01 7 2160 5214870658 46917549814939
+//	name                            type data
+//	dataset_name                    7    tank/home
+//	writes                          4    3
+//	nwritten                        4    12345
+func ParseObjsetKstat(data string) (*DatasetIOStats, error) {
+	item := &DatasetIOStats{}
+	found := false
+	for _, line := range strings.Split(data, "\n") {
+		fields := strings.Fields(line)
+		if len(fields) < 3 {
+			continue
+		}
+		if fields[0] == "dataset_name" {
+			// the name may contain spaces
+			item.Dataset = strings.Join(fields[2:], " ")
+			found = true
+			continue
+		}
+		n, err := strconv.ParseUint(fields[2], 10, 64)
+		if err != nil {
+			continue
+		}
+		switch fields[0] {
+		case "writes":
+			item.Writes = n
+		case "nwritten":
+			item.WrittenBytes = n
+		case "reads":
+			item.Reads = n
+		case "nread":
+			item.ReadBytes = n
+		case "nunlinks":
+			item.Unlinks = n
+		case "nunlinked":
+			item.Unlinked = n
+		}
+	}
+	if !found {
+		return nil, fmt.Errorf("invalid objset kstat, missing dataset_name")
+	}
+	return item, nil
+}
@@ -0,0 +1,281 @@
+// MIT License
+//
+// Copyright (c) 2021 Lack
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+// SOFTWARE.
+
+package zfs
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+)
+
+const zpoolIostat = `1623000005
+tank	49152	10737369088	3	12	12288	98304	1000	2000	800	1500	-	100	-	400	0	-
+mirror-0	49152	10737369088	3	12	12288	98304	1000	2000	800	1500	-	100	-	400	0	-
+sda	-	-	2	6	8192	49152	900	2100	700	1600	-	100	-	400	0	-
+sdb	-	-	1	6	4096	49152	1100	1900	900	1400	-	100	-	400	0	-
+logs	-	-	-	-	-	-	-	-	-	-	-	-	-	-	-	-
+sdc	0	5368709120	0	0	0	0	-	-	-	-	-	-	-	-	-	-
+data	1024	1073740800	0	1	0	4096	0	300	0	200	-	-	-	100	-	-
+sdd	1024	1073740800	0	1	0	4096	0	300	0	200	-	-	-	100	-	-
+1623000010
+tank	49152	10737369088	0	0	0	0	-	-	-	-	-	-	-	-	-	-
+mirror-0	49152	10737369088	0	0	0	0	-	-	-	-	-	-	-	-	-	-
+sda	-	-	0	0	0	0	-	-	-	-	-	-	-	-	-	-
+sdb	-	-	0	0	0	0	-	-	-	-	-	-	-	-	-	-
+logs	-	-	-	-	-	-	-	-	-	-	-	-	-	-	-	-
+sdc	0	5368709120	0	0	0	0	-	-	-	-	-	-	-	-	-	-
+data	1024	1073740800	0	0	0	0	-	-	-	-	-	-	-	-	-	-
+sdd	1024	1073740800	0	0	0	0	-	-	-	-	-	-	-	-	-	-
+`
+
+func TestParseIOStats(t *testing.T) {
+	samples := ParseIOStats(zpoolIostat, "tank", "data")
+	if len(samples) != 2 {
+		t.Fatalf("expect 2 samples, got %d", len(samples))
+	}
+
+	sample := samples[0]
+	if !sample.Time.Equal(time.Unix(1623000005, 0)) || len(sample.Pools) != 2 {
+		t.Fatalf("unexpected sample: %+v", sample)
+	}
+	tank := sample.Pools[0]
+	if tank.Name != "tank" || tank.ReadOps != 3 || tank.WriteBytes != 98304 || tank.Free != 10737369088 {
+		t.Fatalf("unexpected pool: %+v", tank)
+	}
+	if tank.Latency == nil || tank.Latency.TotalWaitWrite != 2000*time.Nanosecond || tank.Latency.AsyncqWaitWrite != 400 {
+		t.Fatalf("unexpected latency: %+v", tank.Latency)
+	}
+	if len(tank.Vdevs) != 4 {
+		t.Fatalf("expect 4 vdevs, got %d", len(tank.Vdevs))
+	}
+	if sda := tank.Vdevs[1]; sda.Name != "sda" || sda.Class != "" || sda.ReadBytes != 8192 || sda.Allocated != 0 {
+		t.Fatalf("unexpected vdev: %+v", sda)
+	}
+	if sdc := tank.Vdevs[3]; sdc.Name != "sdc" || sdc.Class != "logs" || sdc.Free != 5368709120 {
+		t.Fatalf("unexpected log vdev: %+v", sdc)
+	}
+	if data := sample.Pools[1]; data.Name != "data" || len(data.Vdevs) != 1 || data.Vdevs[0].Class != "" {
+		t.Fatalf("unexpected pool: %+v", data)
+	}
+}
+
+func TestZFSadm_WatchIOStats(t *testing.T) {
+	runner := NewFakeRunner().
+		On("zpool list -H -o name", "tank\ndata", nil).
+		On("zpool iostat -T u -Hpyvl tank data 1", zpoolIostat, nil)
+
+	z := New(WithRunner(runner))
+	ctx, cancel := context.WithTimeout(context.TODO(), time.Second*5)
+	defer cancel()
+
+	samples, errs := z.WatchIOStats(ctx, IOStatOptions{Interval: time.Second, Vdevs: true, Latency: true})
+	received := make([]*IOSample, 0)
+	for sample := range samples {
+		received = append(received, sample)
+	}
+	if err := <-errs; err != nil {
+		t.Fatal(err)
+	}
+	if len(received) != 2 || len(received[1].Pools) != 2 || received[1].Pools[0].Latency == nil {
+		t.Fatalf("unexpected samples: %+v", received)
+	}
+}
+
+func TestZFSadm_WatchIOStatsError(t *testing.T) {
+	runner := NewFakeRunner().
+		On("zpool iostat -T u -Hpy nopool 5", "cannot open 'nopool': no such pool", errors.New("exit status 1"))
+
+	z := New(WithRunner(runner))
+	samples, errs := z.WatchIOStats(context.TODO(), IOStatOptions{Pools: []string{"nopool"}})
+	for sample := range samples {
+		t.Fatalf("unexpected sample: %+v", sample)
+	}
+	if err := <-errs; !errors.Is(err, ErrPoolNotFound) {
+		t.Fatalf("expect ErrPoolNotFound, got %v", err)
+	}
+}
+
+const zpoolIostatHistogram = `1623000005
+tank
+1	0	0	0	0	0	0	0	0	0	0
+1023	5	2	3	1	0	0	1	1	0	0
+1048575	1	4	0	2	0	0	0	0	0	0
+mirror-0
+1	0	0	0	0	0	0	0	0	0	0
+1023	5	2	3	1	0	0	1	1	0	0
+logs
+sdc
+1us	1	0	1	0	0	0	0	0	0
+`
+
+func TestParseIOHistograms(t *testing.T) {
+	samples := ParseIOHistograms(zpoolIostatHistogram, "tank")
+	if len(samples) != 1 || len(samples[0].Pools) != 1 {
+		t.Fatalf("unexpected samples: %+v", samples)
+	}
+	tank := samples[0].Pools[0]
+	if len(tank.Histogram) != 3 || len(tank.Vdevs) != 2 {
+		t.Fatalf("unexpected pool: %+v", tank)
+	}
+	if bucket := tank.Histogram[1]; bucket.Latency != 1023 || bucket.TotalWaitRead != 5 || bucket.AsyncqWaitWrite != 1 {
+		t.Fatalf("unexpected bucket: %+v", bucket)
+	}
+	sdc := tank.Vdevs[1]
+	if sdc.Name != "sdc" || sdc.Class != "logs" || len(sdc.Histogram) != 1 {
+		t.Fatalf("unexpected vdev: %+v", sdc)
+	}
+	if bucket := sdc.Histogram[0]; bucket.Latency != time.Microsecond || bucket.TotalWaitRead != 1 || bucket.TrimWait != 0 {
+		t.Fatalf("unexpected bucket: %+v", bucket)
+	}
+
+	runner := NewFakeRunner().On("zpool iostat -T u -Hpyvw tank 5", zpoolIostatHistogram, nil)
+	z := New(WithRunner(runner))
+	received, errs := z.WatchIOStats(context.TODO(), IOStatOptions{Pools: []string{"tank"}, Vdevs: true, Histogram: true})
+	samples = samples[:0]
+	for sample := range received {
+		samples = append(samples, sample)
+	}
+	if err := <-errs; err != nil {
+		t.Fatal(err)
+	}
+	if len(samples) != 1 || len(samples[0].Pools[0].Histogram) != 3 {
+		t.Fatalf("unexpected samples: %+v", samples)
+	}
+}
+
+// slowRunner writes the chunks of output with delay, the command exits when ctx is done if
+// block is set
+type slowRunner struct {
+	chunks []string
+	delay  time.Duration
+	block  bool
+}
+
+func (r *slowRunner) Run(ctx context.Context, cmd *Cmd) error {
+	for _, chunk := range r.chunks {
+		if _, err := cmd.Stdout.Write([]byte(chunk)); err != nil {
+			return err
+		}
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(r.delay):
+		}
+	}
+	if r.block {
+		<-ctx.Done()
+		return ctx.Err()
+	}
+	return nil
+}
+
+func TestZFSadm_WatchIOStatsSlowOutput(t *testing.T) {
+	// the first sample is split in the middle of vdevs of tank
+	i := strings.Index(zpoolIostat, "sdb")
+	runner := &slowRunner{chunks: []string{zpoolIostat[:i], zpoolIostat[i:]}, delay: time.Millisecond * 300}
+	z := New(WithRunner(runner))
+
+	samples, errs := z.WatchIOStats(context.TODO(), IOStatOptions{Pools: []string{"tank", "data"}, Vdevs: true, Latency: true})
+	received := make([]*IOSample, 0)
+	for sample := range samples {
+		received = append(received, sample)
+	}
+	if err := <-errs; err != nil {
+		t.Fatal(err)
+	}
+	if len(received) != 2 {
+		t.Fatalf("expect 2 samples, got %d", len(received))
+	}
+	for _, sample := range received {
+		if len(sample.Pools) != 2 || len(sample.Pools[0].Vdevs) != 4 || len(sample.Pools[1].Vdevs) != 1 {
+			t.Fatalf("unexpected sample: %+v", sample)
+		}
+	}
+}
+
+func TestZFSadm_WatchIOStatsPools(t *testing.T) {
+	// the sample of pools is sent without waiting for the next timestamp
+	runner := &slowRunner{chunks: []string{"1623000005\ntank\t49152\t10737369088\t3\t12\t12288\t98304\n"}, block: true}
+	z := New(WithRunner(runner))
+	ctx, cancel := context.WithCancel(context.TODO())
+	defer cancel()
+
+	samples, errs := z.WatchIOStats(ctx, IOStatOptions{Pools: []string{"tank"}})
+	select {
+	case sample := <-samples:
+		if len(sample.Pools) != 1 || sample.Pools[0].ReadOps != 3 {
+			t.Fatalf("unexpected sample: %+v", sample)
+		}
+	case <-time.After(time.Second * 5):
+		t.Fatal("timeout")
+	}
+	cancel()
+	for range samples {
+	}
+	if err := <-errs; err != nil {
+		t.Fatal(err)
+	}
+
+	_, errs = z.WatchIOStats(context.TODO(), IOStatOptions{Pools: []string{"tank"}, Latency: true, Histogram: true})
+	if err := <-errs; err == nil {
+		t.Fatal("expect error of latency with histogram")
+	}
+}
+
+const objsetKstat = `31 1 0x01 7 2160 5214870658 46917549814939
+name                            type data
+dataset_name                    7    tank/home
+writes                          4    3
+nwritten                        4    12345
+reads                           4    7
+nread                           4    65536
+nunlinks                        4    2
+nunlinked                       4    1
+`
+
+func TestZFSadm_GetDatasetIOStats(t *testing.T) {
+	runner := NewFakeRunner().
+		On("ls /proc/spl/kstat/zfs/tank", "dmu_tx_assign\nobjset-0x36\nobjset-0x54\nstate\ntxgs", nil).
+		On("cat /proc/spl/kstat/zfs/tank/objset-0x36", objsetKstat, nil).
+		On("cat /proc/spl/kstat/zfs/tank/objset-0x54", "", errors.New("exit status 1"))
+
+	z := New(WithRunner(runner))
+	items, err := z.GetDatasetIOStats(context.TODO(), "tank")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(items) != 1 {
+		t.Fatalf("expect 1 dataset, got %d", len(items))
+	}
+	item := items[0]
+	if item.Pool != "tank" || item.Dataset != "tank/home" || item.Objset != "objset-0x36" ||
+		item.WrittenBytes != 12345 || item.Reads != 7 || item.Unlinked != 1 {
+		t.Fatalf("unexpected stats: %+v", item)
+	}
+
+	if _, err = ParseObjsetKstat("name type data\n"); err == nil {
+		t.Fatal("expect error for kstat without dataset_name")
+	}
+}
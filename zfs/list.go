@@ -0,0 +1,134 @@
+// MIT License
+//
+// Copyright (c) 2021 Lack
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+// SOFTWARE.
+
+package zfs
+
+import (
+	"context"
+	"strconv"
+	"strings"
+)
+
+// ListOptions restricts the datasets and properties fetched by ListFileSystems, ListVolumes and ListSnapshots
+type ListOptions struct {
+	// Root lists the subtree of the dataset only, all datasets if it's empty
+	Root string `json:"root"`
+	// Depth limits the depth of subtree, 0 means unlimited
+	Depth int `json:"depth"`
+	// Properties the properties to fetch, all properties if it's empty
+	Properties []string `json:"properties"`
+}
+
+// ListFileSystems returns filesystems with their properties by a single 'zfs get'
+func (z *ZFSadm) ListFileSystems(ctx context.Context, opts ListOptions) (map[string]*Volume, error) {
+	return z.listVolumes(ctx, "filesystem", opts)
+}
+
+// ListVolumes returns volumes with their properties by a single 'zfs get'
+func (z *ZFSadm) ListVolumes(ctx context.Context, opts ListOptions) (map[string]*Volume, error) {
+	return z.listVolumes(ctx, "volume", opts)
+}
+
+func (z *ZFSadm) listVolumes(ctx context.Context, t string, opts ListOptions) (map[string]*Volume, error) {
+	volumes := make(map[string]*Volume, 0)
+	err := z.bulkGet(ctx, t, opts, func(name string, parts []string) {
+		volume, ok := volumes[name]
+		if !ok {
+			volume = &Volume{Name: name}
+			volumes[name] = volume
+		}
+		if parts[1] == "origin" {
+			if parts[2] != "-" {
+				volume.Source = parts[2]
+			}
+			return
+		}
+		set(volume, strings.Join(parts, "\t"), "\t")
+	})
+	if err != nil {
+		return nil, err
+	}
+	return volumes, nil
+}
+
+// ListSnapshots returns snapshots with their properties by a single 'zfs get'
+func (z *ZFSadm) ListSnapshots(ctx context.Context, opts ListOptions) (map[string]*Snapshot, error) {
+	snapshots := make(map[string]*Snapshot, 0)
+	err := z.bulkGet(ctx, "snapshot", opts, func(name string, parts []string) {
+		snapshot, ok := snapshots[name]
+		if !ok {
+			snapshot = &Snapshot{Name: name, Parent: strings.Split(name, "@")[0]}
+			snapshots[name] = snapshot
+		}
+		set(snapshot, strings.Join(parts, "\t"), "\t")
+	})
+	if err != nil {
+		return nil, err
+	}
+	return snapshots, nil
+}
+
+// bulkGet executes 'zfs get -Hp [-r] [-d depth] -t type <properties> [root]' and
+// demultiplexes the output by the name of dataset.
+func (z *ZFSadm) bulkGet(ctx context.Context, t string, opts ListOptions, fn func(name string, parts []string)) error {
+	options := "-Hp"
+	max := ""
+	if len(opts.Root) > 0 {
+		options = "-Hp -r"
+		if opts.Depth > 0 {
+			max = strconv.Itoa(opts.Depth)
+		}
+	}
+
+	properties := []string{"all"}
+	if len(opts.Properties) > 0 {
+		properties = opts.Properties
+		if t != "snapshot" && !contains(properties, "origin") {
+			// origin is required by Volume.Source
+			properties = append(append([]string{}, properties...), "origin")
+		}
+	}
+
+	execute := z.RawZFS().Get(ctx, opts.Root, options, max, nil, t, "", properties...)
+	data, err := execute.Exec()
+	if err != nil {
+		return err
+	}
+
+	for _, line := range strings.Split(string(data), "\n") {
+		parts := strings.Split(strings.TrimRight(line, "\r"), "\t")
+		if len(parts) < 3 || len(parts[0]) == 0 {
+			continue
+		}
+		fn(parts[0], parts)
+	}
+	return nil
+}
+
+func contains(items []string, s string) bool {
+	for _, item := range items {
+		if item == s {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,66 @@
+// MIT License
+//
+// Copyright (c) 2021 Lack
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+// SOFTWARE.
+
+package zfs
+
+import (
+	"context"
+	"testing"
+)
+
+func TestZFSadm_ListSnapshots(t *testing.T) {
+	runner := NewFakeRunner().
+		On("zfs get -Hp -r -d 1 -t snapshot used,userrefs tank/a",
+			"tank/a@s1\tused\t1024\t-\ntank/a@s1\tuserrefs\t1\t-\n"+
+				"tank/a@s2\tused\t0\t-\ntank/a@s2\tuserrefs\t0\t-\n", nil)
+
+	z := New(WithRunner(runner))
+	snapshots, err := z.ListSnapshots(context.TODO(), ListOptions{Root: "tank/a", Depth: 1, Properties: []string{"used", "userrefs"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(snapshots) != 2 {
+		t.Fatalf("expect 2 snapshots, got %d", len(snapshots))
+	}
+	s1 := snapshots["tank/a@s1"]
+	if s1.Parent != "tank/a" || s1.UsedBytes != 1024 || s1.Userrefs != "1" {
+		t.Fatalf("unexpected snapshot: %+v", s1)
+	}
+	if len(runner.History()) != 1 {
+		t.Fatalf("expect a single command, got %v", runner.History())
+	}
+}
+
+func TestZFSadm_ListVolumes(t *testing.T) {
+	runner := NewFakeRunner().
+		On("zfs get -Hp -r -t volume volsize,origin tank",
+			"tank/v1\tvolsize\t1073741824\tlocal\ntank/v1\torigin\ttank/v0@s1\t-\n", nil)
+
+	z := New(WithRunner(runner))
+	volumes, err := z.ListVolumes(context.TODO(), ListOptions{Root: "tank", Properties: []string{"volsize"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if v := volumes["tank/v1"]; v == nil || v.VolsizeBytes != 1<<30 || v.Source != "tank/v0@s1" {
+		t.Fatalf("unexpected volume: %+v", v)
+	}
+}
@@ -0,0 +1,66 @@
+// MIT License
+//
+// Copyright (c) 2021 Lack
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+// SOFTWARE.
+
+package zfs
+
+type Options struct {
+	zfs    string
+	zpool  string
+	runner Runner
+}
+
+func newOptions(opts ...Option) Options {
+	options := Options{
+		zfs:   "zfs",
+		zpool: "zpool",
+	}
+	for _, o := range opts {
+		o(&options)
+	}
+	if options.runner == nil {
+		options.runner = NewLocalRunner()
+	}
+	return options
+}
+
+type Option func(*Options)
+
+// WithZFS sets the path of command 'zfs', defaults to "zfs"
+func WithZFS(cmd string) Option {
+	return func(o *Options) {
+		o.zfs = cmd
+	}
+}
+
+// WithZPool sets the path of command 'zpool', defaults to "zpool"
+func WithZPool(cmd string) Option {
+	return func(o *Options) {
+		o.zpool = cmd
+	}
+}
+
+// WithRunner sets the Runner of ZFSadm, defaults to local runner
+func WithRunner(runner Runner) Option {
+	return func(o *Options) {
+		o.runner = runner
+	}
+}
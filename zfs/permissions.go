@@ -0,0 +1,393 @@
+// MIT License
+//
+// Copyright (c) 2021 Lack
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+// SOFTWARE.
+
+package zfs
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// GrantType the type of grantee of delegated permissions
+type GrantType string
+
+const (
+	GrantUser     GrantType = "user"
+	GrantGroup    GrantType = "group"
+	GrantEveryone GrantType = "everyone"
+	// GrantCreate the create time permissions, which are granted to the creator of descendent dataset
+	GrantCreate GrantType = "create"
+	// GrantSet the permission set, Name is the name of set, e.g. @pset
+	GrantSet GrantType = "set"
+)
+
+// PermissionScope the scope of delegated permissions
+type PermissionScope string
+
+const (
+	ScopeLocal           PermissionScope = "local"
+	ScopeDescendent      PermissionScope = "descendent"
+	ScopeLocalDescendent PermissionScope = "local+descendent"
+)
+
+// Grant a delegated permission entry of dataset
+type Grant struct {
+	Type GrantType `json:"type"`
+	// Name the name of user, group or permission set, it's empty for everyone and create time permissions
+	Name string `json:"name,omitempty"`
+	// Scope the scope of permissions, it's ignored by create time permissions and permission sets
+	Scope       PermissionScope `json:"scope,omitempty"`
+	Permissions []string        `json:"permissions"`
+}
+
+// Permissions the delegated permissions of dataset, displayed by 'zfs allow <dataset>'
+type Permissions struct {
+	Dataset string  `json:"dataset"`
+	Grants  []Grant `json:"grants,omitempty"`
+	// Inherited the permissions of ancestors, which also take effect on dataset
+	Inherited []*Permissions `json:"inherited,omitempty"`
+}
+
+// PermissionChange a change made by ApplyPermissions
+type PermissionChange struct {
+	Revoke bool  `json:"revoke,omitempty"`
+	Grant  Grant `json:"grant"`
+}
+
+func (c PermissionChange) String() string {
+	action := "allow"
+	if c.Revoke {
+		action = "unallow"
+	}
+	g := c.Grant
+	switch g.Type {
+	case GrantCreate:
+		return fmt.Sprintf("%s create time %s", action, strings.Join(g.Permissions, ","))
+	case GrantSet:
+		return fmt.Sprintf("%s set %s %s", action, g.Name, strings.Join(g.Permissions, ","))
+	case GrantEveryone:
+		return fmt.Sprintf("%s %s everyone %s", action, g.Scope, strings.Join(g.Permissions, ","))
+	}
+	return fmt.Sprintf("%s %s %s %s %s", action, g.Scope, g.Type, g.Name, strings.Join(g.Permissions, ","))
+}
+
+// GetPermissions returns the delegated permissions of dataset
+func (z *ZFSadm) GetPermissions(ctx context.Context, dataset string) (*Permissions, error) {
+	out, err := z.RawZFS().Allow1(ctx, dataset).Exec()
+	if err != nil {
+		return nil, err
+	}
+	perms := &Permissions{Dataset: dataset}
+	for _, item := range ParsePermissions(string(out)) {
+		if item.Dataset == dataset {
+			perms.Grants = item.Grants
+		} else {
+			perms.Inherited = append(perms.Inherited, item)
+		}
+	}
+	return perms, nil
+}
+
+// Grant delegates the permissions to the grantees on dataset
+func (z *ZFSadm) Grant(ctx context.Context, dataset string, grants ...Grant) error {
+	for _, g := range grants {
+		if err := z.allow(ctx, dataset, g, false); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Revoke removes the delegated permissions from the grantees on dataset
+func (z *ZFSadm) Revoke(ctx context.Context, dataset string, grants ...Grant) error {
+	for _, g := range grants {
+		if err := z.allow(ctx, dataset, g, true); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (z *ZFSadm) allow(ctx context.Context, dataset string, g Grant, revoke bool) error {
+	if err := validateGrant(g); err != nil {
+		return err
+	}
+	perm := strings.Join(g.Permissions, ",")
+
+	var execute *execute
+	switch g.Type {
+	case GrantCreate:
+		if revoke {
+			execute = z.RawZFS().Unallow3(ctx, dataset, false, perm)
+		} else {
+			execute = z.RawZFS().Allow4(ctx, dataset, perm)
+		}
+	case GrantSet:
+		if revoke {
+			execute = z.RawZFS().Unallow4(ctx, dataset, false, g.Name, perm)
+		} else {
+			execute = z.RawZFS().Allow5(ctx, dataset, g.Name, perm)
+		}
+	case GrantEveryone:
+		options := strings.TrimSpace(scopeOption(g.Scope) + " -e")
+		if revoke {
+			execute = z.RawZFS().Unallow2(ctx, dataset, options, perm)
+		} else {
+			execute = z.RawZFS().Allow3(ctx, dataset, options, perm)
+		}
+	default:
+		options := strings.TrimSpace(scopeOption(g.Scope) + " -" + string(g.Type[0]))
+		if revoke {
+			execute = z.RawZFS().Unallow1(ctx, dataset, options, g.Name, perm)
+		} else {
+			execute = z.RawZFS().Allow2(ctx, dataset, options, g.Name, perm)
+		}
+	}
+	_, err := execute.Exec()
+	return err
+}
+
+func scopeOption(scope PermissionScope) string {
+	switch scope {
+	case ScopeLocal:
+		return "-l"
+	case ScopeDescendent:
+		return "-d"
+	}
+	return ""
+}
+
+func validateGrant(g Grant) error {
+	if len(g.Permissions) == 0 {
+		return fmt.Errorf("%w: no permission is specified for %s %s", ErrInvalidName, g.Type, g.Name)
+	}
+	for _, perm := range g.Permissions {
+		if len(perm) == 0 || strings.ContainsAny(perm, ", \t") {
+			return fmt.Errorf("%w: permission %q", ErrInvalidName, perm)
+		}
+	}
+	switch g.Scope {
+	case "", ScopeLocal, ScopeDescendent, ScopeLocalDescendent:
+	default:
+		return fmt.Errorf("%w: scope %q", ErrInvalidName, g.Scope)
+	}
+	switch g.Type {
+	case GrantUser, GrantGroup:
+		if len(g.Name) == 0 || strings.ContainsAny(g.Name, ", \t") {
+			return fmt.Errorf("%w: %s %q", ErrInvalidName, g.Type, g.Name)
+		}
+	case GrantSet:
+		if !strings.HasPrefix(g.Name, "@") || len(g.Name) == 1 || strings.ContainsAny(g.Name, ", \t") {
+			return fmt.Errorf("%w: permission set %q", ErrInvalidName, g.Name)
+		}
+	case GrantEveryone, GrantCreate:
+	default:
+		return fmt.Errorf("%w: grant type %q", ErrInvalidName, g.Type)
+	}
+	return nil
+}
+
+// ApplyPermissions reconciles the delegated permissions of dataset with the desired ones, the
+// permissions not in desired are revoked, the inherited permissions are left as is. The changes
+// are computed without touching the dataset if dryRun is true.
+func (z *ZFSadm) ApplyPermissions(ctx context.Context, desired *Permissions, dryRun bool) ([]PermissionChange, error) {
+	for _, g := range desired.Grants {
+		if err := validateGrant(g); err != nil {
+			return nil, err
+		}
+	}
+	current, err := z.GetPermissions(ctx, desired.Dataset)
+	if err != nil {
+		return nil, err
+	}
+
+	changes := diffPermissions(current.Grants, desired.Grants)
+	if dryRun {
+		return changes, nil
+	}
+	// revokes first, so that the permission sets could be redefined
+	for _, c := range changes {
+		if err = z.allow(ctx, desired.Dataset, c.Grant, c.Revoke); err != nil {
+			return nil, err
+		}
+	}
+	return changes, nil
+}
+
+// grantKey identifies the grantee of permissions, the local+descendent grants are split
+type grantKey struct {
+	typ   GrantType
+	name  string
+	scope PermissionScope
+}
+
+func expandGrants(grants []Grant) (map[grantKey]map[string]bool, []grantKey) {
+	items := map[grantKey]map[string]bool{}
+	keys := make([]grantKey, 0)
+	add := func(key grantKey, perms []string) {
+		if _, ok := items[key]; !ok {
+			items[key] = map[string]bool{}
+			keys = append(keys, key)
+		}
+		for _, perm := range perms {
+			items[key][perm] = true
+		}
+	}
+	for _, g := range grants {
+		switch g.Type {
+		case GrantCreate, GrantSet:
+			add(grantKey{typ: g.Type, name: g.Name}, g.Permissions)
+		default:
+			if g.Scope != ScopeDescendent {
+				add(grantKey{g.Type, g.Name, ScopeLocal}, g.Permissions)
+			}
+			if g.Scope != ScopeLocal {
+				add(grantKey{g.Type, g.Name, ScopeDescendent}, g.Permissions)
+			}
+		}
+	}
+	return items, keys
+}
+
+// diffPermissions returns the revokes and then the grants from current to desired
+func diffPermissions(current, desired []Grant) []PermissionChange {
+	have, haveKeys := expandGrants(current)
+	want, wantKeys := expandGrants(desired)
+
+	missing := func(from, to map[string]bool) []string {
+		perms := make([]string, 0)
+		for perm := range from {
+			if !to[perm] {
+				perms = append(perms, perm)
+			}
+		}
+		sort.Strings(perms)
+		return perms
+	}
+
+	revokes, grants := make([]PermissionChange, 0), make([]PermissionChange, 0)
+	for _, key := range haveKeys {
+		if perms := missing(have[key], want[key]); len(perms) > 0 {
+			g := Grant{Type: key.typ, Name: key.name, Scope: key.scope, Permissions: perms}
+			revokes = append(revokes, PermissionChange{Revoke: true, Grant: g})
+		}
+	}
+	for _, key := range wantKeys {
+		if perms := missing(want[key], have[key]); len(perms) > 0 {
+			g := Grant{Type: key.typ, Name: key.name, Scope: key.scope, Permissions: perms}
+			grants = append(grants, PermissionChange{Grant: g})
+		}
+	}
+	return mergeScopes(append(revokes, grants...))
+}
+
+// mergeScopes merges the same local and descendent changes into a local+descendent one
+func mergeScopes(changes []PermissionChange) []PermissionChange {
+	merged := make([]PermissionChange, 0, len(changes))
+	skip := map[int]bool{}
+	for i, c := range changes {
+		if skip[i] {
+			continue
+		}
+		if c.Grant.Scope == ScopeLocal {
+			for j := i + 1; j < len(changes); j++ {
+				o := changes[j]
+				if o.Revoke == c.Revoke && o.Grant.Scope == ScopeDescendent && o.Grant.Type == c.Grant.Type &&
+					o.Grant.Name == c.Grant.Name && strings.Join(o.Grant.Permissions, ",") == strings.Join(c.Grant.Permissions, ",") {
+					c.Grant.Scope = ScopeLocalDescendent
+					skip[j] = true
+					break
+				}
+			}
+		}
+		merged = append(merged, c)
+	}
+	return merged
+}
+
+// ParsePermissions parses the output of 'zfs allow <dataset>', which lists the permissions
+// of dataset and its ancestors, e.g.
+//
+//	---- Permissions on tank/home ----------------------------------------
+//	Permission sets:
+//		@pset create,destroy,mount,snapshot
+//	Create time permissions:
+//		create,destroy,mount
+//	Local permissions:
+//		user alice create,mount
+//	Local+Descendent permissions:
+//		everyone mount
+//		group staff @pset
+func ParsePermissions(data string) []*Permissions {
+	items := make([]*Permissions, 0)
+	var current *Permissions
+	section := ""
+	for _, line := range strings.Split(data, "\n") {
+		text := strings.TrimSpace(line)
+		if len(text) == 0 {
+			continue
+		}
+		if strings.HasPrefix(text, "---- Permissions on ") {
+			name := strings.TrimPrefix(text, "---- Permissions on ")
+			name = strings.TrimSpace(strings.TrimRight(name, "-"))
+			current = &Permissions{Dataset: name}
+			items = append(items, current)
+			section = ""
+			continue
+		}
+		if current == nil {
+			continue
+		}
+		if line[0] != ' ' && line[0] != '\t' && strings.HasSuffix(text, ":") {
+			section = strings.TrimSuffix(text, ":")
+			continue
+		}
+
+		fields := strings.Fields(text)
+		var g Grant
+		switch section {
+		case "Permission sets":
+			if len(fields) != 2 {
+				continue
+			}
+			g = Grant{Type: GrantSet, Name: fields[0], Permissions: strings.Split(fields[1], ",")}
+		case "Create time permissions":
+			g = Grant{Type: GrantCreate, Permissions: strings.Split(fields[0], ",")}
+		case "Local permissions", "Descendent permissions", "Local+Descendent permissions":
+			scope := PermissionScope(strings.ToLower(strings.TrimSuffix(section, " permissions")))
+			switch {
+			case len(fields) == 2 && fields[0] == "everyone":
+				g = Grant{Type: GrantEveryone, Scope: scope, Permissions: strings.Split(fields[1], ",")}
+			case len(fields) == 3:
+				g = Grant{Type: GrantType(fields[0]), Name: fields[1], Scope: scope, Permissions: strings.Split(fields[2], ",")}
+			default:
+				continue
+			}
+		default:
+			continue
+		}
+		current.Grants = append(current.Grants, g)
+	}
+	return items
+}
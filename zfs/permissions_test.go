@@ -0,0 +1,142 @@
+// MIT License
+//
+// Copyright (c) 2021 Lack
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+// SOFTWARE.
+
+package zfs
+
+import (
+	"context"
+	"reflect"
+	"testing"
+)
+
+const zfsAllow = `---- Permissions on tank/home ----------------------------------------
+Permission sets:
+	@pset create,destroy,mount,snapshot
+Create time permissions:
+	create,destroy,mount
+Local permissions:
+	user alice create,mount
+Local+Descendent permissions:
+	everyone mount
+	group staff @pset,send
+---- Permissions on tank ---------------------------------------------
+Descendent permissions:
+	user root snapshot
+`
+
+func TestParsePermissions(t *testing.T) {
+	items := ParsePermissions(zfsAllow)
+	if len(items) != 2 || items[0].Dataset != "tank/home" || items[1].Dataset != "tank" {
+		t.Fatalf("unexpected permissions: %+v", items)
+	}
+	expect := []Grant{
+		{Type: GrantSet, Name: "@pset", Permissions: []string{"create", "destroy", "mount", "snapshot"}},
+		{Type: GrantCreate, Permissions: []string{"create", "destroy", "mount"}},
+		{Type: GrantUser, Name: "alice", Scope: ScopeLocal, Permissions: []string{"create", "mount"}},
+		{Type: GrantEveryone, Scope: ScopeLocalDescendent, Permissions: []string{"mount"}},
+		{Type: GrantGroup, Name: "staff", Scope: ScopeLocalDescendent, Permissions: []string{"@pset", "send"}},
+	}
+	if !reflect.DeepEqual(items[0].Grants, expect) {
+		t.Fatalf("expect %+v, got %+v", expect, items[0].Grants)
+	}
+	expect = []Grant{{Type: GrantUser, Name: "root", Scope: ScopeDescendent, Permissions: []string{"snapshot"}}}
+	if !reflect.DeepEqual(items[1].Grants, expect) {
+		t.Fatalf("expect %+v, got %+v", expect, items[1].Grants)
+	}
+}
+
+func TestZFSadm_Grant(t *testing.T) {
+	runner := NewFakeRunner().
+		On("zfs allow -l -u alice snapshot,send tank/home", "", nil).
+		On("zfs allow -e mount tank/home", "", nil).
+		On("zfs allow -c create,destroy tank/home", "", nil).
+		On("zfs allow -s @pset create,mount tank/home", "", nil).
+		On("zfs unallow -d -g staff send tank/home", "", nil)
+	z := New(WithRunner(runner))
+	ctx := context.TODO()
+
+	err := z.Grant(ctx, "tank/home",
+		Grant{Type: GrantUser, Name: "alice", Scope: ScopeLocal, Permissions: []string{"snapshot", "send"}},
+		Grant{Type: GrantEveryone, Permissions: []string{"mount"}},
+		Grant{Type: GrantCreate, Permissions: []string{"create", "destroy"}},
+		Grant{Type: GrantSet, Name: "@pset", Permissions: []string{"create", "mount"}},
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = z.Revoke(ctx, "tank/home", Grant{Type: GrantGroup, Name: "staff", Scope: ScopeDescendent, Permissions: []string{"send"}}); err != nil {
+		t.Fatal(err)
+	}
+	if err = z.Grant(ctx, "tank/home", Grant{Type: GrantSet, Name: "pset", Permissions: []string{"mount"}}); err == nil {
+		t.Fatal("expect error of invalid set name")
+	}
+	if len(runner.History()) != 5 {
+		t.Fatalf("unexpected history: %v", runner.History())
+	}
+}
+
+func TestZFSadm_ApplyPermissions(t *testing.T) {
+	runner := NewFakeRunner().On("zfs allow tank/home", zfsAllow, nil)
+	z := New(WithRunner(runner))
+
+	desired := &Permissions{
+		Dataset: "tank/home",
+		Grants: []Grant{
+			{Type: GrantSet, Name: "@pset", Permissions: []string{"create", "destroy", "mount", "snapshot"}},
+			{Type: GrantUser, Name: "alice", Permissions: []string{"create", "mount", "snapshot"}},
+			{Type: GrantEveryone, Scope: ScopeDescendent, Permissions: []string{"mount"}},
+			{Type: GrantGroup, Name: "staff", Permissions: []string{"@pset", "send"}},
+			{Type: GrantUser, Name: "bob", Permissions: []string{"hold"}},
+		},
+	}
+	changes, err := z.ApplyPermissions(context.TODO(), desired, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expect := []string{
+		"unallow create time create,destroy,mount",
+		"unallow local everyone mount",
+		"allow local user alice snapshot",
+		"allow descendent user alice create,mount,snapshot",
+		"allow local+descendent user bob hold",
+	}
+	got := make([]string, 0)
+	for _, c := range changes {
+		got = append(got, c.String())
+	}
+	if !reflect.DeepEqual(got, expect) {
+		t.Fatalf("expect %v, got %v", expect, got)
+	}
+	if len(runner.History()) != 1 {
+		t.Fatalf("dry run must not change permissions: %v", runner.History())
+	}
+
+	runner.
+		On("zfs unallow -c create,destroy,mount tank/home", "", nil).
+		On("zfs unallow -l -e mount tank/home", "", nil).
+		On("zfs allow -l -u alice snapshot tank/home", "", nil).
+		On("zfs allow -d -u alice create,mount,snapshot tank/home", "", nil).
+		On("zfs allow -u bob hold tank/home", "", nil)
+	if _, err = z.ApplyPermissions(context.TODO(), desired, false); err != nil {
+		t.Fatal(err)
+	}
+}
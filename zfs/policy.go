@@ -0,0 +1,281 @@
+// MIT License
+//
+// Copyright (c) 2021 Lack
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+// SOFTWARE.
+
+package zfs
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Period the period of snapshots created by SnapshotPolicy
+type Period string
+
+const (
+	Hourly  Period = "hourly"
+	Daily   Period = "daily"
+	Weekly  Period = "weekly"
+	Monthly Period = "monthly"
+)
+
+// periods in order of the length
+var periods = []Period{Hourly, Daily, Weekly, Monthly}
+
+// snapshotTimeLayout the layout of time in the name of snapshot created by SnapshotPolicy, in UTC
+const snapshotTimeLayout = "2006-01-02-1504"
+
+// bucket returns the identity of period which t belongs to
+func (p Period) bucket(t time.Time) string {
+	t = t.UTC()
+	switch p {
+	case Hourly:
+		return t.Format("2006-01-02-15")
+	case Daily:
+		return t.Format("2006-01-02")
+	case Weekly:
+		year, week := t.ISOWeek()
+		return fmt.Sprintf("%d-W%02d", year, week)
+	case Monthly:
+		return t.Format("2006-01")
+	}
+	return ""
+}
+
+// SnapshotPolicy describes how snapshots of dataset are created and retained,
+// snapshots are named as "<dataset>@<prefix>-<period>-<time>", e.g. "tank/a@auto-daily-2021-06-01-0000".
+// The snapshots which are not named by the policy are never touched.
+type SnapshotPolicy struct {
+	// Prefix the prefix of snapshot name, defaults to "auto"
+	Prefix string `json:"prefix"`
+	// Hourly the count of hourly snapshots to keep, 0 disables hourly snapshots
+	Hourly int `json:"hourly"`
+	// Daily the count of daily snapshots to keep, 0 disables daily snapshots
+	Daily int `json:"daily"`
+	// Weekly the count of weekly snapshots to keep, 0 disables weekly snapshots
+	Weekly int `json:"weekly"`
+	// Monthly the count of monthly snapshots to keep, 0 disables monthly snapshots
+	Monthly int `json:"monthly"`
+	// Recursive creates and destroys snapshots of descendent datasets too
+	Recursive bool `json:"recursive"`
+}
+
+func (p *SnapshotPolicy) prefix() string {
+	if len(p.Prefix) == 0 {
+		return "auto"
+	}
+	return p.Prefix
+}
+
+func (p *SnapshotPolicy) keep(period Period) int {
+	switch period {
+	case Hourly:
+		return p.Hourly
+	case Daily:
+		return p.Daily
+	case Weekly:
+		return p.Weekly
+	case Monthly:
+		return p.Monthly
+	}
+	return 0
+}
+
+// SnapshotName returns the name of snapshot which is created for period at t
+func (p *SnapshotPolicy) SnapshotName(dataset string, period Period, t time.Time) string {
+	return fmt.Sprintf("%s@%s-%s-%s", dataset, p.prefix(), period, t.UTC().Format(snapshotTimeLayout))
+}
+
+// parse returns the period and time of snapshot if it's named by the policy
+func (p *SnapshotPolicy) parse(dataset, name string) (Period, time.Time, bool) {
+	short := strings.TrimPrefix(name, dataset+"@")
+	if short == name {
+		return "", time.Time{}, false
+	}
+	for _, period := range periods {
+		head := p.prefix() + "-" + string(period) + "-"
+		if !strings.HasPrefix(short, head) {
+			continue
+		}
+		t, err := time.ParseInLocation(snapshotTimeLayout, strings.TrimPrefix(short, head), time.UTC)
+		if err != nil {
+			return "", time.Time{}, false
+		}
+		return period, t, true
+	}
+	return "", time.Time{}, false
+}
+
+// SnapshotPlan the snapshots to create and destroy for a dataset
+type SnapshotPlan struct {
+	Dataset   string `json:"dataset"`
+	Recursive bool   `json:"recursive"`
+	// Create the snapshots to create
+	Create []string `json:"create"`
+	// Prune the snapshots to destroy
+	Prune []string `json:"prune"`
+	// Held the snapshots which are out of retention but kept by holds (zfs hold)
+	Held []string `json:"held"`
+}
+
+// Plan computes which snapshots of dataset to create and prune at now, snapshots
+// are the existing snapshots of dataset, Snapshot.Userrefs is used to respect holds.
+// If the policy is Recursive, snapshots could include the snapshots of descendants too,
+// the snapshot is held if any of its namesakes in descendants is held, because
+// 'zfs destroy -r' fails on the held one.
+func (p *SnapshotPolicy) Plan(dataset string, now time.Time, snapshots []*Snapshot) *SnapshotPlan {
+	plan := &SnapshotPlan{
+		Dataset:   dataset,
+		Recursive: p.Recursive,
+		Create:    []string{},
+		Prune:     []string{},
+		Held:      []string{},
+	}
+
+	type item struct {
+		name string
+		t    time.Time
+		held bool
+	}
+
+	// the short names of snapshots held in descendants
+	descendants := map[string]bool{}
+	if p.Recursive {
+		for _, snapshot := range snapshots {
+			i := strings.Index(snapshot.Name, "@")
+			if i < 0 || !strings.HasPrefix(snapshot.Name[:i], dataset+"/") {
+				continue
+			}
+			if refs, _ := strconv.ParseInt(snapshot.Userrefs, 10, 64); refs > 0 {
+				descendants[snapshot.Name[i+1:]] = true
+			}
+		}
+	}
+
+	owned := map[Period][]item{}
+	for _, snapshot := range snapshots {
+		period, t, ok := p.parse(dataset, snapshot.Name)
+		if !ok {
+			continue
+		}
+		refs, _ := strconv.ParseInt(snapshot.Userrefs, 10, 64)
+		held := refs > 0 || descendants[strings.TrimPrefix(snapshot.Name, dataset+"@")]
+		owned[period] = append(owned[period], item{name: snapshot.Name, t: t, held: held})
+	}
+
+	for _, period := range periods {
+		keep := p.keep(period)
+		if keep <= 0 {
+			continue
+		}
+
+		items := owned[period]
+		sort.Slice(items, func(i, j int) bool { return items[i].t.After(items[j].t) })
+
+		if len(items) == 0 || period.bucket(items[0].t) != period.bucket(now) {
+			name := p.SnapshotName(dataset, period, now)
+			plan.Create = append(plan.Create, name)
+			items = append([]item{{name: name, t: now}}, items...)
+		}
+
+		if len(items) <= keep {
+			continue
+		}
+		for _, it := range items[keep:] {
+			if it.held {
+				plan.Held = append(plan.Held, it.name)
+			} else {
+				plan.Prune = append(plan.Prune, it.name)
+			}
+		}
+	}
+
+	return plan
+}
+
+// PlanSnapshotPolicy computes SnapshotPlan of dataset by the existing snapshots, the snapshots
+// of descendants are listed too if the policy is Recursive
+func (z *ZFSadm) PlanSnapshotPolicy(ctx context.Context, dataset string, policy *SnapshotPolicy) (*SnapshotPlan, error) {
+	options, depth := "-Hp", "-d 1"
+	if policy.Recursive {
+		options, depth = "-Hp -r", ""
+	}
+	execute := z.RawZFS().List(ctx, dataset, options, depth, []string{"name", "userrefs"}, "", "", "snapshot")
+	data, err := execute.Exec()
+	if err != nil {
+		return nil, err
+	}
+
+	snapshots := make([]*Snapshot, 0)
+	for _, line := range strings.Split(string(data), "\n") {
+		parts := strings.Split(strings.TrimSpace(line), "\t")
+		if len(parts) != 2 {
+			continue
+		}
+		parent := strings.SplitN(parts[0], "@", 2)[0]
+		snapshots = append(snapshots, &Snapshot{Name: parts[0], Parent: parent, Userrefs: parts[1]})
+	}
+
+	return policy.Plan(dataset, time.Now(), snapshots), nil
+}
+
+// ExecuteSnapshotPlan creates and prunes snapshots by plan, the snapshots are created
+// atomically before pruning.
+func (z *ZFSadm) ExecuteSnapshotPlan(ctx context.Context, plan *SnapshotPlan) error {
+	if len(plan.Create) > 0 {
+		execute := z.RawZFS().Snapshot2(ctx, plan.Recursive, nil, plan.Create...)
+		if _, err := execute.Exec(); err != nil {
+			return err
+		}
+	}
+
+	options := ""
+	if plan.Recursive {
+		options = "-r"
+	}
+	for _, name := range plan.Prune {
+		execute := z.RawZFS().DestroySnapshot(ctx, name, options)
+		if _, err := execute.Exec(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// ApplySnapshotPolicy applies SnapshotPolicy to dataset, returns the plan which is executed.
+// If dryRun is true, the plan is returned without touching the pool.
+func (z *ZFSadm) ApplySnapshotPolicy(ctx context.Context, dataset string, policy *SnapshotPolicy, dryRun bool) (*SnapshotPlan, error) {
+	plan, err := z.PlanSnapshotPolicy(ctx, dataset, policy)
+	if err != nil {
+		return nil, err
+	}
+	if dryRun {
+		return plan, nil
+	}
+	if err = z.ExecuteSnapshotPlan(ctx, plan); err != nil {
+		return plan, err
+	}
+	return plan, nil
+}
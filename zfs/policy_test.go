@@ -0,0 +1,99 @@
+// MIT License
+//
+// Copyright (c) 2021 Lack
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+// SOFTWARE.
+
+package zfs
+
+import (
+	"context"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestSnapshotPolicy_Plan(t *testing.T) {
+	policy := &SnapshotPolicy{Hourly: 2, Daily: 1}
+	now := time.Date(2021, 6, 2, 10, 30, 0, 0, time.UTC)
+	snapshots := []*Snapshot{
+		{Name: "tank/a@auto-hourly-2021-06-02-0900", Userrefs: "0"},
+		{Name: "tank/a@auto-hourly-2021-06-02-0800", Userrefs: "1"},
+		{Name: "tank/a@auto-hourly-2021-06-02-0700", Userrefs: "0"},
+		{Name: "tank/a@auto-daily-2021-06-02-0000", Userrefs: "0"},
+		{Name: "tank/a@auto-daily-2021-06-01-0000", Userrefs: "0"},
+		{Name: "tank/a@manual", Userrefs: "0"},
+		{Name: "tank/a@auto-weekly-2021-05-31-0000", Userrefs: "0"},
+	}
+
+	plan := policy.Plan("tank/a", now, snapshots)
+	if expect := []string{"tank/a@auto-hourly-2021-06-02-1030"}; !reflect.DeepEqual(plan.Create, expect) {
+		t.Fatalf("expect create %v, got %v", expect, plan.Create)
+	}
+	expect := []string{"tank/a@auto-hourly-2021-06-02-0700", "tank/a@auto-daily-2021-06-01-0000"}
+	if !reflect.DeepEqual(plan.Prune, expect) {
+		t.Fatalf("expect prune %v, got %v", expect, plan.Prune)
+	}
+	if expect := []string{"tank/a@auto-hourly-2021-06-02-0800"}; !reflect.DeepEqual(plan.Held, expect) {
+		t.Fatalf("expect held %v, got %v", expect, plan.Held)
+	}
+}
+
+func TestSnapshotPolicy_PlanRecursive(t *testing.T) {
+	policy := &SnapshotPolicy{Daily: 1, Recursive: true}
+	now := time.Date(2021, 6, 3, 10, 30, 0, 0, time.UTC)
+	snapshots := []*Snapshot{
+		{Name: "tank/a@auto-daily-2021-06-02-0000", Userrefs: "0"},
+		{Name: "tank/a@auto-daily-2021-06-01-0000", Userrefs: "0"},
+		{Name: "tank/a/b@auto-daily-2021-06-02-0000", Userrefs: "0"},
+		{Name: "tank/a/b@auto-daily-2021-06-01-0000", Userrefs: "1"},
+		{Name: "tank/ab@auto-daily-2021-06-02-0000", Userrefs: "1"},
+	}
+
+	plan := policy.Plan("tank/a", now, snapshots)
+	if expect := []string{"tank/a@auto-daily-2021-06-02-0000"}; !reflect.DeepEqual(plan.Prune, expect) {
+		t.Fatalf("expect prune %v, got %v", expect, plan.Prune)
+	}
+	if expect := []string{"tank/a@auto-daily-2021-06-01-0000"}; !reflect.DeepEqual(plan.Held, expect) {
+		t.Fatalf("expect held %v, got %v", expect, plan.Held)
+	}
+}
+
+func TestZFSadm_ApplySnapshotPolicy(t *testing.T) {
+	runner := NewFakeRunner().
+		On("zfs list -Hp -r -o name,userrefs -t snapshot tank/a", "", nil)
+
+	z := New(WithRunner(runner))
+	policy := &SnapshotPolicy{Prefix: "bk", Daily: 7, Recursive: true}
+	plan, err := z.ApplySnapshotPolicy(context.TODO(), "tank/a", policy, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(plan.Create) != 1 || len(plan.Prune) != 0 {
+		t.Fatalf("unexpected plan: %+v", plan)
+	}
+	if len(runner.History()) != 1 {
+		t.Fatalf("dry run must not touch the pool: %v", runner.History())
+	}
+
+	runner.On("zfs snapshot -r "+plan.Create[0], "", nil)
+	if err = z.ExecuteSnapshotPlan(context.TODO(), plan); err != nil {
+		t.Fatal(err)
+	}
+}
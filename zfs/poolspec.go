@@ -0,0 +1,472 @@
+// MIT License
+//
+// Copyright (c) 2021 Lack
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+// SOFTWARE.
+
+package zfs
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+var ErrInvalidPoolSpec = errors.New("invalid pool specification")
+
+// VdevGroup the type of top-level vdev group in PoolSpec
+type VdevGroup string
+
+const (
+	// GroupStripe each device of group is a top-level vdev
+	GroupStripe VdevGroup = ""
+	GroupMirror VdevGroup = "mirror"
+	GroupRaidz1 VdevGroup = "raidz1"
+	GroupRaidz2 VdevGroup = "raidz2"
+	GroupRaidz3 VdevGroup = "raidz3"
+)
+
+// parity returns the number of parity devices, -1 if the type is unknown
+func (g VdevGroup) parity() int {
+	switch g {
+	case GroupStripe, GroupMirror:
+		return 0
+	case "raidz", GroupRaidz1:
+		return 1
+	case GroupRaidz2:
+		return 2
+	case GroupRaidz3:
+		return 3
+	}
+	return -1
+}
+
+// normalize returns the canonical name of group, e.g. "raidz" is "raidz1"
+func (g VdevGroup) normalize() VdevGroup {
+	if g == "raidz" {
+		return GroupRaidz1
+	}
+	return g
+}
+
+// least returns the minimum number of devices of group
+func (g VdevGroup) least() int {
+	switch g.normalize() {
+	case GroupStripe:
+		return 1
+	case GroupMirror:
+		return 2
+	}
+	return g.parity() + 1
+}
+
+// VdevSpec the specification of a top-level vdev group
+type VdevSpec struct {
+	Type    VdevGroup `json:"type,omitempty"`
+	Devices []string  `json:"devices"`
+}
+
+// args returns the vdev arguments of group
+func (v VdevSpec) args() []string {
+	args := make([]string, 0, len(v.Devices)+1)
+	if v.Type != GroupStripe {
+		args = append(args, string(v.Type))
+	}
+	return append(args, v.Devices...)
+}
+
+// PoolSpec describes the vdev tree of pool as well as the properties of pool and its root dataset,
+// e.g. two raidz2 groups with a mirrored log, a cache device and a hot spare:
+//
+//	spec := &PoolSpec{
+//		Name: "tank",
+//		Data: []VdevSpec{
+//			{Type: GroupRaidz2, Devices: []string{"sda", "sdb", "sdc", "sdd"}},
+//			{Type: GroupRaidz2, Devices: []string{"sde", "sdf", "sdg", "sdh"}},
+//		},
+//		Logs:   []VdevSpec{{Type: GroupMirror, Devices: []string{"nvme0n1", "nvme1n1"}}},
+//		Caches: []string{"nvme2n1"},
+//		Spares: []string{"sdi"},
+//		Ashift: 12,
+//	}
+type PoolSpec struct {
+	Name     string     `json:"name"`
+	Data     []VdevSpec `json:"data,omitempty"`
+	Logs     []VdevSpec `json:"logs,omitempty"`
+	Specials []VdevSpec `json:"specials,omitempty"`
+	Dedups   []VdevSpec `json:"dedups,omitempty"`
+	Caches   []string   `json:"caches,omitempty"`
+	Spares   []string   `json:"spares,omitempty"`
+
+	// Ashift the sector size exponent of vdevs, 0 lets zfs detect it
+	Ashift   int  `json:"ashift,omitempty"`
+	Autotrim bool `json:"autotrim,omitempty"`
+	// AltRoot the alternate root directory of pool (zpool create -R)
+	AltRoot    string `json:"altRoot,omitempty"`
+	Mountpoint string `json:"mountpoint,omitempty"`
+	// Features the feature flags to enable, all features are enabled if it's empty,
+	// otherwise the others are disabled (zpool create -d)
+	Features []string `json:"features,omitempty"`
+	// Properties the other pool properties (zpool create -o)
+	Properties map[string]string `json:"properties,omitempty"`
+	// FileSystemProperties the properties of root dataset (zpool create -O)
+	FileSystemProperties map[string]string `json:"fileSystemProperties,omitempty"`
+	// Force overrides the mismatched replication levels and the devices in use
+	Force bool `json:"force,omitempty"`
+}
+
+// VdevArgs returns the vdev specification of zpool create and zpool add
+func (s *PoolSpec) VdevArgs() []string {
+	args := make([]string, 0)
+	for _, v := range s.Data {
+		args = append(args, v.args()...)
+	}
+	classes := []struct {
+		keyword string
+		groups  []VdevSpec
+	}{
+		{"special", s.Specials},
+		{"dedup", s.Dedups},
+		{"log", s.Logs},
+	}
+	for _, c := range classes {
+		if len(c.groups) == 0 {
+			continue
+		}
+		args = append(args, c.keyword)
+		for _, v := range c.groups {
+			args = append(args, v.args()...)
+		}
+	}
+	if len(s.Caches) > 0 {
+		args = append(args, "cache")
+		args = append(args, s.Caches...)
+	}
+	if len(s.Spares) > 0 {
+		args = append(args, "spare")
+		args = append(args, s.Spares...)
+	}
+	return args
+}
+
+// Devices returns all devices of spec
+func (s *PoolSpec) Devices() []string {
+	devices := make([]string, 0)
+	for _, groups := range [][]VdevSpec{s.Data, s.Specials, s.Dedups, s.Logs} {
+		for _, v := range groups {
+			devices = append(devices, v.Devices...)
+		}
+	}
+	devices = append(devices, s.Caches...)
+	return append(devices, s.Spares...)
+}
+
+// poolProperties returns the properties of zpool create -o
+func (s *PoolSpec) poolProperties() map[string]string {
+	properties := make(map[string]string, len(s.Properties)+len(s.Features)+2)
+	for k, v := range s.Properties {
+		properties[k] = v
+	}
+	if s.Ashift > 0 {
+		properties["ashift"] = strconv.Itoa(s.Ashift)
+	}
+	if s.Autotrim {
+		properties["autotrim"] = "on"
+	}
+	for _, feature := range s.Features {
+		properties["feature@"+feature] = "enabled"
+	}
+	return properties
+}
+
+// Validate checks the spec of new pool before zpool create
+func (s *PoolSpec) Validate() error {
+	if err := validatePoolName(s.Name); err != nil {
+		return err
+	}
+	if len(s.Data) == 0 {
+		return fmt.Errorf("%w: pool %s has no data vdev", ErrInvalidPoolSpec, s.Name)
+	}
+	return s.validate()
+}
+
+// ValidateExpansion checks the spec of vdevs added to the existing pool, the new groups must
+// match the replication level of current groups, unless Force is set.
+func (s *PoolSpec) ValidateExpansion(current *PoolStatus) error {
+	if len(s.Devices()) == 0 {
+		return fmt.Errorf("%w: no device to add", ErrInvalidPoolSpec)
+	}
+	if err := s.validate(); err != nil {
+		return err
+	}
+	if s.Force || current == nil {
+		return nil
+	}
+
+	classes := []struct {
+		name     string
+		groups   []VdevSpec
+		existing *Vdev
+		vdevs    []*Vdev
+	}{
+		{name: "data", groups: s.Data, existing: current.Root},
+		{name: "log", groups: s.Logs, vdevs: current.Logs},
+		{name: "special", groups: s.Specials, vdevs: current.Specials},
+		{name: "dedup", groups: s.Dedups, vdevs: current.Dedups},
+	}
+	for _, c := range classes {
+		vdevs := c.vdevs
+		if c.existing != nil {
+			vdevs = c.existing.Children
+		}
+		if len(c.groups) == 0 || len(vdevs) == 0 {
+			continue
+		}
+		typ, width := statusGroup(vdevs[0])
+		for _, v := range c.groups {
+			if v.Type.normalize() != typ || (typ != GroupStripe && len(v.Devices) != width) {
+				return fmt.Errorf("%w: mismatched replication level, pool %s uses %s, but %s is added to %s vdevs",
+					ErrInvalidPoolSpec, current.Name, groupName(typ, width), groupName(v.Type.normalize(), len(v.Devices)), c.name)
+			}
+		}
+	}
+	return nil
+}
+
+func (s *PoolSpec) validate() error {
+	seen := map[string]bool{}
+	for _, device := range s.Devices() {
+		if len(device) == 0 {
+			return fmt.Errorf("%w: empty device name", ErrInvalidPoolSpec)
+		}
+		if seen[device] {
+			return fmt.Errorf("%w: device %s is specified more than once", ErrInvalidPoolSpec, device)
+		}
+		seen[device] = true
+	}
+
+	classes := []struct {
+		name   string
+		groups []VdevSpec
+	}{
+		{"data", s.Data},
+		{"special", s.Specials},
+		{"dedup", s.Dedups},
+		{"log", s.Logs},
+	}
+	for _, c := range classes {
+		for i, v := range c.groups {
+			if v.Type.parity() < 0 {
+				return fmt.Errorf("%w: unknown type %q of %s vdev", ErrInvalidPoolSpec, v.Type, c.name)
+			}
+			if c.name == "log" && v.Type.parity() > 0 {
+				return fmt.Errorf("%w: log vdev must be stripe or mirror", ErrInvalidPoolSpec)
+			}
+			if least := v.Type.least(); len(v.Devices) < least {
+				return fmt.Errorf("%w: %s vdev requires at least %d devices, got %d",
+					ErrInvalidPoolSpec, groupName(v.Type.normalize(), 0), least, len(v.Devices))
+			}
+			if s.Force || i == 0 {
+				continue
+			}
+			first := c.groups[0]
+			if v.Type.normalize() != first.Type.normalize() || (v.Type != GroupStripe && len(v.Devices) != len(first.Devices)) {
+				return fmt.Errorf("%w: mismatched replication level of %s vdevs, %s and %s", ErrInvalidPoolSpec, c.name,
+					groupName(first.Type.normalize(), len(first.Devices)), groupName(v.Type.normalize(), len(v.Devices)))
+			}
+		}
+	}
+
+	if s.Ashift != 0 && (s.Ashift < 9 || s.Ashift > 16) {
+		return fmt.Errorf("%w: ashift %d is out of range 9-16", ErrInvalidPoolSpec, s.Ashift)
+	}
+	if len(s.AltRoot) > 0 && !filepath.IsAbs(s.AltRoot) {
+		return fmt.Errorf("%w: altroot %s must be an absolute path", ErrInvalidPoolSpec, s.AltRoot)
+	}
+	if mp := s.Mountpoint; len(mp) > 0 && mp != "none" && mp != "legacy" && !filepath.IsAbs(mp) {
+		return fmt.Errorf("%w: mountpoint %s must be an absolute path", ErrInvalidPoolSpec, mp)
+	}
+	for _, feature := range s.Features {
+		if len(feature) == 0 || strings.ContainsAny(feature, "@= ") {
+			return fmt.Errorf("%w: invalid feature %q", ErrInvalidPoolSpec, feature)
+		}
+	}
+	for k := range s.Properties {
+		if len(k) == 0 || strings.ContainsAny(k, "= ") {
+			return fmt.Errorf("%w: invalid pool property %q", ErrInvalidPoolSpec, k)
+		}
+	}
+	for k := range s.FileSystemProperties {
+		if len(k) == 0 || strings.ContainsAny(k, "= ") {
+			return fmt.Errorf("%w: invalid file system property %q", ErrInvalidPoolSpec, k)
+		}
+	}
+	return nil
+}
+
+// reservedPoolNames the prefixes which are conflict with the vdev specification
+var reservedPoolNames = []string{"mirror", "raidz", "draid", "spare"}
+
+func validatePoolName(name string) error {
+	if len(name) == 0 {
+		return fmt.Errorf("%w: empty pool name", ErrInvalidName)
+	}
+	if c := name[0]; !(c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z') {
+		return fmt.Errorf("%w: pool name %s must begin with a letter", ErrInvalidName, name)
+	}
+	for _, c := range name {
+		if !(c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c >= '0' && c <= '9' || strings.ContainsRune("_-.: ", c)) {
+			return fmt.Errorf("%w: invalid character %q in pool name %s", ErrInvalidName, c, name)
+		}
+	}
+	for _, reserved := range reservedPoolNames {
+		if strings.HasPrefix(name, reserved) {
+			return fmt.Errorf("%w: pool name %s is reserved", ErrInvalidName, name)
+		}
+	}
+	if name == "log" {
+		return fmt.Errorf("%w: pool name %s is reserved", ErrInvalidName, name)
+	}
+	return nil
+}
+
+// statusGroup returns the group type and width of top-level vdev in zpool status
+func statusGroup(v *Vdev) (VdevGroup, int) {
+	switch v.Type {
+	case VdevMirror:
+		return GroupMirror, len(v.Children)
+	case VdevRaidz:
+		// e.g. raidz2-0
+		name := v.Name
+		if i := strings.LastIndex(name, "-"); i > 0 {
+			name = name[:i]
+		}
+		return VdevGroup(name).normalize(), len(v.Children)
+	}
+	return GroupStripe, 1
+}
+
+func groupName(typ VdevGroup, width int) string {
+	name := string(typ)
+	if typ == GroupStripe {
+		name = "stripe"
+	}
+	if width > 0 && typ != GroupStripe {
+		name = fmt.Sprintf("%d-way %s", width, name)
+	}
+	return name
+}
+
+// ValidatePoolSpec checks the spec of new pool, as well as the devices aren't used by existing pools
+func (z *ZFSadm) ValidatePoolSpec(ctx context.Context, spec *PoolSpec) error {
+	if err := spec.Validate(); err != nil {
+		return err
+	}
+	if _, err := z.getPool(ctx, spec.Name); err == nil {
+		return fmt.Errorf("%w: %s", ErrPoolExists, spec.Name)
+	}
+	return z.checkDevicesInUse(ctx, spec)
+}
+
+// checkDevicesInUse returns ErrDeviceInUse if any device of spec is part of an existing pool
+func (z *ZFSadm) checkDevicesInUse(ctx context.Context, spec *PoolSpec) error {
+	if spec.Force {
+		return nil
+	}
+	return z.devicesInUse(ctx, spec.Devices()...)
+}
+
+// devicesInUse returns ErrDeviceInUse if any of devices is part of imported pool
+func (z *ZFSadm) devicesInUse(ctx context.Context, devices ...string) error {
+	statuses, err := z.GetPoolStatuses(ctx)
+	if err != nil {
+		return err
+	}
+	used := map[string]string{}
+	for _, status := range statuses {
+		for _, v := range status.Devices() {
+			used[v.Name] = status.Name
+			used[filepath.Base(v.Name)] = status.Name
+		}
+	}
+	for _, device := range devices {
+		for _, name := range []string{device, filepath.Base(device)} {
+			if pool, ok := used[name]; ok {
+				return fmt.Errorf("%w: %s is part of pool %s", ErrDeviceInUse, device, pool)
+			}
+		}
+	}
+	return nil
+}
+
+// CreatePool creates pool by the given spec after the validation
+func (z *ZFSadm) CreatePool(ctx context.Context, spec *PoolSpec) (*Pool, error) {
+	if err := z.ValidatePoolSpec(ctx, spec); err != nil {
+		return nil, err
+	}
+	if err := z.createPool(ctx, spec); err != nil {
+		return nil, err
+	}
+	return z.GetPool(ctx, spec.Name)
+}
+
+func (z *ZFSadm) createPool(ctx context.Context, spec *PoolSpec) error {
+	options := make([]string, 0)
+	if spec.Force {
+		options = append(options, "-f")
+	}
+	if len(spec.Features) > 0 {
+		options = append(options, "-d")
+	}
+	execute := z.RawZPool().Create2(ctx, spec.Name, strings.Join(options, " "), spec.Mountpoint, spec.AltRoot,
+		spec.poolProperties(), spec.FileSystemProperties, spec.VdevArgs()...)
+	_, err := execute.Exec()
+	return err
+}
+
+// ExpensePool adds the vdevs of spec to the existing pool after the validation, the name of spec
+// is ignored. Only Ashift of pool properties takes effect.
+func (z *ZFSadm) ExpensePool(ctx context.Context, name string, spec *PoolSpec) (*Pool, error) {
+	status, err := z.GetPoolStatus(ctx, name)
+	if err != nil {
+		return nil, err
+	}
+	if err = spec.ValidateExpansion(status); err != nil {
+		return nil, err
+	}
+	if err = z.checkDevicesInUse(ctx, spec); err != nil {
+		return nil, err
+	}
+
+	options := make([]string, 0)
+	if spec.Force {
+		options = append(options, "-f")
+	}
+	if spec.Ashift > 0 {
+		options = append(options, "-o", "ashift="+strconv.Itoa(spec.Ashift))
+	}
+	execute := z.RawZPool().Add(ctx, name, strings.Join(options, " "), spec.VdevArgs()...)
+	if _, err = execute.Exec(); err != nil {
+		return nil, err
+	}
+	return z.GetPool(ctx, name)
+}
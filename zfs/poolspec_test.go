@@ -0,0 +1,203 @@
+// MIT License
+//
+// Copyright (c) 2021 Lack
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+// SOFTWARE.
+
+package zfs
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func testPoolSpec() *PoolSpec {
+	return &PoolSpec{
+		Name: "data",
+		Data: []VdevSpec{
+			{Type: GroupRaidz2, Devices: []string{"sdc", "sdd", "sde", "sdf"}},
+			{Type: GroupRaidz2, Devices: []string{"sdg", "sdh", "sdi", "sdj"}},
+		},
+		Logs:     []VdevSpec{{Type: GroupMirror, Devices: []string{"nvme0n1", "nvme1n1"}}},
+		Specials: []VdevSpec{{Type: GroupMirror, Devices: []string{"nvme2n1", "nvme3n1"}}},
+		Caches:   []string{"nvme4n1"},
+		Spares:   []string{"sdk"},
+		Ashift:   12,
+		Autotrim: true,
+		Features: []string{"lz4_compress"},
+		FileSystemProperties: map[string]string{
+			"compression": "lz4",
+			"atime":       "off",
+		},
+	}
+}
+
+func TestPoolSpec_VdevArgs(t *testing.T) {
+	spec := testPoolSpec()
+	expect := "raidz2 sdc sdd sde sdf raidz2 sdg sdh sdi sdj special mirror nvme2n1 nvme3n1 " +
+		"log mirror nvme0n1 nvme1n1 cache nvme4n1 spare sdk"
+	if args := strings.Join(spec.VdevArgs(), " "); args != expect {
+		t.Fatalf("expect %s, got %s", expect, args)
+	}
+	if devices := spec.Devices(); len(devices) != 14 {
+		t.Fatalf("unexpected devices: %v", devices)
+	}
+}
+
+func TestPoolSpec_Validate(t *testing.T) {
+	tests := []struct {
+		name   string
+		modify func(spec *PoolSpec)
+		err    error
+	}{
+		{"valid", func(spec *PoolSpec) {}, nil},
+		{"reserved name", func(spec *PoolSpec) { spec.Name = "mirror1" }, ErrInvalidName},
+		{"no data", func(spec *PoolSpec) { spec.Data = nil }, ErrInvalidPoolSpec},
+		{"duplicate", func(spec *PoolSpec) { spec.Spares = []string{"sdc"} }, ErrInvalidPoolSpec},
+		{"mismatched width", func(spec *PoolSpec) { spec.Data[1].Devices = spec.Data[1].Devices[:3] }, ErrInvalidPoolSpec},
+		{"mismatched type", func(spec *PoolSpec) { spec.Data[1].Type = GroupRaidz1 }, ErrInvalidPoolSpec},
+		{"force", func(spec *PoolSpec) { spec.Data[1].Type, spec.Force = GroupRaidz1, true }, nil},
+		{"too few devices", func(spec *PoolSpec) { spec.Logs[0].Devices = spec.Logs[0].Devices[:1] }, ErrInvalidPoolSpec},
+		{"raidz log", func(spec *PoolSpec) { spec.Logs[0].Type = GroupRaidz1 }, ErrInvalidPoolSpec},
+		{"unknown type", func(spec *PoolSpec) { spec.Data[0].Type = "raidz4" }, ErrInvalidPoolSpec},
+		{"ashift", func(spec *PoolSpec) { spec.Ashift = 20 }, ErrInvalidPoolSpec},
+		{"altroot", func(spec *PoolSpec) { spec.AltRoot = "mnt" }, ErrInvalidPoolSpec},
+		{"feature", func(spec *PoolSpec) { spec.Features = []string{"feature@lz4_compress"} }, ErrInvalidPoolSpec},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			spec := testPoolSpec()
+			tt.modify(spec)
+			err := spec.Validate()
+			if tt.err == nil && err != nil || tt.err != nil && !errors.Is(err, tt.err) {
+				t.Fatalf("expect %v, got %v", tt.err, err)
+			}
+		})
+	}
+}
+
+func TestZFSadm_CreatePool(t *testing.T) {
+	sim, z := newTestSimulator(t)
+	ctx := context.TODO()
+
+	spec := testPoolSpec()
+	spec.Spares = []string{"sda"}
+	if _, err := z.CreatePool(ctx, spec); !errors.Is(err, ErrDeviceInUse) {
+		t.Fatalf("expect ErrDeviceInUse, got %v", err)
+	}
+
+	spec = testPoolSpec()
+	pool, err := z.CreatePool(ctx, spec)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if pool.Compression != "lz4" || pool.AShift != "12" {
+		t.Fatalf("unexpected pool: %+v", pool)
+	}
+	history := sim.History()
+	expect := "zpool create -d -o ashift=12 -o autotrim=on -o feature@lz4_compress=enabled " +
+		"-O atime=off -O compression=lz4 data " + strings.Join(spec.VdevArgs(), " ")
+	found := false
+	for _, line := range history {
+		found = found || line == expect
+	}
+	if !found {
+		t.Fatalf("expect %s in %v", expect, history)
+	}
+
+	status, err := z.GetPoolStatus(ctx, "data")
+	if err != nil {
+		t.Fatal(err)
+	}
+	names := make([]string, 0)
+	for _, v := range status.Root.Children {
+		names = append(names, v.Name)
+	}
+	if !reflect.DeepEqual(names, []string{"raidz2-0", "raidz2-1"}) || len(status.Logs) != 1 ||
+		len(status.Specials) != 1 || len(status.Caches) != 1 || len(status.Spares) != 1 {
+		t.Fatalf("unexpected status: %+v", status)
+	}
+
+	if err = z.ValidatePoolSpec(ctx, spec); !errors.Is(err, ErrPoolExists) {
+		t.Fatalf("expect ErrPoolExists, got %v", err)
+	}
+}
+
+func TestZFSadm_ExpensePool(t *testing.T) {
+	_, z := newTestSimulator(t)
+	ctx := context.TODO()
+
+	spec := &PoolSpec{Data: []VdevSpec{{Type: GroupMirror, Devices: []string{"sdc", "sdd", "sde"}}}}
+	if _, err := z.ExpensePool(ctx, "tank", spec); !errors.Is(err, ErrInvalidPoolSpec) {
+		t.Fatalf("expect ErrInvalidPoolSpec, got %v", err)
+	}
+	spec = &PoolSpec{Data: []VdevSpec{{Type: GroupMirror, Devices: []string{"sdb", "sdc"}}}}
+	if _, err := z.ExpensePool(ctx, "tank", spec); !errors.Is(err, ErrDeviceInUse) {
+		t.Fatalf("expect ErrDeviceInUse, got %v", err)
+	}
+
+	spec = &PoolSpec{
+		Data:   []VdevSpec{{Type: GroupMirror, Devices: []string{"sdc", "sdd"}}},
+		Caches: []string{"sde"},
+	}
+	pool, err := z.ExpensePool(ctx, "tank", spec)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if pool.SizeBytes != 20<<30 {
+		t.Fatalf("unexpected size of pool: %d", pool.SizeBytes)
+	}
+	status, err := z.GetPoolStatus(ctx, "tank")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(status.Root.Children) != 2 || status.Root.Children[1].Name != "mirror-1" || len(status.Caches) != 1 {
+		t.Fatalf("unexpected status: %+v", status)
+	}
+}
+
+func TestZFSadm_LegacyPoolValidation(t *testing.T) {
+	_, z := newTestSimulator(t)
+	ctx := context.TODO()
+
+	if _, err := z.CreateRaidPool(ctx, "data", "lz4", "mirror", 100, []string{"sdc", "sdc"}); !errors.Is(err, ErrInvalidPoolSpec) {
+		t.Fatalf("expect ErrInvalidPoolSpec, got %v", err)
+	}
+	if _, err := z.CreateRaidPool(ctx, "data", "lz4", "raidz2", 100, []string{"sdc", "sdd"}); !errors.Is(err, ErrInvalidPoolSpec) {
+		t.Fatalf("expect ErrInvalidPoolSpec, got %v", err)
+	}
+	// the stripe devices don't match the mirror of tank
+	if _, err := z.ExpensePoolDevices(ctx, "tank", "sdc"); !errors.Is(err, ErrInvalidPoolSpec) {
+		t.Fatalf("expect ErrInvalidPoolSpec, got %v", err)
+	}
+
+	if _, err := z.CreateRaidPool(ctx, "data", "", "", 100, []string{"sdc"}); err != nil {
+		t.Fatal(err)
+	}
+	pool, err := z.ExpensePoolDevices(ctx, "data", "sdd")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if pool.SizeBytes != 20<<30 {
+		t.Fatalf("unexpected size of pool: %d", pool.SizeBytes)
+	}
+}
@@ -0,0 +1,414 @@
+// MIT License
+//
+// Copyright (c) 2021 Lack
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+// SOFTWARE.
+
+package zfs
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+var ErrDestructivePlan = errors.New("plan contains destructive actions")
+
+// DatasetSpec the desired state of a dataset and its children
+type DatasetSpec struct {
+	// Name the name relative to parent, e.g. "home" of "tank/home"
+	Name string `json:"name"`
+	// Type "filesystem" or "volume", defaults to "filesystem"
+	Type string `json:"type,omitempty"`
+	// Size the volsize of volume in bytes
+	Size uint64 `json:"size,omitempty"`
+	// Properties the local properties of dataset, the other inheritable properties which are
+	// set locally are inherited, the non-inheritable ones (e.g. quota) are left as is.
+	Properties map[string]string `json:"properties,omitempty"`
+	// RenamedFrom the previous name relative to ReconcileSpec.Root, the existing dataset
+	// is renamed instead of creating a new one
+	RenamedFrom string        `json:"renamedFrom,omitempty"`
+	Children    []DatasetSpec `json:"children,omitempty"`
+}
+
+// ReconcileSpec the desired datasets under Root
+type ReconcileSpec struct {
+	// Root the existing dataset which datasets are created under, it's never changed
+	Root     string        `json:"root"`
+	Datasets []DatasetSpec `json:"datasets"`
+	// Prune destroys the descendents of Root which are not declared
+	Prune bool `json:"prune,omitempty"`
+	// AllowDestructive applies the plan even if it contains destructive actions,
+	// e.g. destroying datasets or shrinking volumes
+	AllowDestructive bool `json:"allowDestructive,omitempty"`
+	// DryRun computes the plan without touching the pool
+	DryRun bool `json:"dryRun,omitempty"`
+}
+
+// ActionKind the kind of ReconcileAction
+type ActionKind string
+
+const (
+	ActionCreate  ActionKind = "create"
+	ActionSet     ActionKind = "set"
+	ActionInherit ActionKind = "inherit"
+	ActionRename  ActionKind = "rename"
+	ActionDestroy ActionKind = "destroy"
+)
+
+// ReconcileAction a step of ReconcilePlan
+type ReconcileAction struct {
+	Kind    ActionKind `json:"kind"`
+	Dataset string     `json:"dataset"`
+	// Type the type of created dataset
+	Type string `json:"type,omitempty"`
+	// Size the volsize of created volume
+	Size uint64 `json:"size,omitempty"`
+	// Target the new name of renamed dataset
+	Target string `json:"target,omitempty"`
+	// Properties the properties of created dataset or set
+	Properties map[string]string `json:"properties,omitempty"`
+	// Property the inherited property
+	Property string `json:"property,omitempty"`
+	// Destructive reports whether the action may lose data
+	Destructive bool `json:"destructive,omitempty"`
+}
+
+func (a ReconcileAction) String() string {
+	properties := strings.Join(propertyArgs("", a.Properties), " ")
+	switch a.Kind {
+	case ActionCreate:
+		if a.Type == "volume" {
+			return strings.TrimSpace(fmt.Sprintf("create volume %s size=%d %s", a.Dataset, a.Size, properties))
+		}
+		return strings.TrimSpace(fmt.Sprintf("create filesystem %s %s", a.Dataset, properties))
+	case ActionSet:
+		return fmt.Sprintf("set %s %s", a.Dataset, properties)
+	case ActionInherit:
+		return fmt.Sprintf("inherit %s %s", a.Dataset, a.Property)
+	case ActionRename:
+		return fmt.Sprintf("rename %s %s", a.Dataset, a.Target)
+	}
+	return fmt.Sprintf("%s %s", a.Kind, a.Dataset)
+}
+
+// ReconcilePlan the actions which turn the live datasets into the desired state, in order
+type ReconcilePlan struct {
+	Root    string            `json:"root"`
+	Actions []ReconcileAction `json:"actions"`
+}
+
+// Destructive returns the destructive actions of plan
+func (p *ReconcilePlan) Destructive() []ReconcileAction {
+	actions := make([]ReconcileAction, 0)
+	for _, a := range p.Actions {
+		if a.Destructive {
+			actions = append(actions, a)
+		}
+	}
+	return actions
+}
+
+// nonInheritable the properties which can't be inherited or only be set on creation,
+// they are never inherited by reconciler
+var nonInheritable = map[string]bool{
+	"quota": true, "refquota": true, "reservation": true, "refreservation": true,
+	"volsize": true, "volblocksize": true, "canmount": true,
+	"filesystem_limit": true, "snapshot_limit": true,
+	"encryption": true, "keyformat": true, "keylocation": true, "pbkdf2iters": true,
+	"casesensitivity": true, "normalization": true, "utf8only": true,
+}
+
+// sizeProperties the properties whose values are compared as sizes, e.g. 1G equals to 1073741824
+var sizeProperties = map[string]bool{
+	"quota": true, "refquota": true, "reservation": true, "refreservation": true,
+	"volsize": true, "volblocksize": true, "recordsize": true, "special_small_blocks": true,
+	"filesystem_limit": true, "snapshot_limit": true,
+}
+
+func propertyEqual(name, a, b string) bool {
+	if a == b {
+		return true
+	}
+	if sizeProperties[name] {
+		x, err1 := parseSize(a)
+		y, err2 := parseSize(b)
+		return err1 == nil && err2 == nil && x == y
+	}
+	return false
+}
+
+// liveDataset the current state of dataset under root
+type liveDataset struct {
+	typ        string
+	size       uint64
+	properties map[string]string
+}
+
+// desiredDataset the flattened DatasetSpec
+type desiredDataset struct {
+	name        string
+	typ         string
+	size        uint64
+	properties  map[string]string
+	renamedFrom string
+}
+
+// flatten returns the desired datasets in pre-order, parents go before children
+func (s *ReconcileSpec) flatten() ([]desiredDataset, error) {
+	items := make([]desiredDataset, 0)
+	seen := map[string]bool{}
+	var walk func(parent string, specs []DatasetSpec) error
+	walk = func(parent string, specs []DatasetSpec) error {
+		for _, spec := range specs {
+			if len(spec.Name) == 0 || strings.ContainsAny(spec.Name, "@#% \t") || strings.HasPrefix(spec.Name, "/") ||
+				strings.HasSuffix(spec.Name, "/") {
+				return fmt.Errorf("%w: dataset %q under %s", ErrInvalidName, spec.Name, parent)
+			}
+			name := parent + "/" + spec.Name
+			if seen[name] {
+				return fmt.Errorf("%w: dataset %s is declared more than once", ErrInvalidName, name)
+			}
+			seen[name] = true
+
+			typ := spec.Type
+			switch typ {
+			case "", "filesystem":
+				typ = "filesystem"
+			case "volume":
+				if spec.Size == 0 {
+					return fmt.Errorf("%w: volume %s has no size", ErrInvalidName, name)
+				}
+				if len(spec.Children) > 0 {
+					return fmt.Errorf("%w: volume %s has children", ErrInvalidName, name)
+				}
+			default:
+				return fmt.Errorf("%w: unknown type %q of %s", ErrInvalidName, spec.Type, name)
+			}
+			item := desiredDataset{name: name, typ: typ, size: spec.Size, properties: spec.Properties}
+			if len(spec.RenamedFrom) > 0 {
+				item.renamedFrom = s.Root + "/" + spec.RenamedFrom
+			}
+			items = append(items, item)
+			if err := walk(name, spec.Children); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+	if err := walk(s.Root, s.Datasets); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+// Reconcile computes the plan which turns the datasets under spec.Root into the desired state and
+// applies it, unless spec.DryRun is set. ErrDestructivePlan is returned with the plan without
+// applying anything if it contains destructive actions and spec.AllowDestructive isn't set.
+func (z *ZFSadm) Reconcile(ctx context.Context, spec *ReconcileSpec) (*ReconcilePlan, error) {
+	plan, err := z.PlanReconcile(ctx, spec)
+	if err != nil {
+		return nil, err
+	}
+	if destructive := plan.Destructive(); len(destructive) > 0 && !spec.AllowDestructive {
+		return plan, fmt.Errorf("%w: %s", ErrDestructivePlan, destructive[0])
+	}
+	if spec.DryRun {
+		return plan, nil
+	}
+	return plan, z.ExecuteReconcilePlan(ctx, plan)
+}
+
+// PlanReconcile computes the plan of spec against the live datasets
+func (z *ZFSadm) PlanReconcile(ctx context.Context, spec *ReconcileSpec) (*ReconcilePlan, error) {
+	desired, err := spec.flatten()
+	if err != nil {
+		return nil, err
+	}
+	live, err := z.liveDatasets(ctx, spec.Root)
+	if err != nil {
+		return nil, err
+	}
+	return planReconcile(spec, desired, live), nil
+}
+
+func planReconcile(spec *ReconcileSpec, desired []desiredDataset, live map[string]*liveDataset) *ReconcilePlan {
+	plan := &ReconcilePlan{Root: spec.Root, Actions: []ReconcileAction{}}
+	declared := map[string]bool{}
+	for _, d := range desired {
+		declared[d.name] = true
+	}
+
+	for _, d := range desired {
+		current, ok := live[d.name]
+		if !ok && len(d.renamedFrom) > 0 && !declared[d.renamedFrom] {
+			if previous, found := live[d.renamedFrom]; found {
+				plan.Actions = append(plan.Actions, ReconcileAction{Kind: ActionRename, Dataset: d.renamedFrom, Target: d.name})
+				renameLive(live, d.renamedFrom, d.name)
+				current, ok = previous, true
+			}
+		}
+
+		if ok && current.typ != d.typ {
+			plan.Actions = append(plan.Actions, ReconcileAction{Kind: ActionDestroy, Dataset: d.name, Destructive: true})
+			for name := range live {
+				if name == d.name || strings.HasPrefix(name, d.name+"/") {
+					delete(live, name)
+				}
+			}
+			ok = false
+		}
+		if !ok {
+			action := ReconcileAction{Kind: ActionCreate, Dataset: d.name, Type: d.typ, Properties: d.properties}
+			if d.typ == "volume" {
+				action.Size = d.size
+			}
+			plan.Actions = append(plan.Actions, action)
+			continue
+		}
+
+		changed := map[string]string{}
+		for name, value := range d.properties {
+			if current, found := current.properties[name]; !found || !propertyEqual(name, current, value) {
+				changed[name] = value
+			}
+		}
+		destructive := false
+		if d.typ == "volume" && d.size != current.size {
+			changed["volsize"] = strconv.FormatUint(d.size, 10)
+			destructive = d.size < current.size
+		}
+		if len(changed) > 0 {
+			plan.Actions = append(plan.Actions, ReconcileAction{Kind: ActionSet, Dataset: d.name, Properties: changed, Destructive: destructive})
+		}
+
+		inherited := make([]string, 0)
+		for name := range current.properties {
+			if _, found := d.properties[name]; !found && !nonInheritable[name] {
+				inherited = append(inherited, name)
+			}
+		}
+		sort.Strings(inherited)
+		for _, name := range inherited {
+			plan.Actions = append(plan.Actions, ReconcileAction{Kind: ActionInherit, Dataset: d.name, Property: name})
+		}
+	}
+
+	if !spec.Prune {
+		return plan
+	}
+	names := make([]string, 0)
+	for name := range live {
+		if !declared[name] {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+	for i, name := range names {
+		// the descendents are destroyed with their ancestor
+		if i > 0 && isDescendent(name, names[:i]) {
+			continue
+		}
+		plan.Actions = append(plan.Actions, ReconcileAction{Kind: ActionDestroy, Dataset: name, Destructive: true})
+	}
+	return plan
+}
+
+func isDescendent(name string, ancestors []string) bool {
+	for _, ancestor := range ancestors {
+		if strings.HasPrefix(name, ancestor+"/") {
+			return true
+		}
+	}
+	return false
+}
+
+// renameLive renames the dataset and its descendents in live
+func renameLive(live map[string]*liveDataset, from, to string) {
+	for name, item := range live {
+		if name == from || strings.HasPrefix(name, from+"/") {
+			delete(live, name)
+			live[to+strings.TrimPrefix(name, from)] = item
+		}
+	}
+}
+
+// liveDatasets returns the descendents of root and their local properties
+func (z *ZFSadm) liveDatasets(ctx context.Context, root string) (map[string]*liveDataset, error) {
+	out, err := z.RawZFS().List(ctx, root, "-Hp -r", "", []string{"name", "type", "volsize"}, "", "", "filesystem,volume").Exec()
+	if err != nil {
+		return nil, err
+	}
+	live := map[string]*liveDataset{}
+	for _, line := range strings.Split(string(out), "\n") {
+		fields := strings.Split(line, "\t")
+		if len(fields) != 3 || fields[0] == root {
+			continue
+		}
+		size, _ := parseSize(fields[2])
+		live[fields[0]] = &liveDataset{typ: fields[1], size: size, properties: map[string]string{}}
+	}
+
+	out, err = z.RawZFS().Get(ctx, root, "-Hp -r", "", []string{"name", "property", "value"}, "filesystem,volume", "local", "all").Exec()
+	if err != nil {
+		return nil, err
+	}
+	for _, line := range strings.Split(string(out), "\n") {
+		fields := strings.Split(line, "\t")
+		if len(fields) != 3 {
+			continue
+		}
+		if item, ok := live[fields[0]]; ok {
+			item.properties[fields[1]] = fields[2]
+		}
+	}
+	return live, nil
+}
+
+// ExecuteReconcilePlan applies the actions of plan in order, it stops at the first failure
+func (z *ZFSadm) ExecuteReconcilePlan(ctx context.Context, plan *ReconcilePlan) error {
+	for _, a := range plan.Actions {
+		var execute *execute
+		switch a.Kind {
+		case ActionCreate:
+			if a.Type == "volume" {
+				execute = z.RawZFS().CreateVolume(ctx, a.Dataset, 0, a.Properties, strconv.FormatUint(a.Size, 10))
+			} else {
+				execute = z.RawZFS().CreateFileSystem(ctx, a.Dataset, a.Properties)
+			}
+		case ActionSet:
+			execute = z.RawZFS().Set(ctx, a.Dataset, a.Properties)
+		case ActionInherit:
+			execute = z.RawZFS().Inherit(ctx, a.Dataset, "", a.Property)
+		case ActionRename:
+			execute = z.RawZFS().RenameFileSystemOrVolume(ctx, a.Dataset, a.Target, false)
+		case ActionDestroy:
+			execute = z.RawZFS().DestroyFileSystemOrVolume(ctx, a.Dataset, "-r")
+		default:
+			return fmt.Errorf("unknown action %q of %s", a.Kind, a.Dataset)
+		}
+		if _, err := execute.Exec(); err != nil {
+			return fmt.Errorf("%s: %w", a, err)
+		}
+	}
+	return nil
+}
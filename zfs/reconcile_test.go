@@ -0,0 +1,131 @@
+// MIT License
+//
+// Copyright (c) 2021 Lack
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+// SOFTWARE.
+
+package zfs
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"testing"
+)
+
+func reconcileActions(plan *ReconcilePlan) []string {
+	actions := make([]string, 0)
+	for _, a := range plan.Actions {
+		actions = append(actions, a.String())
+	}
+	return actions
+}
+
+func TestZFSadm_Reconcile(t *testing.T) {
+	_, z := newTestSimulator(t)
+	ctx := context.TODO()
+
+	spec := &ReconcileSpec{
+		Root: "tank",
+		Datasets: []DatasetSpec{
+			{Name: "tenants", Properties: map[string]string{"compression": "lz4"}, Children: []DatasetSpec{
+				{Name: "alice", Properties: map[string]string{"quota": "1G", "atime": "off"}},
+				{Name: "disk", Type: "volume", Size: 1 << 20},
+			}},
+		},
+	}
+	plan, err := z.Reconcile(ctx, spec)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expect := []string{
+		"create filesystem tank/tenants compression=lz4",
+		"create filesystem tank/tenants/alice atime=off quota=1G",
+		"create volume tank/tenants/disk size=1048576",
+	}
+	if got := reconcileActions(plan); !reflect.DeepEqual(got, expect) {
+		t.Fatalf("expect %q, got %q", expect, got)
+	}
+
+	// the live datasets are converged
+	plan, err = z.Reconcile(ctx, spec)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(plan.Actions) != 0 {
+		t.Fatalf("expect empty plan, got %q", reconcileActions(plan))
+	}
+
+	spec.Datasets[0].Children = []DatasetSpec{
+		{Name: "alice2", RenamedFrom: "tenants/alice", Properties: map[string]string{"quota": "2G"}},
+		{Name: "disk", Type: "volume", Size: 1 << 19},
+	}
+	spec.Prune = true
+	spec.Datasets = append(spec.Datasets, DatasetSpec{Name: "backup"})
+	if _, err = z.CreateFileSystem(ctx, "tank/scratch/tmp", nil); err != nil {
+		t.Fatal(err)
+	}
+	plan, err = z.Reconcile(ctx, spec)
+	if !errors.Is(err, ErrDestructivePlan) {
+		t.Fatalf("expect ErrDestructivePlan, got %v", err)
+	}
+	expect = []string{
+		"rename tank/tenants/alice tank/tenants/alice2",
+		"set tank/tenants/alice2 quota=2G",
+		"inherit tank/tenants/alice2 atime",
+		"set tank/tenants/disk volsize=524288",
+		"create filesystem tank/backup",
+		"destroy tank/scratch",
+	}
+	if got := reconcileActions(plan); !reflect.DeepEqual(got, expect) {
+		t.Fatalf("expect %q, got %q", expect, got)
+	}
+	if len(plan.Destructive()) != 2 {
+		t.Fatalf("unexpected destructive actions: %v", plan.Destructive())
+	}
+	if _, err = z.GetFileSystem(ctx, "tank/tenants/alice"); err != nil {
+		t.Fatalf("destructive plan must not be applied: %v", err)
+	}
+
+	spec.AllowDestructive = true
+	if _, err = z.Reconcile(ctx, spec); err != nil {
+		t.Fatal(err)
+	}
+	spec.Prune = false
+	if plan, err = z.Reconcile(ctx, spec); err != nil || len(plan.Actions) != 0 {
+		t.Fatalf("expect empty plan, got %q, %v", reconcileActions(plan), err)
+	}
+	if _, err = z.GetFileSystem(ctx, "tank/scratch"); err == nil {
+		t.Fatal("expect tank/scratch is destroyed")
+	}
+}
+
+func TestReconcileSpec_Invalid(t *testing.T) {
+	specs := []*ReconcileSpec{
+		{Root: "tank", Datasets: []DatasetSpec{{Name: "a@b"}}},
+		{Root: "tank", Datasets: []DatasetSpec{{Name: "a"}, {Name: "a"}}},
+		{Root: "tank", Datasets: []DatasetSpec{{Name: "v", Type: "volume"}}},
+		{Root: "tank", Datasets: []DatasetSpec{{Name: "a", Type: "bookmark"}}},
+	}
+	for _, spec := range specs {
+		if _, err := spec.flatten(); !errors.Is(err, ErrInvalidName) {
+			t.Fatalf("expect ErrInvalidName of %+v, got %v", spec.Datasets, err)
+		}
+	}
+}
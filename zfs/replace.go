@@ -0,0 +1,354 @@
+// MIT License
+//
+// Copyright (c) 2021 Lack
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+// SOFTWARE.
+
+package zfs
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// ReplaceOptions the options of ReplaceDevice
+type ReplaceOptions struct {
+	// Force replaces with -f and clears labels with -f, e.g. the new device belongs to an exported pool
+	Force bool
+	// KeepLabel skips 'zpool labelclear' of the new device
+	KeepLabel bool
+	// Properties the properties of new device, e.g. ashift=12
+	Properties map[string]string
+	// NoWait returns once the replacement is issued, without tracking the resilver
+	NoWait bool
+	// Progress receives the status of resilver every poll, it could be nil
+	Progress func(*ScanStatus)
+}
+
+// ReplaceStep the step of ReplaceDevice
+type ReplaceStep struct {
+	// Name the name of step: validate, labelclear, offline, replace, resilver, detach or online
+	Name  string    `json:"name"`
+	Start time.Time `json:"start"`
+	End   time.Time `json:"end"`
+	Error string    `json:"error,omitempty"`
+}
+
+// ReplaceReport the report of ReplaceDevice
+type ReplaceReport struct {
+	Pool      string `json:"pool"`
+	OldDevice string `json:"oldDevice"`
+	NewDevice string `json:"newDevice"`
+	// OldSize the size of old device, or the smallest size of its siblings if the old device is gone,
+	// 0 if it's unknown
+	OldSize uint64 `json:"oldSize"`
+	NewSize uint64 `json:"newSize"`
+
+	Steps []*ReplaceStep `json:"steps"`
+	// Resilver the last status of resilver
+	Resilver *ScanStatus `json:"resilver,omitempty"`
+	// RolledBack reports whether the old device is restored after failure
+	RolledBack bool `json:"rolledBack"`
+
+	Start time.Time `json:"start"`
+	End   time.Time `json:"end"`
+}
+
+// step runs fn as the step of report
+func (r *ReplaceReport) step(name string, fn func() error) error {
+	step := &ReplaceStep{Name: name, Start: time.Now()}
+	r.Steps = append(r.Steps, step)
+	err := fn()
+	step.End = time.Now()
+	if err != nil {
+		step.Error = err.Error()
+	}
+	return err
+}
+
+// ReplaceDevice replaces oldDev of pool with newDev:
+//
+//  1. validates the devices, the new device must be unused and not smaller than the old one
+//  2. clears the stale labels of the new device (zpool labelclear)
+//  3. offlines the old device if it's online and redundant (zpool offline)
+//  4. replaces the old device (zpool replace)
+//  5. tracks the resilver until the old device is detached
+//
+// The old device is restored (zpool detach <new>, zpool online <old>) if the replacement or the
+// resilver fails, i.e. the new device is not online, or the resilver is canceled or finished with
+// errors. The errors of polling 'zpool status' are retried replacePollRetries times, then the
+// tracking stops without the rollback. Canceling ctx stops tracking only, the resilver goes on
+// in the background.
+// The report is returned even if it fails after the validation.
+func (z *ZFSadm) ReplaceDevice(ctx context.Context, pool, oldDev, newDev string, opts ReplaceOptions) (*ReplaceReport, error) {
+	report := &ReplaceReport{Pool: pool, OldDevice: oldDev, NewDevice: newDev, Steps: []*ReplaceStep{}, Start: time.Now()}
+	defer func() { report.End = time.Now() }()
+
+	var old *replaceTarget
+	err := report.step("validate", func() (err error) {
+		old, err = z.validateReplace(ctx, report, opts)
+		return
+	})
+	if err != nil {
+		return report, err
+	}
+
+	if !opts.KeepLabel {
+		err = report.step("labelclear", func() error {
+			_, err := z.RawZPool().LabelClear(ctx, newDev, opts.Force).Exec()
+			var e *CommandError
+			if errors.As(err, &e) && strings.Contains(e.Stderr, "failed to read label") {
+				// the new disk has never been used by zfs
+				return nil
+			}
+			return err
+		})
+		if err != nil {
+			return report, err
+		}
+	}
+
+	offlined := false
+	if old.State == "ONLINE" && old.redundant {
+		err = report.step("offline", func() error {
+			_, err := z.RawZPool().Offline(ctx, pool, false, false, oldDev).Exec()
+			return err
+		})
+		if err != nil {
+			return report, err
+		}
+		offlined = true
+	}
+
+	err = report.step("replace", func() error {
+		_, err := z.RawZPool().Replace(ctx, pool, opts.Force, opts.Properties, oldDev, newDev).Exec()
+		return err
+	})
+	if err != nil {
+		z.rollbackReplace(ctx, report, false, offlined)
+		return report, err
+	}
+	if opts.NoWait {
+		return report, nil
+	}
+
+	failed := false
+	err = report.step("resilver", func() (err error) {
+		failed, err = z.waitReplace(ctx, report, opts.Progress)
+		return
+	})
+	if failed {
+		z.rollbackReplace(ctx, report, true, offlined)
+	}
+	return report, err
+}
+
+// replaceTarget the old device of ReplaceDevice
+type replaceTarget struct {
+	*Vdev
+	// redundant reports whether the parent of device tolerates its absence
+	redundant bool
+}
+
+func (z *ZFSadm) validateReplace(ctx context.Context, report *ReplaceReport, opts ReplaceOptions) (*replaceTarget, error) {
+	status, err := z.GetPoolStatus(ctx, report.Pool)
+	if err != nil {
+		return nil, err
+	}
+	for _, groups := range [][]*Vdev{status.Caches, status.Spares} {
+		for _, v := range groups {
+			if device, _ := findDevice(v, report.OldDevice); device != nil {
+				return nil, fmt.Errorf("cannot replace %s: cache and spare devices should be removed and added instead", report.OldDevice)
+			}
+		}
+	}
+	var old, parent *Vdev
+	for _, groups := range [][]*Vdev{{status.Root}, status.Logs, status.Specials, status.Dedups} {
+		for _, v := range groups {
+			if old == nil && v != nil {
+				old, parent = findDevice(v, report.OldDevice)
+			}
+		}
+	}
+	if old == nil {
+		return nil, fmt.Errorf("%w: %s", ErrDeviceNotFound, report.OldDevice)
+	}
+	if parent != nil && parent.Type == VdevReplacing {
+		return nil, fmt.Errorf("%w: %s is being replaced", ErrPoolBusy, report.OldDevice)
+	}
+	if status.Scan != nil && status.Scan.State == ScanScanning && status.Scan.Function == ScanResilver {
+		return nil, fmt.Errorf("%w: resilver of %s is in progress", ErrPoolBusy, report.Pool)
+	}
+	if report.NewDevice != report.OldDevice && !opts.Force {
+		if err = z.devicesInUse(ctx, report.NewDevice); err != nil {
+			return nil, err
+		}
+	}
+
+	report.NewSize, err = z.deviceSize(ctx, report.NewDevice)
+	if err != nil {
+		return nil, err
+	}
+	report.OldSize, err = z.deviceSize(ctx, report.OldDevice)
+	if err != nil && parent != nil {
+		// the old device is gone, the new one must be as large as the smallest sibling
+		report.OldSize = 0
+		for _, sibling := range parent.Children {
+			if sibling == old || !sibling.IsLeaf() {
+				continue
+			}
+			if size, err := z.deviceSize(ctx, sibling.Name); err == nil && (report.OldSize == 0 || size < report.OldSize) {
+				report.OldSize = size
+			}
+		}
+	}
+	if report.NewSize < report.OldSize {
+		return nil, fmt.Errorf("%w: %s has %d bytes, %d bytes at least", ErrDeviceTooSmall, report.NewDevice, report.NewSize, report.OldSize)
+	}
+
+	redundant := false
+	if parent != nil {
+		switch parent.Type {
+		case VdevMirror, VdevRaidz, VdevDraid:
+			redundant = true
+		}
+	}
+	return &replaceTarget{Vdev: old, redundant: redundant}, nil
+}
+
+// replacePollRetries the count of consecutive errors of polling tolerated by ReplaceDevice,
+// e.g. the ssh connection of remote runner is broken for a while
+var replacePollRetries = 3
+
+// waitReplace waits until the old device is detached, reports whether the resilver failed
+// definitely so that the replacement should be rolled back
+func (z *ZFSadm) waitReplace(ctx context.Context, report *ReplaceReport, fn func(*ScanStatus)) (bool, error) {
+	ticker := time.NewTicker(scanPollInterval)
+	defer ticker.Stop()
+	retries := 0
+	for {
+		status, err := z.GetPoolStatus(ctx, report.Pool)
+		if err != nil {
+			if retries++; retries > replacePollRetries || ctx.Err() != nil {
+				return false, fmt.Errorf("tracking resilver of %s: %w", report.NewDevice, err)
+			}
+			select {
+			case <-ctx.Done():
+				return false, ctx.Err()
+			case <-ticker.C:
+			}
+			continue
+		}
+		retries = 0
+		scan := status.Scan
+		if scan == nil {
+			scan = &ScanStatus{State: ScanNone}
+		}
+		report.Resilver = scan
+		if fn != nil {
+			fn(scan)
+		}
+
+		var device, parent *Vdev
+		for _, groups := range [][]*Vdev{{status.Root}, status.Logs, status.Specials, status.Dedups} {
+			for _, v := range groups {
+				if device == nil && v != nil {
+					device, parent = findDevice(v, report.NewDevice)
+				}
+			}
+		}
+		switch {
+		case device == nil:
+			return true, fmt.Errorf("%w: %s disappeared during resilver", ErrDeviceNotFound, report.NewDevice)
+		case device.State != "ONLINE":
+			return true, fmt.Errorf("resilver of %s failed: device is %s", report.NewDevice, device.State)
+		case scan.State == ScanCanceled:
+			return true, fmt.Errorf("resilver of %s was canceled", report.NewDevice)
+		case parent == nil || parent.Type != VdevReplacing:
+			return false, nil
+		case scan.State == ScanFinished:
+			return true, fmt.Errorf("resilver of %s finished with %d errors, the old device is not detached", report.NewDevice, scan.Errors)
+		}
+
+		select {
+		case <-ctx.Done():
+			return false, ctx.Err()
+		case <-ticker.C:
+		}
+	}
+}
+
+// rollbackReplace restores the old device, the errors are recorded in the steps of report
+func (z *ZFSadm) rollbackReplace(ctx context.Context, report *ReplaceReport, replaced, offlined bool) {
+	if replaced {
+		err := report.step("detach", func() error {
+			_, err := z.RawZPool().Detach(ctx, report.Pool, report.NewDevice).Exec()
+			return err
+		})
+		if err != nil {
+			return
+		}
+	}
+	if offlined {
+		err := report.step("online", func() error {
+			_, err := z.RawZPool().Online(ctx, report.Pool, report.OldDevice).Exec()
+			return err
+		})
+		if err != nil {
+			return
+		}
+	}
+	report.RolledBack = true
+}
+
+// deviceSize returns the size of block device by 'lsblk -bdno SIZE <device>'
+func (z *ZFSadm) deviceSize(ctx context.Context, device string) (uint64, error) {
+	if !filepath.IsAbs(device) {
+		device = filepath.Join("/dev", device)
+	}
+	out, err := (&execute{ctx: ctx, name: "lsblk", args: []string{"-bdno", "SIZE", device}, runner: z.runner}).Exec()
+	if err != nil {
+		return 0, err
+	}
+	return strconv.ParseUint(strings.TrimSpace(string(out)), 10, 64)
+}
+
+// findDevice returns the leaf device of the vdev tree and its parent by the name or the path of device
+func findDevice(root *Vdev, name string) (device, parent *Vdev) {
+	var walk func(v, p *Vdev)
+	walk = func(v, p *Vdev) {
+		if device != nil {
+			return
+		}
+		if v.IsLeaf() && (v.Name == name || filepath.Base(v.Name) == filepath.Base(name)) {
+			device, parent = v, p
+			return
+		}
+		for _, child := range v.Children {
+			walk(child, v)
+		}
+	}
+	walk(root, nil)
+	return
+}
@@ -0,0 +1,232 @@
+// MIT License
+//
+// Copyright (c) 2021 Lack
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+// SOFTWARE.
+
+package zfs
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+)
+
+func stepNames(report *ReplaceReport) string {
+	names := make([]string, 0, len(report.Steps))
+	for _, step := range report.Steps {
+		names = append(names, step.Name)
+	}
+	return strings.Join(names, ",")
+}
+
+func TestZFSadm_ReplaceDevice(t *testing.T) {
+	defer func(interval time.Duration) { scanPollInterval = interval }(scanPollInterval)
+	scanPollInterval = time.Millisecond
+
+	sim, z := newTestSimulator(t)
+	now := sim.Now()
+	sim.Now = func() time.Time { return now }
+	sim.ResilverTime = time.Hour
+	ctx := context.TODO()
+
+	progress := make([]float64, 0)
+	report, err := z.ReplaceDevice(ctx, "tank", "sdb", "sdc", ReplaceOptions{
+		Progress: func(scan *ScanStatus) {
+			progress = append(progress, scan.Percent)
+			// the clock goes on by polls
+			now = now.Add(time.Minute * 30)
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if names := stepNames(report); names != "validate,labelclear,offline,replace,resilver" {
+		t.Fatalf("unexpected steps: %s", names)
+	}
+	if report.OldSize != 10<<30 || report.NewSize != 10<<30 || report.RolledBack {
+		t.Fatalf("unexpected report: %+v", report)
+	}
+	if len(progress) != 3 || progress[0] != 0 || progress[1] != 50 || progress[2] != 100 {
+		t.Fatalf("unexpected progress: %v", progress)
+	}
+	if report.Resilver.Function != ScanResilver || report.Resilver.State != ScanFinished {
+		t.Fatalf("unexpected resilver: %+v", report.Resilver)
+	}
+
+	status, err := z.GetPoolStatus(ctx, "tank")
+	if err != nil {
+		t.Fatal(err)
+	}
+	mirror := status.Root.Children[0]
+	if status.State != "ONLINE" || len(mirror.Children) != 2 || mirror.Children[1].Name != "sdc" {
+		t.Fatalf("unexpected status: %+v", mirror)
+	}
+}
+
+func TestZFSadm_ReplaceDeviceValidate(t *testing.T) {
+	sim, z := newTestSimulator(t)
+	ctx := context.TODO()
+
+	sim.SetDeviceSize("sdc", 5<<30)
+	if _, err := z.ReplaceDevice(ctx, "tank", "sdb", "sdc", ReplaceOptions{}); !errors.Is(err, ErrDeviceTooSmall) {
+		t.Fatalf("expect ErrDeviceTooSmall, got %v", err)
+	}
+	if _, err := z.ReplaceDevice(ctx, "tank", "sdb", "sda", ReplaceOptions{}); !errors.Is(err, ErrDeviceInUse) {
+		t.Fatalf("expect ErrDeviceInUse, got %v", err)
+	}
+	if _, err := z.ReplaceDevice(ctx, "tank", "sdx", "sdd", ReplaceOptions{}); !errors.Is(err, ErrDeviceNotFound) {
+		t.Fatalf("expect ErrDeviceNotFound, got %v", err)
+	}
+	for _, line := range sim.History() {
+		if strings.HasPrefix(line, "zpool offline") || strings.HasPrefix(line, "zpool replace") {
+			t.Fatalf("unexpected command: %s", line)
+		}
+	}
+}
+
+func TestZFSadm_ReplaceDeviceRemoved(t *testing.T) {
+	sim, z := newTestSimulator(t)
+	ctx := context.TODO()
+
+	// the old device is pulled out of the machine
+	if err := sim.SetDeviceState("tank", "sdb", "REMOVED"); err != nil {
+		t.Fatal(err)
+	}
+	sim.SetDeviceSize("sdb", 0)
+
+	report, err := z.ReplaceDevice(ctx, "tank", "sdb", "sdc", ReplaceOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if names := stepNames(report); names != "validate,labelclear,replace,resilver" {
+		t.Fatalf("unexpected steps: %s", names)
+	}
+	if report.OldSize != 10<<30 {
+		t.Fatalf("expect the size of sibling, got %d", report.OldSize)
+	}
+}
+
+func TestZFSadm_ReplaceDeviceRollback(t *testing.T) {
+	defer func(interval time.Duration) { scanPollInterval = interval }(scanPollInterval)
+	scanPollInterval = time.Millisecond
+
+	sim, z := newTestSimulator(t)
+	sim.ResilverTime = time.Hour
+	ctx := context.TODO()
+
+	report, err := z.ReplaceDevice(ctx, "tank", "sdb", "sdc", ReplaceOptions{
+		Progress: func(scan *ScanStatus) {
+			// the new device fails during resilver
+			_ = sim.SetDeviceState("tank", "sdc", "FAULTED")
+		},
+	})
+	if err == nil || !strings.Contains(err.Error(), "FAULTED") {
+		t.Fatalf("expect failure of resilver, got %v", err)
+	}
+	if names := stepNames(report); names != "validate,labelclear,offline,replace,resilver,detach,online" || !report.RolledBack {
+		t.Fatalf("unexpected report: %s, %+v", names, report)
+	}
+
+	status, err := z.GetPoolStatus(ctx, "tank")
+	if err != nil {
+		t.Fatal(err)
+	}
+	mirror := status.Root.Children[0]
+	if status.State != "ONLINE" || mirror.Type != VdevMirror || mirror.Children[1].Name != "sdb" || status.Scan.State != ScanCanceled {
+		t.Fatalf("unexpected status: %+v, %+v", mirror, status.Scan)
+	}
+}
+
+// flakyRunner fails the commands matched by fail
+type flakyRunner struct {
+	Runner
+	fail func(cmd *Cmd) bool
+}
+
+func (r *flakyRunner) Run(ctx context.Context, cmd *Cmd) error {
+	if r.fail(cmd) {
+		_, _ = cmd.Stderr.Write([]byte("ssh: connection reset by peer"))
+		return errors.New("exit status 255")
+	}
+	return r.Runner.Run(ctx, cmd)
+}
+
+func TestZFSadm_ReplaceDevicePollError(t *testing.T) {
+	defer func(interval time.Duration) { scanPollInterval = interval }(scanPollInterval)
+	scanPollInterval = time.Millisecond
+
+	sim, _ := newTestSimulator(t)
+	now := sim.Now()
+	sim.Now = func() time.Time { return now }
+	sim.ResilverTime = time.Hour
+
+	replaced, failures := false, 0
+	runner := &flakyRunner{Runner: sim, fail: func(cmd *Cmd) bool {
+		line := cmd.String()
+		if strings.Contains(line, " replace ") {
+			replaced = true
+		}
+		// the first poll of status fails after the replacement
+		if replaced && strings.Contains(line, " status ") && failures == 0 {
+			failures++
+			return true
+		}
+		return false
+	}}
+	z := New(WithRunner(runner))
+	ctx := context.TODO()
+
+	report, err := z.ReplaceDevice(ctx, "tank", "sdb", "sdc", ReplaceOptions{
+		Progress: func(scan *ScanStatus) { now = now.Add(time.Minute * 30) },
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if names := stepNames(report); names != "validate,labelclear,offline,replace,resilver" || report.RolledBack || failures != 1 {
+		t.Fatalf("unexpected report: %s, %+v", names, report)
+	}
+
+	// the status can't be polled any more, the resilver is left going on
+	sim, _ = newTestSimulator(t)
+	sim.ResilverTime = time.Hour
+	replaced = false
+	runner = &flakyRunner{Runner: sim, fail: func(cmd *Cmd) bool {
+		line := cmd.String()
+		if strings.Contains(line, " replace ") {
+			replaced = true
+		}
+		return replaced && strings.Contains(line, " status ")
+	}}
+	z = New(WithRunner(runner))
+	report, err = z.ReplaceDevice(ctx, "tank", "sdb", "sdc", ReplaceOptions{})
+	if err == nil || !strings.Contains(err.Error(), "connection reset") {
+		t.Fatalf("expect error of polling, got %v", err)
+	}
+	if names := stepNames(report); names != "validate,labelclear,offline,replace,resilver" || report.RolledBack {
+		t.Fatalf("unexpected report: %s, %+v", names, report)
+	}
+	for _, line := range sim.History() {
+		if strings.HasPrefix(line, "zpool detach") || strings.HasPrefix(line, "zpool online") {
+			t.Fatalf("unexpected rollback: %s", line)
+		}
+	}
+}
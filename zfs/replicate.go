@@ -0,0 +1,422 @@
+// MIT License
+//
+// Copyright (c) 2021 Lack
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+// SOFTWARE.
+
+package zfs
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/vine-io/pkg/rfs"
+)
+
+// SendOptions options of 'zfs send'
+type SendOptions struct {
+	// From the snapshot or bookmark which incremental stream starts from,
+	// the full stream is sent when it's empty.
+	From string
+	// Intermediary sends all intermediary snapshots between From and snapshot (-I)
+	Intermediary bool
+	// Token resumes an interrupted stream by receive_resume_token, the others are ignored
+	Token string
+
+	// Replicate sends the dataset and all descendent datasets (-R)
+	Replicate bool
+	// Properties sends the properties of dataset (-p)
+	Properties bool
+	// Compressed sends compressed blocks as they are on disk (-c)
+	Compressed bool
+	// LargeBlock allows blocks larger than 128KB (-L)
+	LargeBlock bool
+	// Embed sends WRITE_EMBEDDED records (-e)
+	Embed bool
+	// Raw sends the encrypted dataset as it is on disk, the key isn't needed to be loaded
+	// and the received dataset stays encrypted by the same key (-w)
+	Raw bool
+}
+
+func (o SendOptions) flags() string {
+	flags := ""
+	if o.Replicate {
+		flags += "R"
+	}
+	if o.Properties {
+		flags += "p"
+	}
+	if o.Compressed {
+		flags += "c"
+	}
+	if o.LargeBlock {
+		flags += "L"
+	}
+	if o.Embed {
+		flags += "e"
+	}
+	if o.Raw {
+		flags += "w"
+	}
+	if len(flags) == 0 {
+		return ""
+	}
+	return "-" + flags
+}
+
+// ReceiveOptions options of 'zfs receive'
+type ReceiveOptions struct {
+	// Force rollbacks the target to the most recent snapshot before receiving (-F)
+	Force bool
+	// Resumable saves the partially received state, see receive_resume_token (-s)
+	Resumable bool
+	// NoMount does not mount the received filesystem (-u)
+	NoMount bool
+	// Properties overrides the properties of received dataset (-o)
+	Properties map[string]string
+	// Exclude excludes the property from received dataset (-x)
+	Exclude string
+}
+
+func (o ReceiveOptions) flags() string {
+	flags := ""
+	if o.Force {
+		flags += "F"
+	}
+	if o.Resumable {
+		flags += "s"
+	}
+	if o.NoMount {
+		flags += "u"
+	}
+	if len(flags) == 0 {
+		return ""
+	}
+	return "-" + flags
+}
+
+// receiveWriter feeds the stream written into it to 'zfs receive'
+type receiveWriter struct {
+	pw   *io.PipeWriter
+	once sync.Once
+	done chan error
+	err  error
+}
+
+// NewReceiveWriter executes 'zfs receive' by the Runner of ZFSadm (local or remote host),
+// the stream written into io.WriteCloser is received as dataset name. Close waits for
+// 'zfs receive' exits, and returns *CommandError if it fails.
+func (z *ZFSadm) NewReceiveWriter(ctx context.Context, name string, opts ReceiveOptions) io.WriteCloser {
+	execute := z.RawZFS().Receive(ctx, name, opts.flags(), opts.Properties, opts.Exclude)
+
+	pr, pw := io.Pipe()
+	w := &receiveWriter{pw: pw, done: make(chan error, 1)}
+	go func() {
+		cmd := &Cmd{Name: execute.name, Args: execute.args, Stdin: pr}
+		_, err := execution(ctx, execute.runner, cmd)
+		if err != nil {
+			_ = pr.CloseWithError(err)
+		} else {
+			_ = pr.CloseWithError(io.ErrClosedPipe)
+		}
+		w.done <- err
+	}()
+	return w
+}
+
+func (w *receiveWriter) Write(p []byte) (int, error) {
+	return w.pw.Write(p)
+}
+
+func (w *receiveWriter) Close() error {
+	w.once.Do(func() {
+		_ = w.pw.Close()
+		w.err = <-w.done
+	})
+	return w.err
+}
+
+// Replicator replicates snapshots by 'zfs send' and 'zfs receive'
+type Replicator struct {
+	source *ZFSadm
+	fn     rfs.IOFn
+}
+
+// NewReplicator creates Replicator which sends snapshots of source,
+// fn reports the progress of stream, it could be nil.
+func NewReplicator(source *ZFSadm, fn rfs.IOFn) *Replicator {
+	return &Replicator{source: source, fn: fn}
+}
+
+func (r *Replicator) sendExecute(ctx context.Context, snapshot, options string, opts SendOptions) *execute {
+	flags := strings.TrimSpace(opts.flags() + " " + options)
+	switch {
+	case len(opts.Token) > 0:
+		return r.source.RawZFS().SendToken(ctx, opts.Token, flags)
+	case len(opts.From) > 0 && opts.Intermediary:
+		return r.source.RawZFS().SendIntermediary(ctx, snapshot, flags, opts.From)
+	default:
+		return r.source.RawZFS().Send(ctx, snapshot, flags, opts.From)
+	}
+}
+
+// EstimateSize returns the estimated size of stream by 'zfs send -nP'
+func (r *Replicator) EstimateSize(ctx context.Context, snapshot string, opts SendOptions) (int64, error) {
+	data, err := r.sendExecute(ctx, snapshot, "-nP", opts).Exec()
+	if err != nil {
+		return 0, err
+	}
+	for _, line := range strings.Split(string(data), "\n") {
+		parts := strings.Split(strings.TrimSpace(line), "\t")
+		if len(parts) == 2 && parts[0] == "size" {
+			return strconv.ParseInt(parts[1], 10, 64)
+		}
+	}
+	return 0, nil
+}
+
+// Send streams snapshot into w, returns the bytes of stream
+func (r *Replicator) Send(ctx context.Context, snapshot string, w io.Writer, opts SendOptions) (int64, error) {
+	return r.send(ctx, snapshot, w, "", opts)
+}
+
+func (r *Replicator) send(ctx context.Context, snapshot string, w io.Writer, to string, opts SendOptions) (int64, error) {
+	mw := &meterWriter{w: w, fn: r.fn}
+	if r.fn != nil {
+		mw.metric = &rfs.IOMetric{Name: snapshot, From: snapshot, To: to}
+		if len(opts.Token) > 0 {
+			mw.metric.Name = "token"
+		}
+		mw.metric.Total, _ = r.EstimateSize(ctx, snapshot, opts)
+		mw.last = time.Now()
+	}
+
+	execute := r.sendExecute(ctx, snapshot, "", opts)
+	cmd := &Cmd{Name: execute.name, Args: execute.args}
+	stderr := &syncBuffer{}
+	cmd.Stdout = mw
+	cmd.Stderr = stderr
+	if err := execute.runner.Run(ctx, cmd); err != nil {
+		return mw.written, newCommandError(cmd, "", string(stderr.Bytes()), err)
+	}
+	mw.report()
+	return mw.written, nil
+}
+
+// ReplicateOptions options of Replicator.Replicate
+type ReplicateOptions struct {
+	SendOptions
+	ReceiveOptions
+
+	// Full always sends the full stream, even if there is a common snapshot
+	Full bool
+}
+
+// ReplicateResult describes what Replicator.Replicate does
+type ReplicateResult struct {
+	// Snapshot the replicated snapshot
+	Snapshot string
+	// From the base of incremental stream, it's empty for full stream
+	From string
+	// Resumed reports whether an interrupted stream is resumed
+	Resumed bool
+	// Bytes the size of stream
+	Bytes int64
+}
+
+// Replicate replicates snapshot to the dataset of target (local or remote ZFSadm):
+//
+// - resumes the interrupted stream if target has receive_resume_token
+// - sends incremental stream from the newest common snapshot (or bookmark) of source and target
+// - sends full stream if there is no common snapshot
+//
+// The stream is always received with '-s', so that it could be resumed after interruption.
+func (r *Replicator) Replicate(ctx context.Context, snapshot string, target *ZFSadm, name string, opts ReplicateOptions) (*ReplicateResult, error) {
+	if !strings.Contains(snapshot, "@") {
+		return nil, fmt.Errorf("%w: missing '@' in snapshot name", ErrInvalidName)
+	}
+
+	result := &ReplicateResult{Snapshot: snapshot}
+	sendOpts := opts.SendOptions
+	recvOpts := opts.ReceiveOptions
+	recvOpts.Resumable = true
+
+	token, err := target.GetResumeToken(ctx, name)
+	if err != nil && !errors.Is(err, ErrDatasetNotFound) {
+		return nil, err
+	}
+
+	switch {
+	case len(token) > 0:
+		sendOpts = SendOptions{Token: token}
+		result.Resumed = true
+	case !opts.Full && len(sendOpts.From) == 0:
+		common, err := r.CommonSnapshot(ctx, snapshot, target, name)
+		if err != nil {
+			return nil, err
+		}
+		if common == snapshot {
+			// the snapshot is already replicated
+			return result, nil
+		}
+		sendOpts.From = common
+	}
+	result.From = sendOpts.From
+
+	w := target.NewReceiveWriter(ctx, name, recvOpts)
+	mw := &meterWriter{w: w}
+	result.Bytes, err = r.send(ctx, snapshot, mw, name, sendOpts)
+	cerr := w.Close()
+	if cerr != nil && (err == nil || mw.err != nil) {
+		// 'zfs receive' exits at first, it's the cause of broken pipe
+		return result, cerr
+	}
+	return result, err
+}
+
+// CommonSnapshot returns the newest snapshot (or bookmark) of source dataset which
+// is also on target dataset name and created before snapshot. Snapshots are matched
+// by guid, so they could be renamed. An empty string is returned when there is none.
+func (r *Replicator) CommonSnapshot(ctx context.Context, snapshot string, target *ZFSadm, name string) (string, error) {
+	dataset := strings.SplitN(snapshot, "@", 2)[0]
+	sources, err := r.source.listSnapshotGuids(ctx, dataset, "snapshot,bookmark")
+	if err != nil {
+		return "", err
+	}
+	targets, err := target.listSnapshotGuids(ctx, name, "snapshot")
+	if err != nil {
+		if errors.Is(err, ErrDatasetNotFound) {
+			return "", nil
+		}
+		return "", err
+	}
+
+	guids := map[string]struct{}{}
+	for _, item := range targets {
+		guids[item.guid] = struct{}{}
+	}
+
+	var (
+		common string
+		txg    uint64
+		limit  uint64
+	)
+	for _, item := range sources {
+		if item.name == snapshot {
+			limit = item.txg
+		}
+	}
+	for _, item := range sources {
+		if _, ok := guids[item.guid]; !ok {
+			continue
+		}
+		if limit > 0 && item.txg > limit {
+			continue
+		}
+		// prefers snapshot than bookmark with the same txg
+		if len(common) == 0 || item.txg > txg || (item.txg == txg && strings.Contains(common, "#")) {
+			common, txg = item.name, item.txg
+		}
+	}
+	return common, nil
+}
+
+// GetResumeToken returns receive_resume_token of dataset, it's empty if
+// there is no interrupted stream.
+func (z *ZFSadm) GetResumeToken(ctx context.Context, name string) (string, error) {
+	execute := z.RawZFS().Get(ctx, name, "-H", "", []string{"value"}, "", "", "receive_resume_token")
+	data, err := execute.Exec()
+	if err != nil {
+		return "", err
+	}
+	token := strings.TrimSpace(string(data))
+	if token == "-" {
+		return "", nil
+	}
+	return token, nil
+}
+
+type snapshotGuid struct {
+	name string
+	guid string
+	txg  uint64
+}
+
+func (z *ZFSadm) listSnapshotGuids(ctx context.Context, dataset, t string) ([]snapshotGuid, error) {
+	execute := z.RawZFS().List(ctx, dataset, "-Hp", "-d 1", []string{"name", "guid", "createtxg"}, "", "", t)
+	data, err := execute.Exec()
+	if err != nil {
+		return nil, err
+	}
+
+	items := make([]snapshotGuid, 0)
+	for _, line := range strings.Split(string(data), "\n") {
+		parts := strings.Split(strings.TrimSpace(line), "\t")
+		if len(parts) != 3 {
+			continue
+		}
+		txg, _ := strconv.ParseUint(parts[2], 10, 64)
+		items = append(items, snapshotGuid{name: parts[0], guid: parts[1], txg: txg})
+	}
+	return items, nil
+}
+
+// meterWriter reports the progress of stream by rfs.IOFn
+type meterWriter struct {
+	w       io.Writer
+	fn      rfs.IOFn
+	metric  *rfs.IOMetric
+	written int64
+	err     error
+
+	last time.Time
+	sub  int64
+}
+
+func (m *meterWriter) Write(p []byte) (int, error) {
+	n, err := m.w.Write(p)
+	m.written += int64(n)
+	if err != nil && m.err == nil {
+		m.err = err
+	}
+	if m.fn != nil && time.Since(m.last) >= time.Second {
+		m.report()
+	}
+	return n, err
+}
+
+func (m *meterWriter) report() {
+	if m.fn == nil {
+		return
+	}
+	now := time.Now()
+	m.metric.Block = m.written
+	if elapsed := now.Sub(m.last).Seconds(); elapsed > 0 {
+		m.metric.Speed = int64(float64(m.written-m.sub) / elapsed)
+	}
+	m.last = now
+	m.sub = m.written
+	m.fn(m.metric)
+}
@@ -0,0 +1,103 @@
+// MIT License
+//
+// Copyright (c) 2021 Lack
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+// SOFTWARE.
+
+package zfs
+
+import (
+	"bytes"
+	"context"
+	"testing"
+
+	"github.com/vine-io/pkg/rfs"
+)
+
+func TestReplicator_Replicate(t *testing.T) {
+	source := NewFakeRunner().
+		On("zfs list -Hp -d 1 -o name,guid,createtxg -t snapshot,bookmark tank/a", "tank/a@s1\t1001\t10\ntank/a#s1\t1001\t10\ntank/a@s2\t1002\t20\ntank/a@s3\t1003\t30", nil).
+		On("zfs send -nP -i tank/a@s1 tank/a@s2", "incremental\ts1\ttank/a@s2\t4\nsize\t4", nil).
+		On("zfs send -i tank/a@s1 tank/a@s2", "data", nil)
+	target := NewFakeRunner().
+		On("zfs get -H -o value receive_resume_token backup/a", "-", nil).
+		On("zfs list -Hp -d 1 -o name,guid,createtxg -t snapshot backup/a", "backup/a@s1\t1001\t5", nil).
+		On("zfs receive -s backup/a", "", nil)
+
+	var metric rfs.IOMetric
+	r := NewReplicator(New(WithRunner(source)), func(m *rfs.IOMetric) { metric = *m })
+	result, err := r.Replicate(context.TODO(), "tank/a@s2", New(WithRunner(target)), "backup/a", ReplicateOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result.From != "tank/a@s1" || result.Resumed || result.Bytes != 4 {
+		t.Fatalf("unexpected result: %+v", result)
+	}
+	if metric.Total != 4 || metric.Block != 4 {
+		t.Fatalf("unexpected metric: %+v", metric)
+	}
+}
+
+func TestReplicator_Resume(t *testing.T) {
+	source := NewFakeRunner().
+		On("zfs send -t 1-abc", "data", nil)
+	target := NewFakeRunner().
+		On("zfs get -H -o value receive_resume_token backup/a", "1-abc", nil).
+		On("zfs receive -Fs backup/a", "", nil)
+
+	r := NewReplicator(New(WithRunner(source)), nil)
+	opts := ReplicateOptions{ReceiveOptions: ReceiveOptions{Force: true}}
+	result, err := r.Replicate(context.TODO(), "tank/a@s2", New(WithRunner(target)), "backup/a", opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !result.Resumed || result.Bytes != 4 {
+		t.Fatalf("unexpected result: %+v", result)
+	}
+}
+
+func TestReplicator_Send(t *testing.T) {
+	source := NewFakeRunner().
+		On("zfs send -Rp tank/a@s1", "stream", nil)
+
+	buf := bytes.NewBuffer(nil)
+	r := NewReplicator(New(WithRunner(source)), nil)
+	n, err := r.Send(context.TODO(), "tank/a@s1", buf, SendOptions{Replicate: true, Properties: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n != 6 || buf.String() != "stream" {
+		t.Fatalf("unexpected stream: %d %s", n, buf.String())
+	}
+}
+
+func TestReplicator_SendRaw(t *testing.T) {
+	source := NewFakeRunner().
+		On("zfs send -w -i tank/secret@s1 tank/secret@s2", "encrypted", nil)
+
+	buf := bytes.NewBuffer(nil)
+	r := NewReplicator(New(WithRunner(source)), nil)
+	_, err := r.Send(context.TODO(), "tank/secret@s2", buf, SendOptions{From: "tank/secret@s1", Raw: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if buf.String() != "encrypted" {
+		t.Fatalf("unexpected stream: %s", buf.String())
+	}
+}
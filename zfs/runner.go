@@ -0,0 +1,165 @@
+// MIT License
+//
+// Copyright (c) 2021 Lack
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+// SOFTWARE.
+
+package zfs
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os/exec"
+	"strings"
+	"sync"
+
+	"github.com/vine-io/pkg/rfs"
+)
+
+// Cmd describes a zfs or zpool command which is executed by Runner
+type Cmd struct {
+	Name string
+	Args []string
+
+	Stdin  io.Reader
+	Stdout io.Writer
+	Stderr io.Writer
+}
+
+func (c *Cmd) String() string {
+	return fmt.Sprintf(`%s %s`, c.Name, strings.Join(c.Args, " "))
+}
+
+// Runner executes Cmd, on the local host or on a remote host
+type Runner interface {
+	Run(ctx context.Context, cmd *Cmd) error
+}
+
+var _ Runner = (*localRunner)(nil)
+
+type localRunner struct{}
+
+// NewLocalRunner returns Runner which executes commands on the local host
+func NewLocalRunner() Runner {
+	return &localRunner{}
+}
+
+func (r *localRunner) Run(ctx context.Context, cmd *Cmd) error {
+	c := exec.CommandContext(ctx, cmd.Name, cmd.Args...)
+	c.Stdin = cmd.Stdin
+	c.Stdout = cmd.Stdout
+	c.Stderr = cmd.Stderr
+	return c.Run()
+}
+
+var _ Runner = (*rfsRunner)(nil)
+
+type rfsRunner struct {
+	r rfs.Rfs
+}
+
+// NewRfsRunner returns Runner which executes commands through rfs.Rfs,
+// so that ZFSadm manages zfs on a remote host (e.g. by rfs/ssh).
+func NewRfsRunner(r rfs.Rfs) Runner {
+	return &rfsRunner{r: r}
+}
+
+func (r *rfsRunner) Run(ctx context.Context, cmd *Cmd) error {
+	// the command is interpreted by the shell of remote host, quotes every argument
+	args := make([]string, 0, len(cmd.Args))
+	for _, arg := range cmd.Args {
+		args = append(args, shellQuote(arg))
+	}
+	c := &rfs.Cmd{
+		Name:   cmd.Name,
+		Args:   args,
+		Env:    []string{},
+		Stdin:  cmd.Stdin,
+		Stdout: cmd.Stdout,
+		Stderr: cmd.Stderr,
+	}
+	return r.r.Exec(ctx, c)
+}
+
+var _ Runner = (*FakeRunner)(nil)
+
+type fakeReply struct {
+	out string
+	err error
+}
+
+// FakeRunner records executed commands and replies with stubbed output,
+// it is used for tests.
+type FakeRunner struct {
+	mu sync.Mutex
+
+	replies map[string]fakeReply
+	history []string
+}
+
+func NewFakeRunner() *FakeRunner {
+	return &FakeRunner{replies: map[string]fakeReply{}, history: []string{}}
+}
+
+// On stubs the reply of the given command line, e.g. "zpool list -Hp -o name tank"
+func (f *FakeRunner) On(line string, out string, err error) *FakeRunner {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.replies[line] = fakeReply{out: out, err: err}
+	return f
+}
+
+// History returns all command lines executed by FakeRunner
+func (f *FakeRunner) History() []string {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	history := make([]string, len(f.history))
+	copy(history, f.history)
+	return history
+}
+
+func (f *FakeRunner) Run(ctx context.Context, cmd *Cmd) error {
+	line := cmd.String()
+
+	f.mu.Lock()
+	f.history = append(f.history, line)
+	reply, ok := f.replies[line]
+	f.mu.Unlock()
+
+	if !ok {
+		return fmt.Errorf("fake runner: unexpected command '%s'", line)
+	}
+	if cmd.Stdin != nil {
+		// consumes the input like a real command, e.g. 'zfs receive'
+		if _, err := io.Copy(io.Discard, cmd.Stdin); err != nil {
+			return err
+		}
+	}
+	w := cmd.Stdout
+	if reply.err != nil {
+		w = cmd.Stderr
+	}
+	if w != nil && len(reply.out) > 0 {
+		if _, err := io.WriteString(w, reply.out); err != nil {
+			return err
+		}
+	}
+	return reply.err
+}
@@ -0,0 +1,70 @@
+// MIT License
+//
+// Copyright (c) 2021 Lack
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+// SOFTWARE.
+
+package zfs
+
+import (
+	"context"
+	"errors"
+	"testing"
+)
+
+func TestFakeRunner(t *testing.T) {
+	runner := NewFakeRunner().
+		On("zpool list -Hp -o name tank", "tank\n", nil).
+		On("zpool list -Hp -o name dup", "cannot open 'dup': no such pool\n", errors.New("exit status 1"))
+
+	z := New(WithRunner(runner))
+
+	ctx := context.TODO()
+	if _, err := z.getPool(ctx, "tank"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := z.getPool(ctx, "dup"); err == nil {
+		t.Fatal("expect error for missing pool 'dup'")
+	}
+
+	history := runner.History()
+	if len(history) != 2 || history[0] != "zpool list -Hp -o name tank" {
+		t.Fatalf("unexpected history: %v", history)
+	}
+}
+
+func TestZFSadm_Runner(t *testing.T) {
+	runner := NewFakeRunner().
+		On("zfs get -Hp -t filesystem all", "tank\ttype\tfilesystem\t-\n"+
+			"tank/a\ttype\tfilesystem\t-\ntank/a\tused\t1024\t-\ntank/a\torigin\t-\t-\n"+
+			"tank/b\ttype\tfilesystem\t-\ntank/b\tused\t2048\t-\ntank/b\torigin\ttank/a@s1\t-", nil)
+
+	z := New(WithRunner(runner))
+	filesystems, err := z.GetFileSystems(context.TODO())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(filesystems) != 2 {
+		t.Fatalf("expect 2 filesystems, got %d", len(filesystems))
+	}
+	if fs := filesystems["tank/b"]; fs.Source != "tank/a@s1" || fs.Used != "2048" {
+		t.Fatalf("unexpected filesystem: %+v", fs)
+	}
+}
@@ -0,0 +1,289 @@
+// MIT License
+//
+// Copyright (c) 2021 Lack
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+// SOFTWARE.
+
+package zfs
+
+import (
+	"context"
+	"strings"
+	"sync"
+	"time"
+)
+
+// scanPollInterval the interval of polling 'zpool status' by WaitScan
+var scanPollInterval = time.Second * 5
+
+// StartScrub starts scrub of pool, or resumes the paused scrub
+func (z *ZFSadm) StartScrub(ctx context.Context, pool string) error {
+	_, err := z.RawZPool().Scrub(ctx, pool, "").Exec()
+	return err
+}
+
+// PauseScrub pauses the scrub of pool, StartScrub resumes it
+func (z *ZFSadm) PauseScrub(ctx context.Context, pool string) error {
+	_, err := z.RawZPool().Scrub(ctx, pool, "-p").Exec()
+	return err
+}
+
+// StopScrub cancels the scrub of pool
+func (z *ZFSadm) StopScrub(ctx context.Context, pool string) error {
+	_, err := z.RawZPool().Scrub(ctx, pool, "-s").Exec()
+	return err
+}
+
+// TrimOptions the options of StartTrim
+type TrimOptions struct {
+	// Secure uses secure TRIM (-d)
+	Secure bool
+	// Rate the rate of TRIM per device, e.g. "100M"
+	Rate string
+	// Devices trims the given devices only, all devices of pool if it's empty
+	Devices []string
+}
+
+// StartTrim starts TRIM of pool
+func (z *ZFSadm) StartTrim(ctx context.Context, pool string, opts TrimOptions) error {
+	options := ""
+	if opts.Secure {
+		options = "-d"
+	}
+	_, err := z.RawZPool().Trim(ctx, pool, options, opts.Rate, opts.Devices...).Exec()
+	return err
+}
+
+// StopTrim cancels TRIM of pool
+func (z *ZFSadm) StopTrim(ctx context.Context, pool string, devices ...string) error {
+	_, err := z.RawZPool().Trim(ctx, pool, "-c", "", devices...).Exec()
+	return err
+}
+
+// ScrubStatus returns the state of scrub or resilver of pool
+func (z *ZFSadm) ScrubStatus(ctx context.Context, pool string) (*ScanStatus, error) {
+	status, err := z.GetPoolStatus(ctx, pool)
+	if err != nil {
+		return nil, err
+	}
+	if status.Scan == nil {
+		return &ScanStatus{State: ScanNone}, nil
+	}
+	return status.Scan, nil
+}
+
+// WaitScan blocks until the scrub or resilver of pool is not in progress (finished, canceled or paused),
+// fn reports the progress of scan every poll, it could be nil. The last ScanStatus is returned.
+func (z *ZFSadm) WaitScan(ctx context.Context, pool string, fn func(*ScanStatus)) (*ScanStatus, error) {
+	ticker := time.NewTicker(scanPollInterval)
+	defer ticker.Stop()
+	for {
+		scan, err := z.ScrubStatus(ctx, pool)
+		if err != nil {
+			return nil, err
+		}
+		if fn != nil {
+			fn(scan)
+		}
+		if scan.State != ScanScanning {
+			return scan, nil
+		}
+
+		select {
+		case <-ctx.Done():
+			return scan, ctx.Err()
+		case <-ticker.C:
+		}
+	}
+}
+
+// ScrubScheduler starts the scrubs of pools periodically. A scrub is started when the last
+// scrub of pool finished before the interval and there is no scan in progress, so that the
+// schedule survives the restart of process.
+type ScrubScheduler struct {
+	z *ZFSadm
+
+	mu        sync.Mutex
+	intervals map[string]time.Duration
+	// scrubbed the end time of last scrub of pools, zero if the pool is never scrubbed
+	scrubbed map[string]time.Time
+
+	// Check the interval of checking whether scrubs are due, defaults to 1 minute
+	Check time.Duration
+	// OnError receives the errors of pools, it could be nil
+	OnError func(pool string, err error)
+}
+
+// NewScrubScheduler creates ScrubScheduler for pools managed by z
+func NewScrubScheduler(z *ZFSadm) *ScrubScheduler {
+	return &ScrubScheduler{z: z, intervals: map[string]time.Duration{}, scrubbed: map[string]time.Time{}, Check: time.Minute}
+}
+
+// Schedule scrubs pool every interval
+func (s *ScrubScheduler) Schedule(pool string, interval time.Duration) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.intervals[pool] = interval
+}
+
+// Unschedule removes the schedule of pool
+func (s *ScrubScheduler) Unschedule(pool string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.intervals, pool)
+}
+
+// RunOnce starts the scrubs which are due at now, returns the pools scrubbed
+func (s *ScrubScheduler) RunOnce(ctx context.Context, now time.Time) []string {
+	s.mu.Lock()
+	intervals := make(map[string]time.Duration, len(s.intervals))
+	for pool, interval := range s.intervals {
+		intervals[pool] = interval
+	}
+	s.mu.Unlock()
+
+	started := make([]string, 0)
+	for pool, interval := range intervals {
+		due := false
+		scan, err := s.z.ScrubStatus(ctx, pool)
+		if err == nil {
+			due, err = s.due(ctx, pool, scan, interval, now)
+		}
+		if err == nil && !due {
+			continue
+		}
+		if err == nil {
+			err = s.z.StartScrub(ctx, pool)
+		}
+		if err != nil {
+			if s.OnError != nil {
+				s.OnError(pool, err)
+			}
+			continue
+		}
+		started = append(started, pool)
+	}
+	return started
+}
+
+// Run checks the schedules until ctx is done
+func (s *ScrubScheduler) Run(ctx context.Context) error {
+	check := s.Check
+	if check <= 0 {
+		check = time.Minute
+	}
+	ticker := time.NewTicker(check)
+	defer ticker.Stop()
+	for {
+		s.RunOnce(ctx, time.Now())
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-ticker.C:
+		}
+	}
+}
+
+// due reports whether a new scrub of pool should be started
+func (s *ScrubScheduler) due(ctx context.Context, pool string, scan *ScanStatus, interval time.Duration, now time.Time) (bool, error) {
+	switch {
+	case scan.State == ScanScanning || scan.State == ScanPaused:
+		// don't interrupt the scan in progress or paused by user
+		return false, nil
+	case (scan.State == ScanFinished || scan.State == ScanCanceled) && scan.Function == ScanScrub && !scan.End.IsZero():
+		// a scrub canceled by user is counted as the last scrub, otherwise it's started again at next check
+		s.mu.Lock()
+		s.scrubbed[pool] = scan.End
+		s.mu.Unlock()
+		return scrubDue(scan, scan.End, interval, now), nil
+	}
+	// the last scan is not a finished scrub, the last scrub is looked up in the history
+	last, err := s.lastScrub(ctx, pool)
+	if err != nil {
+		return false, err
+	}
+	return scrubDue(scan, last, interval, now), nil
+}
+
+// lastScrub returns the end time of last scrub of pool, it's looked up in 'zpool history -i'
+// once and cached
+func (s *ScrubScheduler) lastScrub(ctx context.Context, pool string) (time.Time, error) {
+	s.mu.Lock()
+	last, ok := s.scrubbed[pool]
+	s.mu.Unlock()
+	if ok {
+		return last, nil
+	}
+
+	out, err := s.z.RawZPool().History(ctx, "-i", pool).Exec()
+	if err != nil {
+		return time.Time{}, err
+	}
+	last = parseLastScrub(string(out))
+	s.mu.Lock()
+	s.scrubbed[pool] = last
+	s.mu.Unlock()
+	return last, nil
+}
+
+// parseLastScrub returns the end time of last scrub in the output of 'zpool history -i':
+//
+//	2021-06-06.10:00:00 [txg:4] scan setup func=1 mintxg=0 maxtxg=4
+//	2021-06-06.10:10:00 [txg:9] scan done errors=0
+//
+// func=1 is scrub and func=2 is resilver
+func parseLastScrub(data string) time.Time {
+	var last time.Time
+	scrub := false
+	for _, line := range strings.Split(data, "\n") {
+		fields := strings.Fields(line)
+		if len(fields) < 4 || !strings.HasPrefix(fields[1], "[txg:") || fields[2] != "scan" {
+			continue
+		}
+		switch fields[3] {
+		case "setup":
+			scrub = len(fields) > 4 && fields[4] == "func=1"
+		case "done":
+			if !scrub {
+				continue
+			}
+			if t, err := time.ParseInLocation("2006-01-02.15:04:05", fields[0], time.Local); err == nil {
+				last = t
+			}
+		}
+	}
+	return last
+}
+
+// scrubDue reports whether a new scrub should be started, last is the end time of last scrub.
+// A resilver finished after the last scrub defers the scrub too, because it has just read the pool.
+func scrubDue(scan *ScanStatus, last time.Time, interval time.Duration, now time.Time) bool {
+	switch scan.State {
+	case ScanScanning, ScanPaused:
+		return false
+	case ScanFinished:
+		if scan.Function == ScanResilver && scan.End.After(last) {
+			last = scan.End
+		}
+	}
+	if last.IsZero() {
+		return true
+	}
+	return now.Sub(last) >= interval
+}
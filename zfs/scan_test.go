@@ -0,0 +1,201 @@
+// MIT License
+//
+// Copyright (c) 2021 Lack
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+// SOFTWARE.
+
+package zfs
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+)
+
+const scrubbingStatus = `  pool: tank
+ state: ONLINE
+  scan: scrub in progress since Sun Jun  6 10:00:00 2021
+	1.23G scanned at 100M/s, 512M issued at 50M/s, 10.0G total
+	0B repaired, 5.00% done, 00:03:14 to go
+config:
+
+	NAME        STATE     READ WRITE CKSUM
+	tank        ONLINE       0     0     0
+	  sda       ONLINE       0     0     0
+
+errors: No known data errors
+`
+
+const scrubbedStatus = `  pool: tank
+ state: ONLINE
+  scan: scrub repaired 0B in 00:10:00 with 0 errors on Sun Jun  6 10:10:00 2021
+config:
+
+	NAME        STATE     READ WRITE CKSUM
+	tank        ONLINE       0     0     0
+	  sda       ONLINE       0     0     0
+
+errors: No known data errors
+`
+
+func TestZFSadm_Scrub(t *testing.T) {
+	runner := NewFakeRunner().
+		On("zpool scrub tank", "", nil).
+		On("zpool scrub -p tank", "", nil).
+		On("zpool scrub -s tank", "", nil).
+		On("zpool trim -d -r 100M tank sda", "", nil)
+
+	z := New(WithRunner(runner))
+	ctx := context.TODO()
+	if err := z.StartScrub(ctx, "tank"); err != nil {
+		t.Fatal(err)
+	}
+	if err := z.PauseScrub(ctx, "tank"); err != nil {
+		t.Fatal(err)
+	}
+	if err := z.StopScrub(ctx, "tank"); err != nil {
+		t.Fatal(err)
+	}
+	if err := z.StartTrim(ctx, "tank", TrimOptions{Secure: true, Rate: "100M", Devices: []string{"sda"}}); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestZFSadm_WaitScan(t *testing.T) {
+	interval := scanPollInterval
+	scanPollInterval = time.Millisecond
+	defer func() { scanPollInterval = interval }()
+
+	runner := NewFakeRunner().On("zpool status -p tank", scrubbingStatus, nil)
+	z := New(WithRunner(runner))
+
+	polls := 0
+	scan, err := z.WaitScan(context.TODO(), "tank", func(scan *ScanStatus) {
+		polls++
+		if polls == 2 {
+			runner.On("zpool status -p tank", scrubbedStatus, nil)
+		}
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if scan.State != ScanFinished || polls != 3 {
+		t.Fatalf("unexpected scan after %d polls: %+v", polls, scan)
+	}
+}
+
+func TestScrubScheduler_RunOnce(t *testing.T) {
+	runner := NewFakeRunner().
+		On("zpool status -p tank", scrubbedStatus, nil).
+		On("zpool scrub tank", "", nil)
+
+	z := New(WithRunner(runner))
+	s := NewScrubScheduler(z)
+	s.Schedule("tank", time.Hour*24*7)
+
+	end := time.Date(2021, 6, 6, 10, 10, 0, 0, time.Local)
+	if started := s.RunOnce(context.TODO(), end.Add(time.Hour)); len(started) != 0 {
+		t.Fatalf("scrub is not due: %v", started)
+	}
+	if started := s.RunOnce(context.TODO(), end.Add(time.Hour*24*8)); len(started) != 1 {
+		t.Fatalf("scrub is due: %v", started)
+	}
+}
+
+func TestScrubScheduler_Canceled(t *testing.T) {
+	runner := NewFakeRunner().
+		On("zpool status -p tank", scrubbedStatus, nil).
+		On("zpool scrub tank", "", nil).
+		On("zpool scrub -s tank", "", nil)
+
+	z := New(WithRunner(runner))
+	s := NewScrubScheduler(z)
+	week := time.Hour * 24 * 7
+	s.Schedule("tank", week)
+
+	end := time.Date(2021, 6, 6, 10, 10, 0, 0, time.Local)
+	if started := s.RunOnce(context.TODO(), end.Add(week)); len(started) != 1 {
+		t.Fatalf("scrub is due: %v", started)
+	}
+	if err := z.StopScrub(context.TODO(), "tank"); err != nil {
+		t.Fatal(err)
+	}
+	canceledStatus := strings.Replace(scrubbedStatus, "scrub repaired 0B in 00:10:00 with 0 errors on Sun Jun  6 10:10:00 2021",
+		"scrub canceled on Sun Jun 13 10:11:00 2021", 1)
+	runner.On("zpool status -p tank", canceledStatus, nil)
+
+	canceled := time.Date(2021, 6, 13, 10, 11, 0, 0, time.Local)
+	if started := s.RunOnce(context.TODO(), canceled.Add(time.Minute)); len(started) != 0 {
+		t.Fatalf("canceled scrub is started again: %v", started)
+	}
+	if started := s.RunOnce(context.TODO(), canceled.Add(week)); len(started) != 1 {
+		t.Fatalf("scrub is due: %v", started)
+	}
+}
+
+const scrubHistory = `History for 'tank':
+2021-05-01.10:00:00 zpool create tank sda
+2021-05-30.10:00:00 [txg:4] scan setup func=1 mintxg=0 maxtxg=4
+2021-05-30.10:10:00 [txg:9] scan done errors=0
+2021-06-05.10:00:00 [txg:20] scan setup func=2 mintxg=3 maxtxg=20
+2021-06-05.10:05:00 [txg:25] scan done errors=0
+2021-06-05.11:00:00 zpool scrub tank
+2021-06-05.11:00:00 [txg:30] scan setup func=1 mintxg=0 maxtxg=30
+2021-06-05.11:01:00 [txg:31] scan cancelled
+`
+
+func TestScrubScheduler_History(t *testing.T) {
+	resilveredStatus := strings.Replace(scrubbedStatus, "scrub repaired 0B in 00:10:00", "resilvered 1G in 00:10:00", 1)
+	canceledStatus := strings.Replace(scrubbedStatus, "scrub repaired 0B in 00:10:00 with 0 errors", "scrub canceled", 1)
+	unscannedStatus := strings.Replace(scrubbedStatus, "scrub repaired 0B in 00:10:00 with 0 errors on Sun Jun  6 10:10:00 2021", "none requested", 1)
+	end := time.Date(2021, 6, 6, 10, 10, 0, 0, time.Local)
+	scrubbed := time.Date(2021, 5, 30, 10, 10, 0, 0, time.Local)
+	week := time.Hour * 24 * 7
+
+	cases := []struct {
+		name    string
+		status  string
+		history string
+		now     time.Time
+		due     bool
+	}{
+		{name: "resilver just finished", status: resilveredStatus, history: scrubHistory, now: end.Add(time.Hour), due: false},
+		{name: "resilver finished before interval", status: resilveredStatus, history: scrubHistory, now: end.Add(week), due: true},
+		{name: "scrub canceled", status: canceledStatus, history: scrubHistory, now: end.Add(time.Hour * 24), due: false},
+		{name: "scrub canceled after interval", status: canceledStatus, history: scrubHistory, now: end.Add(week), due: true},
+		{name: "scrubbed before interval", status: unscannedStatus, history: scrubHistory, now: scrubbed.Add(time.Hour * 24), due: false},
+		{name: "never scrubbed", status: unscannedStatus, history: "History for 'tank':\n", now: end, due: true},
+	}
+	for _, c := range cases {
+		runner := NewFakeRunner().
+			On("zpool status -p tank", c.status, nil).
+			On("zpool history -i tank", c.history, nil).
+			On("zpool scrub tank", "", nil)
+		s := NewScrubScheduler(New(WithRunner(runner)))
+		s.Schedule("tank", week)
+		if started := s.RunOnce(context.TODO(), c.now); (len(started) == 1) != c.due {
+			t.Fatalf("%s: expect due %v, got %v", c.name, c.due, started)
+		}
+	}
+
+	if last := parseLastScrub(scrubHistory); !last.Equal(scrubbed) {
+		t.Fatalf("unexpected last scrub %v", last)
+	}
+}
@@ -0,0 +1,322 @@
+// MIT License
+//
+// Copyright (c) 2021 Lack
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+// SOFTWARE.
+
+package zfs
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+)
+
+var ErrInvalidShareSpec = errors.New("invalid share specification")
+
+// NFSAccess the access of NFS client
+type NFSAccess string
+
+const (
+	NFSReadWrite NFSAccess = "rw"
+	NFSReadOnly  NFSAccess = "ro"
+)
+
+// NFSSquash the mapping of remote users to the anonymous user
+type NFSSquash string
+
+const (
+	// RootSquash maps root to the anonymous user, it's the default of NFS server
+	RootSquash   NFSSquash = "root_squash"
+	NoRootSquash NFSSquash = "no_root_squash"
+	AllSquash    NFSSquash = "all_squash"
+)
+
+// nfsSecFlavors the security flavors of sec option
+var nfsSecFlavors = map[string]bool{"sys": true, "krb5": true, "krb5i": true, "krb5p": true, "none": true}
+
+// NFSClient the client which the filesystem is exported to
+type NFSClient struct {
+	// Host the hostname, netgroup, wildcard or network, e.g. @192.168.2.0/24, *.example.com
+	Host   string    `json:"host"`
+	Access NFSAccess `json:"access"`
+}
+
+// NFSShare the options of sharenfs
+type NFSShare struct {
+	// Clients the clients which the filesystem is exported to, the filesystem is exported
+	// to everyone with the default options of NFS server if it's empty
+	Clients []NFSClient `json:"clients,omitempty"`
+	// Squash the squash option, the default of NFS server if it's empty
+	Squash NFSSquash `json:"squash,omitempty"`
+	// Sec the security flavors in the order of preference, e.g. krb5p, krb5
+	Sec []string `json:"sec,omitempty"`
+	// Insecure allows the requests from the ports above 1024
+	Insecure bool `json:"insecure,omitempty"`
+	// Options the other options kept verbatim, e.g. anonuid=65534
+	Options []string `json:"options,omitempty"`
+}
+
+// SMBShare the options of sharesmb
+type SMBShare struct {
+	// Name the name of share, the name of filesystem is used if it's empty
+	Name string `json:"name,omitempty"`
+	// GuestOK allows the access without password
+	GuestOK bool `json:"guestOK,omitempty"`
+	// Options the other options kept verbatim
+	Options []string `json:"options,omitempty"`
+}
+
+// ShareSpec the NFS and SMB shares of filesystem, the share is off if it's nil
+type ShareSpec struct {
+	NFS *NFSShare `json:"nfs,omitempty"`
+	SMB *SMBShare `json:"smb,omitempty"`
+}
+
+// Validate checks the clients, squash and security flavors of shares
+func (s *ShareSpec) Validate() error {
+	if s.NFS != nil {
+		for _, client := range s.NFS.Clients {
+			if len(client.Host) == 0 || strings.ContainsAny(client.Host, ", \t\n") {
+				return fmt.Errorf("%w: invalid NFS client %q", ErrInvalidShareSpec, client.Host)
+			}
+			if client.Access != NFSReadWrite && client.Access != NFSReadOnly {
+				return fmt.Errorf("%w: invalid access %q of NFS client %s", ErrInvalidShareSpec, client.Access, client.Host)
+			}
+		}
+		switch s.NFS.Squash {
+		case "", RootSquash, NoRootSquash, AllSquash:
+		default:
+			return fmt.Errorf("%w: invalid NFS squash %q", ErrInvalidShareSpec, s.NFS.Squash)
+		}
+		for _, flavor := range s.NFS.Sec {
+			if !nfsSecFlavors[flavor] {
+				return fmt.Errorf("%w: invalid NFS security flavor %q", ErrInvalidShareSpec, flavor)
+			}
+		}
+		if err := validateShareOptions("NFS", s.NFS.Options); err != nil {
+			return err
+		}
+	}
+	if s.SMB != nil {
+		if strings.ContainsAny(s.SMB.Name, ",= \t\n") {
+			return fmt.Errorf("%w: invalid SMB share name %q", ErrInvalidShareSpec, s.SMB.Name)
+		}
+		if err := validateShareOptions("SMB", s.SMB.Options); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func validateShareOptions(kind string, options []string) error {
+	for _, option := range options {
+		if len(option) == 0 || strings.ContainsAny(option, ", \t\n") {
+			return fmt.Errorf("%w: invalid %s option %q", ErrInvalidShareSpec, kind, option)
+		}
+	}
+	return nil
+}
+
+// NFSValue returns the value of sharenfs, e.g. rw=@192.168.2.0/24,ro=@10.0.0.0/8,all_squash
+func (s *ShareSpec) NFSValue() string {
+	if s == nil || s.NFS == nil {
+		return "off"
+	}
+	options := make([]string, 0)
+	for _, client := range s.NFS.Clients {
+		options = append(options, string(client.Access)+"="+client.Host)
+	}
+	if len(s.NFS.Squash) > 0 {
+		options = append(options, string(s.NFS.Squash))
+	}
+	if len(s.NFS.Sec) > 0 {
+		options = append(options, "sec="+strings.Join(s.NFS.Sec, ":"))
+	}
+	if s.NFS.Insecure {
+		options = append(options, "insecure")
+	}
+	options = append(options, s.NFS.Options...)
+	if len(options) == 0 {
+		return "on"
+	}
+	return strings.Join(options, ",")
+}
+
+// SMBValue returns the value of sharesmb, e.g. name=data,guestok=true
+func (s *ShareSpec) SMBValue() string {
+	if s == nil || s.SMB == nil {
+		return "off"
+	}
+	options := make([]string, 0)
+	if len(s.SMB.Name) > 0 {
+		options = append(options, "name="+s.SMB.Name)
+	}
+	if s.SMB.GuestOK {
+		options = append(options, "guestok=true")
+	}
+	options = append(options, s.SMB.Options...)
+	if len(options) == 0 {
+		return "on"
+	}
+	return strings.Join(options, ",")
+}
+
+// ParseShareSpec parses the values of sharenfs and sharesmb
+func ParseShareSpec(sharenfs, sharesmb string) (*ShareSpec, error) {
+	nfs, err := ParseNFSShare(sharenfs)
+	if err != nil {
+		return nil, err
+	}
+	smb, err := ParseSMBShare(sharesmb)
+	if err != nil {
+		return nil, err
+	}
+	return &ShareSpec{NFS: nfs, SMB: smb}, nil
+}
+
+// ParseNFSShare parses the value of sharenfs, nil if it's off. The hosts of rw and ro could be
+// separated by colon, e.g. rw=@192.168.2.0/24:@192.168.221.0/24, the IPv6 addresses must be
+// enclosed in brackets.
+func ParseNFSShare(value string) (*NFSShare, error) {
+	value = strings.TrimSpace(value)
+	switch value {
+	case "", "-", "off":
+		return nil, nil
+	case "on":
+		return &NFSShare{}, nil
+	}
+
+	share := &NFSShare{}
+	for _, option := range strings.Split(value, ",") {
+		if len(option) == 0 {
+			continue
+		}
+		key, val, _ := strings.Cut(option, "=")
+		switch key {
+		case string(NFSReadWrite), string(NFSReadOnly):
+			if len(val) == 0 {
+				// the access for everyone
+				share.Clients = append(share.Clients, NFSClient{Host: "*", Access: NFSAccess(key)})
+				continue
+			}
+			for _, host := range splitNFSHosts(val) {
+				share.Clients = append(share.Clients, NFSClient{Host: host, Access: NFSAccess(key)})
+			}
+		case string(RootSquash), string(NoRootSquash), string(AllSquash):
+			share.Squash = NFSSquash(key)
+		case "sec":
+			share.Sec = append(share.Sec, strings.Split(val, ":")...)
+		case "insecure":
+			share.Insecure = true
+		case "secure":
+			share.Insecure = false
+		default:
+			share.Options = append(share.Options, option)
+		}
+	}
+	if err := (&ShareSpec{NFS: share}).Validate(); err != nil {
+		return nil, err
+	}
+	return share, nil
+}
+
+// splitNFSHosts splits the hosts separated by colon, except the colons in brackets
+func splitNFSHosts(s string) []string {
+	hosts := make([]string, 0)
+	depth, start := 0, 0
+	for i, c := range s {
+		switch c {
+		case '[':
+			depth++
+		case ']':
+			depth--
+		case ':':
+			if depth == 0 {
+				if i > start {
+					hosts = append(hosts, s[start:i])
+				}
+				start = i + 1
+			}
+		}
+	}
+	if start < len(s) {
+		hosts = append(hosts, s[start:])
+	}
+	return hosts
+}
+
+// ParseSMBShare parses the value of sharesmb, nil if it's off
+func ParseSMBShare(value string) (*SMBShare, error) {
+	value = strings.TrimSpace(value)
+	switch value {
+	case "", "-", "off":
+		return nil, nil
+	case "on":
+		return &SMBShare{}, nil
+	}
+
+	share := &SMBShare{}
+	for _, option := range strings.Split(value, ",") {
+		if len(option) == 0 {
+			continue
+		}
+		key, val, _ := strings.Cut(option, "=")
+		switch key {
+		case "name":
+			share.Name = val
+		case "guestok":
+			share.GuestOK = val == "true" || val == "on"
+		default:
+			share.Options = append(share.Options, option)
+		}
+	}
+	if err := (&ShareSpec{SMB: share}).Validate(); err != nil {
+		return nil, err
+	}
+	return share, nil
+}
+
+// GetShare returns the NFS and SMB shares of filesystem
+func (z *ZFSadm) GetShare(ctx context.Context, name string) (*ShareSpec, error) {
+	out, err := z.RawZFS().Get(ctx, name, "-Hp", "", []string{"value"}, "", "", "sharenfs", "sharesmb").Exec()
+	if err != nil {
+		return nil, err
+	}
+	values := strings.Split(string(out), "\n")
+	if len(values) != 2 {
+		return nil, fmt.Errorf("invalid share properties of %s: %q", name, string(out))
+	}
+	return ParseShareSpec(values[0], values[1])
+}
+
+// SetShare sets sharenfs and sharesmb of filesystem by spec, the share which is nil is turned
+// off. No other property is changed.
+func (z *ZFSadm) SetShare(ctx context.Context, name string, spec *ShareSpec) error {
+	if spec == nil {
+		spec = &ShareSpec{}
+	}
+	if err := spec.Validate(); err != nil {
+		return err
+	}
+	properties := map[string]string{"sharenfs": spec.NFSValue(), "sharesmb": spec.SMBValue()}
+	_, err := z.RawZFS().Set(ctx, name, properties).Exec()
+	return err
+}
@@ -0,0 +1,132 @@
+// MIT License
+//
+// Copyright (c) 2021 Lack
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+// SOFTWARE.
+
+package zfs
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"testing"
+)
+
+func TestParseShareSpec(t *testing.T) {
+	spec, err := ParseShareSpec("rw=@192.168.2.0/24:@[fe80::1]/64,ro,all_squash,sec=krb5p:krb5,insecure,anonuid=65534", "name=data,guestok=true,abe=true")
+	if err != nil {
+		t.Fatal(err)
+	}
+	expect := &ShareSpec{
+		NFS: &NFSShare{
+			Clients: []NFSClient{
+				{Host: "@192.168.2.0/24", Access: NFSReadWrite},
+				{Host: "@[fe80::1]/64", Access: NFSReadWrite},
+				{Host: "*", Access: NFSReadOnly},
+			},
+			Squash:   AllSquash,
+			Sec:      []string{"krb5p", "krb5"},
+			Insecure: true,
+			Options:  []string{"anonuid=65534"},
+		},
+		SMB: &SMBShare{Name: "data", GuestOK: true, Options: []string{"abe=true"}},
+	}
+	if !reflect.DeepEqual(spec, expect) {
+		t.Fatalf("expect %+v, got %+v", expect, spec)
+	}
+	if value := spec.NFSValue(); value != "rw=@192.168.2.0/24,rw=@[fe80::1]/64,ro=*,all_squash,sec=krb5p:krb5,insecure,anonuid=65534" {
+		t.Fatalf("unexpected sharenfs %s", value)
+	}
+	if value := spec.SMBValue(); value != "name=data,guestok=true,abe=true" {
+		t.Fatalf("unexpected sharesmb %s", value)
+	}
+
+	spec, err = ParseShareSpec("on", "off")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if spec.NFS == nil || spec.SMB != nil || spec.NFSValue() != "on" || spec.SMBValue() != "off" {
+		t.Fatalf("unexpected spec %+v", spec)
+	}
+
+	if _, err = ParseNFSShare("sec=ntlm"); !errors.Is(err, ErrInvalidShareSpec) {
+		t.Fatalf("expect ErrInvalidShareSpec, got %v", err)
+	}
+	spec = &ShareSpec{NFS: &NFSShare{Clients: []NFSClient{{Host: "@10.0.0.0/8", Access: "rx"}}}}
+	if err = spec.Validate(); !errors.Is(err, ErrInvalidShareSpec) {
+		t.Fatalf("expect ErrInvalidShareSpec, got %v", err)
+	}
+	spec = &ShareSpec{NFS: &NFSShare{Clients: []NFSClient{{Host: "a,b", Access: NFSReadOnly}}}}
+	if err = spec.Validate(); !errors.Is(err, ErrInvalidShareSpec) {
+		t.Fatalf("expect ErrInvalidShareSpec, got %v", err)
+	}
+}
+
+func TestZFSadm_Share(t *testing.T) {
+	_, z := newTestSimulator(t)
+	ctx := context.TODO()
+
+	mustExec(t, z.RawZFS().CreateFileSystem(ctx, "tank/a", nil))
+	spec := &ShareSpec{
+		NFS: &NFSShare{
+			Clients: []NFSClient{{Host: "@192.168.2.0/24", Access: NFSReadWrite}, {Host: "backup", Access: NFSReadOnly}},
+			Squash:  RootSquash,
+		},
+		SMB: &SMBShare{Name: "a"},
+	}
+	if err := z.SetShare(ctx, "tank/a", spec); err != nil {
+		t.Fatal(err)
+	}
+	got, err := z.GetShare(ctx, "tank/a")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(got, spec) {
+		t.Fatalf("expect %+v, got %+v", spec, got)
+	}
+
+	if err = z.SetShare(ctx, "tank/a", &ShareSpec{SMB: &SMBShare{}}); err != nil {
+		t.Fatal(err)
+	}
+	if got, err = z.GetShare(ctx, "tank/a"); err != nil || got.NFS != nil || got.SMB == nil {
+		t.Fatalf("unexpected share %+v, %v", got, err)
+	}
+
+	// the legacy share changes sharenfs only
+	if err = z.ShareFileSystem(ctx, "tank/a", []string{"192.168.2.0/24"}, "rw"); err != nil {
+		t.Fatal(err)
+	}
+	if value := mustGet(t, z, "tank/a", "sharenfs"); value != "rw=@192.168.2.0/24,no_root_squash,insecure" {
+		t.Fatalf("unexpected sharenfs %s", value)
+	}
+	if value := mustGet(t, z, "tank/a", "sync"); value != "standard" {
+		t.Fatalf("unexpected sync %s", value)
+	}
+	if value := mustGet(t, z, "tank/a", "sharesmb"); value != "on" {
+		t.Fatalf("unexpected sharesmb %s", value)
+	}
+	if err = z.ShareFileSystem(ctx, "tank/a", []string{"192.168.2.0/24"}, "rx"); !errors.Is(err, ErrInvalidShareSpec) {
+		t.Fatalf("expect ErrInvalidShareSpec, got %v", err)
+	}
+
+	if err = z.SetShare(ctx, "tank/x", nil); !errors.Is(err, ErrDatasetNotFound) {
+		t.Fatalf("expect ErrDatasetNotFound, got %v", err)
+	}
+}
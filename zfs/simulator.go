@@ -0,0 +1,1843 @@
+// MIT License
+//
+// Copyright (c) 2021 Lack
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+// SOFTWARE.
+
+package zfs
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"text/tabwriter"
+	"time"
+)
+
+var _ Runner = (*Simulator)(nil)
+
+// Simulator is an in-memory zfs and zpool which implements Runner. It emulates pools,
+// filesystems, volumes, snapshots, clones, holds and properties with inheritance, and
+// prints the output formats (-H, -p) of 'zfs list/get' and 'zpool list/get/status',
+// so that ZFSadm could be tested without real pools:
+//
+//	sim := NewSimulator()
+//	z := New(WithRunner(sim))
+//	_, err := z.CreatePool(ctx, &PoolSpec{Name: "tank", Data: []VdevSpec{{Type: GroupMirror, Devices: []string{"sda", "sdb"}}}})
+type Simulator struct {
+	mu sync.Mutex
+
+	// Now returns the current time, defaults to time.Now
+	Now func() time.Time
+	// DeviceSize the capacity of every device, defaults to 10G
+	DeviceSize uint64
+	// ResilverTime the duration of resilver started by 'zpool replace', it's measured by Now,
+	// 0 finishes the resilver at once
+	ResilverTime time.Duration
+
+	txg      uint64
+	guid     uint64
+	pools    map[string]*simPool
+	exported map[string]*simPool
+	datasets map[string]*simDataset
+	// bookmarks the bookmarks by their full names, e.g. tank/a#b1
+	bookmarks map[string]*simDataset
+	// sizes the capacities of devices which are different from DeviceSize
+	sizes   map[string]uint64
+	history []string
+}
+
+// NewSimulator creates Simulator without any pool
+func NewSimulator() *Simulator {
+	return &Simulator{
+		Now:        time.Now,
+		DeviceSize: 10 << 30,
+		txg:        1,
+		guid:       0x1000,
+		pools:      map[string]*simPool{},
+		exported:   map[string]*simPool{},
+		datasets:   map[string]*simDataset{},
+		bookmarks:  map[string]*simDataset{},
+		sizes:      map[string]uint64{},
+		history:    []string{},
+	}
+}
+
+// History returns all command lines executed by Simulator
+func (s *Simulator) History() []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	history := make([]string, len(s.history))
+	copy(history, s.history)
+	return history
+}
+
+// Write simulates writing size bytes into filesystem or volume
+func (s *Simulator) Write(dataset string, size uint64) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	d, ok := s.datasets[dataset]
+	if !ok || d.typ == "snapshot" {
+		return fmt.Errorf("%w: %s", ErrDatasetNotFound, dataset)
+	}
+	if size > s.available(d) {
+		return fmt.Errorf("%w: %s", ErrNoSpace, dataset)
+	}
+	d.referenced += size
+	s.txg++
+	return nil
+}
+
+// SetDeviceState simulates the failure or recovery of device, e.g. "FAULTED", "REMOVED", "ONLINE"
+func (s *Simulator) SetDeviceState(pool, device, state string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	p, ok := s.pools[pool]
+	if !ok {
+		return fmt.Errorf("%w: %s", ErrPoolNotFound, pool)
+	}
+	v := p.device(device)
+	if v == nil {
+		return fmt.Errorf("%w: %s", ErrDeviceNotFound, device)
+	}
+	v.state = state
+	return nil
+}
+
+// SetDeviceErrors simulates the read, write and checksum errors of device
+func (s *Simulator) SetDeviceErrors(pool, device string, read, write, checksum uint64) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	p, ok := s.pools[pool]
+	if !ok {
+		return fmt.Errorf("%w: %s", ErrPoolNotFound, pool)
+	}
+	v := p.device(device)
+	if v == nil {
+		return fmt.Errorf("%w: %s", ErrDeviceNotFound, device)
+	}
+	v.read, v.write, v.checksum = read, write, checksum
+	return nil
+}
+
+// SetDeviceSize sets the capacity of device reported by lsblk, 0 simulates a missing device
+func (s *Simulator) SetDeviceSize(device string, size uint64) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.sizes[filepath.Base(device)] = size
+}
+
+// deviceSize returns the capacity of device, 0 if it's missing
+func (s *Simulator) deviceSize(device string) uint64 {
+	if size, ok := s.sizes[filepath.Base(device)]; ok {
+		return size
+	}
+	return s.DeviceSize
+}
+
+func (s *Simulator) Run(ctx context.Context, cmd *Cmd) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.history = append(s.history, cmd.String())
+	if cmd.Stdin != nil {
+		if _, err := io.Copy(io.Discard, cmd.Stdin); err != nil {
+			return err
+		}
+	}
+
+	var (
+		out string
+		err error
+	)
+	switch filepath.Base(cmd.Name) {
+	case "zfs":
+		out, err = s.zfs(cmd.Args)
+	case "zpool":
+		out, err = s.zpool(cmd.Args)
+	case "lsblk":
+		out, err = s.lsblk(cmd.Args)
+	default:
+		err = &simError{code: 127, message: fmt.Sprintf("%s: command not found", cmd.Name)}
+	}
+
+	if err != nil {
+		e, ok := err.(*simError)
+		if !ok {
+			e = &simError{code: 1, message: err.Error()}
+		}
+		if cmd.Stderr != nil {
+			_, _ = io.WriteString(cmd.Stderr, e.message+"\n")
+		}
+		return e
+	}
+	if cmd.Stdout != nil && len(out) > 0 {
+		if _, err = io.WriteString(cmd.Stdout, out); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// simError the failure of simulated command, the message is written to stderr
+type simError struct {
+	code    int
+	message string
+}
+
+func simErrorf(format string, args ...interface{}) error {
+	return &simError{code: 1, message: fmt.Sprintf(format, args...)}
+}
+
+func simUsage(format string, args ...interface{}) error {
+	return &simError{code: 2, message: fmt.Sprintf(format, args...)}
+}
+
+func (e *simError) Error() string {
+	return fmt.Sprintf("exit status %d", e.code)
+}
+
+func (e *simError) ExitCode() int {
+	return e.code
+}
+
+// simFlags the parsed options of command line
+type simFlags struct {
+	set    map[byte]bool
+	values map[byte][]string
+	args   []string
+}
+
+// parseSimFlags parses the options like getopt, valued are the options which take an argument
+func parseSimFlags(args []string, valued string) (*simFlags, error) {
+	f := &simFlags{set: map[byte]bool{}, values: map[byte][]string{}}
+	i := 0
+	for ; i < len(args); i++ {
+		arg := args[i]
+		if arg == "--" {
+			i++
+			break
+		}
+		if !strings.HasPrefix(arg, "-") || arg == "-" {
+			break
+		}
+		for j := 1; j < len(arg); j++ {
+			c := arg[j]
+			if strings.IndexByte(valued, c) < 0 {
+				f.set[c] = true
+				continue
+			}
+			value := arg[j+1:]
+			if len(value) == 0 {
+				i++
+				if i >= len(args) {
+					return nil, simUsage("missing argument for '-%c' option", c)
+				}
+				value = args[i]
+			}
+			f.set[c] = true
+			f.values[c] = append(f.values[c], value)
+			break
+		}
+	}
+	f.args = args[i:]
+	return f, nil
+}
+
+func (f *simFlags) has(c byte) bool {
+	return f.set[c]
+}
+
+func (f *simFlags) value(c byte) string {
+	if values := f.values[c]; len(values) > 0 {
+		return values[len(values)-1]
+	}
+	return ""
+}
+
+// list returns the comma separated values of option
+func (f *simFlags) list(c byte) []string {
+	items := make([]string, 0)
+	for _, value := range f.values[c] {
+		items = append(items, strings.Split(value, ",")...)
+	}
+	return items
+}
+
+func simPropertyArgs(values []string) (map[string]string, error) {
+	properties := map[string]string{}
+	for _, value := range values {
+		kv := strings.SplitN(value, "=", 2)
+		if len(kv) != 2 {
+			return nil, simUsage("missing '=' for property=value argument")
+		}
+		properties[kv[0]] = kv[1]
+	}
+	return properties, nil
+}
+
+// simTable prints rows with tab separated (-H) or aligned columns with header
+func simTable(scripted bool, header []string, rows [][]string) string {
+	b := &strings.Builder{}
+	if scripted {
+		for _, row := range rows {
+			b.WriteString(strings.Join(row, "\t") + "\n")
+		}
+		return b.String()
+	}
+	w := tabwriter.NewWriter(b, 0, 8, 2, ' ', 0)
+	titles := make([]string, 0, len(header))
+	for _, title := range header {
+		titles = append(titles, strings.ToUpper(title))
+	}
+	_, _ = fmt.Fprintln(w, strings.Join(titles, "\t"))
+	for _, row := range rows {
+		_, _ = fmt.Fprintln(w, strings.Join(row, "\t"))
+	}
+	_ = w.Flush()
+	return b.String()
+}
+
+// simNicenum formats size like zfs, e.g. 0B, 512B, 96K, 1.50G
+func simNicenum(n uint64) string {
+	units := "BKMGTPE"
+	f := float64(n)
+	i := 0
+	for f >= 1024 && i < len(units)-1 {
+		f /= 1024
+		i++
+	}
+	switch {
+	case i == 0:
+		return fmt.Sprintf("%dB", n)
+	case f == float64(int64(f)):
+		return fmt.Sprintf("%.0f%c", f, units[i])
+	case f < 10:
+		return fmt.Sprintf("%.2f%c", f, units[i])
+	case f < 100:
+		return fmt.Sprintf("%.1f%c", f, units[i])
+	}
+	return fmt.Sprintf("%.0f%c", f, units[i])
+}
+
+// simDataset the filesystem, volume or snapshot
+type simDataset struct {
+	name       string
+	typ        string
+	guid       uint64
+	createtxg  uint64
+	creation   time.Time
+	referenced uint64
+	origin     string
+	props      map[string]string
+	holds      map[string]time.Time
+	// deferred the snapshot is destroyed when the last hold or clone is gone (zfs destroy -d)
+	deferred bool
+}
+
+// simProperty the native property of dataset
+type simProperty struct {
+	name string
+	// types the types which property applies to, f: filesystem, v: volume, s: snapshot, b: bookmark
+	types string
+	def   string
+	// inherit the property is inherited from the parent
+	inherit bool
+	// readonly the property is computed or could be set on creation only
+	readonly bool
+	// size the value is the size in bytes
+	size bool
+}
+
+// simProperties the native properties in the order of 'zfs get all'
+var simProperties = []simProperty{
+	{name: "type", types: "fvsb", readonly: true},
+	{name: "creation", types: "fvsb", readonly: true},
+	{name: "used", types: "fvs", readonly: true, size: true},
+	{name: "available", types: "fv", readonly: true, size: true},
+	{name: "referenced", types: "fvs", readonly: true, size: true},
+	{name: "compressratio", types: "fvs", readonly: true},
+	{name: "mounted", types: "f", readonly: true},
+	{name: "origin", types: "fv", readonly: true},
+	{name: "quota", types: "f", def: "0", size: true},
+	{name: "reservation", types: "fv", def: "0", size: true},
+	{name: "volsize", types: "v", size: true},
+	{name: "volblocksize", types: "v", def: "8192", readonly: true, size: true},
+	{name: "recordsize", types: "f", def: "131072", inherit: true, size: true},
+	{name: "mountpoint", types: "f", inherit: true},
+	{name: "sharenfs", types: "f", def: "off", inherit: true},
+	{name: "checksum", types: "fv", def: "on", inherit: true},
+	{name: "compression", types: "fv", def: "off", inherit: true},
+	{name: "atime", types: "f", def: "on", inherit: true},
+	{name: "devices", types: "fs", def: "on", inherit: true},
+	{name: "exec", types: "fs", def: "on", inherit: true},
+	{name: "setuid", types: "fs", def: "on", inherit: true},
+	{name: "readonly", types: "fv", def: "off", inherit: true},
+	{name: "zoned", types: "f", def: "off", inherit: true},
+	{name: "snapdir", types: "f", def: "hidden", inherit: true},
+	{name: "aclinherit", types: "f", def: "restricted", inherit: true},
+	{name: "createtxg", types: "fvsb", readonly: true},
+	{name: "canmount", types: "f", def: "on"},
+	{name: "xattr", types: "fs", def: "on", inherit: true},
+	{name: "copies", types: "fv", def: "1", inherit: true},
+	{name: "version", types: "fs", def: "5", readonly: true},
+	{name: "utf8only", types: "fs", def: "off", readonly: true},
+	{name: "normalization", types: "fs", def: "none", readonly: true},
+	{name: "casesensitivity", types: "fs", def: "sensitive", readonly: true},
+	{name: "vscan", types: "f", def: "off", inherit: true},
+	{name: "nbmand", types: "fs", def: "off", inherit: true},
+	{name: "sharesmb", types: "f", def: "off", inherit: true},
+	{name: "refquota", types: "f", def: "0", size: true},
+	{name: "refreservation", types: "fv", def: "0", size: true},
+	{name: "guid", types: "fvsb", readonly: true},
+	{name: "primarycache", types: "fvs", def: "all", inherit: true},
+	{name: "secondarycache", types: "fvs", def: "all", inherit: true},
+	{name: "usedbysnapshots", types: "fv", readonly: true, size: true},
+	{name: "usedbydataset", types: "fv", readonly: true, size: true},
+	{name: "usedbychildren", types: "fv", readonly: true, size: true},
+	{name: "usedbyrefreservation", types: "fv", readonly: true, size: true},
+	{name: "logbias", types: "fv", def: "latency", inherit: true},
+	{name: "dedup", types: "fv", def: "off", inherit: true},
+	{name: "mlslabel", types: "fvs", def: "none", inherit: true},
+	{name: "sync", types: "fv", def: "standard", inherit: true},
+	{name: "dnodesize", types: "f", def: "legacy", inherit: true},
+	{name: "refcompressratio", types: "fvs", readonly: true},
+	{name: "written", types: "fvs", readonly: true, size: true},
+	{name: "logicalused", types: "fv", readonly: true, size: true},
+	{name: "logicalreferenced", types: "fvs", readonly: true, size: true},
+	{name: "volmode", types: "v", def: "default", inherit: true},
+	{name: "snapdev", types: "fv", def: "hidden", inherit: true},
+	{name: "acltype", types: "fs", def: "off", inherit: true},
+	{name: "redundant_metadata", types: "fv", def: "all", inherit: true},
+	{name: "special_small_blocks", types: "f", def: "0", inherit: true, size: true},
+	{name: "encryption", types: "fvs", def: "off", readonly: true},
+	{name: "keylocation", types: "fv", def: "none"},
+	{name: "keyformat", types: "fv", def: "none", readonly: true},
+	{name: "pbkdf2iters", types: "fv", def: "0", readonly: true},
+	{name: "userrefs", types: "s", readonly: true},
+	{name: "clones", types: "s", readonly: true},
+	{name: "defer_destroy", types: "s", readonly: true},
+	{name: "receive_resume_token", types: "fv", readonly: true},
+}
+
+// simPropertyAliases the short names of properties
+var simPropertyAliases = map[string]string{
+	"avail":     "available",
+	"refer":     "referenced",
+	"ratio":     "compressratio",
+	"reserv":    "reservation",
+	"refreserv": "refreservation",
+	"volblock":  "volblocksize",
+	"recsize":   "recordsize",
+	"lused":     "logicalused",
+	"lrefer":    "logicalreferenced",
+	"compress":  "compression",
+	"refratio":  "refcompressratio",
+}
+
+func lookupSimProperty(name string) (simProperty, bool) {
+	if alias, ok := simPropertyAliases[name]; ok {
+		name = alias
+	}
+	for _, p := range simProperties {
+		if p.name == name {
+			return p, true
+		}
+	}
+	return simProperty{}, false
+}
+
+func isUserProperty(name string) bool {
+	return strings.Contains(name, ":")
+}
+
+func (p simProperty) applies(typ string) bool {
+	return strings.Contains(p.types, typ[:1])
+}
+
+// datasetParent returns the parent of dataset, the dataset of snapshot or "" for the root of pool
+func datasetParent(name string) string {
+	if i := strings.Index(name, "@"); i >= 0 {
+		return name[:i]
+	}
+	if i := strings.LastIndex(name, "/"); i >= 0 {
+		return name[:i]
+	}
+	return ""
+}
+
+func datasetPool(name string) string {
+	return strings.SplitN(strings.SplitN(name, "@", 2)[0], "/", 2)[0]
+}
+
+// datasetDepth returns the depth of dataset, snapshots are one level deeper than their datasets
+func datasetDepth(name string) int {
+	depth := strings.Count(name, "/")
+	if strings.Contains(name, "@") {
+		depth++
+	}
+	return depth
+}
+
+// datasetLess sorts datasets like 'zfs list', a dataset is followed by its snapshots and then its children
+func (s *Simulator) datasetLess(a, b *simDataset) bool {
+	an, bn := strings.SplitN(a.name, "@", 2)[0], strings.SplitN(b.name, "@", 2)[0]
+	if an != bn {
+		ap, bp := strings.Split(an, "/"), strings.Split(bn, "/")
+		for i := 0; i < len(ap) && i < len(bp); i++ {
+			if ap[i] != bp[i] {
+				return ap[i] < bp[i]
+			}
+		}
+		return len(ap) < len(bp)
+	}
+	if (a.typ == "snapshot") != (b.typ == "snapshot") {
+		return b.typ == "snapshot"
+	}
+	return a.createtxg < b.createtxg
+}
+
+func (s *Simulator) sorted(items []*simDataset) []*simDataset {
+	sort.Slice(items, func(i, j int) bool { return s.datasetLess(items[i], items[j]) })
+	return items
+}
+
+// children returns the direct children of dataset, snapshots are included if snapshots is true
+func (s *Simulator) children(name string, snapshots bool) []*simDataset {
+	items := make([]*simDataset, 0)
+	for _, d := range s.datasets {
+		if datasetParent(d.name) != name {
+			continue
+		}
+		if d.typ == "snapshot" && !snapshots {
+			continue
+		}
+		items = append(items, d)
+	}
+	return s.sorted(items)
+}
+
+// descendants returns dataset and all descendants including snapshots
+func (s *Simulator) descendants(name string) []*simDataset {
+	items := make([]*simDataset, 0)
+	for _, d := range s.datasets {
+		base := strings.SplitN(d.name, "@", 2)[0]
+		if base == name || strings.HasPrefix(base, name+"/") {
+			items = append(items, d)
+		}
+	}
+	return s.sorted(items)
+}
+
+// clones returns the datasets cloned from snapshot
+func (s *Simulator) clones(snapshot string) []*simDataset {
+	items := make([]*simDataset, 0)
+	for _, d := range s.datasets {
+		if d.origin == snapshot {
+			items = append(items, d)
+		}
+	}
+	return s.sorted(items)
+}
+
+func (s *Simulator) newDataset(name, typ string, props map[string]string) *simDataset {
+	s.txg++
+	s.guid++
+	d := &simDataset{
+		name:       name,
+		typ:        typ,
+		guid:       s.guid,
+		createtxg:  s.txg,
+		creation:   s.Now(),
+		referenced: 24576,
+		props:      map[string]string{},
+		holds:      map[string]time.Time{},
+	}
+	if typ == "volume" {
+		d.referenced = 12288
+	}
+	for k, v := range props {
+		d.props[k] = v
+	}
+	s.datasets[name] = d
+	return d
+}
+
+// used returns the space used by dataset and its descendants
+func (s *Simulator) used(d *simDataset) uint64 {
+	if d.typ == "snapshot" {
+		return 0
+	}
+	used := d.referenced + s.refreserved(d)
+	for _, child := range s.children(d.name, false) {
+		used += s.used(child)
+	}
+	return used
+}
+
+// refreserved returns the space reserved by refreservation but not referenced
+func (s *Simulator) refreserved(d *simDataset) uint64 {
+	refreservation, _ := strconv.ParseUint(d.props["refreservation"], 10, 64)
+	if refreservation > d.referenced {
+		return refreservation - d.referenced
+	}
+	return 0
+}
+
+func (s *Simulator) available(d *simDataset) uint64 {
+	p, ok := s.pools[datasetPool(d.name)]
+	if !ok {
+		return 0
+	}
+	var available uint64
+	if root, ok := s.datasets[p.name]; ok {
+		if size, used := p.capacity(s.DeviceSize), s.used(root); size > used {
+			available = size - used
+		}
+	}
+	for name := strings.SplitN(d.name, "@", 2)[0]; len(name) > 0; name = datasetParent(name) {
+		ancestor := s.datasets[name]
+		quota, _ := strconv.ParseUint(ancestor.props["quota"], 10, 64)
+		if quota == 0 {
+			continue
+		}
+		used := s.used(ancestor)
+		if used >= quota {
+			return 0
+		}
+		if quota-used < available {
+			available = quota - used
+		}
+	}
+	return available
+}
+
+// property returns the value and the source of property of dataset
+func (s *Simulator) property(d *simDataset, name string, parsable bool) (string, string) {
+	if alias, ok := simPropertyAliases[name]; ok {
+		name = alias
+	}
+	size := func(n uint64) string {
+		if parsable {
+			return strconv.FormatUint(n, 10)
+		}
+		return simNicenum(n)
+	}
+
+	if isUserProperty(name) {
+		for current := d.name; len(current) > 0; current = datasetParent(current) {
+			ancestor := s.datasets[current]
+			if value, ok := ancestor.props[name]; ok {
+				if current == d.name {
+					return value, "local"
+				}
+				return value, "inherited from " + current
+			}
+		}
+		return "-", "-"
+	}
+
+	switch name {
+	case "name":
+		return d.name, "-"
+	case "type":
+		return d.typ, "-"
+	case "creation":
+		if parsable {
+			return strconv.FormatInt(d.creation.Unix(), 10), "-"
+		}
+		return d.creation.Format("Mon Jan _2 15:04 2006"), "-"
+	case "used":
+		return size(s.used(d)), "-"
+	case "available":
+		return size(s.available(d)), "-"
+	case "referenced", "logicalreferenced", "written", "usedbydataset":
+		return size(d.referenced), "-"
+	case "logicalused":
+		return size(s.used(d)), "-"
+	case "usedbychildren":
+		var used uint64
+		for _, child := range s.children(d.name, false) {
+			used += s.used(child)
+		}
+		return size(used), "-"
+	case "usedbysnapshots":
+		return size(0), "-"
+	case "usedbyrefreservation":
+		return size(s.refreserved(d)), "-"
+	case "compressratio", "refcompressratio":
+		if parsable {
+			return "1.00", "-"
+		}
+		return "1.00x", "-"
+	case "mounted":
+		mountpoint, _ := s.property(d, "mountpoint", parsable)
+		if d.props["canmount"] == "off" || mountpoint == "none" || mountpoint == "legacy" {
+			return "no", "-"
+		}
+		return "yes", "-"
+	case "origin":
+		if len(d.origin) == 0 {
+			return "-", "-"
+		}
+		return d.origin, "-"
+	case "guid":
+		return strconv.FormatUint(d.guid, 10), "-"
+	case "createtxg":
+		return strconv.FormatUint(d.createtxg, 10), "-"
+	case "userrefs":
+		return strconv.Itoa(len(d.holds)), "-"
+	case "clones":
+		names := make([]string, 0)
+		for _, clone := range s.clones(d.name) {
+			names = append(names, clone.name)
+		}
+		return strings.Join(names, ","), "-"
+	case "defer_destroy":
+		if d.deferred {
+			return "on", "-"
+		}
+		return "off", "-"
+	case "receive_resume_token":
+		return "-", "-"
+	case "mountpoint":
+		for current := d.name; len(current) > 0; current = datasetParent(current) {
+			ancestor := s.datasets[current]
+			value, ok := ancestor.props["mountpoint"]
+			if !ok {
+				continue
+			}
+			if current == d.name {
+				return value, "local"
+			}
+			if value != "none" && value != "legacy" {
+				value = strings.TrimSuffix(value, "/") + strings.TrimPrefix(d.name, current)
+			}
+			return value, "inherited from " + current
+		}
+		return "/" + d.name, "default"
+	}
+
+	p, ok := lookupSimProperty(name)
+	if !ok {
+		return "-", "-"
+	}
+	format := func(value string) string {
+		if !p.size {
+			return value
+		}
+		n, _ := strconv.ParseUint(value, 10, 64)
+		if !parsable && n == 0 && p.def == "0" && name != "special_small_blocks" {
+			return "none"
+		}
+		return size(n)
+	}
+	if value, ok := d.props[name]; ok {
+		return format(value), "local"
+	}
+	if p.inherit {
+		for current := datasetParent(d.name); len(current) > 0; current = datasetParent(current) {
+			if value, ok := s.datasets[current].props[name]; ok {
+				return format(value), "inherited from " + current
+			}
+		}
+	}
+	if len(p.def) == 0 {
+		return "-", "-"
+	}
+	return format(p.def), "default"
+}
+
+// normalizeProperty validates the value of native property, sizes are converted to bytes
+func normalizeProperty(name, value string) (string, error) {
+	if isUserProperty(name) {
+		return value, nil
+	}
+	p, ok := lookupSimProperty(name)
+	if !ok {
+		return "", simUsage("invalid property '%s'", name)
+	}
+	if p.size {
+		if value == "none" {
+			return "0", nil
+		}
+		n, err := parseSize(value)
+		if err != nil {
+			return "", simErrorf("bad numeric value '%s'", value)
+		}
+		return strconv.FormatUint(n, 10), nil
+	}
+	return value, nil
+}
+
+func (s *Simulator) zfs(args []string) (string, error) {
+	if len(args) == 0 {
+		return "", simUsage("missing command")
+	}
+	switch args[0] {
+	case "create":
+		return s.zfsCreate(args[1:])
+	case "destroy":
+		return s.zfsDestroy(args[1:])
+	case "snapshot", "snap":
+		return s.zfsSnapshot(args[1:])
+	case "clone":
+		return s.zfsClone(args[1:])
+	case "rename":
+		return s.zfsRename(args[1:])
+	case "rollback":
+		return s.zfsRollback(args[1:])
+	case "promote":
+		return s.zfsPromote(args[1:])
+	case "list":
+		return s.zfsList(args[1:])
+	case "get":
+		return s.zfsGet(args[1:])
+	case "set":
+		return s.zfsSet(args[1:])
+	case "inherit":
+		return s.zfsInherit(args[1:])
+	case "hold":
+		return s.zfsHold(args[1:])
+	case "release":
+		return s.zfsRelease(args[1:])
+	case "holds":
+		return s.zfsHolds(args[1:])
+	case "bookmark":
+		return s.zfsBookmark(args[1:])
+	case "mount", "unmount", "umount", "share", "unshare":
+		return "", nil
+	}
+	return "", simUsage("unrecognized command '%s'", args[0])
+}
+
+func (s *Simulator) zfsCreate(args []string) (string, error) {
+	f, err := parseSimFlags(args, "obV")
+	if err != nil {
+		return "", err
+	}
+	if len(f.args) != 1 {
+		return "", simUsage("missing filesystem argument")
+	}
+	name := f.args[0]
+	if strings.ContainsAny(name, "@#") {
+		return "", simErrorf("cannot create '%s': invalid character '@' in name", name)
+	}
+	props, err := simPropertyArgs(f.values['o'])
+	if err != nil {
+		return "", err
+	}
+
+	typ := "filesystem"
+	if f.has('V') {
+		typ = "volume"
+		props["volsize"] = f.value('V')
+		if f.has('b') {
+			props["volblocksize"] = f.value('b')
+		}
+		if !f.has('s') {
+			props["refreservation"] = props["volsize"]
+		}
+	}
+	for k, v := range props {
+		if props[k], err = normalizeProperty(k, v); err != nil {
+			return "", err
+		}
+	}
+
+	if _, ok := s.pools[datasetPool(name)]; !ok {
+		return "", simErrorf("cannot create '%s': no such pool '%s'", name, datasetPool(name))
+	}
+	if _, ok := s.datasets[name]; ok {
+		return "", simErrorf("cannot create '%s': dataset already exists", name)
+	}
+	parent := datasetParent(name)
+	if len(parent) == 0 {
+		return "", simErrorf("cannot create '%s': missing dataset name", name)
+	}
+	if d, ok := s.datasets[parent]; ok && d.typ != "filesystem" {
+		return "", simErrorf("cannot create '%s': parent is not a filesystem", name)
+	}
+	if _, ok := s.datasets[parent]; !ok {
+		if !f.has('p') {
+			return "", simErrorf("cannot create '%s': parent does not exist", name)
+		}
+		missing := make([]string, 0)
+		for current := parent; len(current) > 0; current = datasetParent(current) {
+			if _, ok := s.datasets[current]; ok {
+				break
+			}
+			missing = append([]string{current}, missing...)
+		}
+		for _, item := range missing {
+			s.newDataset(item, "filesystem", nil)
+		}
+	}
+
+	s.newDataset(name, typ, props)
+	return "", nil
+}
+
+func (s *Simulator) zfsDestroy(args []string) (string, error) {
+	f, err := parseSimFlags(args, "")
+	if err != nil {
+		return "", err
+	}
+	if len(f.args) != 1 {
+		return "", simUsage("missing dataset argument")
+	}
+	name := f.args[0]
+	recursive, dependents := f.has('r') || f.has('R'), f.has('R')
+	if strings.Contains(name, "#") {
+		if _, ok := s.bookmarks[name]; !ok {
+			return "", simErrorf("cannot destroy '%s': bookmark does not exist", name)
+		}
+		delete(s.bookmarks, name)
+		return "", nil
+	}
+
+	targets := make([]*simDataset, 0)
+	if i := strings.Index(name, "@"); i >= 0 {
+		dataset := name[:i]
+		if _, ok := s.datasets[dataset]; !ok {
+			return "", simErrorf("cannot open '%s': dataset does not exist", dataset)
+		}
+		for _, snap := range strings.Split(name[i+1:], ",") {
+			if recursive {
+				for _, d := range s.descendants(dataset) {
+					if d.typ != "snapshot" && s.datasets[d.name+"@"+snap] != nil {
+						targets = append(targets, s.datasets[d.name+"@"+snap])
+					}
+				}
+			} else if d, ok := s.datasets[dataset+"@"+snap]; ok {
+				targets = append(targets, d)
+			}
+		}
+		if len(targets) == 0 {
+			return "", simErrorf("could not find any snapshots to destroy; check snapshot names.")
+		}
+	} else {
+		d, ok := s.datasets[name]
+		if !ok {
+			return "", simErrorf("cannot open '%s': dataset does not exist", name)
+		}
+		if len(datasetParent(name)) == 0 {
+			return "", simErrorf("cannot destroy '%s': operation does not apply to pools\n"+
+				"use 'zfs destroy -r %s' to destroy all datasets in the pool\n"+
+				"use 'zpool destroy %s' to destroy the pool itself", name, name, name)
+		}
+		if !recursive {
+			if children := s.children(name, true); len(children) > 0 {
+				return "", simErrorf("cannot destroy '%s': %s has children\n"+
+					"use '-r' to destroy the following datasets:\n%s", name, d.typ, simNames(children))
+			}
+			targets = append(targets, d)
+		} else {
+			targets = s.descendants(name)
+		}
+	}
+
+	// the clones of destroyed snapshots outside of targets
+	destroying := map[string]bool{}
+	for _, d := range targets {
+		destroying[d.name] = true
+	}
+	clones := make([]*simDataset, 0)
+	for _, d := range targets {
+		if d.typ != "snapshot" {
+			continue
+		}
+		for _, clone := range s.clones(d.name) {
+			if !destroying[clone.name] {
+				clones = append(clones, clone)
+			}
+		}
+	}
+	deferred := f.has('d')
+	if len(clones) > 0 && !deferred {
+		if !dependents {
+			return "", simErrorf("cannot destroy '%s': snapshot has dependent clones\n"+
+				"use '-R' to destroy the following datasets:\n%s", name, simNames(clones))
+		}
+		for _, clone := range clones {
+			for _, d := range s.descendants(clone.name) {
+				if !destroying[d.name] {
+					destroying[d.name] = true
+					targets = append(targets, d)
+				}
+			}
+		}
+	}
+
+	for _, d := range targets {
+		if len(d.holds) > 0 && !deferred {
+			return "", simErrorf("cannot destroy snapshot %s: dataset is busy", d.name)
+		}
+	}
+
+	out := &strings.Builder{}
+	for _, d := range targets {
+		if f.has('v') {
+			if f.has('n') {
+				out.WriteString("would destroy " + d.name + "\n")
+			} else {
+				out.WriteString("will destroy " + d.name + "\n")
+			}
+		}
+		switch {
+		case f.has('n'):
+		case deferred && d.typ == "snapshot" && (len(d.holds) > 0 || len(s.clones(d.name)) > 0):
+			d.deferred = true
+		default:
+			delete(s.datasets, d.name)
+		}
+	}
+	s.reap()
+	s.txg++
+	return out.String(), nil
+}
+
+// reap destroys the deferred snapshots which have neither holds nor clones, and the bookmarks
+// of destroyed datasets
+func (s *Simulator) reap() {
+	for name, d := range s.datasets {
+		if d.deferred && len(d.holds) == 0 && len(s.clones(name)) == 0 {
+			delete(s.datasets, name)
+		}
+	}
+	for name := range s.bookmarks {
+		if _, ok := s.datasets[strings.SplitN(name, "#", 2)[0]]; !ok {
+			delete(s.bookmarks, name)
+		}
+	}
+}
+
+func simNames(items []*simDataset) string {
+	names := make([]string, 0, len(items))
+	for _, d := range items {
+		names = append(names, d.name)
+	}
+	return strings.Join(names, "\n")
+}
+
+func (s *Simulator) zfsSnapshot(args []string) (string, error) {
+	f, err := parseSimFlags(args, "o")
+	if err != nil {
+		return "", err
+	}
+	if len(f.args) == 0 {
+		return "", simUsage("missing snapshot argument")
+	}
+	props, err := simPropertyArgs(f.values['o'])
+	if err != nil {
+		return "", err
+	}
+
+	names := make([]string, 0)
+	for _, name := range f.args {
+		i := strings.Index(name, "@")
+		if i < 0 {
+			return "", simErrorf("cannot create snapshot '%s': missing '@' delimiter in snapshot name", name)
+		}
+		dataset := name[:i]
+		d, ok := s.datasets[dataset]
+		if !ok || d.typ == "snapshot" {
+			return "", simErrorf("cannot open '%s': dataset does not exist", dataset)
+		}
+		items := []*simDataset{d}
+		if f.has('r') {
+			items = s.descendants(dataset)
+		}
+		for _, item := range items {
+			if item.typ == "snapshot" {
+				continue
+			}
+			snapshot := item.name + name[i:]
+			if _, ok := s.datasets[snapshot]; ok {
+				return "", simErrorf("cannot create snapshot '%s': dataset already exists", snapshot)
+			}
+			names = append(names, snapshot)
+		}
+	}
+
+	// the snapshots are created atomically in the same txg
+	s.txg++
+	for _, name := range names {
+		d := s.newDataset(name, "snapshot", props)
+		d.referenced = s.datasets[datasetParent(name)].referenced
+		d.createtxg = s.txg
+	}
+	return "", nil
+}
+
+func (s *Simulator) zfsClone(args []string) (string, error) {
+	f, err := parseSimFlags(args, "o")
+	if err != nil {
+		return "", err
+	}
+	if len(f.args) != 2 {
+		return "", simUsage("missing source or target dataset")
+	}
+	source, target := f.args[0], f.args[1]
+	props, err := simPropertyArgs(f.values['o'])
+	if err != nil {
+		return "", err
+	}
+
+	snapshot, ok := s.datasets[source]
+	if !ok || snapshot.typ != "snapshot" {
+		return "", simErrorf("cannot open '%s': dataset does not exist", source)
+	}
+	if _, ok := s.datasets[target]; ok {
+		return "", simErrorf("cannot create '%s': dataset already exists", target)
+	}
+	if datasetPool(target) != datasetPool(source) {
+		return "", simErrorf("cannot create '%s': source and target pools differ", target)
+	}
+	if parent := datasetParent(target); s.datasets[parent] == nil {
+		if !f.has('p') {
+			return "", simErrorf("cannot create '%s': parent does not exist", target)
+		}
+		if _, err = s.zfsCreate([]string{"-p", parent}); err != nil {
+			return "", err
+		}
+	}
+
+	typ := s.datasets[datasetParent(source)].typ
+	d := s.newDataset(target, typ, props)
+	d.origin = source
+	d.referenced = snapshot.referenced
+	if typ == "volume" {
+		d.props["volsize"] = s.datasets[datasetParent(source)].props["volsize"]
+	}
+	return "", nil
+}
+
+func (s *Simulator) zfsRename(args []string) (string, error) {
+	f, err := parseSimFlags(args, "")
+	if err != nil {
+		return "", err
+	}
+	if len(f.args) != 2 {
+		return "", simUsage("missing source or target dataset")
+	}
+	source, target := f.args[0], f.args[1]
+	d, ok := s.datasets[source]
+	if !ok {
+		return "", simErrorf("cannot open '%s': dataset does not exist", source)
+	}
+
+	if d.typ == "snapshot" {
+		if strings.HasPrefix(target, "@") {
+			target = datasetParent(source) + target
+		}
+		if datasetParent(target) != datasetParent(source) {
+			return "", simErrorf("cannot rename to '%s': snapshots must be part of same dataset", target)
+		}
+		items := []*simDataset{d}
+		if f.has('r') {
+			items = make([]*simDataset, 0)
+			snap := source[strings.Index(source, "@"):]
+			for _, item := range s.descendants(datasetParent(source)) {
+				if strings.HasSuffix(item.name, snap) {
+					items = append(items, item)
+				}
+			}
+		}
+		newSnap := target[strings.Index(target, "@"):]
+		for _, item := range items {
+			if _, ok := s.datasets[datasetParent(item.name)+newSnap]; ok {
+				return "", simErrorf("cannot rename to '%s': dataset already exists", datasetParent(item.name)+newSnap)
+			}
+		}
+		for _, item := range items {
+			s.renameDataset(item.name, datasetParent(item.name)+newSnap)
+		}
+		return "", nil
+	}
+
+	if _, ok := s.datasets[target]; ok {
+		return "", simErrorf("cannot rename to '%s': dataset already exists", target)
+	}
+	if datasetPool(target) != datasetPool(source) {
+		return "", simErrorf("cannot rename to '%s': datasets must be within same pool", target)
+	}
+	if strings.HasPrefix(target, source+"/") {
+		return "", simErrorf("cannot rename to '%s': new dataset name cannot be a descendant of current dataset name", target)
+	}
+	if parent := datasetParent(target); s.datasets[parent] == nil {
+		if !f.has('p') {
+			return "", simErrorf("cannot rename to '%s': parent does not exist", target)
+		}
+		if _, err = s.zfsCreate([]string{"-p", parent}); err != nil {
+			return "", err
+		}
+	}
+	for _, item := range s.descendants(source) {
+		s.renameDataset(item.name, target+strings.TrimPrefix(item.name, source))
+	}
+	return "", nil
+}
+
+func (s *Simulator) renameDataset(name, newName string) {
+	d := s.datasets[name]
+	delete(s.datasets, name)
+	d.name = newName
+	s.datasets[newName] = d
+	for _, item := range s.datasets {
+		if item.origin == name {
+			item.origin = newName
+		}
+	}
+	for bookmark, item := range s.bookmarks {
+		if strings.HasPrefix(bookmark, name+"#") {
+			delete(s.bookmarks, bookmark)
+			item.name = newName + strings.TrimPrefix(bookmark, name)
+			s.bookmarks[item.name] = item
+		}
+	}
+}
+
+// snapshotsOf returns the snapshots of dataset in the order of creation
+func (s *Simulator) snapshotsOf(name string) []*simDataset {
+	items := make([]*simDataset, 0)
+	for _, d := range s.datasets {
+		if d.typ == "snapshot" && datasetParent(d.name) == name {
+			items = append(items, d)
+		}
+	}
+	sort.Slice(items, func(i, j int) bool { return items[i].createtxg < items[j].createtxg })
+	return items
+}
+
+func (s *Simulator) zfsRollback(args []string) (string, error) {
+	f, err := parseSimFlags(args, "")
+	if err != nil {
+		return "", err
+	}
+	if len(f.args) != 1 {
+		return "", simUsage("missing dataset argument")
+	}
+	name := f.args[0]
+	snapshot, ok := s.datasets[name]
+	if !ok || snapshot.typ != "snapshot" {
+		return "", simErrorf("cannot open '%s': dataset does not exist", name)
+	}
+	d := s.datasets[datasetParent(name)]
+
+	newer := make([]*simDataset, 0)
+	for _, item := range s.snapshotsOf(d.name) {
+		if item.createtxg > snapshot.createtxg {
+			newer = append(newer, item)
+		}
+	}
+	bookmarks := make([]string, 0)
+	for bookmark, item := range s.bookmarks {
+		if strings.HasPrefix(bookmark, d.name+"#") && item.createtxg > snapshot.createtxg {
+			bookmarks = append(bookmarks, bookmark)
+		}
+	}
+	sort.Strings(bookmarks)
+	if (len(newer) > 0 || len(bookmarks) > 0) && !f.has('r') && !f.has('R') {
+		names := make([]string, 0, len(newer)+len(bookmarks))
+		for _, item := range newer {
+			names = append(names, item.name)
+		}
+		return "", simErrorf("cannot rollback to '%s': more recent snapshots or bookmarks exist\n"+
+			"use '-r' to force deletion of the following snapshots and bookmarks:\n%s",
+			name, strings.Join(append(names, bookmarks...), "\n"))
+	}
+
+	clones := make([]*simDataset, 0)
+	for _, item := range newer {
+		for _, clone := range s.clones(item.name) {
+			clones = append(clones, s.descendants(clone.name)...)
+		}
+	}
+	if len(clones) > 0 && !f.has('R') {
+		return "", simErrorf("cannot rollback to '%s': clones of previous snapshots exist\n"+
+			"use '-R' to force deletion of the following clones and dependents:\n%s", name, simNames(clones))
+	}
+	for _, item := range append(newer, clones...) {
+		if len(item.holds) > 0 {
+			return "", simErrorf("cannot destroy '%s': dataset is busy", item.name)
+		}
+	}
+
+	for _, item := range append(clones, newer...) {
+		delete(s.datasets, item.name)
+	}
+	for _, bookmark := range bookmarks {
+		delete(s.bookmarks, bookmark)
+	}
+	d.referenced = snapshot.referenced
+	s.txg++
+	return "", nil
+}
+
+func (s *Simulator) zfsPromote(args []string) (string, error) {
+	if len(args) != 1 {
+		return "", simUsage("missing clone filesystem argument")
+	}
+	name := args[0]
+	clone, ok := s.datasets[name]
+	if !ok || clone.typ == "snapshot" {
+		return "", simErrorf("cannot open '%s': dataset does not exist", name)
+	}
+	if len(clone.origin) == 0 {
+		return "", simErrorf("cannot promote '%s': not a cloned filesystem", name)
+	}
+	origin := s.datasets[clone.origin]
+	parent := s.datasets[datasetParent(clone.origin)]
+
+	moved := make([]*simDataset, 0)
+	for _, item := range s.snapshotsOf(parent.name) {
+		if item.createtxg <= origin.createtxg {
+			moved = append(moved, item)
+		}
+	}
+	for _, item := range moved {
+		snap := item.name[strings.Index(item.name, "@"):]
+		if _, ok := s.datasets[name+snap]; ok {
+			return "", simErrorf("cannot promote '%s': snapshot name '%s' from origin\nconflicts with '%s' from target",
+				name, item.name, name+snap)
+		}
+	}
+
+	parentOrigin := parent.origin
+	for _, item := range moved {
+		s.renameDataset(item.name, name+item.name[strings.Index(item.name, "@"):])
+	}
+	parent.origin = clone.origin
+	clone.origin = parentOrigin
+	s.txg++
+	return "", nil
+}
+
+// simTypes parses the types of -t option
+func simTypes(values []string, defaults string) (map[string]bool, error) {
+	types := map[string]bool{}
+	if len(values) == 0 {
+		values = strings.Split(defaults, ",")
+	}
+	for _, t := range values {
+		switch t {
+		case "filesystem", "fs":
+			types["filesystem"] = true
+		case "volume", "vol":
+			types["volume"] = true
+		case "snapshot", "snap":
+			types["snapshot"] = true
+		case "bookmark":
+			types["bookmark"] = true
+		case "all":
+			types["filesystem"], types["volume"], types["snapshot"], types["bookmark"] = true, true, true, true
+		default:
+			return nil, simUsage("invalid type '%s'", t)
+		}
+	}
+	return types, nil
+}
+
+// selectDatasets selects datasets by names, types and depth (-1 is unlimited) like 'zfs list' and 'zfs get'
+func (s *Simulator) selectDatasets(names []string, types map[string]bool, recursive bool, depth int) ([]*simDataset, error) {
+	items := make([]*simDataset, 0)
+	seen := map[string]bool{}
+	add := func(d *simDataset) {
+		if !seen[d.name] && types[d.typ] {
+			seen[d.name] = true
+			items = append(items, d)
+		}
+	}
+
+	if len(names) == 0 {
+		for _, d := range s.datasets {
+			add(d)
+		}
+		return append(s.sorted(items), s.selectBookmarks("", types, depth)...), nil
+	}
+
+	for _, name := range names {
+		if strings.Contains(name, "#") {
+			d, ok := s.bookmarks[name]
+			if !ok {
+				return nil, simErrorf("cannot open '%s': bookmark does not exist", name)
+			}
+			add(d)
+			continue
+		}
+		d, ok := s.datasets[name]
+		if !ok {
+			return nil, simErrorf("cannot open '%s': dataset does not exist", name)
+		}
+		if !recursive {
+			// the explicit snapshot is listed without -t snapshot
+			if d.typ == "snapshot" {
+				seen[d.name] = true
+				items = append(items, d)
+			} else {
+				add(d)
+			}
+			continue
+		}
+		for _, item := range s.descendants(name) {
+			if depth < 0 || datasetDepth(item.name)-datasetDepth(name) <= depth {
+				add(item)
+			}
+		}
+		for _, item := range s.selectBookmarks(name, types, depth) {
+			add(item)
+		}
+	}
+	return items, nil
+}
+
+// selectBookmarks returns the bookmarks of dataset and its descendants in the order of names,
+// all bookmarks if name is empty
+func (s *Simulator) selectBookmarks(name string, types map[string]bool, depth int) []*simDataset {
+	items := make([]*simDataset, 0)
+	if !types["bookmark"] {
+		return items
+	}
+	for bookmark, d := range s.bookmarks {
+		base := strings.SplitN(bookmark, "#", 2)[0]
+		if len(name) > 0 && base != name && !strings.HasPrefix(base, name+"/") {
+			continue
+		}
+		// the bookmark is at the depth of snapshots
+		if len(name) > 0 && depth >= 0 && datasetDepth(base)+1-datasetDepth(name) > depth {
+			continue
+		}
+		items = append(items, d)
+	}
+	sort.Slice(items, func(i, j int) bool { return items[i].name < items[j].name })
+	return items
+}
+
+func (s *Simulator) zfsBookmark(args []string) (string, error) {
+	f, err := parseSimFlags(args, "")
+	if err != nil {
+		return "", err
+	}
+	if len(f.args) != 2 {
+		return "", simUsage("missing snapshot or bookmark argument")
+	}
+	source, name := f.args[0], f.args[1]
+	var d *simDataset
+	if strings.Contains(source, "#") {
+		d = s.bookmarks[source]
+	} else if item, ok := s.datasets[source]; ok && item.typ == "snapshot" {
+		d = item
+	}
+	if d == nil {
+		return "", simErrorf("cannot create bookmark '%s': dataset does not exist", name)
+	}
+	i := strings.Index(name, "#")
+	if i <= 0 || name[:i] != strings.FieldsFunc(source, func(r rune) bool { return r == '@' || r == '#' })[0] {
+		return "", simErrorf("cannot create bookmark '%s': must be in same dataset as source", name)
+	}
+	if _, ok := s.bookmarks[name]; ok {
+		return "", simErrorf("cannot create bookmark '%s': bookmark exists", name)
+	}
+	s.bookmarks[name] = &simDataset{
+		name:      name,
+		typ:       "bookmark",
+		guid:      d.guid,
+		createtxg: d.createtxg,
+		creation:  d.creation,
+		props:     map[string]string{},
+		holds:     map[string]time.Time{},
+	}
+	return "", nil
+}
+
+func (s *Simulator) zfsList(args []string) (string, error) {
+	f, err := parseSimFlags(args, "odsSt")
+	if err != nil {
+		return "", err
+	}
+	columns := f.list('o')
+	if len(columns) == 0 {
+		columns = []string{"name", "used", "avail", "refer", "mountpoint"}
+	}
+	for _, column := range columns {
+		if _, ok := lookupSimProperty(column); !ok && column != "name" && !isUserProperty(column) {
+			return "", simUsage("bad property list: invalid property '%s'", column)
+		}
+	}
+	types, err := simTypes(f.list('t'), "filesystem,volume")
+	if err != nil {
+		return "", err
+	}
+	depth := -1
+	if f.has('d') {
+		if depth, err = strconv.Atoi(f.value('d')); err != nil {
+			return "", simUsage("invalid depth '%s'", f.value('d'))
+		}
+	}
+	items, err := s.selectDatasets(f.args, types, f.has('r') || f.has('d'), depth)
+	if err != nil {
+		return "", err
+	}
+
+	parsable := f.has('p')
+	row := func(d *simDataset) []string {
+		row := make([]string, 0, len(columns))
+		for _, column := range columns {
+			// the property which doesn't apply to the type is shown as "-"
+			value := "-"
+			if p, ok := lookupSimProperty(column); !ok || p.applies(d.typ) {
+				value, _ = s.property(d, column, parsable)
+			}
+			row = append(row, value)
+		}
+		return row
+	}
+	rows := make([][]string, 0, len(items))
+	for _, d := range items {
+		rows = append(rows, row(d))
+	}
+
+	for _, key := range [][2]string{{"s", f.value('s')}, {"S", f.value('S')}} {
+		if len(key[1]) == 0 {
+			continue
+		}
+		reverse := key[0] == "S"
+		sort.SliceStable(items, func(i, j int) bool {
+			a, _ := s.property(items[i], key[1], true)
+			b, _ := s.property(items[j], key[1], true)
+			an, aerr := strconv.ParseFloat(a, 64)
+			bn, berr := strconv.ParseFloat(b, 64)
+			less := a < b
+			if aerr == nil && berr == nil {
+				less = an < bn
+			}
+			if reverse {
+				return !less && a != b
+			}
+			return less
+		})
+		rows = rows[:0]
+		for _, d := range items {
+			rows = append(rows, row(d))
+		}
+	}
+
+	if len(rows) == 0 && len(f.args) == 0 && !f.has('H') {
+		return "no datasets available\n", nil
+	}
+	return simTable(f.has('H'), columns, rows), nil
+}
+
+func (s *Simulator) zfsGet(args []string) (string, error) {
+	f, err := parseSimFlags(args, "odts")
+	if err != nil {
+		return "", err
+	}
+	if len(f.args) == 0 {
+		return "", simUsage("missing property argument")
+	}
+	properties := strings.Split(f.args[0], ",")
+	for _, name := range properties {
+		if _, ok := lookupSimProperty(name); !ok && name != "all" && name != "name" && !isUserProperty(name) {
+			return "", simUsage("bad property list: invalid property '%s'", name)
+		}
+	}
+	fields := f.list('o')
+	if len(fields) == 0 || (len(fields) == 1 && fields[0] == "all") {
+		fields = []string{"name", "property", "value", "source"}
+	}
+	types, err := simTypes(f.list('t'), "all")
+	if err != nil {
+		return "", err
+	}
+	sources := map[string]bool{}
+	for _, source := range f.list('s') {
+		sources[source] = true
+	}
+	depth := -1
+	if f.has('d') {
+		if depth, err = strconv.Atoi(f.value('d')); err != nil {
+			return "", simUsage("invalid depth '%s'", f.value('d'))
+		}
+	}
+	items, err := s.selectDatasets(f.args[1:], types, f.has('r') || f.has('d'), depth)
+	if err != nil {
+		return "", err
+	}
+
+	rows := make([][]string, 0)
+	for _, d := range items {
+		names := properties
+		if len(properties) == 1 && properties[0] == "all" {
+			names = s.allProperties(d)
+		}
+		for _, name := range names {
+			value, source := s.property(d, name, f.has('p'))
+			if len(sources) > 0 && !sources[simSourceClass(source)] {
+				continue
+			}
+			row := make([]string, 0, len(fields))
+			for _, field := range fields {
+				switch field {
+				case "name":
+					row = append(row, d.name)
+				case "property":
+					row = append(row, name)
+				case "value":
+					row = append(row, value)
+				case "received":
+					row = append(row, "-")
+				case "source":
+					row = append(row, source)
+				default:
+					return "", simUsage("invalid field '%s'", field)
+				}
+			}
+			rows = append(rows, row)
+		}
+	}
+	return simTable(f.has('H'), fields, rows), nil
+}
+
+// allProperties returns the names of properties printed by 'zfs get all'
+func (s *Simulator) allProperties(d *simDataset) []string {
+	names := make([]string, 0, len(simProperties))
+	for _, p := range simProperties {
+		if p.applies(d.typ) {
+			names = append(names, p.name)
+		}
+	}
+	users := map[string]bool{}
+	for current := d.name; len(current) > 0; current = datasetParent(current) {
+		for name := range s.datasets[current].props {
+			if isUserProperty(name) {
+				users[name] = true
+			}
+		}
+	}
+	extra := make([]string, 0, len(users))
+	for name := range users {
+		extra = append(extra, name)
+	}
+	sort.Strings(extra)
+	return append(names, extra...)
+}
+
+func simSourceClass(source string) string {
+	switch {
+	case source == "-":
+		return "none"
+	case strings.HasPrefix(source, "inherited"):
+		return "inherited"
+	}
+	return source
+}
+
+func (s *Simulator) zfsSet(args []string) (string, error) {
+	if len(args) < 2 {
+		return "", simUsage("missing arguments")
+	}
+	name := args[len(args)-1]
+	d, ok := s.datasets[name]
+	if !ok {
+		return "", simErrorf("cannot open '%s': dataset does not exist", name)
+	}
+	props, err := simPropertyArgs(args[:len(args)-1])
+	if err != nil {
+		return "", err
+	}
+	for k, v := range props {
+		if !isUserProperty(k) {
+			p, ok := lookupSimProperty(k)
+			if !ok {
+				return "", simErrorf("cannot set property for '%s': invalid property '%s'", name, k)
+			}
+			if p.readonly {
+				return "", simErrorf("cannot set property for '%s': '%s' is readonly", name, k)
+			}
+			if !p.applies(d.typ) {
+				return "", simErrorf("cannot set property for '%s': '%s' does not apply to datasets of this type", name, k)
+			}
+			k = p.name
+		}
+		value, err := normalizeProperty(k, v)
+		if err != nil {
+			return "", err
+		}
+		if k == "quota" && value != "0" {
+			quota, _ := strconv.ParseUint(value, 10, 64)
+			if quota < s.used(d) {
+				return "", simErrorf("cannot set property for '%s': size is less than current used or reserved space", name)
+			}
+		}
+		d.props[k] = value
+	}
+	s.txg++
+	return "", nil
+}
+
+func (s *Simulator) zfsInherit(args []string) (string, error) {
+	f, err := parseSimFlags(args, "")
+	if err != nil {
+		return "", err
+	}
+	if len(f.args) < 2 {
+		return "", simUsage("missing arguments")
+	}
+	prop := f.args[0]
+	if alias, ok := simPropertyAliases[prop]; ok {
+		prop = alias
+	}
+	for _, name := range f.args[1:] {
+		if _, ok := s.datasets[name]; !ok {
+			return "", simErrorf("cannot open '%s': dataset does not exist", name)
+		}
+		items := []*simDataset{s.datasets[name]}
+		if f.has('r') {
+			items = s.descendants(name)
+		}
+		for _, d := range items {
+			delete(d.props, prop)
+		}
+	}
+	return "", nil
+}
+
+// snapshots returns the snapshots of holds commands, with their namesakes of descendants if recursive
+func (s *Simulator) holdSnapshots(name string, recursive bool) ([]*simDataset, error) {
+	d, ok := s.datasets[name]
+	if !ok || d.typ != "snapshot" {
+		return nil, simErrorf("cannot open '%s': dataset does not exist", name)
+	}
+	if !recursive {
+		return []*simDataset{d}, nil
+	}
+	items := make([]*simDataset, 0)
+	snap := name[strings.Index(name, "@"):]
+	for _, item := range s.descendants(datasetParent(name)) {
+		if strings.HasSuffix(item.name, snap) {
+			items = append(items, item)
+		}
+	}
+	return items, nil
+}
+
+func (s *Simulator) zfsHold(args []string) (string, error) {
+	f, err := parseSimFlags(args, "")
+	if err != nil {
+		return "", err
+	}
+	if len(f.args) < 2 {
+		return "", simUsage("missing tag or snapshot argument")
+	}
+	tag := f.args[0]
+	for _, name := range f.args[1:] {
+		items, err := s.holdSnapshots(name, f.has('r'))
+		if err != nil {
+			return "", err
+		}
+		for _, d := range items {
+			if _, ok := d.holds[tag]; ok {
+				return "", simErrorf("cannot hold snapshot '%s': tag already exists on this dataset", d.name)
+			}
+		}
+		for _, d := range items {
+			d.holds[tag] = s.Now()
+		}
+	}
+	return "", nil
+}
+
+func (s *Simulator) zfsRelease(args []string) (string, error) {
+	f, err := parseSimFlags(args, "")
+	if err != nil {
+		return "", err
+	}
+	if len(f.args) < 2 {
+		return "", simUsage("missing tag or snapshot argument")
+	}
+	tag := f.args[0]
+	for _, name := range f.args[1:] {
+		items, err := s.holdSnapshots(name, f.has('r'))
+		if err != nil {
+			return "", err
+		}
+		for _, d := range items {
+			if _, ok := d.holds[tag]; !ok {
+				return "", simErrorf("cannot release hold from snapshot '%s': no such tag on this dataset", d.name)
+			}
+		}
+		for _, d := range items {
+			delete(d.holds, tag)
+		}
+	}
+	s.reap()
+	return "", nil
+}
+
+func (s *Simulator) zfsHolds(args []string) (string, error) {
+	f, err := parseSimFlags(args, "")
+	if err != nil {
+		return "", err
+	}
+	rows := make([][]string, 0)
+	for _, name := range f.args {
+		items, err := s.holdSnapshots(name, f.has('r'))
+		if err != nil {
+			return "", err
+		}
+		for _, d := range items {
+			tags := make([]string, 0, len(d.holds))
+			for tag := range d.holds {
+				tags = append(tags, tag)
+			}
+			sort.Strings(tags)
+			for _, tag := range tags {
+				timestamp := d.holds[tag].Format("Mon Jan _2 15:04 2006")
+				if f.has('p') {
+					timestamp = strconv.FormatInt(d.holds[tag].Unix(), 10)
+				}
+				rows = append(rows, []string{d.name, tag, timestamp})
+			}
+		}
+	}
+	return simTable(f.has('H'), []string{"name", "tag", "timestamp"}, rows), nil
+}
+
+// lsblk emulates 'lsblk -bdno SIZE <device>'
+func (s *Simulator) lsblk(args []string) (string, error) {
+	f, err := parseSimFlags(args, "o")
+	if err != nil {
+		return "", err
+	}
+	if len(f.args) == 0 {
+		return "", simUsage("missing device")
+	}
+	size := s.deviceSize(f.args[0])
+	if size == 0 {
+		return "", &simError{code: 32, message: fmt.Sprintf("lsblk: %s: not a block device", f.args[0])}
+	}
+	return strconv.FormatUint(size, 10) + "\n", nil
+}
@@ -0,0 +1,241 @@
+// MIT License
+//
+// Copyright (c) 2021 Lack
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+// SOFTWARE.
+
+package zfs
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+)
+
+func newTestSimulator(t *testing.T) (*Simulator, *ZFSadm) {
+	sim := NewSimulator()
+	sim.Now = func() time.Time { return time.Date(2021, 6, 6, 10, 0, 0, 0, time.Local) }
+	z := New(WithRunner(sim))
+	if _, err := z.CreateRaidPool(context.TODO(), "tank", "lz4", "mirror", 50, []string{"sda", "sdb"}); err != nil {
+		t.Fatal(err)
+	}
+	return sim, z
+}
+
+func TestSimulator_Pool(t *testing.T) {
+	sim, z := newTestSimulator(t)
+	ctx := context.TODO()
+
+	pool, err := z.GetPool(ctx, "tank")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if pool.Health != "ONLINE" || pool.SizeBytes != 10<<30 || pool.Quota != "5368709120" || pool.Compression != "lz4" {
+		t.Fatalf("unexpected pool: %+v", pool)
+	}
+
+	if _, err = z.CreateRaidPool(ctx, "tank", "lz4", "", 100, []string{"sdc"}); !errors.Is(err, ErrPoolExists) {
+		t.Fatalf("expect ErrPoolExists, got %v", err)
+	}
+	if _, err = z.CreateRaidPool(ctx, "data", "lz4", "", 100, []string{"sda"}); !errors.Is(err, ErrDeviceInUse) {
+		t.Fatalf("expect ErrDeviceInUse, got %v", err)
+	}
+
+	if err = sim.SetDeviceState("tank", "sdb", "FAULTED"); err != nil {
+		t.Fatal(err)
+	}
+	status, err := z.GetPoolStatus(ctx, "tank")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if status.State != "DEGRADED" || status.Root.Children[0].Name != "mirror-0" {
+		t.Fatalf("unexpected status: %+v", status)
+	}
+	if devices := status.UnhealthyDevices(); len(devices) != 1 || devices[0].Name != "sdb" {
+		t.Fatalf("unexpected unhealthy devices: %+v", devices)
+	}
+
+	if _, err = z.DeletePool(ctx, "tank"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err = z.GetPool(ctx, "tank"); !errors.Is(err, ErrPoolNotFound) {
+		t.Fatalf("expect ErrPoolNotFound, got %v", err)
+	}
+}
+
+func TestSimulator_Datasets(t *testing.T) {
+	sim, z := newTestSimulator(t)
+	ctx := context.TODO()
+
+	fs, err := z.CreateFileSystem(ctx, "tank/a", map[string]string{"atime": "off"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if fs.Compression != "lz4" || fs.Type != "filesystem" {
+		t.Fatalf("unexpected filesystem: %+v", fs)
+	}
+	if _, err = z.CreateFileSystem(ctx, "tank/a", nil); !errors.Is(err, ErrDatasetExists) {
+		t.Fatalf("expect ErrDatasetExists, got %v", err)
+	}
+	if _, err = z.CreateVolume(ctx, "tank/v", nil, 1<<30); err != nil {
+		t.Fatal(err)
+	}
+	if err = sim.Write("tank/a", 1<<20); err != nil {
+		t.Fatal(err)
+	}
+
+	filesystems, err := z.GetFileSystems(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(filesystems) != 1 || filesystems["tank/a"].ReferencedBytes != 1<<20+24576 {
+		t.Fatalf("unexpected filesystems: %+v", filesystems)
+	}
+	volumes, err := z.GetVolumes(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if v := volumes["tank/v"]; v == nil || v.VolsizeBytes != 1<<30 {
+		t.Fatalf("unexpected volumes: %+v", volumes)
+	}
+
+	data, err := z.RawZFS().Get(ctx, "tank/a", "-Hp", "", []string{"value", "source"}, "", "", "atime", "compression", "mountpoint").Exec()
+	if err != nil {
+		t.Fatal(err)
+	}
+	expect := "off\tlocal\nlz4\tinherited from tank\n/tank/a\tdefault"
+	if string(data) != expect {
+		t.Fatalf("expect %q, got %q", expect, string(data))
+	}
+
+	if _, err = z.CreateSnapshot(ctx, "tank/a", "tank/a@s1", nil); err != nil {
+		t.Fatal(err)
+	}
+	if _, err = z.CloneFileSystem(ctx, "b", "tank/a@s1", nil); err != nil {
+		t.Fatal(err)
+	}
+	clone, err := z.GetFileSystem(ctx, "tank/b")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if clone.UsedBytes == 0 {
+		t.Fatalf("unexpected clone: %+v", clone)
+	}
+
+	_, err = z.RawZFS().DestroyFileSystemOrVolume(ctx, "tank/a", "").Exec()
+	if !errors.Is(err, ErrHasChildren) {
+		t.Fatalf("expect ErrHasChildren, got %v", err)
+	}
+	_, err = z.RawZFS().DestroyFileSystemOrVolume(ctx, "tank/a", "-r").Exec()
+	if !errors.Is(err, ErrHasClones) {
+		t.Fatalf("expect ErrHasClones, got %v", err)
+	}
+
+	_, err = z.RawZFS().DestroySnapshot(ctx, "tank/a@s1", "").Exec()
+	if !errors.Is(err, ErrHasClones) {
+		t.Fatalf("expect ErrHasClones, got %v", err)
+	}
+	if _, err = z.RawZFS().Hold(ctx, "tank/a@s1", false, "keep").Exec(); err != nil {
+		t.Fatal(err)
+	}
+	if _, err = z.RawZFS().Snapshot2(ctx, false, nil, "tank/a@s2").Exec(); err != nil {
+		t.Fatal(err)
+	}
+	if _, err = z.RawZFS().Hold(ctx, "tank/a@s2", false, "keep").Exec(); err != nil {
+		t.Fatal(err)
+	}
+	_, err = z.RawZFS().DestroySnapshot(ctx, "tank/a@s2", "").Exec()
+	if !errors.Is(err, ErrDatasetBusy) {
+		t.Fatalf("expect ErrDatasetBusy, got %v", err)
+	}
+	snapshot, err := z.GetSnapshot(ctx, "tank/a@s1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if snapshot.Userrefs != "1" {
+		t.Fatalf("unexpected snapshot: %+v", snapshot)
+	}
+
+	// the deferred destroy is done when the hold and clone are gone
+	holds, err := z.DeleteSnapshot(ctx, "tank/a@s1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(holds) != 1 || holds[0].Tag != "keep" {
+		t.Fatalf("unexpected holds: %+v", holds)
+	}
+	if _, err = z.RawZFS().Release(ctx, "tank/a@s1", false, "keep").Exec(); err != nil {
+		t.Fatal(err)
+	}
+	if _, err = z.GetSnapshot(ctx, "tank/a@s1"); err != nil {
+		t.Fatal(err)
+	}
+	if err = z.DeleteFileSystem(ctx, "tank/b"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err = z.GetSnapshot(ctx, "tank/a@s1"); !errors.Is(err, ErrDatasetNotFound) {
+		t.Fatalf("expect ErrDatasetNotFound, got %v", err)
+	}
+}
+
+func TestSimulator_List(t *testing.T) {
+	_, z := newTestSimulator(t)
+	ctx := context.TODO()
+
+	for _, name := range []string{"tank/a/b", "tank/c"} {
+		if _, err := z.RawZFS().CreateFileSystem(ctx, name, nil).Exec(); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if _, err := z.RawZFS().Snapshot2(ctx, true, nil, "tank@s1").Exec(); err != nil {
+		t.Fatal(err)
+	}
+
+	data, err := z.RawZFS().List(ctx, "tank", "-Hp -r", "", []string{"name"}, "", "", "all").Exec()
+	if err != nil {
+		t.Fatal(err)
+	}
+	expect := []string{"tank", "tank@s1", "tank/a", "tank/a@s1", "tank/a/b", "tank/a/b@s1", "tank/c", "tank/c@s1"}
+	if got := strings.Split(string(data), "\n"); strings.Join(got, ",") != strings.Join(expect, ",") {
+		t.Fatalf("expect %v, got %v", expect, got)
+	}
+
+	data, err = z.RawZFS().List(ctx, "tank/a", "-H", "-d 1", []string{"name", "used"}, "", "", "filesystem").Exec()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if expect := "tank/a\t48K\ntank/a/b\t24K"; string(data) != expect {
+		t.Fatalf("expect %q, got %q", expect, string(data))
+	}
+
+	policy := &SnapshotPolicy{Daily: 1, Recursive: true}
+	plan, err := z.ApplySnapshotPolicy(ctx, "tank/a", policy, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	snapshots, err := z.ListSnapshots(ctx, ListOptions{Root: "tank/a/b", Properties: []string{"userrefs"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(plan.Create) != 1 || len(snapshots) != 2 {
+		t.Fatalf("unexpected plan %+v, snapshots: %v", plan, snapshots)
+	}
+}
@@ -0,0 +1,1102 @@
+// MIT License
+//
+// Copyright (c) 2021 Lack
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+// SOFTWARE.
+
+package zfs
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// simPool the pool of Simulator
+type simPool struct {
+	name    string
+	guid    uint64
+	created time.Time
+	props   map[string]string
+	vdevs   []*simVdev
+	scan    *ScanStatus
+	// nextID the id of next top-level vdev, e.g. mirror-0
+	nextID int
+	// datasets the datasets of exported pool
+	datasets map[string]*simDataset
+}
+
+// simVdev the vdev of simPool
+type simVdev struct {
+	name string
+	typ  VdevType
+	// class the allocation class of top-level vdev: data, logs, cache, spares, special or dedup
+	class  string
+	parity int
+	state  string
+
+	read, write, checksum uint64
+
+	children []*simVdev
+}
+
+func (v *simVdev) walk(fn func(*simVdev)) {
+	fn(v)
+	for _, child := range v.children {
+		child.walk(fn)
+	}
+}
+
+// health returns the state of vdev computed from its children
+func (v *simVdev) health() string {
+	if len(v.children) == 0 {
+		return v.state
+	}
+	failed, degraded := 0, false
+	for _, child := range v.children {
+		switch child.health() {
+		case "ONLINE":
+		case "DEGRADED":
+			degraded = true
+		default:
+			failed++
+		}
+	}
+	tolerance := v.parity
+	if v.typ == VdevMirror || v.typ == VdevReplacing || v.typ == VdevSpare {
+		tolerance = len(v.children) - 1
+	}
+	switch {
+	case failed == 0 && !degraded:
+		return "ONLINE"
+	case failed <= tolerance:
+		return "DEGRADED"
+	}
+	return "UNAVAIL"
+}
+
+func (p *simPool) device(name string) *simVdev {
+	var found *simVdev
+	for _, top := range p.vdevs {
+		top.walk(func(v *simVdev) {
+			if len(v.children) == 0 && v.typ == VdevDisk && (v.name == name || strings.TrimPrefix(v.name, "/dev/") == name) {
+				found = v
+			}
+		})
+	}
+	return found
+}
+
+// capacity returns the usable size of data vdevs
+func (p *simPool) capacity(deviceSize uint64) uint64 {
+	var size uint64
+	for _, v := range p.vdevs {
+		if v.class != "data" && v.class != "special" && v.class != "dedup" {
+			continue
+		}
+		switch v.typ {
+		case VdevMirror:
+			size += deviceSize
+		case VdevRaidz, VdevDraid:
+			size += uint64(len(v.children)-v.parity) * deviceSize
+		default:
+			size += deviceSize
+		}
+	}
+	return size
+}
+
+// health returns the state of pool
+func (p *simPool) health() string {
+	state := "ONLINE"
+	for _, v := range p.vdevs {
+		if v.class == "cache" || v.class == "spares" {
+			continue
+		}
+		switch v.health() {
+		case "ONLINE":
+		case "DEGRADED":
+			state = "DEGRADED"
+		default:
+			if v.class == "logs" {
+				state = "DEGRADED"
+			} else {
+				return "UNAVAIL"
+			}
+		}
+	}
+	return state
+}
+
+var simVdevClasses = map[string]string{
+	"log":     "logs",
+	"logs":    "logs",
+	"cache":   "cache",
+	"spare":   "spares",
+	"spares":  "spares",
+	"special": "special",
+	"dedup":   "dedup",
+}
+
+// parseVdevs parses the vdev specification, e.g. "mirror sda sdb log sdc cache sdd"
+func (s *Simulator) parseVdevs(p *simPool, specs []string) ([]*simVdev, error) {
+	if len(specs) == 0 {
+		return nil, simUsage("missing vdev specification")
+	}
+	vdevs := make([]*simVdev, 0)
+	devices := map[string]bool{}
+	class := "data"
+	for i := 0; i < len(specs); {
+		token := specs[i]
+		if c, ok := simVdevClasses[token]; ok {
+			class = c
+			i++
+			continue
+		}
+
+		typ, parity := VdevDisk, 0
+		switch token {
+		case "mirror":
+			typ = VdevMirror
+		case "raidz", "raidz1", "raidz2", "raidz3":
+			typ, parity = VdevRaidz, 1
+			if len(token) == 6 {
+				parity = int(token[5] - '0')
+			}
+		}
+		if typ == VdevDisk {
+			vdevs = append(vdevs, &simVdev{name: token, typ: VdevDisk, class: class, state: "ONLINE"})
+			devices[token] = true
+			i++
+			continue
+		}
+
+		top := &simVdev{typ: typ, class: class, parity: parity, state: "ONLINE"}
+		for i++; i < len(specs); i++ {
+			if _, ok := simVdevClasses[specs[i]]; ok || specs[i] == "mirror" || strings.HasPrefix(specs[i], "raidz") {
+				break
+			}
+			top.children = append(top.children, &simVdev{name: specs[i], typ: VdevDisk, class: class, state: "ONLINE"})
+			devices[specs[i]] = true
+		}
+		least := parity + 1
+		if least < 2 {
+			least = 2
+		}
+		if len(top.children) < least {
+			return nil, simErrorf("invalid vdev specification: %s requires at least %d devices", token, least)
+		}
+		vdevs = append(vdevs, top)
+	}
+
+	for device := range devices {
+		for _, other := range s.pools {
+			if other.device(device) != nil {
+				return nil, simErrorf("invalid vdev specification\nuse '-f' to override the following errors:\n"+
+					"%s is part of active pool '%s'", device, other.name)
+			}
+		}
+	}
+	// the top-level vdevs are numbered in the order of creation, except caches and spares
+	for _, v := range vdevs {
+		if v.class == "cache" || v.class == "spares" {
+			continue
+		}
+		switch v.typ {
+		case VdevMirror:
+			v.name = fmt.Sprintf("mirror-%d", p.nextID)
+		case VdevRaidz:
+			v.name = fmt.Sprintf("raidz%d-%d", v.parity, p.nextID)
+		}
+		p.nextID++
+	}
+	return vdevs, nil
+}
+
+func (s *Simulator) zpool(args []string) (string, error) {
+	if len(args) == 0 {
+		return "", simUsage("missing command")
+	}
+	switch args[0] {
+	case "create":
+		return s.zpoolCreate(args[1:])
+	case "destroy":
+		return s.zpoolDestroy(args[1:])
+	case "add":
+		return s.zpoolAdd(args[1:])
+	case "list":
+		return s.zpoolList(args[1:])
+	case "get":
+		return s.zpoolGet(args[1:])
+	case "set":
+		return s.zpoolSet(args[1:])
+	case "status":
+		return s.zpoolStatus(args[1:])
+	case "scrub":
+		return s.zpoolScrub(args[1:])
+	case "online", "offline":
+		return s.zpoolOnline(args[0] == "online", args[1:])
+	case "clear":
+		return s.zpoolClear(args[1:])
+	case "replace":
+		return s.zpoolReplace(args[1:])
+	case "detach":
+		return s.zpoolDetach(args[1:])
+	case "labelclear":
+		return s.zpoolLabelClear(args[1:])
+	case "export":
+		return s.zpoolExport(args[1:])
+	case "import":
+		return s.zpoolImport(args[1:])
+	case "sync", "trim", "reopen":
+		return "", nil
+	}
+	return "", simUsage("unrecognized command '%s'", args[0])
+}
+
+func (s *Simulator) zpoolCreate(args []string) (string, error) {
+	f, err := parseSimFlags(args, "oOmRt")
+	if err != nil {
+		return "", err
+	}
+	if len(f.args) == 0 {
+		return "", simUsage("missing pool name argument")
+	}
+	name := f.args[0]
+	if _, ok := s.pools[name]; ok {
+		return "", simErrorf("cannot create '%s': pool already exists", name)
+	}
+	if _, ok := s.exported[name]; ok {
+		return "", simErrorf("cannot create '%s': a pool with that name already exists", name)
+	}
+	if strings.ContainsAny(name, "/@#") {
+		return "", simErrorf("cannot create '%s': invalid character in pool name", name)
+	}
+	props, err := simPropertyArgs(f.values['o'])
+	if err != nil {
+		return "", err
+	}
+	fsProps, err := simPropertyArgs(f.values['O'])
+	if err != nil {
+		return "", err
+	}
+	for k, v := range fsProps {
+		if fsProps[k], err = normalizeProperty(k, v); err != nil {
+			return "", err
+		}
+	}
+	if f.has('m') {
+		fsProps["mountpoint"] = f.value('m')
+	}
+	if f.has('R') {
+		props["altroot"] = f.value('R')
+	}
+	if f.has('d') {
+		for _, feature := range simPoolProperties {
+			if _, ok := props[feature]; !ok && strings.HasPrefix(feature, "feature@") {
+				props[feature] = "disabled"
+			}
+		}
+	}
+
+	s.guid++
+	p := &simPool{name: name, guid: s.guid, created: s.Now(), props: props}
+	if p.vdevs, err = s.parseVdevs(p, f.args[1:]); err != nil {
+		return "", err
+	}
+	if f.has('n') {
+		return fmt.Sprintf("would create '%s' with the following layout:\n", name), nil
+	}
+	s.pools[name] = p
+	s.newDataset(name, "filesystem", fsProps)
+	return "", nil
+}
+
+func (s *Simulator) zpoolDestroy(args []string) (string, error) {
+	f, err := parseSimFlags(args, "")
+	if err != nil {
+		return "", err
+	}
+	if len(f.args) != 1 {
+		return "", simUsage("missing pool argument")
+	}
+	name := f.args[0]
+	if _, ok := s.pools[name]; !ok {
+		return "", simErrorf("cannot open '%s': no such pool", name)
+	}
+	for _, d := range s.descendants(name) {
+		delete(s.datasets, d.name)
+	}
+	delete(s.pools, name)
+	return "", nil
+}
+
+func (s *Simulator) zpoolAdd(args []string) (string, error) {
+	f, err := parseSimFlags(args, "o")
+	if err != nil {
+		return "", err
+	}
+	if len(f.args) < 2 {
+		return "", simUsage("missing pool or vdev specification")
+	}
+	p, ok := s.pools[f.args[0]]
+	if !ok {
+		return "", simErrorf("cannot open '%s': no such pool", f.args[0])
+	}
+	vdevs, err := s.parseVdevs(p, f.args[1:])
+	if err != nil {
+		return "", err
+	}
+	if !f.has('n') {
+		p.vdevs = append(p.vdevs, vdevs...)
+	}
+	return "", nil
+}
+
+// simPoolProperties the properties in the order of 'zpool get all'
+var simPoolProperties = []string{
+	"size", "capacity", "altroot", "health", "guid", "version", "bootfs", "delegation",
+	"autoreplace", "cachefile", "failmode", "listsnapshots", "autoexpand", "dedupratio",
+	"free", "allocated", "readonly", "ashift", "comment", "expandsize", "freeing",
+	"fragmentation", "leaked", "multihost", "checkpoint", "load_guid", "autotrim",
+	"feature@async_destroy", "feature@empty_bpobj", "feature@lz4_compress",
+	"feature@spacemap_histogram", "feature@enabled_txg", "feature@hole_birth",
+	"feature@extensible_dataset", "feature@embedded_data", "feature@bookmarks",
+	"feature@filesystem_limits", "feature@large_blocks", "feature@large_dnode",
+	"feature@sha512", "feature@skein", "feature@userobj_accounting", "feature@encryption",
+}
+
+var simPoolPropertyAliases = map[string]string{
+	"alloc":    "allocated",
+	"cap":      "capacity",
+	"dedup":    "dedupratio",
+	"frag":     "fragmentation",
+	"expandsz": "expandsize",
+	"ckpoint":  "checkpoint",
+}
+
+var simPoolDefaults = map[string]string{
+	"altroot":       "-",
+	"version":       "-",
+	"bootfs":        "-",
+	"delegation":    "on",
+	"autoreplace":   "off",
+	"cachefile":     "-",
+	"failmode":      "wait",
+	"listsnapshots": "off",
+	"autoexpand":    "off",
+	"readonly":      "off",
+	"ashift":        "0",
+	"comment":       "-",
+	"multihost":     "off",
+	"autotrim":      "off",
+}
+
+// poolProperty returns the value and the source of pool property
+func (s *Simulator) poolProperty(p *simPool, name string, parsable bool) (string, string, bool) {
+	if alias, ok := simPoolPropertyAliases[name]; ok {
+		name = alias
+	}
+	size := func(n uint64) string {
+		if parsable {
+			return strconv.FormatUint(n, 10)
+		}
+		return simNicenum(n)
+	}
+
+	capacity := p.capacity(s.DeviceSize)
+	var allocated uint64
+	if root, ok := s.datasets[p.name]; ok {
+		allocated = s.used(root)
+	}
+	switch name {
+	case "name":
+		return p.name, "-", true
+	case "size":
+		return size(capacity), "-", true
+	case "allocated":
+		return size(allocated), "-", true
+	case "free":
+		return size(capacity - allocated), "-", true
+	case "capacity":
+		percent := uint64(0)
+		if capacity > 0 {
+			percent = allocated * 100 / capacity
+		}
+		if parsable {
+			return strconv.FormatUint(percent, 10), "-", true
+		}
+		return fmt.Sprintf("%d%%", percent), "-", true
+	case "health":
+		return p.health(), "-", true
+	case "guid", "load_guid":
+		return strconv.FormatUint(p.guid, 10), "-", true
+	case "dedupratio":
+		if parsable {
+			return "1.00", "-", true
+		}
+		return "1.00x", "-", true
+	case "fragmentation":
+		if parsable {
+			return "0", "-", true
+		}
+		return "0%", "-", true
+	case "expandsize", "checkpoint":
+		return "-", "-", true
+	case "freeing", "leaked":
+		return size(0), "-", true
+	}
+	if value, ok := p.props[name]; ok {
+		return value, "local", true
+	}
+	if value, ok := simPoolDefaults[name]; ok {
+		return value, "default", true
+	}
+	if strings.HasPrefix(name, "feature@") {
+		return "active", "local", true
+	}
+	return "", "", false
+}
+
+func (s *Simulator) selectPools(names []string) ([]*simPool, error) {
+	items := make([]*simPool, 0)
+	if len(names) == 0 {
+		for _, p := range s.pools {
+			items = append(items, p)
+		}
+		sort.Slice(items, func(i, j int) bool { return items[i].name < items[j].name })
+		return items, nil
+	}
+	for _, name := range names {
+		p, ok := s.pools[name]
+		if !ok {
+			return nil, simErrorf("cannot open '%s': no such pool", name)
+		}
+		items = append(items, p)
+	}
+	return items, nil
+}
+
+func (s *Simulator) zpoolList(args []string) (string, error) {
+	f, err := parseSimFlags(args, "oT")
+	if err != nil {
+		return "", err
+	}
+	columns := f.list('o')
+	if len(columns) == 0 {
+		columns = []string{"name", "size", "alloc", "free", "ckpoint", "expandsz", "frag", "cap", "dedup", "health", "altroot"}
+	}
+	items, err := s.selectPools(f.args)
+	if err != nil {
+		return "", err
+	}
+	if len(items) == 0 {
+		return "no pools available\n", nil
+	}
+	rows := make([][]string, 0, len(items))
+	for _, p := range items {
+		row := make([]string, 0, len(columns))
+		for _, column := range columns {
+			value, _, ok := s.poolProperty(p, column, f.has('p'))
+			if !ok {
+				return "", simUsage("invalid property '%s'", column)
+			}
+			row = append(row, value)
+		}
+		rows = append(rows, row)
+	}
+	return simTable(f.has('H'), columns, rows), nil
+}
+
+func (s *Simulator) zpoolGet(args []string) (string, error) {
+	f, err := parseSimFlags(args, "o")
+	if err != nil {
+		return "", err
+	}
+	if len(f.args) == 0 {
+		return "", simUsage("missing property argument")
+	}
+	properties := strings.Split(f.args[0], ",")
+	if len(properties) == 1 && properties[0] == "all" {
+		properties = simPoolProperties
+	}
+	fields := f.list('o')
+	if len(fields) == 0 {
+		fields = []string{"name", "property", "value", "source"}
+	}
+	items, err := s.selectPools(f.args[1:])
+	if err != nil {
+		return "", err
+	}
+
+	rows := make([][]string, 0)
+	for _, p := range items {
+		for _, name := range properties {
+			value, source, ok := s.poolProperty(p, name, f.has('p'))
+			if !ok {
+				return "", simUsage("bad property list: invalid property '%s'", name)
+			}
+			row := make([]string, 0, len(fields))
+			for _, field := range fields {
+				switch field {
+				case "name":
+					row = append(row, p.name)
+				case "property":
+					row = append(row, name)
+				case "value":
+					row = append(row, value)
+				case "source":
+					row = append(row, source)
+				default:
+					return "", simUsage("invalid field '%s'", field)
+				}
+			}
+			rows = append(rows, row)
+		}
+	}
+	return simTable(f.has('H'), fields, rows), nil
+}
+
+func (s *Simulator) zpoolSet(args []string) (string, error) {
+	if len(args) != 2 {
+		return "", simUsage("missing property=value or pool argument")
+	}
+	p, ok := s.pools[args[1]]
+	if !ok {
+		return "", simErrorf("cannot open '%s': no such pool", args[1])
+	}
+	kv := strings.SplitN(args[0], "=", 2)
+	if len(kv) != 2 {
+		return "", simUsage("missing '=' for property=value argument")
+	}
+	if _, _, ok := s.poolProperty(p, kv[0], true); !ok {
+		return "", simErrorf("cannot set property for '%s': invalid property '%s'", p.name, kv[0])
+	}
+	p.props[kv[0]] = kv[1]
+	return "", nil
+}
+
+func (s *Simulator) zpoolStatus(args []string) (string, error) {
+	f, err := parseSimFlags(args, "cT")
+	if err != nil {
+		return "", err
+	}
+	items, err := s.selectPools(f.args)
+	if err != nil {
+		return "", err
+	}
+	if len(items) == 0 {
+		return "no pools available\n", nil
+	}
+
+	b := &strings.Builder{}
+	for i, p := range items {
+		s.resilver(p)
+		if f.has('x') && p.health() == "ONLINE" {
+			continue
+		}
+		if i > 0 {
+			b.WriteString("\n")
+		}
+		b.WriteString(s.renderStatus(p))
+	}
+	if f.has('x') && b.Len() == 0 {
+		return "all pools are healthy\n", nil
+	}
+	return b.String(), nil
+}
+
+// renderStatus prints pool like 'zpool status'
+func (s *Simulator) renderStatus(p *simPool) string {
+	b := &strings.Builder{}
+	state := p.health()
+	fmt.Fprintf(b, "  pool: %s\n state: %s\n", p.name, state)
+	if state != "ONLINE" {
+		b.WriteString("status: One or more devices are faulted or could not be opened.  Sufficient\n" +
+			"\treplicas may exist for the pool to continue functioning.\n")
+		b.WriteString("action: Replace the faulted device, or use 'zpool clear' to mark the device\n\trepaired.\n")
+	}
+	fmt.Fprintf(b, "  scan: %s\n", simScanText(p.scan))
+	b.WriteString("config:\n\n")
+	b.WriteString(s.renderConfig(p, true))
+	b.WriteString("\nerrors: No known data errors\n")
+	return b.String()
+}
+
+// renderConfig prints the vdev tree of pool, with the error counters if counters is true
+func (s *Simulator) renderConfig(p *simPool, counters bool) string {
+	type row struct {
+		name, state string
+		v           *simVdev
+		group       bool
+	}
+	rows := []row{{name: p.name, state: p.health()}}
+	var add func(v *simVdev, indent int)
+	add = func(v *simVdev, indent int) {
+		state := v.health()
+		if v.class == "spares" {
+			state = "AVAIL"
+		}
+		rows = append(rows, row{name: strings.Repeat(" ", indent) + v.name, state: state, v: v})
+		for _, child := range v.children {
+			add(child, indent+2)
+		}
+	}
+	for _, v := range p.vdevs {
+		if v.class == "data" {
+			add(v, 2)
+		}
+	}
+	for _, class := range []string{"dedup", "special", "logs", "cache", "spares"} {
+		first := true
+		for _, v := range p.vdevs {
+			if v.class != class {
+				continue
+			}
+			if first {
+				rows = append(rows, row{name: class, group: true})
+				first = false
+			}
+			add(v, 2)
+		}
+	}
+
+	width := 10
+	for _, r := range rows {
+		if len(r.name) > width {
+			width = len(r.name)
+		}
+	}
+	b := &strings.Builder{}
+	// 'zpool import' prints the tree without header and counters
+	if counters {
+		fmt.Fprintf(b, "\t%-*s  %-8s %5s %5s %5s\n", width, "NAME", "STATE", "READ", "WRITE", "CKSUM")
+	}
+	for _, r := range rows {
+		switch {
+		case r.group:
+			fmt.Fprintf(b, "\t%s\n", r.name)
+		case !counters || r.state == "AVAIL":
+			fmt.Fprintf(b, "\t%-*s  %s\n", width, r.name, r.state)
+		case r.v == nil:
+			fmt.Fprintf(b, "\t%-*s  %-8s %5d %5d %5d\n", width, r.name, r.state, 0, 0, 0)
+		default:
+			fmt.Fprintf(b, "\t%-*s  %-8s %5d %5d %5d\n", width, r.name, r.state, r.v.read, r.v.write, r.v.checksum)
+		}
+	}
+	return b.String()
+}
+
+func simScanText(scan *ScanStatus) string {
+	if scan == nil {
+		return "none requested"
+	}
+	switch scan.State {
+	case ScanScanning:
+		done := uint64(float64(scan.Total) * scan.Percent / 100)
+		return fmt.Sprintf("%s in progress since %s\n\t%s scanned at 1M/s, %s issued at 1M/s, %s total\n\t%s %s, %.2f%% done, %s to go",
+			scan.Function, scan.Start.Format(time.ANSIC), simNicenum(done), simNicenum(done), simNicenum(scan.Total),
+			simNicenum(done), map[ScanFunction]string{ScanScrub: "repaired", ScanResilver: "resilvered"}[scan.Function],
+			scan.Percent, simScanDuration(scan.ETA))
+	case ScanFinished:
+		if scan.Function == ScanResilver {
+			return fmt.Sprintf("resilvered %s in %s with 0 errors on %s", simNicenum(scan.Total),
+				simScanDuration(scan.End.Sub(scan.Start)), scan.End.Format(time.ANSIC))
+		}
+		return fmt.Sprintf("%s repaired 0B in 00:00:01 with 0 errors on %s", scan.Function, scan.End.Format(time.ANSIC))
+	case ScanCanceled:
+		return fmt.Sprintf("%s canceled on %s", scan.Function, scan.End.Format(time.ANSIC))
+	}
+	return "none requested"
+}
+
+func (s *Simulator) zpoolScrub(args []string) (string, error) {
+	f, err := parseSimFlags(args, "")
+	if err != nil {
+		return "", err
+	}
+	if len(f.args) == 0 {
+		return "", simUsage("missing pool name argument")
+	}
+	for _, name := range f.args {
+		p, ok := s.pools[name]
+		if !ok {
+			return "", simErrorf("cannot open '%s': no such pool", name)
+		}
+		switch {
+		case f.has('s'):
+			return "", simErrorf("cannot cancel scrubbing %s: there is no active scrub", name)
+		case f.has('p'):
+			return "", simErrorf("cannot pause scrubbing %s: there is no active scrub", name)
+		}
+		// the scrub of simulated pool finishes immediately
+		now := s.Now()
+		p.scan = &ScanStatus{Function: ScanScrub, State: ScanFinished, Start: now, End: now}
+		for _, v := range p.vdevs {
+			v.walk(func(v *simVdev) { v.checksum = 0 })
+		}
+	}
+	return "", nil
+}
+
+func (s *Simulator) zpoolOnline(online bool, args []string) (string, error) {
+	f, err := parseSimFlags(args, "")
+	if err != nil {
+		return "", err
+	}
+	if len(f.args) < 2 {
+		return "", simUsage("missing pool name or device")
+	}
+	p, ok := s.pools[f.args[0]]
+	if !ok {
+		return "", simErrorf("cannot open '%s': no such pool", f.args[0])
+	}
+	for _, device := range f.args[1:] {
+		v := p.device(device)
+		if v == nil {
+			return "", simErrorf("cannot online %s: no such device in pool", device)
+		}
+		if online {
+			v.state = "ONLINE"
+		} else {
+			v.state = "OFFLINE"
+		}
+	}
+	return "", nil
+}
+
+func (s *Simulator) zpoolClear(args []string) (string, error) {
+	if len(args) == 0 {
+		return "", simUsage("missing pool name")
+	}
+	p, ok := s.pools[args[0]]
+	if !ok {
+		return "", simErrorf("cannot open '%s': no such pool", args[0])
+	}
+	for _, v := range p.vdevs {
+		v.walk(func(v *simVdev) {
+			if len(args) > 1 && v.name != args[1] {
+				return
+			}
+			v.read, v.write, v.checksum = 0, 0, 0
+		})
+	}
+	return "", nil
+}
+
+func (s *Simulator) zpoolExport(args []string) (string, error) {
+	f, err := parseSimFlags(args, "")
+	if err != nil {
+		return "", err
+	}
+	names := f.args
+	if f.has('a') {
+		names = make([]string, 0, len(s.pools))
+		for name := range s.pools {
+			names = append(names, name)
+		}
+	}
+	if len(names) == 0 {
+		return "", simUsage("missing pool argument")
+	}
+	for _, name := range names {
+		p, ok := s.pools[name]
+		if !ok {
+			return "", simErrorf("cannot open '%s': no such pool", name)
+		}
+		p.datasets = map[string]*simDataset{}
+		for _, d := range s.descendants(name) {
+			p.datasets[d.name] = d
+			delete(s.datasets, d.name)
+		}
+		delete(s.pools, name)
+		s.exported[name] = p
+	}
+	return "", nil
+}
+
+func (s *Simulator) zpoolImport(args []string) (string, error) {
+	f, err := parseSimFlags(args, "odcRT")
+	if err != nil {
+		return "", err
+	}
+
+	if len(f.args) == 0 && !f.has('a') {
+		names := make([]string, 0, len(s.exported))
+		for name := range s.exported {
+			names = append(names, name)
+		}
+		if len(names) == 0 {
+			return "", simErrorf("no pools available to import")
+		}
+		sort.Strings(names)
+		b := &strings.Builder{}
+		for i, name := range names {
+			p := s.exported[name]
+			if i > 0 {
+				b.WriteString("\n")
+			}
+			fmt.Fprintf(b, "   pool: %s\n     id: %d\n  state: %s\n", p.name, p.guid, p.health())
+			b.WriteString(" action: The pool can be imported using its name or numeric identifier.\n")
+			b.WriteString(" config:\n\n")
+			b.WriteString(s.renderConfig(p, false))
+		}
+		return b.String(), nil
+	}
+
+	props, err := simPropertyArgs(f.values['o'])
+	if err != nil {
+		return "", err
+	}
+	if f.has('R') {
+		props["altroot"] = f.value('R')
+	}
+
+	names, newName := f.args, ""
+	if f.has('a') {
+		names = make([]string, 0, len(s.exported))
+		for name := range s.exported {
+			names = append(names, name)
+		}
+	} else if len(names) == 2 {
+		names, newName = names[:1], names[1]
+	}
+	for _, name := range names {
+		var p *simPool
+		for _, item := range s.exported {
+			if item.name == name || strconv.FormatUint(item.guid, 10) == name {
+				p = item
+			}
+		}
+		if p == nil {
+			return "", simErrorf("cannot import '%s': no such pool available", name)
+		}
+		target := p.name
+		if len(newName) > 0 {
+			target = newName
+		}
+		if _, ok := s.pools[target]; ok {
+			return "", simErrorf("cannot import '%s': a pool with that name already exists", name)
+		}
+		if f.has('n') {
+			continue
+		}
+		delete(s.exported, p.name)
+		for k, v := range props {
+			p.props[k] = v
+		}
+		for _, d := range p.datasets {
+			d.name = target + strings.TrimPrefix(d.name, p.name)
+			if len(d.origin) > 0 {
+				d.origin = target + strings.TrimPrefix(d.origin, p.name)
+			}
+			s.datasets[d.name] = d
+		}
+		p.datasets = nil
+		p.name = target
+		s.pools[p.name] = p
+	}
+	return "", nil
+}
+
+// simScanDuration formats duration like 'zpool status', e.g. "01:02:03"
+func simScanDuration(d time.Duration) string {
+	seconds := int64(d / time.Second)
+	return fmt.Sprintf("%02d:%02d:%02d", seconds/3600, seconds/60%60, seconds%60)
+}
+
+// parent returns the parent of vdev and its index, the parent is nil for top-level vdevs
+func (p *simPool) parent(v *simVdev) (*simVdev, int) {
+	for i, top := range p.vdevs {
+		if top == v {
+			return nil, i
+		}
+	}
+	var (
+		found *simVdev
+		index int
+	)
+	for _, top := range p.vdevs {
+		top.walk(func(parent *simVdev) {
+			for i, child := range parent.children {
+				if child == v {
+					found, index = parent, i
+				}
+			}
+		})
+	}
+	return found, index
+}
+
+// swap puts new in the place of old
+func (p *simPool) swap(old, new *simVdev) {
+	parent, i := p.parent(old)
+	if parent == nil {
+		p.vdevs[i] = new
+		return
+	}
+	parent.children[i] = new
+}
+
+func (s *Simulator) zpoolReplace(args []string) (string, error) {
+	f, err := parseSimFlags(args, "o")
+	if err != nil {
+		return "", err
+	}
+	if len(f.args) < 2 {
+		return "", simUsage("missing pool name or device")
+	}
+	p, ok := s.pools[f.args[0]]
+	if !ok {
+		return "", simErrorf("cannot open '%s': no such pool", f.args[0])
+	}
+	device, replacement := f.args[1], f.args[1]
+	if len(f.args) > 2 {
+		replacement = f.args[2]
+	}
+	old := p.device(device)
+	if old == nil {
+		return "", simErrorf("cannot replace %s with %s: no such device in pool", device, replacement)
+	}
+	if old.class == "cache" || old.class == "spares" {
+		return "", simErrorf("cannot replace %s with %s: device is reserved as a hot spare or cache", device, replacement)
+	}
+	if replacement != device {
+		for _, other := range s.pools {
+			if other.device(replacement) != nil {
+				return "", simErrorf("invalid vdev specification\nuse '-f' to override the following errors:\n"+
+					"%s is part of active pool '%s'", replacement, other.name)
+			}
+		}
+	}
+	size := s.deviceSize(replacement)
+	if size == 0 {
+		return "", simErrorf("cannot open '%s': no such device in /dev", replacement)
+	}
+	if size < s.deviceSize(device) {
+		return "", simErrorf("cannot replace %s with %s: device is too small", device, replacement)
+	}
+
+	now := s.Now()
+	var allocated uint64
+	if root, ok := s.datasets[p.name]; ok {
+		allocated = s.used(root)
+	}
+	p.scan = &ScanStatus{Function: ScanResilver, State: ScanScanning, Start: now, Total: allocated}
+	if replacement == device {
+		old.state = "ONLINE"
+	} else {
+		_, i := p.parent(old)
+		replacing := &simVdev{name: fmt.Sprintf("replacing-%d", i), typ: VdevReplacing, class: old.class, state: "ONLINE"}
+		p.swap(old, replacing)
+		replacing.children = []*simVdev{old, {name: replacement, typ: VdevDisk, class: old.class, state: "ONLINE"}}
+	}
+	s.resilver(p)
+	return "", nil
+}
+
+// resilver progresses the resilver of pool by ResilverTime, the old devices are detached
+// from the replacing vdevs when it's finished
+func (s *Simulator) resilver(p *simPool) {
+	scan := p.scan
+	if scan == nil || scan.Function != ScanResilver || scan.State != ScanScanning {
+		return
+	}
+	now := s.Now()
+	elapsed := now.Sub(scan.Start)
+	if elapsed < s.ResilverTime {
+		scan.Percent = float64(elapsed) * 100 / float64(s.ResilverTime)
+		scan.ETA = s.ResilverTime - elapsed
+		return
+	}
+
+	p.scan = &ScanStatus{Function: ScanResilver, State: ScanFinished, Start: scan.Start, End: now, Total: scan.Total}
+	replacings := make([]*simVdev, 0)
+	for _, top := range p.vdevs {
+		top.walk(func(v *simVdev) {
+			if v.typ == VdevReplacing {
+				replacings = append(replacings, v)
+			}
+		})
+	}
+	for _, v := range replacings {
+		// the replacement stays with the old device if it fails during resilver
+		if replacement := v.children[len(v.children)-1]; replacement.state == "ONLINE" {
+			p.swap(v, replacement)
+		}
+	}
+}
+
+func (s *Simulator) zpoolDetach(args []string) (string, error) {
+	if len(args) < 2 {
+		return "", simUsage("missing pool name or device")
+	}
+	p, ok := s.pools[args[0]]
+	if !ok {
+		return "", simErrorf("cannot open '%s': no such pool", args[0])
+	}
+	v := p.device(args[1])
+	if v == nil {
+		return "", simErrorf("cannot detach %s: no such device in pool", args[1])
+	}
+	parent, i := p.parent(v)
+	if parent == nil || (parent.typ != VdevMirror && parent.typ != VdevReplacing && parent.typ != VdevSpare) {
+		return "", simErrorf("cannot detach %s: only applicable to mirror and replacing vdevs", args[1])
+	}
+	parent.children = append(parent.children[:i:i], parent.children[i+1:]...)
+	if len(parent.children) == 1 {
+		p.swap(parent, parent.children[0])
+	}
+
+	if parent.typ == VdevReplacing && p.scan != nil && p.scan.State == ScanScanning {
+		p.scan = &ScanStatus{Function: ScanResilver, State: ScanCanceled, Start: p.scan.Start, End: s.Now()}
+	}
+	return "", nil
+}
+
+func (s *Simulator) zpoolLabelClear(args []string) (string, error) {
+	f, err := parseSimFlags(args, "")
+	if err != nil {
+		return "", err
+	}
+	if len(f.args) == 0 {
+		return "", simUsage("missing vdev name")
+	}
+	device := f.args[0]
+	if s.deviceSize(device) == 0 {
+		return "", simErrorf("failed to open %s: No such file or directory", device)
+	}
+	for _, p := range s.pools {
+		if p.device(device) != nil {
+			return "", simErrorf("labelclear operation failed.\n\tVdev %s is a member (ACTIVE), of pool \"%s\".\n"+
+				"\tTo remove label information from this device, export or destroy\n"+
+				"\tthe pool, or remove %s from the configuration of this pool\n"+
+				"\tand retry the labelclear operation.", device, p.name, device)
+		}
+	}
+	for _, p := range s.exported {
+		if p.device(device) != nil && !f.has('f') {
+			return "", simErrorf("use '-f' to override the following error:\n"+
+				"%s is a member of exported pool \"%s\"", device, p.name)
+		}
+	}
+	return "", nil
+}
@@ -0,0 +1,554 @@
+// MIT License
+//
+// Copyright (c) 2021 Lack
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+// SOFTWARE.
+
+package zfs
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// VdevType the type of virtual device
+type VdevType string
+
+const (
+	VdevRoot      VdevType = "root"
+	VdevDisk      VdevType = "disk"
+	VdevMirror    VdevType = "mirror"
+	VdevRaidz     VdevType = "raidz"
+	VdevDraid     VdevType = "draid"
+	VdevReplacing VdevType = "replacing"
+	VdevSpare     VdevType = "spare"
+)
+
+// Vdev the virtual device in the config of pool
+type Vdev struct {
+	Name string   `json:"name"`
+	Type VdevType `json:"type"`
+	// State ONLINE, DEGRADED, FAULTED, OFFLINE, UNAVAIL, REMOVED, AVAIL (spare), INUSE (spare) ...
+	State string `json:"state"`
+	// Read the count of read errors
+	Read uint64 `json:"read"`
+	// Write the count of write errors
+	Write uint64 `json:"write"`
+	// Checksum the count of checksum errors
+	Checksum uint64 `json:"checksum"`
+	// Message the trailing message, e.g. "was /dev/sdb1", "(resilvering)"
+	Message string `json:"message,omitempty"`
+
+	Children []*Vdev `json:"children,omitempty"`
+}
+
+// IsLeaf reports whether the vdev is a device (disk or file)
+func (v *Vdev) IsLeaf() bool {
+	return len(v.Children) == 0 && v.Type == VdevDisk
+}
+
+// IsHealthy reports whether the vdev is online without any errors
+func (v *Vdev) IsHealthy() bool {
+	switch v.State {
+	case "ONLINE", "AVAIL", "INUSE":
+	default:
+		return false
+	}
+	return v.Read == 0 && v.Write == 0 && v.Checksum == 0
+}
+
+// Walk calls fn for vdev and all descendent vdevs in depth-first order
+func (v *Vdev) Walk(fn func(*Vdev)) {
+	fn(v)
+	for _, child := range v.Children {
+		child.Walk(fn)
+	}
+}
+
+// ScanFunction the function of scan
+type ScanFunction string
+
+const (
+	ScanScrub    ScanFunction = "scrub"
+	ScanResilver ScanFunction = "resilver"
+)
+
+// ScanState the state of scan
+type ScanState string
+
+const (
+	ScanNone     ScanState = "none"
+	ScanScanning ScanState = "scanning"
+	ScanPaused   ScanState = "paused"
+	ScanFinished ScanState = "finished"
+	ScanCanceled ScanState = "canceled"
+)
+
+// ScanStatus the status of scrub or resilver
+type ScanStatus struct {
+	Function ScanFunction `json:"function,omitempty"`
+	State    ScanState    `json:"state"`
+	// Start the start time of scanning
+	Start time.Time `json:"start,omitempty"`
+	// End the time when scan finished or canceled
+	End time.Time `json:"end,omitempty"`
+	// Duration the duration of finished scan
+	Duration time.Duration `json:"duration,omitempty"`
+
+	// Scanned the bytes scanned
+	Scanned uint64 `json:"scanned"`
+	// Issued the bytes issued
+	Issued uint64 `json:"issued"`
+	// Total the total bytes to scan
+	Total uint64 `json:"total"`
+	// Repaired the bytes repaired or resilvered
+	Repaired uint64 `json:"repaired"`
+	// Percent the percentage of progress
+	Percent float64 `json:"percent"`
+	// ETA the estimated time to go, 0 if it's unknown
+	ETA time.Duration `json:"eta"`
+	// Errors the count of errors of finished scan
+	Errors uint64 `json:"errors"`
+
+	// Message the raw text of scan
+	Message string `json:"message"`
+}
+
+// PoolStatus the output of 'zpool status'
+type PoolStatus struct {
+	Name   string `json:"name"`
+	State  string `json:"state"`
+	Status string `json:"status,omitempty"`
+	Action string `json:"action,omitempty"`
+	See    string `json:"see,omitempty"`
+
+	Scan *ScanStatus `json:"scan,omitempty"`
+
+	// Root the vdev tree of data vdevs, its name is the name of pool
+	Root *Vdev `json:"root"`
+	// Logs the separate intent log vdevs
+	Logs []*Vdev `json:"logs,omitempty"`
+	// Caches the L2ARC devices
+	Caches []*Vdev `json:"caches,omitempty"`
+	// Spares the hot spare devices
+	Spares []*Vdev `json:"spares,omitempty"`
+	// Specials the special allocation class vdevs
+	Specials []*Vdev `json:"specials,omitempty"`
+	// Dedups the dedup allocation class vdevs
+	Dedups []*Vdev `json:"dedups,omitempty"`
+
+	// Errors the summary of "errors:" section, e.g. "No known data errors"
+	Errors string `json:"errors"`
+	// ErrorFiles the files which have permanent errors
+	ErrorFiles []string `json:"errorFiles,omitempty"`
+}
+
+// Devices returns all leaf devices of pool, including logs, caches, spares, specials and dedups
+func (s *PoolStatus) Devices() []*Vdev {
+	devices := make([]*Vdev, 0)
+	fn := func(v *Vdev) {
+		if v.IsLeaf() {
+			devices = append(devices, v)
+		}
+	}
+	for _, groups := range [][]*Vdev{{s.Root}, s.Logs, s.Caches, s.Spares, s.Specials, s.Dedups} {
+		for _, vdev := range groups {
+			if vdev != nil {
+				vdev.Walk(fn)
+			}
+		}
+	}
+	return devices
+}
+
+// UnhealthyDevices returns the leaf devices which are not online or have errors
+func (s *PoolStatus) UnhealthyDevices() []*Vdev {
+	devices := make([]*Vdev, 0)
+	for _, device := range s.Devices() {
+		if !device.IsHealthy() {
+			devices = append(devices, device)
+		}
+	}
+	return devices
+}
+
+// GetPoolStatus returns the status of pool by 'zpool status -p'
+func (z *ZFSadm) GetPoolStatus(ctx context.Context, name string) (*PoolStatus, error) {
+	execute := z.RawZPool().Status(ctx, "", "-p", "", name)
+	data, err := execute.Exec()
+	if err != nil {
+		return nil, err
+	}
+
+	items := ParsePoolStatus(string(data))
+	for _, item := range items {
+		if item.Name == name {
+			return item, nil
+		}
+	}
+	return nil, fmt.Errorf("%w: %s", ErrPoolNotFound, name)
+}
+
+// GetPoolStatuses returns the status of all pools
+func (z *ZFSadm) GetPoolStatuses(ctx context.Context) ([]*PoolStatus, error) {
+	execute := z.RawZPool().Status(ctx, "", "-p", "", "")
+	data, err := execute.Exec()
+	if err != nil {
+		return nil, err
+	}
+	return ParsePoolStatus(string(data)), nil
+}
+
+var sectionRegexp = regexp.MustCompile(`^ {0,8}(pool|id|state|status|action|see|scan|remove|checkpoint|comment|config|errors):(.*)$`)
+
+type section struct {
+	key   string
+	lines []string
+}
+
+// splitSections splits the output of 'zpool status' or 'zpool import' into sections,
+// every pool starts with "pool:" section.
+func splitSections(data string) [][]*section {
+	pools := make([][]*section, 0)
+	var current []*section
+	for _, line := range strings.Split(data, "\n") {
+		line = strings.TrimRight(line, " \r")
+		if m := sectionRegexp.FindStringSubmatch(line); m != nil {
+			if m[1] == "pool" && len(current) > 0 {
+				pools = append(pools, current)
+				current = nil
+			}
+			current = append(current, &section{key: m[1], lines: []string{strings.TrimSpace(m[2])}})
+			continue
+		}
+		if len(current) == 0 {
+			continue
+		}
+		last := current[len(current)-1]
+		last.lines = append(last.lines, line)
+	}
+	if len(current) > 0 {
+		pools = append(pools, current)
+	}
+	return pools
+}
+
+// text joins the lines of section into a paragraph
+func (s *section) text() string {
+	parts := make([]string, 0, len(s.lines))
+	for _, line := range s.lines {
+		if line = strings.TrimSpace(line); len(line) > 0 {
+			parts = append(parts, line)
+		}
+	}
+	return strings.Join(parts, " ")
+}
+
+// ParsePoolStatus parses the output of 'zpool status'
+func ParsePoolStatus(data string) []*PoolStatus {
+	items := make([]*PoolStatus, 0)
+	for _, sections := range splitSections(data) {
+		items = append(items, parseStatusSections(sections))
+	}
+	return items
+}
+
+// parseStatusSections parses the sections of a pool, which are shared by 'zpool status' and 'zpool import'
+func parseStatusSections(sections []*section) *PoolStatus {
+	status := &PoolStatus{}
+	for _, s := range sections {
+		switch s.key {
+		case "pool":
+			status.Name = s.text()
+		case "state":
+			status.State = s.text()
+		case "status":
+			status.Status = s.text()
+		case "action":
+			status.Action = s.text()
+		case "see":
+			status.See = s.text()
+		case "scan":
+			status.Scan = parseScan(s.lines)
+		case "config":
+			config := parseConfig(s.lines)
+			status.Root = config.root
+			status.Logs = config.groups["logs"]
+			status.Caches = config.groups["cache"]
+			status.Spares = config.groups["spares"]
+			status.Specials = config.groups["special"]
+			status.Dedups = config.groups["dedup"]
+		case "errors":
+			status.Errors = strings.TrimSpace(s.lines[0])
+			for _, line := range s.lines[1:] {
+				if line = strings.TrimSpace(line); len(line) > 0 {
+					status.ErrorFiles = append(status.ErrorFiles, line)
+				}
+			}
+		}
+	}
+	return status
+}
+
+type vdevConfig struct {
+	root   *Vdev
+	groups map[string][]*Vdev
+}
+
+var vdevGroups = map[string]struct{}{
+	"logs":    {},
+	"cache":   {},
+	"spares":  {},
+	"special": {},
+	"dedup":   {},
+}
+
+// parseConfig parses the vdev tree of "config:" section, the children are indented by two spaces,
+// the header is optional:
+//
+//	NAME        STATE     READ WRITE CKSUM
+//	tank        DEGRADED     0     0     0
+//	  mirror-0  DEGRADED     0     0     0
+//	    sda     ONLINE       0     0     0
+//	    sdb     UNAVAIL      0     0     0  was /dev/sdb1
+//	logs
+//	  sdc       ONLINE       0     0     0
+func parseConfig(lines []string) *vdevConfig {
+	config := &vdevConfig{groups: map[string][]*Vdev{}}
+
+	type node struct {
+		indent int
+		vdev   *Vdev
+	}
+
+	var (
+		stack  []node
+		group  string
+		header bool
+	)
+	for _, line := range lines {
+		if len(strings.TrimSpace(line)) == 0 {
+			continue
+		}
+		line = strings.TrimPrefix(line, "\t")
+		indent := len(line) - len(strings.TrimLeft(line, " "))
+		fields := strings.Fields(line)
+		// 'zpool import' prints the tree without header
+		if !header {
+			header = true
+			if fields[0] == "NAME" {
+				continue
+			}
+		}
+
+		if indent == 0 {
+			stack = stack[:0]
+			if _, ok := vdevGroups[fields[0]]; ok && len(fields) == 1 {
+				group = fields[0]
+				continue
+			}
+			// the root of pool
+			group = ""
+			config.root = parseVdev(fields)
+			config.root.Type = VdevRoot
+			stack = append(stack, node{indent: indent, vdev: config.root})
+			continue
+		}
+
+		vdev := parseVdev(fields)
+		for len(stack) > 0 && stack[len(stack)-1].indent >= indent {
+			stack = stack[:len(stack)-1]
+		}
+		switch {
+		case len(stack) > 0:
+			parent := stack[len(stack)-1].vdev
+			parent.Children = append(parent.Children, vdev)
+		case len(group) > 0:
+			config.groups[group] = append(config.groups[group], vdev)
+		case config.root != nil:
+			config.root.Children = append(config.root.Children, vdev)
+		}
+		stack = append(stack, node{indent: indent, vdev: vdev})
+	}
+	return config
+}
+
+func parseVdev(fields []string) *Vdev {
+	vdev := &Vdev{Name: fields[0], Type: vdevType(fields[0])}
+	if len(fields) > 1 {
+		vdev.State = fields[1]
+	}
+	if len(fields) > 4 {
+		vdev.Read = parseCount(fields[2])
+		vdev.Write = parseCount(fields[3])
+		vdev.Checksum = parseCount(fields[4])
+		vdev.Message = strings.Join(fields[5:], " ")
+	} else if len(fields) > 2 {
+		// e.g. spare: sdg  INUSE  currently in use
+		vdev.Message = strings.Join(fields[2:], " ")
+	}
+	return vdev
+}
+
+var vdevTypeRegexp = regexp.MustCompile(`^(mirror|raidz[123]?|draid[123]?(:[0-9a-z:]+)?|replacing|spare)-\d+$`)
+
+func vdevType(name string) VdevType {
+	m := vdevTypeRegexp.FindStringSubmatch(name)
+	if m == nil {
+		return VdevDisk
+	}
+	switch {
+	case strings.HasPrefix(m[1], "raidz"):
+		return VdevRaidz
+	case strings.HasPrefix(m[1], "draid"):
+		return VdevDraid
+	}
+	return VdevType(m[1])
+}
+
+// parseCount parses the error counter of vdev, e.g. "0", "12", "1.2K"
+func parseCount(s string) uint64 {
+	if n, err := strconv.ParseUint(s, 10, 64); err == nil {
+		return n
+	}
+	n, _ := parseSize(s)
+	return n
+}
+
+var (
+	scanFinishedRegexp = regexp.MustCompile(`^(scrub|resilver) repaired (\S+) in (.+) with (\d+) errors on (.+)$`)
+	scanResilverRegexp = regexp.MustCompile(`^resilvered (\S+) in (.+) with (\d+) errors on (.+)$`)
+	scanProgressRegexp = regexp.MustCompile(`^(scrub|resilver) in progress since (.+)$`)
+	scanPausedRegexp   = regexp.MustCompile(`^(scrub|resilver) paused since (.+)$`)
+	scanCanceledRegexp = regexp.MustCompile(`^(scrub|resilver) canceled on (.+)$`)
+
+	// 1.23G scanned at 100M/s, 500M issued at 50M/s, 10.0G total
+	scannedRegexp = regexp.MustCompile(`^(\S+) scanned at \S+, (\S+) issued at \S+, (\S+) total`)
+	// 1.23G / 10.0G scanned at 100M/s, 500M / 10.0G issued at 50M/s
+	scannedTotalRegexp = regexp.MustCompile(`^(\S+) / (\S+) scanned(?: at \S+)?, (\S+) / \S+ issued`)
+	// 1.23G scanned out of 10.0G at 100M/s, 0h3m to go
+	scannedLegacyRegexp = regexp.MustCompile(`^(\S+) scanned out of (\S+) at \S+, (.+) to go`)
+	// 490M resilvered, 5.00% done, 00:03:14 to go
+	doneRegexp = regexp.MustCompile(`^(\S+) (?:repaired|resilvered), ([\d.]+)% done(?:, (.+) to go)?`)
+)
+
+// parseScan parses "scan:" section of 'zpool status'
+func parseScan(lines []string) *ScanStatus {
+	scan := &ScanStatus{State: ScanNone}
+	texts := make([]string, 0, len(lines))
+	for _, line := range lines {
+		if line = strings.TrimSpace(line); len(line) > 0 {
+			texts = append(texts, line)
+		}
+	}
+	if len(texts) == 0 {
+		return scan
+	}
+	scan.Message = strings.Join(texts, "\n")
+
+	head := texts[0]
+	switch {
+	case scanFinishedRegexp.MatchString(head):
+		m := scanFinishedRegexp.FindStringSubmatch(head)
+		scan.Function, scan.State = ScanFunction(m[1]), ScanFinished
+		scan.Repaired, _ = parseSize(m[2])
+		scan.Duration = parseScanDuration(m[3])
+		scan.Errors, _ = strconv.ParseUint(m[4], 10, 64)
+		scan.End = parseScanTime(m[5])
+		scan.Percent = 100
+	case scanResilverRegexp.MatchString(head):
+		m := scanResilverRegexp.FindStringSubmatch(head)
+		scan.Function, scan.State = ScanResilver, ScanFinished
+		scan.Repaired, _ = parseSize(m[1])
+		scan.Duration = parseScanDuration(m[2])
+		scan.Errors, _ = strconv.ParseUint(m[3], 10, 64)
+		scan.End = parseScanTime(m[4])
+		scan.Percent = 100
+	case scanProgressRegexp.MatchString(head):
+		m := scanProgressRegexp.FindStringSubmatch(head)
+		scan.Function, scan.State = ScanFunction(m[1]), ScanScanning
+		scan.Start = parseScanTime(m[2])
+	case scanPausedRegexp.MatchString(head):
+		m := scanPausedRegexp.FindStringSubmatch(head)
+		scan.Function, scan.State = ScanFunction(m[1]), ScanPaused
+	case scanCanceledRegexp.MatchString(head):
+		m := scanCanceledRegexp.FindStringSubmatch(head)
+		scan.Function, scan.State = ScanFunction(m[1]), ScanCanceled
+		scan.End = parseScanTime(m[2])
+	}
+
+	for _, text := range texts[1:] {
+		switch {
+		case scannedRegexp.MatchString(text):
+			m := scannedRegexp.FindStringSubmatch(text)
+			scan.Scanned, _ = parseSize(m[1])
+			scan.Issued, _ = parseSize(m[2])
+			scan.Total, _ = parseSize(m[3])
+		case scannedTotalRegexp.MatchString(text):
+			m := scannedTotalRegexp.FindStringSubmatch(text)
+			scan.Scanned, _ = parseSize(m[1])
+			scan.Total, _ = parseSize(m[2])
+			scan.Issued, _ = parseSize(m[3])
+		case scannedLegacyRegexp.MatchString(text):
+			m := scannedLegacyRegexp.FindStringSubmatch(text)
+			scan.Scanned, _ = parseSize(m[1])
+			scan.Total, _ = parseSize(m[2])
+			scan.ETA = parseScanDuration(m[3])
+		case doneRegexp.MatchString(text):
+			m := doneRegexp.FindStringSubmatch(text)
+			scan.Repaired, _ = parseSize(m[1])
+			scan.Percent, _ = strconv.ParseFloat(m[2], 64)
+			if len(m[3]) > 0 {
+				scan.ETA = parseScanDuration(m[3])
+			}
+		}
+	}
+
+	return scan
+}
+
+// parseScanTime parses the time of scan, e.g. "Sun Jun  6 10:00:00 2021"
+func parseScanTime(s string) time.Time {
+	t, err := time.ParseInLocation(time.ANSIC, strings.TrimSpace(s), time.Local)
+	if err != nil {
+		return time.Time{}
+	}
+	return t
+}
+
+var scanDurationRegexp = regexp.MustCompile(`^(?:(\d+) days? )?(\d+):(\d+):(\d+)$`)
+
+// parseScanDuration parses the duration of scan, e.g. "00:03:14", "1 days 02:03:04", "0h3m"
+func parseScanDuration(s string) time.Duration {
+	s = strings.TrimSpace(s)
+	if m := scanDurationRegexp.FindStringSubmatch(s); m != nil {
+		days, _ := strconv.Atoi(m[1])
+		hours, _ := strconv.Atoi(m[2])
+		minutes, _ := strconv.Atoi(m[3])
+		seconds, _ := strconv.Atoi(m[4])
+		return time.Duration(days)*24*time.Hour + time.Duration(hours)*time.Hour +
+			time.Duration(minutes)*time.Minute + time.Duration(seconds)*time.Second
+	}
+	d, _ := time.ParseDuration(s)
+	return d
+}
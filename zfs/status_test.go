@@ -0,0 +1,164 @@
+// MIT License
+//
+// Copyright (c) 2021 Lack
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+// SOFTWARE.
+
+package zfs
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+const degradedStatus = `  pool: tank
+ state: DEGRADED
+status: One or more devices could not be used because the label is missing or
+	invalid.  Sufficient replicas exist for the pool to continue
+	functioning in a degraded state.
+action: Replace the device using 'zpool replace'.
+   see: https://openzfs.github.io/openzfs-docs/msg/ZFS-8000-4J
+  scan: resilver in progress since Sun Jun  6 10:00:00 2021
+	1.23G scanned at 100M/s, 512M issued at 50M/s, 10.0G total
+	490M resilvered, 5.00% done, 00:03:14 to go
+config:
+
+	NAME        STATE     READ WRITE CKSUM
+	tank        DEGRADED     0     0     0
+	  mirror-0  DEGRADED     0     0     0
+	    sda     ONLINE       0     0     0
+	    sdb     UNAVAIL      3     1     0  was /dev/sdb1
+	  raidz2-1  ONLINE       0     0     0
+	    sdc     ONLINE       0     0     0
+	    sdd     ONLINE       0     0     0
+	    sde     ONLINE       0     0    12
+	logs
+	  mirror-2  ONLINE       0     0     0
+	    sdf     ONLINE       0     0     0
+	    sdg     ONLINE       0     0     0
+	cache
+	  sdh       ONLINE       0     0     0
+	spares
+	  sdi       AVAIL
+
+errors: Permanent errors have been detected in the following files:
+
+        /tank/data/file.bin
+`
+
+func TestParsePoolStatus(t *testing.T) {
+	items := ParsePoolStatus(degradedStatus)
+	if len(items) != 1 {
+		t.Fatalf("expect 1 pool, got %d", len(items))
+	}
+	status := items[0]
+	if status.Name != "tank" || status.State != "DEGRADED" {
+		t.Fatalf("unexpected pool: %s %s", status.Name, status.State)
+	}
+	if status.See != "https://openzfs.github.io/openzfs-docs/msg/ZFS-8000-4J" {
+		t.Fatalf("unexpected see: %s", status.See)
+	}
+
+	root := status.Root
+	if root == nil || root.Type != VdevRoot || len(root.Children) != 2 {
+		t.Fatalf("unexpected root: %+v", root)
+	}
+	mirror := root.Children[0]
+	if mirror.Type != VdevMirror || len(mirror.Children) != 2 {
+		t.Fatalf("unexpected mirror: %+v", mirror)
+	}
+	sdb := mirror.Children[1]
+	if sdb.Name != "sdb" || sdb.State != "UNAVAIL" || sdb.Read != 3 || sdb.Write != 1 || sdb.Message != "was /dev/sdb1" {
+		t.Fatalf("unexpected device: %+v", sdb)
+	}
+	if raidz := root.Children[1]; raidz.Type != VdevRaidz || len(raidz.Children) != 3 {
+		t.Fatalf("unexpected raidz: %+v", raidz)
+	}
+	if len(status.Logs) != 1 || status.Logs[0].Type != VdevMirror || len(status.Logs[0].Children) != 2 {
+		t.Fatalf("unexpected logs: %+v", status.Logs)
+	}
+	if len(status.Caches) != 1 || status.Caches[0].Name != "sdh" {
+		t.Fatalf("unexpected caches: %+v", status.Caches)
+	}
+	if len(status.Spares) != 1 || status.Spares[0].State != "AVAIL" {
+		t.Fatalf("unexpected spares: %+v", status.Spares)
+	}
+
+	unhealthy := status.UnhealthyDevices()
+	if len(unhealthy) != 2 || unhealthy[0].Name != "sdb" || unhealthy[1].Name != "sde" {
+		t.Fatalf("unexpected unhealthy devices: %+v", unhealthy)
+	}
+	if len(status.Devices()) != 9 {
+		t.Fatalf("expect 9 devices, got %d", len(status.Devices()))
+	}
+
+	scan := status.Scan
+	if scan.Function != ScanResilver || scan.State != ScanScanning {
+		t.Fatalf("unexpected scan: %+v", scan)
+	}
+	if scan.Issued != 512<<20 || scan.Total != 10<<30 || scan.Percent != 5 || scan.ETA != 3*time.Minute+14*time.Second {
+		t.Fatalf("unexpected scan progress: %+v", scan)
+	}
+	if scan.Start.IsZero() {
+		t.Fatal("expect start time of scan")
+	}
+
+	if status.Errors != "Permanent errors have been detected in the following files:" {
+		t.Fatalf("unexpected errors: %s", status.Errors)
+	}
+	if len(status.ErrorFiles) != 1 || status.ErrorFiles[0] != "/tank/data/file.bin" {
+		t.Fatalf("unexpected error files: %v", status.ErrorFiles)
+	}
+}
+
+func TestParseScan(t *testing.T) {
+	scan := parseScan([]string{"scrub repaired 0B in 1 days 02:03:04 with 2 errors on Sun Jun  6 10:00:00 2021"})
+	if scan.Function != ScanScrub || scan.State != ScanFinished || scan.Errors != 2 {
+		t.Fatalf("unexpected scan: %+v", scan)
+	}
+	if scan.Duration != 26*time.Hour+3*time.Minute+4*time.Second {
+		t.Fatalf("unexpected duration: %v", scan.Duration)
+	}
+
+	scan = parseScan([]string{"none requested"})
+	if scan.State != ScanNone {
+		t.Fatalf("unexpected scan: %+v", scan)
+	}
+
+	scan = parseScan([]string{"scrub in progress since Sun Jun  6 10:00:00 2021",
+		"\t2.37T / 3.12T scanned at 1.05G/s, 1.23T / 3.12T issued at 560M/s",
+		"\t0B repaired, 39.42% done, no estimated completion time"})
+	if scan.State != ScanScanning || scan.Percent != 39.42 || scan.ETA != 0 || scan.Total == 0 || scan.Issued == 0 {
+		t.Fatalf("unexpected scan: %+v", scan)
+	}
+}
+
+func TestZFSadm_GetPoolStatus(t *testing.T) {
+	runner := NewFakeRunner().On("zpool status -p tank", degradedStatus, nil)
+
+	z := New(WithRunner(runner))
+	status, err := z.GetPoolStatus(context.TODO(), "tank")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if status.Name != "tank" || len(status.UnhealthyDevices()) != 2 {
+		t.Fatalf("unexpected status: %+v", status)
+	}
+}
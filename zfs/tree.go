@@ -0,0 +1,220 @@
+// MIT License
+//
+// Copyright (c) 2021 Lack
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+// SOFTWARE.
+
+package zfs
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Space the space accounting of filesystem or volume, the used space is the sum of the
+// space used by snapshots, children, dataset and refreservation
+type Space struct {
+	Used                 uint64 `json:"used"`
+	Available            uint64 `json:"available"`
+	Referenced           uint64 `json:"referenced"`
+	UsedBySnapshots      uint64 `json:"usedBySnapshots"`
+	UsedByChildren       uint64 `json:"usedByChildren"`
+	UsedByDataset        uint64 `json:"usedByDataset"`
+	UsedByRefreservation uint64 `json:"usedByRefreservation"`
+}
+
+// TreeNode the filesystem, volume, snapshot or bookmark in the hierarchy of datasets
+type TreeNode struct {
+	Name      string    `json:"name"`
+	Type      string    `json:"type"`
+	GUID      uint64    `json:"guid"`
+	CreateTXG uint64    `json:"createTXG"`
+	Creation  time.Time `json:"creation"`
+	Space     Space     `json:"space"`
+	// Mountpoint the mountpoint of filesystem
+	Mountpoint string `json:"mountpoint,omitempty"`
+	// Origin the snapshot which the clone is created from
+	Origin string `json:"origin,omitempty"`
+	// Clones the clones created from snapshot
+	Clones []string `json:"clones,omitempty"`
+
+	// Parent the parent filesystem of dataset, or the dataset of snapshot and bookmark,
+	// nil for the root of tree
+	Parent    *TreeNode   `json:"-"`
+	Children  []*TreeNode `json:"children,omitempty"`
+	Snapshots []*TreeNode `json:"snapshots,omitempty"`
+	Bookmarks []*TreeNode `json:"bookmarks,omitempty"`
+}
+
+// Tree the hierarchy of datasets, the origin and clones of nodes could be resolved by Lookup
+type Tree struct {
+	Roots []*TreeNode `json:"roots"`
+
+	nodes map[string]*TreeNode
+}
+
+// Lookup returns the node by its full name, nil if it's not in the tree
+func (t *Tree) Lookup(name string) *TreeNode {
+	return t.nodes[name]
+}
+
+// Walk calls fn for each node in depth-first order, the snapshots and bookmarks of dataset
+// are visited before its children, the walk stops if fn returns false
+func (t *Tree) Walk(fn func(node *TreeNode) bool) {
+	var walk func(nodes []*TreeNode) bool
+	walk = func(nodes []*TreeNode) bool {
+		for _, node := range nodes {
+			if !fn(node) {
+				return false
+			}
+			if !walk(node.Snapshots) || !walk(node.Bookmarks) || !walk(node.Children) {
+				return false
+			}
+		}
+		return true
+	}
+	walk(t.Roots)
+}
+
+// Dependents returns the clones which depend on the snapshot, or on the snapshots of dataset
+// and its descendants, including the clones of these clones
+func (t *Tree) Dependents(name string) []string {
+	node := t.Lookup(name)
+	if node == nil {
+		return nil
+	}
+	dependents := make([]string, 0)
+	seen := map[string]bool{}
+	var visit func(node *TreeNode)
+	visit = func(node *TreeNode) {
+		for _, clone := range node.Clones {
+			if seen[clone] {
+				continue
+			}
+			seen[clone] = true
+			dependents = append(dependents, clone)
+			if n := t.Lookup(clone); n != nil {
+				visit(n)
+			}
+		}
+		for _, snapshot := range node.Snapshots {
+			visit(snapshot)
+		}
+		for _, child := range node.Children {
+			visit(child)
+		}
+	}
+	visit(node)
+	return dependents
+}
+
+var treeProperties = []string{
+	"name", "type", "guid", "createtxg", "creation", "used", "available", "referenced",
+	"usedbysnapshots", "usedbychildren", "usedbydataset", "usedbyrefreservation",
+	"mountpoint", "origin", "clones",
+}
+
+// GetTree returns the hierarchy of filesystems, volumes, snapshots and bookmarks under root,
+// the hierarchy of all pools if root is empty
+func (z *ZFSadm) GetTree(ctx context.Context, root string) (*Tree, error) {
+	out, err := z.RawZFS().List(ctx, root, "-Hp -r", "", treeProperties, "", "", "all").Exec()
+	if err != nil {
+		return nil, err
+	}
+	return ParseTree(string(out))
+}
+
+// ParseTree parses the output of 'zfs list -Hp -r -t all' with the properties name, type, guid,
+// createtxg, creation, used, available, referenced, usedbysnapshots, usedbychildren,
+// usedbydataset, usedbyrefreservation, mountpoint, origin and clones
+func ParseTree(data string) (*Tree, error) {
+	tree := &Tree{Roots: make([]*TreeNode, 0), nodes: map[string]*TreeNode{}}
+	nodes := make([]*TreeNode, 0)
+	for _, line := range strings.Split(data, "\n") {
+		if len(strings.TrimSpace(line)) == 0 {
+			continue
+		}
+		parts := strings.Split(line, "\t")
+		if len(parts) != len(treeProperties) {
+			return nil, fmt.Errorf("invalid dataset '%s'", line)
+		}
+		node := &TreeNode{Name: parts[0], Type: parts[1]}
+		node.GUID = parseTreeUint(parts[2])
+		node.CreateTXG = parseTreeUint(parts[3])
+		if sec, err := strconv.ParseInt(parts[4], 10, 64); err == nil {
+			node.Creation = time.Unix(sec, 0)
+		}
+		node.Space = Space{
+			Used:                 parseTreeUint(parts[5]),
+			Available:            parseTreeUint(parts[6]),
+			Referenced:           parseTreeUint(parts[7]),
+			UsedBySnapshots:      parseTreeUint(parts[8]),
+			UsedByChildren:       parseTreeUint(parts[9]),
+			UsedByDataset:        parseTreeUint(parts[10]),
+			UsedByRefreservation: parseTreeUint(parts[11]),
+		}
+		if parts[12] != "-" {
+			node.Mountpoint = parts[12]
+		}
+		if parts[13] != "-" {
+			node.Origin = parts[13]
+		}
+		if parts[14] != "-" && len(parts[14]) > 0 {
+			node.Clones = strings.Split(parts[14], ",")
+		}
+		tree.nodes[node.Name] = node
+		nodes = append(nodes, node)
+	}
+
+	// the nodes are linked after all are parsed, the parent which is not listed
+	// makes the node a root of tree
+	for _, node := range nodes {
+		var parent *TreeNode
+		switch i := strings.IndexAny(node.Name, "@#"); {
+		case i > 0:
+			parent = tree.nodes[node.Name[:i]]
+		case strings.Contains(node.Name, "/"):
+			parent = tree.nodes[node.Name[:strings.LastIndex(node.Name, "/")]]
+		}
+		if parent == nil {
+			tree.Roots = append(tree.Roots, node)
+			continue
+		}
+		node.Parent = parent
+		switch node.Type {
+		case "snapshot":
+			parent.Snapshots = append(parent.Snapshots, node)
+		case "bookmark":
+			parent.Bookmarks = append(parent.Bookmarks, node)
+		default:
+			parent.Children = append(parent.Children, node)
+		}
+	}
+	return tree, nil
+}
+
+// parseTreeUint parses the numeric property, "-" which is shown for the property
+// not applying to the type is parsed as 0
+func parseTreeUint(s string) uint64 {
+	n, _ := strconv.ParseUint(s, 10, 64)
+	return n
+}
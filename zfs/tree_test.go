@@ -0,0 +1,102 @@
+// MIT License
+//
+// Copyright (c) 2021 Lack
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+// SOFTWARE.
+
+package zfs
+
+import (
+	"context"
+	"reflect"
+	"testing"
+)
+
+func TestZFSadm_GetTree(t *testing.T) {
+	sim, z := newTestSimulator(t)
+	ctx := context.TODO()
+
+	mustExec(t, z.RawZFS().CreateFileSystem(ctx, "tank/a", nil))
+	mustExec(t, z.RawZFS().CreateFileSystem(ctx, "tank/a/b", nil))
+	if err := sim.Write("tank/a/b", 1<<20); err != nil {
+		t.Fatal(err)
+	}
+	mustExec(t, z.RawZFS().Snapshot2(ctx, false, nil, "tank/a@s1"))
+	mustExec(t, z.RawZFS().Bookmark(ctx, "tank/a@s1", "tank/a#b1"))
+	mustExec(t, z.RawZFS().Clone(ctx, "tank/c", nil, "tank/a@s1"))
+	mustExec(t, z.RawZFS().Snapshot2(ctx, false, nil, "tank/c@s2"))
+	mustExec(t, z.RawZFS().Clone(ctx, "tank/d", nil, "tank/c@s2"))
+	if _, err := z.CreateVolume(ctx, "tank/v", map[string]string{"refreservation": "1048576"}, 1<<30); err != nil {
+		t.Fatal(err)
+	}
+
+	tree, err := z.GetTree(ctx, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(tree.Roots) != 1 || tree.Roots[0].Name != "tank" {
+		t.Fatalf("unexpected roots: %+v", tree.Roots)
+	}
+
+	names := make([]string, 0)
+	tree.Walk(func(node *TreeNode) bool {
+		names = append(names, node.Name)
+		return true
+	})
+	expect := []string{"tank", "tank/a", "tank/a@s1", "tank/a#b1", "tank/a/b", "tank/c", "tank/c@s2", "tank/d", "tank/v"}
+	if !reflect.DeepEqual(names, expect) {
+		t.Fatalf("expect %v, got %v", expect, names)
+	}
+
+	a, b := tree.Lookup("tank/a"), tree.Lookup("tank/a/b")
+	if b.Parent != a || a.Parent != tree.Roots[0] || a.Mountpoint != "/tank/a" {
+		t.Fatalf("unexpected node: %+v", a)
+	}
+	if a.Space.UsedByChildren != b.Space.Used || b.Space.UsedByDataset != 1<<20+24576 || a.Space.Used < b.Space.Used {
+		t.Fatalf("unexpected space of %s: %+v, %s: %+v", a.Name, a.Space, b.Name, b.Space)
+	}
+	if v := tree.Lookup("tank/v"); v.Type != "volume" || v.Mountpoint != "" || v.Space.UsedByRefreservation == 0 {
+		t.Fatalf("unexpected volume: %+v", v)
+	}
+
+	s1, b1 := tree.Lookup("tank/a@s1"), tree.Lookup("tank/a#b1")
+	if s1.Parent != a || !reflect.DeepEqual(s1.Clones, []string{"tank/c"}) {
+		t.Fatalf("unexpected snapshot: %+v", s1)
+	}
+	if b1.Parent != a || b1.GUID != s1.GUID || b1.CreateTXG != s1.CreateTXG {
+		t.Fatalf("unexpected bookmark: %+v", b1)
+	}
+	if c := tree.Lookup(tree.Lookup("tank/d").Origin); c == nil || c.Name != "tank/c@s2" {
+		t.Fatalf("unexpected origin: %+v", c)
+	}
+	if dependents := tree.Dependents("tank/a"); !reflect.DeepEqual(dependents, []string{"tank/c", "tank/d"}) {
+		t.Fatalf("unexpected dependents: %v", dependents)
+	}
+
+	tree, err = z.GetTree(ctx, "tank/a")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(tree.Roots) != 1 || tree.Roots[0].Name != "tank/a" || tree.Roots[0].Parent != nil || tree.Lookup("tank/c") != nil {
+		t.Fatalf("unexpected tree: %+v", tree.Roots)
+	}
+	if dependents := tree.Dependents("tank/a"); !reflect.DeepEqual(dependents, []string{"tank/c"}) {
+		t.Fatalf("unexpected dependents: %v", dependents)
+	}
+}
@@ -13,6 +13,8 @@
 
 package zfs
 
+import "time"
+
 // Pool
 // +gogo:deepcopy-gen=true
 type Pool struct {
@@ -238,6 +240,34 @@ type Pool struct {
 	Pbkdf2iters string `json:"pbkdf2Iters" zfs:"pbkdf2iters" protobuf:"bytes,109,opt,name=pbkdf2Iters"`
 
 	SpecialSmallBlocks string `json:"specialSmallBlocks" zfs:"special_small_blocks" protobuf:"bytes,110,opt,name=specialSmallBlocks"`
+
+	// typed values of properties, they are converted from parsable output (-Hp)
+
+	CreationTime time.Time `json:"creationTime" zfs:"creation" protobuf:"bytes,111,opt,name=creationTime,stdtime"`
+
+	SizeBytes uint64 `json:"sizeBytes" zfs:"size" protobuf:"varint,112,opt,name=sizeBytes"`
+
+	FreeBytes uint64 `json:"freeBytes" zfs:"free" protobuf:"varint,113,opt,name=freeBytes"`
+
+	AllocatedBytes uint64 `json:"allocatedBytes" zfs:"allocated" protobuf:"varint,114,opt,name=allocatedBytes"`
+
+	UsedBytes uint64 `json:"usedBytes" zfs:"used" protobuf:"varint,115,opt,name=usedBytes"`
+
+	AvailableBytes uint64 `json:"availableBytes" zfs:"available" protobuf:"varint,116,opt,name=availableBytes"`
+
+	ReferencedBytes uint64 `json:"referencedBytes" zfs:"referenced" protobuf:"varint,117,opt,name=referencedBytes"`
+
+	CompressRatioValue float64 `json:"compressRatioValue" zfs:"compressratio" protobuf:"fixed64,118,opt,name=compressRatioValue"`
+
+	DedupRatioValue float64 `json:"dedupRatioValue" zfs:"dedupratio" protobuf:"fixed64,119,opt,name=dedupRatioValue"`
+
+	IsReadonly bool `json:"isReadonly" zfs:"readonly" protobuf:"varint,120,opt,name=isReadonly"`
+
+	IsMounted bool `json:"isMounted" zfs:"mounted" protobuf:"varint,121,opt,name=isMounted"`
+
+	Keystatus string `json:"keystatus" zfs:"keystatus" protobuf:"bytes,122,opt,name=keystatus"`
+
+	EncryptionRoot string `json:"encryptionRoot" zfs:"encryptionroot" protobuf:"bytes,123,opt,name=encryptionRoot"`
 }
 
 // Volume
@@ -326,6 +356,36 @@ type Volume struct {
 	Rootcontext string `json:"rootcontext" zfs:"rootcontext" protobuf:"bytes,41,opt,name=rootcontext"`
 
 	RedundantMetadata string `json:"redundantMetadata" zfs:"redundant_metadata" protobuf:"bytes,42,opt,name=redundantMetadata"`
+
+	// typed values of properties, they are converted from parsable output (-Hp)
+
+	CreationTime time.Time `json:"creationTime" zfs:"creation" protobuf:"bytes,43,opt,name=creationTime,stdtime"`
+
+	UsedBytes uint64 `json:"usedBytes" zfs:"used" protobuf:"varint,44,opt,name=usedBytes"`
+
+	AvailableBytes uint64 `json:"availableBytes" zfs:"available" protobuf:"varint,45,opt,name=availableBytes"`
+
+	ReferencedBytes uint64 `json:"referencedBytes" zfs:"referenced" protobuf:"varint,46,opt,name=referencedBytes"`
+
+	VolsizeBytes uint64 `json:"volsizeBytes" zfs:"volsize" protobuf:"varint,47,opt,name=volsizeBytes"`
+
+	ReservationBytes uint64 `json:"reservationBytes" zfs:"reservation" protobuf:"varint,48,opt,name=reservationBytes"`
+
+	CompressRatioValue float64 `json:"compressRatioValue" zfs:"compressratio" protobuf:"fixed64,49,opt,name=compressRatioValue"`
+
+	IsReadonly bool `json:"isReadonly" zfs:"readonly" protobuf:"varint,50,opt,name=isReadonly"`
+
+	// native encryption
+
+	Encryption string `json:"encryption" zfs:"encryption" protobuf:"bytes,51,opt,name=encryption"`
+
+	Keylocation string `json:"keylocation" zfs:"keylocation" protobuf:"bytes,52,opt,name=keylocation"`
+
+	Keyformat string `json:"keyformat" zfs:"keyformat" protobuf:"bytes,53,opt,name=keyformat"`
+
+	Keystatus string `json:"keystatus" zfs:"keystatus" protobuf:"bytes,54,opt,name=keystatus"`
+
+	EncryptionRoot string `json:"encryptionRoot" zfs:"encryptionroot" protobuf:"bytes,55,opt,name=encryptionRoot"`
 }
 
 // Snapshot
@@ -392,4 +452,16 @@ type Snapshot struct {
 	Defcontext string `json:"defcontext" zfs:"defcontext" protobuf:"bytes,30,opt,name=defcontext"`
 
 	Rootcontext string `json:"rootcontext" zfs:"rootcontext" protobuf:"bytes,31,opt,name=rootcontext"`
+
+	// typed values of properties, they are converted from parsable output (-Hp)
+
+	CreationTime time.Time `json:"creationTime" zfs:"creation" protobuf:"bytes,32,opt,name=creationTime,stdtime"`
+
+	UsedBytes uint64 `json:"usedBytes" zfs:"used" protobuf:"varint,33,opt,name=usedBytes"`
+
+	ReferencedBytes uint64 `json:"referencedBytes" zfs:"referenced" protobuf:"varint,34,opt,name=referencedBytes"`
+
+	WrittenBytes uint64 `json:"writtenBytes" zfs:"written" protobuf:"varint,35,opt,name=writtenBytes"`
+
+	CompressRatioValue float64 `json:"compressRatioValue" zfs:"compressratio" protobuf:"fixed64,36,opt,name=compressRatioValue"`
 }
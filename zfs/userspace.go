@@ -0,0 +1,170 @@
+// MIT License
+//
+// Copyright (c) 2021 Lack
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+// SOFTWARE.
+
+package zfs
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// SpaceUsage the space usage and quotas of a user, group or project on file system
+type SpaceUsage struct {
+	// Type the type of principal, e.g. "POSIX User", "POSIX Group", "SMB User" or "Project"
+	Type      string `json:"type"`
+	Principal string `json:"principal"`
+	Used      uint64 `json:"used"`
+	// Quota the limit of space in bytes, 0 if there is no limit
+	Quota      uint64 `json:"quota"`
+	ObjectUsed uint64 `json:"objectUsed"`
+	// ObjectQuota the limit of objects (files and directories), 0 if there is no limit
+	ObjectQuota uint64 `json:"objectQuota"`
+}
+
+// SpaceQuota the limits of a user, group or project, the limit which is nil is left unchanged
+// and 0 removes the limit, e.g. SpaceQuota{Bytes: QuotaLimit(1 << 30)}
+type SpaceQuota struct {
+	Bytes   *uint64 `json:"bytes,omitempty"`
+	Objects *uint64 `json:"objects,omitempty"`
+}
+
+// QuotaLimit returns the pointer of limit for SpaceQuota
+func QuotaLimit(n uint64) *uint64 {
+	return &n
+}
+
+var spaceFields = []string{"type", "name", "used", "quota", "objused", "objquota"}
+
+// GetUserSpace returns the space usage of users on file system or snapshot
+func (z *ZFSadm) GetUserSpace(ctx context.Context, name string) ([]*SpaceUsage, error) {
+	out, err := z.RawZFS().Userspace(ctx, name, "-Hp", spaceFields, "", "", "").Exec()
+	if err != nil {
+		return nil, err
+	}
+	return ParseSpaceUsage(string(out), spaceFields)
+}
+
+// GetGroupSpace returns the space usage of groups on file system or snapshot
+func (z *ZFSadm) GetGroupSpace(ctx context.Context, name string) ([]*SpaceUsage, error) {
+	out, err := z.RawZFS().GroupSpace(ctx, name, "-Hp", spaceFields, "", "", "").Exec()
+	if err != nil {
+		return nil, err
+	}
+	return ParseSpaceUsage(string(out), spaceFields)
+}
+
+// GetProjectSpace returns the space usage of projects on file system or snapshot
+func (z *ZFSadm) GetProjectSpace(ctx context.Context, name string) ([]*SpaceUsage, error) {
+	// projectspace has no type field
+	fields := spaceFields[1:]
+	out, err := z.RawZFS().ProjectSpace(ctx, name, "-Hp", fields, "", "").Exec()
+	if err != nil {
+		return nil, err
+	}
+	usages, err := ParseSpaceUsage(string(out), fields)
+	if err != nil {
+		return nil, err
+	}
+	for _, usage := range usages {
+		usage.Type = "Project"
+	}
+	return usages, nil
+}
+
+// SetUserQuota sets the space and object limits of user on file system
+func (z *ZFSadm) SetUserQuota(ctx context.Context, name, user string, quota SpaceQuota) error {
+	return z.setSpaceQuota(ctx, name, "user", user, quota)
+}
+
+// SetGroupQuota sets the space and object limits of group on file system
+func (z *ZFSadm) SetGroupQuota(ctx context.Context, name, group string, quota SpaceQuota) error {
+	return z.setSpaceQuota(ctx, name, "group", group, quota)
+}
+
+// SetProjectQuota sets the space and object limits of project on file system
+func (z *ZFSadm) SetProjectQuota(ctx context.Context, name string, project uint64, quota SpaceQuota) error {
+	return z.setSpaceQuota(ctx, name, "project", strconv.FormatUint(project, 10), quota)
+}
+
+func (z *ZFSadm) setSpaceQuota(ctx context.Context, name, kind, principal string, quota SpaceQuota) error {
+	// the principal could be a SID or the domain user, e.g. joe.smith@mydomain
+	if len(principal) == 0 || strings.ContainsAny(principal, "= \t") {
+		return fmt.Errorf("%w: %s %q", ErrInvalidName, kind, principal)
+	}
+	value := func(n uint64) string {
+		if n == 0 {
+			return "none"
+		}
+		return strconv.FormatUint(n, 10)
+	}
+	properties := map[string]string{}
+	if quota.Bytes != nil {
+		properties[kind+"quota@"+principal] = value(*quota.Bytes)
+	}
+	if quota.Objects != nil {
+		properties[kind+"objquota@"+principal] = value(*quota.Objects)
+	}
+	if len(properties) == 0 {
+		return nil
+	}
+	_, err := z.RawZFS().Set(ctx, name, properties).Exec()
+	return err
+}
+
+// ParseSpaceUsage parses the output of 'zfs userspace|groupspace|projectspace -Hp -o <fields>'
+func ParseSpaceUsage(data string, fields []string) ([]*SpaceUsage, error) {
+	usages := make([]*SpaceUsage, 0)
+	for _, line := range strings.Split(data, "\n") {
+		if len(strings.TrimSpace(line)) == 0 {
+			continue
+		}
+		values := strings.Split(line, "\t")
+		if len(values) != len(fields) {
+			return nil, fmt.Errorf("invalid line of space usage: %q", line)
+		}
+		usage := &SpaceUsage{}
+		for i, field := range fields {
+			var err error
+			switch field {
+			case "type":
+				usage.Type = values[i]
+			case "name":
+				usage.Principal = values[i]
+			case "used":
+				usage.Used, err = parseSize(values[i])
+			case "quota":
+				usage.Quota, err = parseSize(values[i])
+			case "objused":
+				usage.ObjectUsed, err = parseSize(values[i])
+			case "objquota":
+				usage.ObjectQuota, err = parseSize(values[i])
+			}
+			if err != nil {
+				return nil, fmt.Errorf("invalid %s of space usage: %q", field, line)
+			}
+		}
+		usages = append(usages, usage)
+	}
+	return usages, nil
+}
@@ -0,0 +1,94 @@
+// MIT License
+//
+// Copyright (c) 2021 Lack
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+// SOFTWARE.
+
+package zfs
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"testing"
+)
+
+func TestZFSadm_GetUserSpace(t *testing.T) {
+	runner := NewFakeRunner().
+		On("zfs userspace -Hp -o type,name,used,quota,objused,objquota tank/home",
+			"POSIX User\talice\t1048576\t10737418240\t12\tnone\nPOSIX User\troot\t512\tnone\t3\t1000\n", nil).
+		On("zfs projectspace -Hp -o name,used,quota,objused,objquota tank/home", "100\t4096\t-\t1\t-\n", nil)
+	z := New(WithRunner(runner))
+
+	usages, err := z.GetUserSpace(context.TODO(), "tank/home")
+	if err != nil {
+		t.Fatal(err)
+	}
+	expect := []*SpaceUsage{
+		{Type: "POSIX User", Principal: "alice", Used: 1 << 20, Quota: 10 << 30, ObjectUsed: 12},
+		{Type: "POSIX User", Principal: "root", Used: 512, ObjectUsed: 3, ObjectQuota: 1000},
+	}
+	if !reflect.DeepEqual(usages, expect) {
+		t.Fatalf("expect %+v, got %+v", expect, usages)
+	}
+
+	usages, err = z.GetProjectSpace(context.TODO(), "tank/home")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(usages) != 1 || *usages[0] != (SpaceUsage{Type: "Project", Principal: "100", Used: 4096, ObjectUsed: 1}) {
+		t.Fatalf("unexpected project space: %+v", usages)
+	}
+
+	if _, err = ParseSpaceUsage("POSIX User\talice\t1M", spaceFields); err == nil {
+		t.Fatal("expect error of invalid line")
+	}
+}
+
+func TestZFSadm_SetUserQuota(t *testing.T) {
+	runner := NewFakeRunner().
+		On("zfs set userquota@alice=1073741824 tank/home", "", nil).
+		On("zfs set userobjquota@joe.smith@mydomain=none userquota@joe.smith@mydomain=none tank/home", "", nil).
+		On("zfs set projectobjquota@100=5000 tank/home", "", nil)
+	z := New(WithRunner(runner))
+
+	// the object limit is left unchanged
+	if err := z.SetUserQuota(context.TODO(), "tank/home", "alice", SpaceQuota{Bytes: QuotaLimit(1 << 30)}); err != nil {
+		t.Fatal(err)
+	}
+	quota := SpaceQuota{Bytes: QuotaLimit(0), Objects: QuotaLimit(0)}
+	if err := z.SetUserQuota(context.TODO(), "tank/home", "joe.smith@mydomain", quota); err != nil {
+		t.Fatal(err)
+	}
+	if err := z.SetProjectQuota(context.TODO(), "tank/home", 100, SpaceQuota{Objects: QuotaLimit(5000)}); err != nil {
+		t.Fatal(err)
+	}
+	if err := z.SetUserQuota(context.TODO(), "tank/home", "alice", SpaceQuota{}); err != nil {
+		t.Fatal(err)
+	}
+	if history := runner.History(); len(history) != 3 {
+		t.Fatalf("unexpected commands: %v", history)
+	}
+	if err := z.SetGroupQuota(context.TODO(), "tank/home", "", SpaceQuota{}); !errors.Is(err, ErrInvalidName) {
+		t.Fatalf("expect ErrInvalidName, got %v", err)
+	}
+	if err := z.SetGroupQuota(context.TODO(), "tank/home", "staff=1", SpaceQuota{}); !errors.Is(err, ErrInvalidName) {
+		t.Fatalf("expect ErrInvalidName, got %v", err)
+	}
+}